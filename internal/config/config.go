@@ -5,6 +5,8 @@ type AppConfig struct {
 	Port int    `mapstructure:"port" default:"8888"`
 	Mode string `mapstructure:"mode" default:"dootask"` // debug or release
 	Base string `mapstructure:"base" default:"/apps/cs"`
+	// 独立模式下默认管理员密码，为空时启动时随机生成
+	AdminPassword string `mapstructure:"admin_password" default:""`
 }
 
 type DBConfig struct {
@@ -22,8 +24,21 @@ type DBConfig struct {
 }
 
 type RedisConfig struct {
-	Host string `mapstructure:"host" default:"127.0.0.1"`
-	Port int    `mapstructure:"port" default:"6379"`
+	Host     string `mapstructure:"host" default:"127.0.0.1"`
+	Port     int    `mapstructure:"port" default:"6379"`
+	Password string `mapstructure:"password" default:""`
+	DB       int    `mapstructure:"db" default:"0"`
+}
+
+type WebSocketConfig struct {
+	// 跨节点推送通道：memory（单节点）或 redis（多副本部署）
+	Backplane string `mapstructure:"backplane" default:"memory"`
+	// Redis频道与键名前缀，多套部署共用Redis时需区分
+	Prefix string `mapstructure:"prefix" default:"cs:ws"`
+	// 节点标识，为空时按主机名与进程号生成
+	NodeID string `mapstructure:"node_id" default:""`
+	// 在线状态有效期（秒），节点异常退出后超时清除
+	PresenceTTL int `mapstructure:"presence_ttl" default:"30"`
 }
 
 type DooTaskConfig struct {
@@ -31,6 +46,48 @@ type DooTaskConfig struct {
 	Token   string `mapstructure:"token" default:""`
 	Version string `mapstructure:"version" default:"1.0.0"`
 	WebHook string `mapstructure:"webhook" default:"http://nginx/api/dialog/msg/sendtext"`
+
+	// 用户信息缓存（秒）
+	UserCacheTTL      int `mapstructure:"user_cache_ttl" default:"60"`      // 有效令牌缓存时间
+	UserCacheNegative int `mapstructure:"user_cache_negative" default:"10"` // 无效令牌缓存时间
+	UserCacheGrace    int `mapstructure:"user_cache_grace" default:"600"`   // DooTask不可用时沿用上次结果的最长时间
+
+	// 轮询关联任务完成状态的间隔（秒），0表示不轮询
+	TaskSyncInterval int `mapstructure:"task_sync_interval" default:"60"`
+	// 只轮询最近活跃（天）的对话，更早的对话由关闭或重新打开时同步
+	TaskSyncActiveDays int `mapstructure:"task_sync_active_days" default:"7"`
+	// 同时查询任务状态的最大请求数
+	TaskSyncConcurrency int `mapstructure:"task_sync_concurrency" default:"4"`
+}
+
+type SearchConfig struct {
+	// 全文检索方式：auto（SQLite 使用 FTS5，MySQL 使用 FULLTEXT，不可用时退回 LIKE）或 like
+	Backend string `mapstructure:"backend" default:"auto"`
+}
+
+type SLAConfig struct {
+	// 检查 SLA 到期的间隔（秒），0表示不检查
+	CheckInterval int `mapstructure:"check_interval" default:"60"`
+}
+
+type StorageConfig struct {
+	Type      string `mapstructure:"type" default:"local"` // local or s3
+	MaxSizeMB int    `mapstructure:"max_size_mb" default:"20"`
+	// 允许上传的MIME类型，逗号分隔，支持 image/* 形式的通配
+	AllowedTypes string `mapstructure:"allowed_types" default:"image/*,application/pdf,text/plain,application/zip,application/msword,application/vnd.openxmlformats-officedocument.*,application/vnd.ms-excel"`
+	// 附件对外访问地址前缀，为空时按请求域名拼接
+	PublicURL string `mapstructure:"public_url" default:""`
+
+	// 本地存储配置
+	Dir string `mapstructure:"dir" default:"uploads"`
+
+	// S3兼容存储配置
+	Endpoint  string `mapstructure:"endpoint" default:""`
+	Region    string `mapstructure:"region" default:""`
+	Bucket    string `mapstructure:"bucket" default:""`
+	AccessKey string `mapstructure:"access_key" default:""`
+	SecretKey string `mapstructure:"secret_key" default:""`
+	UseSSL    bool   `mapstructure:"use_ssl" default:"true"`
 }
 
 type LoggerConfig struct {
@@ -38,9 +95,13 @@ type LoggerConfig struct {
 }
 
 type Config struct {
-	App     AppConfig     `mapstructure:"app"`
-	DB      DBConfig      `mapstructure:"db"`
-	Redis   RedisConfig   `mapstructure:"redis"`
-	DooTask DooTaskConfig `mapstructure:"dootask"`
-	Log     LoggerConfig  `mapstructure:"log"`
+	App       AppConfig       `mapstructure:"app"`
+	DB        DBConfig        `mapstructure:"db"`
+	Redis     RedisConfig     `mapstructure:"redis"`
+	DooTask   DooTaskConfig   `mapstructure:"dootask"`
+	Log       LoggerConfig    `mapstructure:"log"`
+	Storage   StorageConfig   `mapstructure:"storage"`
+	WebSocket WebSocketConfig `mapstructure:"websocket"`
+	Search    SearchConfig    `mapstructure:"search"`
+	SLA       SLAConfig       `mapstructure:"sla"`
 }
@@ -5,8 +5,10 @@ import (
 
 	"support-plugin/internal/config"
 	"support-plugin/internal/i18n"
+	"support-plugin/internal/middleware"
 	"support-plugin/internal/models"
 	"support-plugin/internal/pkg/database"
+	bizErrors "support-plugin/internal/pkg/errors"
 	"support-plugin/internal/pkg/response"
 	"support-plugin/internal/service"
 
@@ -149,6 +151,10 @@ func (a *AgentHeadler) Verify(c *gin.Context) {
 		})
 		return
 	}
+	if config.Cfg.App.Mode != "dootask" {
+		a.verifyStandalone(c)
+		return
+	}
 	// 获取当前用户信息
 	dootaskUserID, exists := c.Get("dootask_user_id")
 	if !exists {
@@ -191,3 +197,117 @@ func (a *AgentHeadler) Verify(c *gin.Context) {
 		}(),
 	})
 }
+
+// verifyStandalone 独立模式下根据登录令牌验证客服身份
+func (a *AgentHeadler) verifyStandalone(c *gin.Context) {
+	agentID, exists := middleware.GetCurrentAgentID(c)
+	if !exists {
+		response.UnauthorizedWithCode(c, i18n.ErrCodeUnauthenticated)
+		return
+	}
+
+	var agent models.Agent
+	if err := database.GetDB().Where("id = ? AND status = ?", agentID, "active").First(&agent).Error; err != nil {
+		response.ForbiddenWithCode(c, i18n.ErrCodePermissionDenied)
+		return
+	}
+
+	response.Success(c, "验证成功", map[string]interface{}{
+		"is_admin":   agent.IsAdmin,
+		"is_agent":   true,
+		"user_id":    agent.ID,
+		"agent_info": agent,
+	})
+}
+
+// @Summary 获取客服在线状态
+// @Description 获取所有启用客服的在线状态及当前接待的对话数
+// @Accept json
+// @Produce json
+// @Success 200 {object} models.Response{data=[]models.AgentPresence}
+// @Failure 401 {object} models.Response
+// @Failure 500 {object} models.Response
+// @Router /agents/presence [get]
+func (a *AgentHeadler) Presence(c *gin.Context) {
+	presences, err := service.Presence.List()
+	if err != nil {
+		response.ServerError(c, "获取客服在线状态失败", err)
+		return
+	}
+	response.Success(c, "获取客服在线状态成功", presences)
+}
+
+// @Summary 设置在线状态
+// @Description 当前客服手动设置在线状态，离开、忙碌和隐身时不分配新对话
+// @Accept json
+// @Produce json
+// @Param request body models.SetPresenceStatusRequest true "在线状态"
+// @Success 200 {object} models.Response{data=models.AgentPresence}
+// @Failure 400 {object} models.Response
+// @Failure 401 {object} models.Response
+// @Failure 500 {object} models.Response
+// @Router /agents/status [put]
+func (a *AgentHeadler) SetStatus(c *gin.Context) {
+	var req models.SetPresenceStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequestWithCode(c, i18n.ErrCodeInvalidParams)
+		return
+	}
+
+	authID, exists := middleware.GetCurrentAgentID(c)
+	if !exists {
+		response.UnauthorizedWithCode(c, i18n.ErrCodeUnauthenticated)
+		return
+	}
+	agent, err := service.Agent.FindByAuthID(authID)
+	if err != nil {
+		response.ForbiddenWithCode(c, i18n.ErrCodePermissionDenied)
+		return
+	}
+
+	presence, err := service.Presence.SetStatus(agent, req.Status)
+	if err != nil {
+		if bizErr, ok := bizErrors.IsBusinessError(err); ok {
+			response.BadRequest(c, bizErr.Message, bizErr)
+			return
+		}
+		response.ServerError(c, "设置在线状态失败", err)
+		return
+	}
+	response.Success(c, "设置在线状态成功", presence)
+}
+
+// @Summary 设置最大同时接待对话数
+// @Description 设置客服最大同时接待的对话数，达到上限后不再自动分配新对话，0表示不限制
+// @Accept json
+// @Produce json
+// @Param id path int true "客服ID"
+// @Param request body models.SetMaxChatsRequest true "最大同时接待对话数"
+// @Success 200 {object} models.Response{data=models.Agent}
+// @Failure 400 {object} models.Response
+// @Failure 401 {object} models.Response
+// @Failure 500 {object} models.Response
+// @Router /agents/{id}/max-chats [put]
+func (a *AgentHeadler) SetMaxChats(c *gin.Context) {
+	agentID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequestWithCode(c, i18n.ErrCodeInvalidParams)
+		return
+	}
+	var req models.SetMaxChatsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequestWithCode(c, i18n.ErrCodeInvalidParams)
+		return
+	}
+
+	agent, err := service.Presence.SetMaxChats(uint(agentID), *req.MaxConcurrentChats)
+	if err != nil {
+		if i18nErr, ok := err.(*i18n.ErrorInfo); ok {
+			response.BadRequestWithCode(c, i18nErr.Code)
+			return
+		}
+		response.ServerError(c, "设置最大接待数失败", err)
+		return
+	}
+	response.Success(c, "设置最大接待数成功", agent)
+}
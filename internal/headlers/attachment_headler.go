@@ -0,0 +1,183 @@
+package headlers
+
+import (
+	"errors"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"support-plugin/internal/config"
+	"support-plugin/internal/i18n"
+	"support-plugin/internal/middleware"
+	"support-plugin/internal/pkg/response"
+	"support-plugin/internal/service"
+	"support-plugin/internal/utils/common"
+)
+
+type AttachmentHeadler struct {
+}
+
+var Attachment = AttachmentHeadler{}
+
+// @Summary 客户上传附件
+// @Description 上传文件并作为附件消息发送到对话中，图片会生成缩略图
+// @Accept multipart/form-data
+// @Produce json
+// @Param X-Conversation-Token header string true "对话访问令牌，也可通过conv_token参数传递"
+// @Param uuid formData string true "对话UUID"
+// @Param file formData file true "附件文件"
+// @Param content formData string false "附言"
+// @Success 200 {object} models.Response
+// @Failure 400 {object} models.Response
+// @Failure 401 {object} models.Response
+// @Failure 500 {object} models.Response
+// @Router /chat/attachments [post]
+func (h AttachmentHeadler) UploadByCustomer(c *gin.Context) {
+	fileHeader, ok := h.formFile(c)
+	if !ok {
+		return
+	}
+
+	uuid := c.PostForm("uuid")
+	if !ChatPublic.authorize(c, uuid) {
+		return
+	}
+
+	conversation, err := service.ChatPublic.GetConversation(uuid)
+	if err != nil {
+		response.BadRequestWithCode(c, i18n.ErrCodeConversationNotFound)
+		return
+	}
+
+	message, err := service.Attachment.Upload(conversation, "customer", conversation.CustomerID, fileHeader, c.PostForm("content"), h.baseURL(c))
+	if err != nil {
+		response.ServiceError(c, "上传附件失败", err)
+		return
+	}
+
+	response.SuccessWithCode(c, map[string]interface{}{
+		"id":         message.ID,
+		"content":    message.Content,
+		"sender":     message.Sender,
+		"type":       message.Type,
+		"metadata":   message.Metadata,
+		"created_at": message.CreatedAt,
+	})
+}
+
+// @Summary 客服上传附件
+// @Description 客服上传文件并作为附件消息发送到对话中
+// @Accept multipart/form-data
+// @Produce json
+// @Param conversation_id formData int true "对话ID"
+// @Param file formData file true "附件文件"
+// @Param content formData string false "附言"
+// @Success 200 {object} models.Response{data=models.Message}
+// @Failure 400 {object} models.Response
+// @Failure 500 {object} models.Response
+// @Router /chat/agent/attachments [post]
+func (h AttachmentHeadler) UploadByAgent(c *gin.Context) {
+	fileHeader, ok := h.formFile(c)
+	if !ok {
+		return
+	}
+
+	conversationID, err := strconv.Atoi(c.PostForm("conversation_id"))
+	if err != nil {
+		response.BadRequestWithCode(c, i18n.ErrCodeInvalidParams)
+		return
+	}
+
+	agentID, exists := middleware.GetCurrentAgentID(c)
+	if !exists {
+		response.UnauthorizedWithCode(c, i18n.ErrCodeUnauthenticated)
+		return
+	}
+
+	conversation, err := service.ChatAgent.GetConversationByID(uint(conversationID))
+	if err != nil {
+		response.BadRequestWithCode(c, i18n.ErrCodeConversationNotFound)
+		return
+	}
+
+	message, err := service.Attachment.Upload(conversation, "agent", agentID, fileHeader, c.PostForm("content"), h.baseURL(c))
+	if err != nil {
+		response.ServiceError(c, "上传附件失败", err)
+		return
+	}
+
+	response.Success(c, "发送附件成功", message)
+}
+
+// @Summary 下载附件
+// @Description 根据附件访问标识下载附件，图片直接展示，其他文件以下载方式返回
+// @Produce octet-stream
+// @Param key path string true "附件访问标识"
+// @Success 200 {file} file
+// @Failure 404 {object} models.Response
+// @Router /chat/attachments/{key} [get]
+func (h AttachmentHeadler) Download(c *gin.Context) {
+	h.serve(c, false)
+}
+
+// @Summary 获取图片缩略图
+// @Description 根据附件访问标识获取图片附件的缩略图
+// @Produce image/jpeg,image/png
+// @Param key path string true "附件访问标识"
+// @Success 200 {file} file
+// @Failure 404 {object} models.Response
+// @Router /chat/attachments/{key}/thumbnail [get]
+func (h AttachmentHeadler) Thumbnail(c *gin.Context) {
+	h.serve(c, true)
+}
+
+// serve 输出附件内容
+func (h AttachmentHeadler) serve(c *gin.Context, thumbnail bool) {
+	attachment, reader, mimeType, size, err := service.Attachment.Open(c.Param("key"), thumbnail)
+	if err != nil {
+		if i18nErr, ok := err.(*i18n.ErrorInfo); ok {
+			response.NotFoundWithCode(c, i18nErr.Code)
+			return
+		}
+		response.ServerError(c, "读取附件失败", err)
+		return
+	}
+	defer reader.Close()
+
+	// 仅图片允许在浏览器中直接打开，其余文件一律作为下载处理
+	disposition := "attachment"
+	if strings.HasPrefix(mimeType, "image/") {
+		disposition = "inline"
+	}
+	c.DataFromReader(http.StatusOK, size, mimeType, reader, map[string]string{
+		"Content-Disposition":     mime.FormatMediaType(disposition, map[string]string{"filename": attachment.Name}),
+		"Content-Security-Policy": "default-src 'none'; sandbox",
+		"X-Content-Type-Options":  "nosniff",
+		"Cache-Control":           "private, max-age=86400",
+	})
+}
+
+// formFile 读取上传的文件，请求体超过附件大小上限时直接拒绝
+func (h AttachmentHeadler) formFile(c *gin.Context) (*multipart.FileHeader, bool) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, service.Attachment.MaxSize()+1<<20)
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			response.BadRequestWithCode(c, i18n.ErrCodeFileSizeExceeded)
+		} else {
+			response.BadRequestWithCode(c, i18n.ErrCodeInvalidParams)
+		}
+		return nil, false
+	}
+	return fileHeader, true
+}
+
+// baseURL 当前请求对应的服务访问地址
+func (h AttachmentHeadler) baseURL(c *gin.Context) string {
+	return common.GetCurrentDomain(c) + config.Cfg.App.Base
+}
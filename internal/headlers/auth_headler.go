@@ -10,6 +10,7 @@ import (
 	"support-plugin/internal/models"
 	"support-plugin/internal/pkg/database"
 	"support-plugin/internal/pkg/response"
+	"support-plugin/internal/service"
 )
 
 type AuthHeadler struct{}
@@ -34,22 +35,72 @@ func (h AuthHeadler) Login(c *gin.Context) {
 		return
 	}
 
-	// 查找客服
-	var agent models.Agent
-	result := database.DB.Where("username = ?", req.Username).First(&agent)
-	if result.Error != nil {
-		response.UnauthorizedWithCode(c, i18n.ErrCodeInvalidCredentials)
+	// 校验账号密码并签发令牌
+	loginResp, err := service.Auth.Login(req.Username, req.Password, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		if i18nErr, ok := err.(*i18n.ErrorInfo); ok {
+			response.UnauthorizedWithCode(c, i18nErr.Code)
+		} else {
+			response.ServerError(c, "登录失败", err)
+		}
+		return
+	}
+
+	response.Success(c, "登录成功", loginResp)
+}
+
+// @Summary 刷新令牌
+// @Description 使用刷新令牌换取新的访问令牌，原刷新令牌随即失效
+// @Accept json
+// @Produce json
+// @Param request body models.AgentRefreshRequest true "刷新令牌请求参数"
+// @Success 200 {object} models.Response{data=models.AgentLoginResponse}
+// @Failure 400 {object} models.Response
+// @Failure 401 {object} models.Response
+// @Failure 500 {object} models.Response
+// @Router /auth/refresh [post]
+func (h AuthHeadler) Refresh(c *gin.Context) {
+	var req models.AgentRefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequestWithCode(c, i18n.ErrCodeInvalidParams)
+		return
+	}
+
+	loginResp, err := service.Auth.Refresh(req.RefreshToken)
+	if err != nil {
+		if i18nErr, ok := err.(*i18n.ErrorInfo); ok {
+			response.UnauthorizedWithCode(c, i18nErr.Code)
+		} else {
+			response.ServerError(c, "刷新令牌失败", err)
+		}
 		return
 	}
 
-	// 检查客服状态
-	if agent.Status != "active" {
-		response.UnauthorizedWithCode(c, i18n.ErrCodeAccountDisabled)
+	response.Success(c, "刷新成功", loginResp)
+}
+
+// @Summary 退出登录
+// @Description 注销当前登录会话，访问令牌和刷新令牌立即失效
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer {token}"
+// @Success 200 {object} models.Response
+// @Failure 401 {object} models.Response
+// @Failure 500 {object} models.Response
+// @Router /auth/logout [post]
+func (h AuthHeadler) Logout(c *gin.Context) {
+	sessionID := c.GetString("session_id")
+	if sessionID == "" {
+		response.UnauthorizedWithCode(c, i18n.ErrCodeUnauthenticated)
 		return
 	}
 
-	// 更新最后登录时间
-	database.DB.Save(&agent)
+	if err := service.Auth.Logout(sessionID); err != nil {
+		response.ServerError(c, "退出登录失败", err)
+		return
+	}
+
+	response.Success(c, "已退出登录", nil)
 }
 
 // @Summary 获取当前客服信息
@@ -83,6 +134,7 @@ func (h AuthHeadler) GetCurrentAgent(c *gin.Context) {
 		Name:     agent.Name,
 		Avatar:   agent.Avatar,
 		Status:   agent.Status,
+		IsAdmin:  agent.IsAdmin,
 	})
 }
 
@@ -90,7 +142,7 @@ func (h AuthHeadler) GetCurrentAgent(c *gin.Context) {
 // @Description 创建新的客服账号
 // @Accept json
 // @Produce json
-// @Param request body models.Agent true "客服信息"
+// @Param request body models.CreateAgentRequest true "客服信息"
 // @Success 200 {object} models.Response{data=models.AgentInfoResponse}
 // @Failure 400 {object} models.Response
 // @Failure 401 {object} models.Response
@@ -98,24 +150,36 @@ func (h AuthHeadler) GetCurrentAgent(c *gin.Context) {
 // @Router /auth/agents [post]
 func (h AuthHeadler) CreateAgent(c *gin.Context) {
 	// 解析请求参数
-	var agent models.Agent
-	if err := c.ShouldBindJSON(&agent); err != nil {
+	var req models.CreateAgentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
 		response.BadRequestWithCode(c, i18n.ErrCodeInvalidParams)
 		return
 	}
 
 	// 检查用户名是否已存在
 	var count int64
-	database.DB.Model(&models.Agent{}).Where("username = ?", agent.Username).Count(&count)
+	database.DB.Model(&models.Agent{}).Where("username = ?", req.Username).Count(&count)
 	if count > 0 {
 		response.BadRequestWithCode(c, i18n.ErrCodeUsernameExists)
 		return
 	}
 
-	// 设置默认值
-	agent.Status = "active"
-	agent.CreatedAt = time.Now()
-	agent.UpdatedAt = time.Now()
+	passwordHash, err := service.Auth.HashPassword(req.Password)
+	if err != nil {
+		response.ServerError(c, "创建客服失败", err)
+		return
+	}
+
+	agent := models.Agent{
+		Username:     req.Username,
+		Name:         req.Name,
+		Avatar:       req.Avatar,
+		PasswordHash: passwordHash,
+		IsAdmin:      req.IsAdmin,
+		Status:       "active",
+		CreatedAt:    time.Now(),
+		UpdatedAt:    time.Now(),
+	}
 
 	// 保存到数据库
 	result := database.DB.Create(&agent)
@@ -131,5 +195,6 @@ func (h AuthHeadler) CreateAgent(c *gin.Context) {
 		Name:     agent.Name,
 		Avatar:   agent.Avatar,
 		Status:   agent.Status,
+		IsAdmin:  agent.IsAdmin,
 	})
 }
@@ -0,0 +1,150 @@
+package headlers
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"support-plugin/internal/i18n"
+	"support-plugin/internal/middleware"
+	"support-plugin/internal/models"
+	"support-plugin/internal/pkg/response"
+	"support-plugin/internal/service"
+)
+
+type CannedResponseHeadler struct{}
+
+var CannedResponse = CannedResponseHeadler{}
+
+// @Summary 获取快捷回复列表
+// @Description 获取快捷回复，指定来源时返回全局及该来源的快捷回复
+// @Accept json
+// @Produce json
+// @Param source_key query string false "来源标识"
+// @Param keyword query string false "按快捷短语、标题或内容搜索"
+// @Success 200 {object} models.Response{data=[]models.CannedResponse}
+// @Failure 400 {object} models.Response
+// @Failure 500 {object} models.Response
+// @Router /canned-responses [get]
+func (h CannedResponseHeadler) List(c *gin.Context) {
+	var query models.CannedResponseQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		response.BadRequestWithCode(c, i18n.ErrCodeInvalidParams)
+		return
+	}
+
+	responses, err := service.CannedResponse.List(query)
+	if err != nil {
+		response.ServerError(c, "获取快捷回复失败", err)
+		return
+	}
+	response.Success(c, "获取快捷回复成功", responses)
+}
+
+// @Summary 获取快捷回复
+// @Description 根据ID获取快捷回复详情
+// @Accept json
+// @Produce json
+// @Param id path int true "快捷回复ID"
+// @Success 200 {object} models.Response{data=models.CannedResponse}
+// @Failure 400 {object} models.Response
+// @Router /canned-responses/{id} [get]
+func (h CannedResponseHeadler) Get(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.BadRequestWithCode(c, i18n.ErrCodeInvalidParams)
+		return
+	}
+
+	cannedResponse, err := service.CannedResponse.Get(uint(id))
+	if err != nil {
+		response.ServiceError(c, "获取快捷回复失败", err)
+		return
+	}
+	response.Success(c, "获取快捷回复成功", cannedResponse)
+}
+
+// @Summary 创建快捷回复
+// @Description 创建快捷回复，来源标识为空时全局可用
+// @Accept json
+// @Produce json
+// @Param request body models.CannedResponseRequest true "快捷回复"
+// @Success 200 {object} models.Response{data=models.CannedResponse}
+// @Failure 400 {object} models.Response
+// @Failure 500 {object} models.Response
+// @Router /canned-responses [post]
+func (h CannedResponseHeadler) Create(c *gin.Context) {
+	var req models.CannedResponseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequestWithCode(c, i18n.ErrCodeInvalidParams)
+		return
+	}
+
+	// 记录创建人，未登记为客服的管理员记为0
+	var createdBy uint
+	if authID, exists := middleware.GetCurrentAgentID(c); exists {
+		if agent, err := service.Agent.FindByAuthID(authID); err == nil {
+			createdBy = agent.ID
+		}
+	}
+
+	cannedResponse, err := service.CannedResponse.Create(req, createdBy)
+	if err != nil {
+		response.ServiceError(c, "创建快捷回复失败", err)
+		return
+	}
+	response.Success(c, "创建快捷回复成功", cannedResponse)
+}
+
+// @Summary 更新快捷回复
+// @Description 更新快捷回复的快捷短语、适用来源、标题与内容
+// @Accept json
+// @Produce json
+// @Param id path int true "快捷回复ID"
+// @Param request body models.CannedResponseRequest true "快捷回复"
+// @Success 200 {object} models.Response{data=models.CannedResponse}
+// @Failure 400 {object} models.Response
+// @Failure 500 {object} models.Response
+// @Router /canned-responses/{id} [put]
+func (h CannedResponseHeadler) Update(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.BadRequestWithCode(c, i18n.ErrCodeInvalidParams)
+		return
+	}
+	var req models.CannedResponseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequestWithCode(c, i18n.ErrCodeInvalidParams)
+		return
+	}
+
+	cannedResponse, err := service.CannedResponse.Update(uint(id), req)
+	if err != nil {
+		response.ServiceError(c, "更新快捷回复失败", err)
+		return
+	}
+	response.Success(c, "更新快捷回复成功", cannedResponse)
+}
+
+// @Summary 删除快捷回复
+// @Description 删除快捷回复
+// @Accept json
+// @Produce json
+// @Param id path int true "快捷回复ID"
+// @Success 200 {object} models.Response
+// @Failure 400 {object} models.Response
+// @Failure 500 {object} models.Response
+// @Router /canned-responses/{id} [delete]
+func (h CannedResponseHeadler) Delete(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.BadRequestWithCode(c, i18n.ErrCodeInvalidParams)
+		return
+	}
+
+	if err := service.CannedResponse.Delete(uint(id)); err != nil {
+		response.ServiceError(c, "删除快捷回复失败", err)
+		return
+	}
+	response.Success(c, "删除快捷回复成功", nil)
+}
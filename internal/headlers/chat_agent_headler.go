@@ -2,6 +2,7 @@ package headlers
 
 import (
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 
@@ -19,7 +20,7 @@ type ChatAgentHeadler struct {
 var ChatAgent = ChatAgentHeadler{}
 
 // @Summary 发送消息
-// @Description 在指定对话中发送新消息
+// @Description 在指定对话中发送新消息，internal 为 true 时添加仅客服可见的内部备注，指定 canned_id 时发送展开后的快捷回复
 // @Accept json
 // @Produce json
 // @Param request body models.SendMessageByAgentRequest true "发送消息请求参数"
@@ -36,8 +37,43 @@ func (h ChatAgentHeadler) SendMessageByAgent(c *gin.Context) {
 		return
 	}
 
+	// 使用快捷回复时以展开后的内容替换消息内容
+	if req.CannedID > 0 {
+		conversation, err := service.ChatAgent.GetConversationByID(uint(req.ID))
+		if err != nil {
+			response.BadRequestWithCode(c, i18n.ErrCodeConversationNotFound)
+			return
+		}
+		content, err := service.CannedResponse.Expand(conversation, req.CannedID)
+		if err != nil {
+			response.ServiceError(c, "展开快捷回复失败", err)
+			return
+		}
+		req.Content = content
+	}
+	if strings.TrimSpace(req.Content) == "" {
+		response.BadRequestWithCode(c, i18n.ErrCodeInvalidParams)
+		return
+	}
+
+	operator, ok := h.operator(c)
+	if !ok {
+		return
+	}
+
+	// 内部备注不发送给客户
+	if req.Internal {
+		message, err := service.ChatAgent.SendNote(uint(req.ID), req.Content, req.Metadata, operator)
+		if err != nil {
+			response.ServerError(c, "添加内部备注失败", err)
+			return
+		}
+		response.Success(c, "添加内部备注成功", message)
+		return
+	}
+
 	// 发送消息
-	message, err := service.ChatAgent.SendMessageByAgent(uint(req.ID), req.Content, "text", req.Metadata)
+	message, err := service.ChatAgent.SendMessageByAgent(uint(req.ID), req.Content, "text", req.Metadata, operator)
 	if err != nil {
 		response.ServerError(c, "发送消息失败", err)
 		return
@@ -55,6 +91,7 @@ func (h ChatAgentHeadler) SendMessageByAgent(c *gin.Context) {
 // @Param page_size query int false "每页数量,默认20"
 // @Param status query string false "状态筛选(open/closed)"
 // @Param keyword query string false "关键词搜索"
+// @Param tag_ids query string false "标签ID，多个以逗号分隔，返回带有全部这些标签的对话"
 // @Success 200 {object} models.Response{data=models.PaginationData}
 // @Failure 401 {object} models.Response
 // @Failure 500 {object} models.Response
@@ -74,8 +111,26 @@ func (h ChatAgentHeadler) GetAgentConversations(c *gin.Context) {
 	status := c.Query("status")
 	keyword := c.Query("keyword")
 
+	// 获取标签筛选参数
+	var tagIDs []uint
+	seen := map[uint]bool{}
+	for _, value := range strings.Split(c.Query("tag_ids"), ",") {
+		if value = strings.TrimSpace(value); value == "" {
+			continue
+		}
+		tagID, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			response.BadRequestWithCode(c, i18n.ErrCodeInvalidParams)
+			return
+		}
+		if !seen[uint(tagID)] {
+			seen[uint(tagID)] = true
+			tagIDs = append(tagIDs, uint(tagID))
+		}
+	}
+
 	// 获取对话列表
-	conversations, total, err := service.ChatAgent.GetAgentConversations(agentID, page, pageSize, status, keyword)
+	conversations, total, err := service.ChatAgent.GetAgentConversations(agentID, page, pageSize, status, keyword, tagIDs)
 	if err != nil {
 		response.ServerError(c, "获取对话列表失败", err)
 		return
@@ -112,13 +167,14 @@ func (h ChatAgentHeadler) GetConversationByUUID(c *gin.Context) {
 }
 
 // @Summary 获取对话消息列表
-// @Description 获取指定对话的消息历史记录
+// @Description 获取指定对话的消息历史记录，默认返回最新消息，通过 before_id 向前翻页，通过 after_id 同步新消息
 // @Accept json
 // @Produce json
 // @Param id path string true "对话ID"
-// @Param page query int false "页码,默认1"
-// @Param page_size query int false "每页数量,默认20"
-// @Success 200 {object} models.Response{data=models.PaginationData}
+// @Param before_id query int false "返回该消息之前的消息"
+// @Param after_id query int false "返回该消息之后的消息（同步模式）"
+// @Param limit query int false "返回数量,默认20,最大100"
+// @Success 200 {object} models.Response{data=models.CursorData}
 // @Failure 400 {object} models.Response
 // @Failure 500 {object} models.Response
 // @Router /chat/agent/{id}/messages [get]
@@ -132,17 +188,30 @@ func (h ChatAgentHeadler) GetMessageListByConversationID(c *gin.Context) {
 		return
 	}
 
-	// 获取分页参数
-	page, pageSize := getPaginationParams(c)
+	// 获取游标参数
+	query, ok := getHistoryParams(c)
+	if !ok {
+		response.BadRequestWithCode(c, i18n.ErrCodeInvalidParams)
+		return
+	}
 
 	// 获取消息列表
-	messages, total, err := service.ChatAgent.GetMessageListByConversationID(id, page, pageSize)
+	messages, hasMore, err := service.ChatAgent.GetMessageListByConversationID(id, query)
 	if err != nil {
+		if i18nErr, ok := err.(*i18n.ErrorInfo); ok {
+			response.BadRequestWithCode(c, i18nErr.Code)
+			return
+		}
+		if bizErr, ok := bizErrors.IsBusinessError(err); ok {
+			response.BadRequest(c, bizErr.Message, bizErr)
+			return
+		}
 		response.ServerError(c, "获取消息失败", err)
 		return
 	}
 
-	response.SuccessWithPagination(c, "获取消息成功", messages, total, page, pageSize)
+	firstID, lastID := service.MessageHistory.Cursors(messages)
+	response.SuccessWithCursor(c, "获取消息成功", messages, hasMore, firstID, lastID)
 }
 
 // @Summary 关闭对话
@@ -152,6 +221,7 @@ func (h ChatAgentHeadler) GetMessageListByConversationID(c *gin.Context) {
 // @Param uuid path string true "对话UUID"
 // @Success 200 {object} models.Response
 // @Failure 400 {object} models.Response
+// @Failure 403 {object} models.Response
 // @Failure 500 {object} models.Response
 // @Router /chat/agent/conversations/{id}/close [put]
 func (h ChatAgentHeadler) CloseConversation(c *gin.Context) {
@@ -176,6 +246,9 @@ func (h ChatAgentHeadler) CloseConversation(c *gin.Context) {
 		response.UnauthorizedWithCode(c, i18n.ErrCodeUnauthenticated)
 		return
 	}
+	if _, ok := h.member(c, uint(id)); !ok {
+		return
+	}
 
 	// 关闭对话
 	err = service.ChatAgent.CloseConversation(id, agentID)
@@ -199,6 +272,7 @@ func (h ChatAgentHeadler) CloseConversation(c *gin.Context) {
 // @Param uuid path string true "对话UUID"
 // @Success 200 {object} models.Response
 // @Failure 400 {object} models.Response
+// @Failure 403 {object} models.Response
 // @Failure 500 {object} models.Response
 // @Router /chat/agent/conversations/{id}/reopen [put]
 func (h ChatAgentHeadler) ReopenConversation(c *gin.Context) {
@@ -216,6 +290,9 @@ func (h ChatAgentHeadler) ReopenConversation(c *gin.Context) {
 		response.UnauthorizedWithCode(c, i18n.ErrCodeUnauthenticated)
 		return
 	}
+	if _, ok := h.member(c, uint(id)); !ok {
+		return
+	}
 
 	// 重新打开对话
 	err = service.ChatAgent.ReopenConversation(id, agentID)
@@ -232,6 +309,393 @@ func (h ChatAgentHeadler) ReopenConversation(c *gin.Context) {
 	response.Success(c, "对话已重新打开", nil)
 }
 
+// @Summary 标记消息已读
+// @Description 将当前客服在对话中的已读位置推进到指定消息，并通知客户端
+// @Accept json
+// @Produce json
+// @Param id path int true "对话ID"
+// @Param request body models.MarkReadRequest true "已读请求参数"
+// @Success 200 {object} models.Response
+// @Failure 400 {object} models.Response
+// @Failure 403 {object} models.Response
+// @Failure 500 {object} models.Response
+// @Router /chat/agent/conversations/{id}/read [put]
+func (h ChatAgentHeadler) MarkRead(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.BadRequestWithCode(c, i18n.ErrCodeInvalidParams)
+		return
+	}
+
+	var req models.MarkReadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequestWithCode(c, i18n.ErrCodeInvalidParams)
+		return
+	}
+
+	agentID, exists := middleware.GetCurrentAgentID(c)
+	if !exists {
+		response.UnauthorizedWithCode(c, i18n.ErrCodeUnauthenticated)
+		return
+	}
+	if _, ok := h.member(c, uint(id)); !ok {
+		return
+	}
+
+	read, err := service.ChatAgent.MarkRead(id, agentID, req.MessageID)
+	if err != nil {
+		if i18nErr, ok := err.(*i18n.ErrorInfo); ok {
+			response.BadRequestWithCode(c, i18nErr.Code)
+			return
+		}
+		if bizErr, ok := bizErrors.IsBusinessError(err); ok {
+			response.BadRequest(c, bizErr.Message, bizErr)
+			return
+		}
+		response.ServerError(c, "标记已读失败", err)
+		return
+	}
+
+	response.Success(c, "标记已读成功", read)
+}
+
+// @Summary 设置对话标签
+// @Description 整体替换对话的标签，对话已创建DooTask任务时同步任务优先级与任务标签
+// @Accept json
+// @Produce json
+// @Param id path int true "对话ID"
+// @Param request body models.SetConversationTagsRequest true "标签ID列表"
+// @Success 200 {object} models.Response{data=[]models.Tag}
+// @Failure 400 {object} models.Response
+// @Failure 403 {object} models.Response
+// @Failure 500 {object} models.Response
+// @Router /chat/agent/conversations/{id}/tags [put]
+func (h ChatAgentHeadler) SetConversationTags(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.BadRequestWithCode(c, i18n.ErrCodeInvalidParams)
+		return
+	}
+	var req models.SetConversationTagsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequestWithCode(c, i18n.ErrCodeInvalidParams)
+		return
+	}
+	operator, ok := h.member(c, uint(id))
+	if !ok {
+		return
+	}
+	var operatorID uint
+	if operator != nil {
+		operatorID = operator.ID
+	}
+
+	tags, err := service.Tag.SetConversationTags(uint(id), req.TagIDs, operatorID)
+	if err != nil {
+		if bizErr, ok := bizErrors.IsBusinessError(err); ok {
+			response.BadRequest(c, bizErr.Message, bizErr)
+			return
+		}
+		response.ServerError(c, "设置对话标签失败", err)
+		return
+	}
+	response.Success(c, "设置对话标签成功", tags)
+}
+
+// @Summary 设置对话自定义字段
+// @Description 设置对话的自定义字段取值，只修改提交的字段，取值为 null 或空字符串时清除，返回对话全部字段取值
+// @Accept json
+// @Produce json
+// @Param id path int true "对话ID"
+// @Param request body models.SetConversationFieldsRequest true "字段取值"
+// @Success 200 {object} models.Response{data=map[string]interface{}}
+// @Failure 400 {object} models.Response
+// @Failure 403 {object} models.Response
+// @Failure 500 {object} models.Response
+// @Router /chat/agent/conversations/{id}/fields [put]
+func (h ChatAgentHeadler) SetConversationFields(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.BadRequestWithCode(c, i18n.ErrCodeInvalidParams)
+		return
+	}
+	var req models.SetConversationFieldsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequestWithCode(c, i18n.ErrCodeInvalidParams)
+		return
+	}
+	operator, ok := h.member(c, uint(id))
+	if !ok {
+		return
+	}
+	var operatorID uint
+	if operator != nil {
+		operatorID = operator.ID
+	}
+
+	fields, err := service.CustomField.SetValues(uint(id), req.Fields, operatorID)
+	if err != nil {
+		if bizErr, ok := bizErrors.IsBusinessError(err); ok {
+			response.BadRequest(c, bizErr.Message, bizErr)
+			return
+		}
+		response.ServerError(c, "设置对话自定义字段失败", err)
+		return
+	}
+	response.Success(c, "设置对话自定义字段成功", fields)
+}
+
+// @Summary 设置对话优先级
+// @Description 设置对话优先级（low、normal、high、urgent），按来源SLA策略中该优先级的时限重新计算到期时间
+// @Accept json
+// @Produce json
+// @Param id path int true "对话ID"
+// @Param request body models.SetPriorityRequest true "优先级"
+// @Success 200 {object} models.Response{data=models.Conversations}
+// @Failure 400 {object} models.Response
+// @Failure 403 {object} models.Response
+// @Failure 500 {object} models.Response
+// @Router /chat/agent/conversations/{id}/priority [put]
+func (h ChatAgentHeadler) SetConversationPriority(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.BadRequestWithCode(c, i18n.ErrCodeInvalidParams)
+		return
+	}
+	var req models.SetPriorityRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequestWithCode(c, i18n.ErrCodeInvalidParams)
+		return
+	}
+	if _, ok := h.member(c, uint(id)); !ok {
+		return
+	}
+
+	conversation, err := service.SLA.SetPriority(uint(id), req.Priority)
+	if err != nil {
+		if bizErr, ok := bizErrors.IsBusinessError(err); ok {
+			response.BadRequest(c, bizErr.Message, bizErr)
+			return
+		}
+		response.ServerError(c, "设置对话优先级失败", err)
+		return
+	}
+	response.Success(c, "设置对话优先级成功", conversation)
+}
+
+// @Summary 转接对话
+// @Description 将对话转接给其他客服，原负责客服离开对话，接手客服收到WebSocket通知
+// @Accept json
+// @Produce json
+// @Param id path int true "对话ID"
+// @Param request body models.TransferConversationRequest true "转接请求参数"
+// @Success 200 {object} models.Response{data=models.Conversations}
+// @Failure 400 {object} models.Response
+// @Failure 403 {object} models.Response
+// @Failure 500 {object} models.Response
+// @Router /chat/agent/conversations/{id}/transfer [put]
+func (h ChatAgentHeadler) TransferConversation(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.BadRequestWithCode(c, i18n.ErrCodeInvalidParams)
+		return
+	}
+
+	var req models.TransferConversationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequestWithCode(c, i18n.ErrCodeInvalidParams)
+		return
+	}
+
+	operator, ok := h.operator(c)
+	if !ok {
+		return
+	}
+
+	conversation, err := service.Participant.Transfer(uint(id), operator, c.GetBool("is_admin"), req.AgentID, req.Note)
+	if err != nil {
+		response.ServiceError(c, "转接对话失败", err)
+		return
+	}
+
+	response.Success(c, "对话已转接", conversation)
+}
+
+// @Summary 邀请客服加入对话
+// @Description 邀请其他客服加入对话协助处理，受邀客服收到WebSocket通知
+// @Accept json
+// @Produce json
+// @Param id path int true "对话ID"
+// @Param request body models.InviteAgentRequest true "邀请请求参数"
+// @Success 200 {object} models.Response
+// @Failure 400 {object} models.Response
+// @Failure 403 {object} models.Response
+// @Failure 500 {object} models.Response
+// @Router /chat/agent/conversations/{id}/invite [post]
+func (h ChatAgentHeadler) InviteAgent(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.BadRequestWithCode(c, i18n.ErrCodeInvalidParams)
+		return
+	}
+
+	var req models.InviteAgentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequestWithCode(c, i18n.ErrCodeInvalidParams)
+		return
+	}
+
+	operator, ok := h.operator(c)
+	if !ok {
+		return
+	}
+
+	if err := service.Participant.Invite(uint(id), operator, c.GetBool("is_admin"), req.AgentID); err != nil {
+		response.ServiceError(c, "邀请客服失败", err)
+		return
+	}
+
+	response.Success(c, "已邀请客服加入对话", nil)
+}
+
+// @Summary 离开对话
+// @Description 当前客服离开对话，负责客服需要先转接
+// @Accept json
+// @Produce json
+// @Param id path int true "对话ID"
+// @Success 200 {object} models.Response
+// @Failure 400 {object} models.Response
+// @Failure 500 {object} models.Response
+// @Router /chat/agent/conversations/{id}/leave [put]
+func (h ChatAgentHeadler) LeaveConversation(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.BadRequestWithCode(c, i18n.ErrCodeInvalidParams)
+		return
+	}
+
+	agentID, exists := middleware.GetCurrentAgentID(c)
+	if !exists {
+		response.UnauthorizedWithCode(c, i18n.ErrCodeUnauthenticated)
+		return
+	}
+	agent, err := service.Agent.FindByAuthID(agentID)
+	if err != nil {
+		response.BadRequestWithCode(c, i18n.ErrCodeAgentNotFound)
+		return
+	}
+
+	if err := service.Participant.Leave(uint(id), agent); err != nil {
+		response.ServiceError(c, "离开对话失败", err)
+		return
+	}
+
+	response.Success(c, "已离开对话", nil)
+}
+
+// @Summary 获取对话参与客服
+// @Description 获取对话中当前的负责客服和协助客服
+// @Accept json
+// @Produce json
+// @Param id path int true "对话ID"
+// @Success 200 {object} models.Response{data=[]models.ConversationParticipant}
+// @Failure 400 {object} models.Response
+// @Failure 500 {object} models.Response
+// @Router /chat/agent/{id}/participants [get]
+func (h ChatAgentHeadler) GetParticipants(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.BadRequestWithCode(c, i18n.ErrCodeInvalidParams)
+		return
+	}
+
+	participants, err := service.Participant.List(uint(id))
+	if err != nil {
+		response.ServiceError(c, "获取参与客服失败", err)
+		return
+	}
+
+	response.Success(c, "获取成功", participants)
+}
+
+// operator 获取当前操作的客服，未登记为客服的管理员以管理员身份操作
+func (h ChatAgentHeadler) operator(c *gin.Context) (*models.Agent, bool) {
+	agentID, exists := middleware.GetCurrentAgentID(c)
+	if !exists {
+		response.UnauthorizedWithCode(c, i18n.ErrCodeUnauthenticated)
+		return nil, false
+	}
+	agent, err := service.Agent.FindByAuthID(agentID)
+	if err == nil {
+		return agent, true
+	}
+	if c.GetBool("is_admin") {
+		return nil, true
+	}
+	response.ForbiddenWithCode(c, i18n.ErrCodePermissionDenied)
+	return nil, false
+}
+
+// member 获取当前操作的客服，并校验其为对话的负责客服、参与客服或管理员
+func (h ChatAgentHeadler) member(c *gin.Context, conversationID uint) (*models.Agent, bool) {
+	operator, ok := h.operator(c)
+	if !ok {
+		return nil, false
+	}
+	if err := service.Participant.Authorize(conversationID, operator, c.GetBool("is_admin")); err != nil {
+		response.ServiceError(c, "校验对话权限失败", err)
+		return nil, false
+	}
+	return operator, true
+}
+
+// @Summary 吊销对话访问令牌
+// @Description 使客户持有的对话访问令牌全部失效，并断开客户端的WebSocket连接
+// @Accept json
+// @Produce json
+// @Param id path int true "对话ID"
+// @Success 200 {object} models.Response
+// @Failure 400 {object} models.Response
+// @Failure 403 {object} models.Response
+// @Failure 500 {object} models.Response
+// @Router /chat/agent/conversations/{id}/revoke-token [put]
+func (h ChatAgentHeadler) RevokeConversationToken(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.BadRequestWithCode(c, i18n.ErrCodeInvalidParams)
+		return
+	}
+	if _, ok := h.member(c, uint(id)); !ok {
+		return
+	}
+
+	if err := service.ConversationToken.Revoke(uint(id)); err != nil {
+		if bizErr, ok := bizErrors.IsBusinessError(err); ok {
+			response.BadRequest(c, bizErr.Message, bizErr)
+			return
+		}
+		response.ServerError(c, "吊销访问令牌失败", err)
+		return
+	}
+
+	response.Success(c, "访问令牌已吊销", nil)
+}
+
+// 获取消息历史游标参数，兼容旧版的 page_size 参数
+func getHistoryParams(c *gin.Context) (models.MessageHistoryQuery, bool) {
+	var query models.MessageHistoryQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		return query, false
+	}
+	if query.BeforeID > 0 && query.AfterID > 0 {
+		return query, false
+	}
+	if query.Limit == 0 {
+		query.Limit, _ = strconv.Atoi(c.Query("page_size"))
+	}
+	return query, true
+}
+
 // 获取分页参数
 func getPaginationParams(c *gin.Context) (int, int) {
 	// 获取分页参数
@@ -1,12 +1,13 @@
 package headlers
 
 import (
-	"strconv"
-
+	"support-plugin/internal/config"
 	"support-plugin/internal/i18n"
+	"support-plugin/internal/middleware"
 	"support-plugin/internal/models"
 	bizErrors "support-plugin/internal/pkg/errors"
 	"support-plugin/internal/pkg/response"
+	"support-plugin/internal/pkg/websocket"
 	"support-plugin/internal/service"
 
 	"github.com/gin-gonic/gin"
@@ -35,8 +36,41 @@ func (h ChatPublicHeadler) CreateConversation(c *gin.Context) {
 		return
 	}
 
+	// 识别客户，令牌优先取请求体，其次取Header/Query/Cookie
+	visitorToken := req.VisitorToken
+	if visitorToken == "" {
+		visitorToken = middleware.VisitorToken(c)
+	}
+	identity := &service.CustomerIdentity{
+		UserID:   req.UserID,
+		UserHash: req.UserHash,
+	}
+	customer, visitorToken, err := service.Customer.Identify(req.Source, visitorToken, identity, &service.CustomerProfile{
+		Name:         req.Name,
+		Email:        req.Email,
+		Phone:        req.Phone,
+		IP:           c.ClientIP(),
+		UserAgent:    c.Request.UserAgent(),
+		CustomFields: req.CustomFields,
+	})
+	if err != nil {
+		if i18nErr, ok := err.(*i18n.ErrorInfo); ok {
+			switch i18nErr.Code {
+			case i18n.ErrCodeAnonymousNotAllowed:
+				response.ForbiddenWithCode(c, i18nErr.Code)
+			case i18n.ErrCodeSourceNotFound:
+				response.BadRequestWithCode(c, i18nErr.Code)
+			default:
+				response.UnauthorizedWithCode(c, i18nErr.Code)
+			}
+			return
+		}
+		response.ServerError(c, "", err)
+		return
+	}
+
 	// 创建对话
-	uuid, err := service.ChatPublic.CreateConversation(req.AgentID, req.CustomerID, req.Title, req.Source)
+	uuid, err := service.ChatPublic.CreateConversation(customer.ID, req.Title, req.Source)
 	if err != nil {
 		// 检查是否为i18n错误
 		if i18nErr, ok := err.(*i18n.ErrorInfo); ok {
@@ -47,8 +81,25 @@ func (h ChatPublicHeadler) CreateConversation(c *gin.Context) {
 		return
 	}
 
+	// 签发对话访问令牌
+	conversation, err := service.ChatPublic.GetConversation(uuid)
+	if err != nil {
+		response.ServerError(c, "", err)
+		return
+	}
+	token, err := service.ConversationToken.Issue(conversation)
+	if err != nil {
+		response.ServerError(c, "", err)
+		return
+	}
+
+	if visitorToken != "" {
+		middleware.SetVisitorCookie(c, visitorToken)
+	}
 	response.SuccessWithCode(c, models.ConversationResponse{
-		UUID: uuid,
+		UUID:         uuid,
+		VisitorToken: visitorToken,
+		Token:        token,
 	})
 }
 
@@ -56,6 +107,7 @@ func (h ChatPublicHeadler) CreateConversation(c *gin.Context) {
 // @Description 在指定对话中发送新消息
 // @Accept json
 // @Produce json
+// @Param X-Conversation-Token header string true "对话访问令牌，也可通过conv_token参数传递"
 // @Param request body models.SendMessageRequest true "发送消息请求参数"
 // @Success 200 {object} models.Response
 // @Failure 400 {object} models.Response
@@ -70,8 +122,18 @@ func (h ChatPublicHeadler) SendMessage(c *gin.Context) {
 		return
 	}
 
-	// 发送消息
-	message, err := service.ChatPublic.SendMessage(req.UUID, req.Content, req.Sender, req.Type, req.Metadata)
+	// 系统消息只能由服务端发送
+	if req.Type == "system" {
+		response.BadRequestWithCode(c, i18n.ErrCodeInvalidParams)
+		return
+	}
+
+	if !h.authorize(c, req.UUID) {
+		return
+	}
+
+	// 发送消息，公开接口的发送者固定为客户
+	message, err := service.ChatPublic.SendMessage(req.UUID, req.Content, req.Type, req.Metadata)
 	if err != nil {
 		// 检查是否为i18n错误
 		if i18nErr, ok := err.(*i18n.ErrorInfo); ok {
@@ -88,6 +150,7 @@ func (h ChatPublicHeadler) SendMessage(c *gin.Context) {
 		"content":    message.Content,
 		"sender":     message.Sender,
 		"type":       message.Type,
+		"metadata":   message.Metadata,
 		"created_at": message.CreatedAt,
 	}
 
@@ -95,13 +158,15 @@ func (h ChatPublicHeadler) SendMessage(c *gin.Context) {
 }
 
 // @Summary 获取对话消息列表
-// @Description 获取指定对话的消息历史记录
+// @Description 获取指定对话的消息历史记录，默认返回最新消息，通过 before_id 向前翻页，重连后通过 after_id 补齐断线期间的消息
 // @Accept json
 // @Produce json
 // @Param uuid path string true "对话UUID"
-// @Param page query int false "页码,默认1"
-// @Param page_size query int false "每页数量,默认20"
-// @Success 200 {object} models.Response{data=models.PaginationData}
+// @Param X-Conversation-Token header string true "对话访问令牌，也可通过conv_token参数传递"
+// @Param before_id query int false "返回该消息之前的消息"
+// @Param after_id query int false "返回该消息之后的消息（同步模式）"
+// @Param limit query int false "返回数量,默认20,最大100"
+// @Success 200 {object} models.Response{data=models.CursorData}
 // @Failure 400 {object} models.Response
 // @Failure 500 {object} models.Response
 // @Router /chat/{uuid}/messages [get]
@@ -113,26 +178,25 @@ func (h ChatPublicHeadler) GetMessages(c *gin.Context) {
 		return
 	}
 
-	// 获取分页参数
-	pageStr := c.DefaultQuery("page", "1")
-	pageSizeStr := c.DefaultQuery("page_size", "20")
-
-	page, err := strconv.Atoi(pageStr)
-	if err != nil || page < 1 {
-		page = 1
+	if !h.authorize(c, uuid) {
+		return
 	}
 
-	pageSize, err := strconv.Atoi(pageSizeStr)
-	if err != nil || pageSize < 1 || pageSize > 100 {
-		pageSize = 20
+	// 获取游标参数
+	query, ok := getHistoryParams(c)
+	if !ok {
+		response.BadRequestWithCode(c, i18n.ErrCodeInvalidParams)
+		return
 	}
 
 	// 获取消息列表
-	messages, total, err := service.ChatPublic.GetMessages(uuid, page, pageSize)
+	messages, hasMore, err := service.ChatPublic.GetMessages(uuid, query)
 	if err != nil {
 		// 检查是否为i18n错误
 		if i18nErr, ok := err.(*i18n.ErrorInfo); ok {
 			response.BadRequestWithCode(c, i18nErr.Code)
+		} else if bizErr, ok := bizErrors.IsBusinessError(err); ok {
+			response.BadRequest(c, bizErr.Message, bizErr)
 		} else {
 			response.ServerError(c, "", err)
 		}
@@ -147,17 +211,19 @@ func (h ChatPublicHeadler) GetMessages(c *gin.Context) {
 			"content":    msg.Content,
 			"sender":     msg.Sender,
 			"type":       msg.Type,
+			"metadata":   msg.Metadata,
 			"created_at": msg.CreatedAt,
 		}
 	}
 
-	// 使用通用成功消息的分页响应
+	// 使用通用成功消息的游标分页响应
 	lang := c.GetHeader("Accept-Language")
 	if lang == "" {
 		lang = "zh-CN"
 	}
 	message := i18n.T(i18n.Language(lang), string(i18n.ErrCodeSuccess))
-	response.SuccessWithPagination(c, message, simplifiedMessages, total, page, pageSize)
+	firstID, lastID := service.MessageHistory.Cursors(messages)
+	response.SuccessWithCursor(c, message, simplifiedMessages, hasMore, firstID, lastID)
 }
 
 // @Summary 获取对话信息
@@ -165,8 +231,10 @@ func (h ChatPublicHeadler) GetMessages(c *gin.Context) {
 // @Accept json
 // @Produce json
 // @Param uuid path string true "对话UUID"
+// @Param X-Conversation-Token header string true "对话访问令牌，也可通过conv_token参数传递"
 // @Success 200 {object} models.Response
 // @Failure 400 {object} models.Response
+// @Failure 401 {object} models.Response
 // @Failure 500 {object} models.Response
 // @Router /chat/{uuid} [get]
 func (h ChatPublicHeadler) GetConversation(c *gin.Context) {
@@ -177,6 +245,10 @@ func (h ChatPublicHeadler) GetConversation(c *gin.Context) {
 		return
 	}
 
+	if !h.authorize(c, uuid) {
+		return
+	}
+
 	// 获取对话信息
 	conversation, err := service.ChatPublic.GetConversation(uuid)
 	if err != nil {
@@ -203,7 +275,179 @@ func (h ChatPublicHeadler) GetConversation(c *gin.Context) {
 		"last_message":    conversation.LastMessage,
 		"last_message_at": conversation.LastMessageAt,
 		"created_at":      conversation.CreatedAt,
+		"csat_survey":     service.CSAT.Pending(conversation), // 待提交的满意度评价，没有时为null
 	}
 
 	response.SuccessWithCode(c, simplifiedConversation)
 }
+
+// @Summary 提交满意度评价
+// @Description 对话关闭后客户提交满意度评价，每个对话只能评价一次
+// @Accept json
+// @Produce json
+// @Param uuid path string true "对话UUID"
+// @Param X-Conversation-Token header string true "对话访问令牌，也可通过conv_token参数传递"
+// @Param request body models.SubmitRatingRequest true "评价内容"
+// @Success 200 {object} models.Response{data=models.ConversationRating}
+// @Failure 400 {object} models.Response
+// @Failure 401 {object} models.Response
+// @Failure 500 {object} models.Response
+// @Router /chat/{uuid}/rating [post]
+func (h ChatPublicHeadler) SubmitRating(c *gin.Context) {
+	uuid := c.Param("uuid")
+	if !h.authorize(c, uuid) {
+		return
+	}
+
+	var req models.SubmitRatingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequestWithCode(c, i18n.ErrCodeInvalidParams)
+		return
+	}
+
+	rating, err := service.CSAT.Submit(uuid, *req.Score, req.Comment)
+	if err != nil {
+		if bizErr, ok := bizErrors.IsBusinessError(err); ok {
+			response.BadRequest(c, bizErr.Message, bizErr)
+			return
+		}
+		response.ServerError(c, "", err)
+		return
+	}
+	response.SuccessWithCode(c, rating)
+}
+
+// @Summary 获取客服在线状态
+// @Description 根据来源的工作时间判断当前是否有客服在线，供客户端在发送消息前展示
+// @Accept json
+// @Produce json
+// @Param source query string false "来源标识,默认widget"
+// @Success 200 {object} models.Response{data=models.AvailabilityResponse}
+// @Failure 400 {object} models.Response
+// @Router /chat/availability [get]
+func (h ChatPublicHeadler) GetAvailability(c *gin.Context) {
+	availability, err := service.Availability.GetAvailability(c.Query("source"))
+	if err != nil {
+		if bizErr, ok := bizErrors.IsBusinessError(err); ok {
+			response.BadRequest(c, bizErr.Message, bizErr)
+			return
+		}
+		response.ServerError(c, "", err)
+		return
+	}
+
+	response.SuccessWithCode(c, availability)
+}
+
+// @Summary 获取访客的历史对话
+// @Description 根据访客令牌获取客户的历史对话，供回访客户继续之前的对话
+// @Description 客户端通过 conv_tokens[<对话UUID>]=<令牌> 提交持有的对话令牌，仅有效且未吊销的令牌会续签
+// @Accept json
+// @Produce json
+// @Param X-Visitor-Token header string false "访客令牌，也可通过visitor_token参数或Cookie传递"
+// @Param conv_tokens query object false "客户端持有的对话访问令牌，键为对话UUID"
+// @Success 200 {object} models.Response
+// @Failure 401 {object} models.Response
+// @Failure 500 {object} models.Response
+// @Router /chat/customer/conversations [get]
+func (h ChatPublicHeadler) GetCustomerConversations(c *gin.Context) {
+	customer, err := service.Customer.ParseVisitorToken(middleware.VisitorToken(c))
+	if err != nil {
+		if i18nErr, ok := err.(*i18n.ErrorInfo); ok {
+			response.UnauthorizedWithCode(c, i18nErr.Code)
+		} else {
+			response.UnauthorizedWithCode(c, i18n.ErrCodeTokenInvalid)
+		}
+		return
+	}
+
+	conversations, err := service.Customer.GetConversations(customer.ID)
+	if err != nil {
+		response.ServerError(c, "", err)
+		return
+	}
+
+	// 为客户端简化对话数据
+	convTokens := c.QueryMap("conv_tokens")
+	simplifiedConversations := make([]map[string]interface{}, len(conversations))
+	for i, conversation := range conversations {
+		// 只续签客户端持有的有效令牌，已吊销或版本落后的令牌不再换发
+		token := ""
+		if convTokens[conversation.Uuid] != "" {
+			token, _ = service.ConversationToken.Renew(&conversations[i], convTokens[conversation.Uuid])
+		}
+		simplifiedConversations[i] = map[string]interface{}{
+			"token":           token,
+			"uuid":            conversation.Uuid,
+			"title":           conversation.Title,
+			"status":          conversation.Status,
+			"source":          conversation.Source,
+			"last_message":    conversation.LastMessage,
+			"last_message_at": conversation.LastMessageAt,
+			"created_at":      conversation.CreatedAt,
+		}
+	}
+
+	response.SuccessWithCode(c, simplifiedConversations)
+}
+
+// @Summary WebSocket连接
+// @Description 建立WebSocket连接，客户端需携带对话访问令牌，客服端需携带DooTask令牌
+// @Param conv_uuid query string true "对话UUID"
+// @Param client_type query string true "客户端类型：customer, agent"
+// @Param conv_token query string false "对话访问令牌，客户端必填"
+// @Param token query string false "客服端必填，DooTask模式为DooTask令牌，独立模式为登录令牌"
+// @Failure 400 {object} models.Response
+// @Failure 401 {object} models.Response
+// @Router /chat/ws [get]
+func (h ChatPublicHeadler) ServeWs(c *gin.Context) {
+	switch c.Query("client_type") {
+	case "customer":
+		if !h.authorize(c, c.Query("conv_uuid")) {
+			return
+		}
+	case "agent":
+		// DooTask模式在websocket.ServeWs中校验DooTask令牌
+		if config.Cfg.App.Mode != "dootask" {
+			claims, err := service.Auth.ParseToken(middleware.BearerToken(c))
+			if err != nil {
+				if i18nErr, ok := err.(*i18n.ErrorInfo); ok {
+					response.UnauthorizedWithCode(c, i18nErr.Code)
+				} else {
+					response.UnauthorizedWithCode(c, i18n.ErrCodeTokenInvalid)
+				}
+				return
+			}
+			c.Set("agent_id", claims.AgentID)
+			c.Set("is_admin", claims.IsAdmin)
+		}
+	}
+	websocket.ServeWs(c)
+}
+
+// authorize 校验请求携带的对话访问令牌，失败时直接写入响应
+func (h ChatPublicHeadler) authorize(c *gin.Context, conversationUUID string) bool {
+	if conversationUUID == "" {
+		response.BadRequestWithCode(c, i18n.ErrCodeInvalidParams)
+		return false
+	}
+	token := middleware.ConversationToken(c)
+	if token == "" {
+		response.UnauthorizedWithCode(c, i18n.ErrCodeUnauthenticated)
+		return false
+	}
+
+	if _, err := service.ConversationToken.Verify(conversationUUID, token); err != nil {
+		if i18nErr, ok := err.(*i18n.ErrorInfo); ok {
+			if i18nErr.Code == i18n.ErrCodeConversationNotFound {
+				response.BadRequestWithCode(c, i18nErr.Code)
+			} else {
+				response.UnauthorizedWithCode(c, i18nErr.Code)
+			}
+		} else {
+			response.ServerError(c, "", err)
+		}
+		return false
+	}
+	return true
+}
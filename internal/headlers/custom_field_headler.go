@@ -0,0 +1,119 @@
+package headlers
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"support-plugin/internal/i18n"
+	"support-plugin/internal/models"
+	bizErrors "support-plugin/internal/pkg/errors"
+	"support-plugin/internal/pkg/response"
+	"support-plugin/internal/service"
+)
+
+type CustomFieldHeadler struct{}
+
+var CustomField = CustomFieldHeadler{}
+
+// @Summary 获取自定义字段列表
+// @Description 获取全部对话自定义字段定义
+// @Accept json
+// @Produce json
+// @Success 200 {object} models.Response{data=[]models.CustomField}
+// @Failure 500 {object} models.Response
+// @Router /custom-fields [get]
+func (h CustomFieldHeadler) List(c *gin.Context) {
+	fields, err := service.CustomField.List()
+	if err != nil {
+		response.ServerError(c, "获取自定义字段失败", err)
+		return
+	}
+	response.Success(c, "获取自定义字段成功", fields)
+}
+
+// @Summary 创建自定义字段
+// @Description 创建对话自定义字段，类型为 text、select、number 或 date，单选字段需提供选项
+// @Accept json
+// @Produce json
+// @Param request body models.CustomFieldRequest true "自定义字段"
+// @Success 200 {object} models.Response{data=models.CustomField}
+// @Failure 400 {object} models.Response
+// @Failure 500 {object} models.Response
+// @Router /custom-fields [post]
+func (h CustomFieldHeadler) Create(c *gin.Context) {
+	var req models.CustomFieldRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequestWithCode(c, i18n.ErrCodeInvalidParams)
+		return
+	}
+
+	field, err := service.CustomField.Create(req)
+	if err != nil {
+		h.error(c, "创建自定义字段失败", err)
+		return
+	}
+	response.Success(c, "创建自定义字段成功", field)
+}
+
+// @Summary 更新自定义字段
+// @Description 更新自定义字段定义，已有取值的字段不能修改类型
+// @Accept json
+// @Produce json
+// @Param id path int true "自定义字段ID"
+// @Param request body models.CustomFieldRequest true "自定义字段"
+// @Success 200 {object} models.Response{data=models.CustomField}
+// @Failure 400 {object} models.Response
+// @Failure 500 {object} models.Response
+// @Router /custom-fields/{id} [put]
+func (h CustomFieldHeadler) Update(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.BadRequestWithCode(c, i18n.ErrCodeInvalidParams)
+		return
+	}
+	var req models.CustomFieldRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequestWithCode(c, i18n.ErrCodeInvalidParams)
+		return
+	}
+
+	field, err := service.CustomField.Update(uint(id), req)
+	if err != nil {
+		h.error(c, "更新自定义字段失败", err)
+		return
+	}
+	response.Success(c, "更新自定义字段成功", field)
+}
+
+// @Summary 删除自定义字段
+// @Description 删除自定义字段及其在对话上的取值
+// @Accept json
+// @Produce json
+// @Param id path int true "自定义字段ID"
+// @Success 200 {object} models.Response
+// @Failure 400 {object} models.Response
+// @Failure 500 {object} models.Response
+// @Router /custom-fields/{id} [delete]
+func (h CustomFieldHeadler) Delete(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.BadRequestWithCode(c, i18n.ErrCodeInvalidParams)
+		return
+	}
+
+	if err := service.CustomField.Delete(uint(id)); err != nil {
+		h.error(c, "删除自定义字段失败", err)
+		return
+	}
+	response.Success(c, "删除自定义字段成功", nil)
+}
+
+// error 输出自定义字段操作的错误信息
+func (h CustomFieldHeadler) error(c *gin.Context, message string, err error) {
+	if bizErr, ok := bizErrors.IsBusinessError(err); ok {
+		response.BadRequest(c, bizErr.Message, bizErr)
+		return
+	}
+	response.ServerError(c, message, err)
+}
@@ -19,7 +19,7 @@ var DooTask = DooTaskHeadler{}
 
 // swagger
 // @Summary 机器人聊天
-// @Description 机器人聊天
+// @Description 机器人聊天，任务对话中以 / 开头的消息作为客服命令执行（/close、/reopen、/transfer、/note、/history、/info）
 // @Tags 机器人
 // @Accept json
 // @Produce json
@@ -54,7 +54,15 @@ func (d DooTaskHeadler) Chat(c *gin.Context) {
 		return
 	}
 
-	_, err = service.ChatAgent.SendMessageByAgent(conversation.ID, message.Text, "text", "dootask")
+	// 以 / 开头的命令由机器人执行，不发送给客户；#快捷短语 展开为快捷回复后发送
+	if service.BotCommand.Handle(conversation, message) || service.BotCommand.Shortcut(conversation, message) {
+		response.Success(c, "命令已执行", gin.H{
+			"status": "ok",
+		})
+		return
+	}
+
+	_, err = service.ChatAgent.SendMessageByAgent(conversation.ID, message.Text, "text", "dootask", service.BotCommand.Sender(message))
 	if err != nil {
 		// response.ServerError(c, "发送消息失败", err)
 		return
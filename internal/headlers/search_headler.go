@@ -0,0 +1,67 @@
+package headlers
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"support-plugin/internal/i18n"
+	"support-plugin/internal/models"
+	"support-plugin/internal/pkg/response"
+	"support-plugin/internal/service"
+)
+
+type SearchHeadler struct{}
+
+var Search = SearchHeadler{}
+
+// @Summary 全文检索
+// @Description 按关键词检索消息内容、客户信息与对话元数据，返回带 <mark> 高亮的摘要及对话、消息ID
+// @Accept json
+// @Produce json
+// @Param q query string true "关键词"
+// @Param source_key query string false "来源标识"
+// @Param agent_id query int false "负责客服ID"
+// @Param status query string false "对话状态"
+// @Param kind query string false "文档类型：message, conversation"
+// @Param start_date query string false "开始日期，格式 2006-01-02"
+// @Param end_date query string false "结束日期（含），格式 2006-01-02"
+// @Param page query int false "页码" default(1)
+// @Param page_size query int false "每页数量" default(20)
+// @Success 200 {object} models.Response{data=[]models.SearchHit}
+// @Failure 400 {object} models.Response
+// @Failure 500 {object} models.Response
+// @Router /search [get]
+func (h SearchHeadler) Search(c *gin.Context) {
+	var query models.SearchQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		response.BadRequestWithCode(c, i18n.ErrCodeInvalidParams)
+		return
+	}
+	service.Search.Normalize(&query)
+
+	hits, total, err := service.Search.Query(query)
+	if err != nil {
+		if i18nErr, ok := err.(*i18n.ErrorInfo); ok {
+			response.BadRequestWithCode(c, i18nErr.Code)
+			return
+		}
+		response.ServerError(c, "检索失败", err)
+		return
+	}
+	response.SuccessWithPagination(c, "检索成功", hits, total, query.Page, query.PageSize)
+}
+
+// @Summary 重建检索索引
+// @Description 根据全部消息与对话重新生成检索文档并刷新全文索引
+// @Accept json
+// @Produce json
+// @Success 200 {object} models.Response
+// @Failure 500 {object} models.Response
+// @Router /search/rebuild [post]
+func (h SearchHeadler) Rebuild(c *gin.Context) {
+	count, err := service.Search.Rebuild()
+	if err != nil {
+		response.ServerError(c, "重建检索索引失败", err)
+		return
+	}
+	response.Success(c, "重建检索索引成功", gin.H{"documents": count})
+}
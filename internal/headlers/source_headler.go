@@ -14,6 +14,7 @@ import (
 	"support-plugin/internal/pkg/database"
 	"support-plugin/internal/pkg/logger"
 	"support-plugin/internal/pkg/response"
+	"support-plugin/internal/service"
 	"support-plugin/internal/utils/common"
 )
 
@@ -64,6 +65,9 @@ func (h SourceHeadler) CreateSource(c *gin.Context) {
 		ColumnID:  req.ColumnID,
 		Config:    string(configJSON),
 		Status:    1, // 默认启用
+		Secret:    common.SecureRandString(32),
+
+		IdentityVerification: req.IdentityVerification,
 	}
 
 	if err := database.DB.Create(&source).Error; err != nil {
@@ -80,6 +84,7 @@ func (h SourceHeadler) CreateSource(c *gin.Context) {
 		DialogID:  source.DialogID,
 		ProjectID: source.ProjectID,
 		ColumnID:  source.ColumnID,
+		Secret:    source.Secret,
 	}
 
 	response.Success(c, "创建来源成功", resp)
@@ -177,6 +182,8 @@ func (h SourceHeadler) UpdateSource(c *gin.Context) {
 		"taskId":   true,
 		"dialogId": true,
 		"status":   true,
+
+		"identity_verification": true,
 	}
 
 	updateMap := make(map[string]interface{})
@@ -206,6 +213,11 @@ func (h SourceHeadler) UpdateSource(c *gin.Context) {
 		return
 	}
 
+	// 来源配置变化后按新的SLA策略重新计算未关闭对话的到期时间
+	if _, ok := updateMap["config"]; ok {
+		go service.SLA.RefreshOpen(source.SourceKey)
+	}
+
 	response.Success(c, "更新来源成功", source)
 }
 
@@ -247,6 +259,160 @@ func (h SourceHeadler) DeleteSource(c *gin.Context) {
 	response.Success(c, "删除来源成功", nil)
 }
 
+// @Summary 重置来源身份验证密钥
+// @Description 重新生成来源的身份验证密钥，旧密钥签名的用户身份将失效
+// @Accept json
+// @Produce json
+// @Param id path int true "来源ID"
+// @Success 200 {object} models.Response{data=models.CustomerServiceSource}
+// @Failure 400 {object} models.Response
+// @Failure 404 {object} models.Response
+// @Failure 500 {object} models.Response
+// @Router /sources/{id}/secret [post]
+func (h SourceHeadler) ResetSecret(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		response.BadRequest(c, "无效的来源ID", err)
+		return
+	}
+
+	var source models.CustomerServiceSource
+	if err := database.DB.Where("id = ? AND status = ?", id, 1).First(&source).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			response.NotFound(c, "来源不存在")
+		} else {
+			response.InternalServerError(c, "获取来源失败", err)
+		}
+		return
+	}
+
+	secret := common.SecureRandString(32)
+	if err := database.DB.Model(&source).Update("secret", secret).Error; err != nil {
+		response.InternalServerError(c, "重置密钥失败", err)
+		return
+	}
+	source.Secret = secret
+
+	response.Success(c, "重置密钥成功", source)
+}
+
+// @Summary 获取来源成员
+// @Description 获取负责接待来源中待分配对话的客服
+// @Accept json
+// @Produce json
+// @Param id path int true "来源ID"
+// @Success 200 {object} models.Response{data=[]models.Agent}
+// @Failure 400 {object} models.Response
+// @Failure 404 {object} models.Response
+// @Failure 500 {object} models.Response
+// @Router /sources/{id}/members [get]
+func (h SourceHeadler) GetSourceMembers(c *gin.Context) {
+	source, ok := h.findSource(c)
+	if !ok {
+		return
+	}
+
+	var agents []models.Agent
+	err := database.DB.Joins("JOIN cs_source_members AS m ON m.agent_id = cs_agents.id").
+		Where("m.source_id = ?", source.ID).
+		Order("cs_agents.id ASC").
+		Find(&agents).Error
+	if err != nil {
+		response.InternalServerError(c, "获取来源成员失败", err)
+		return
+	}
+
+	response.Success(c, "获取来源成员成功", agents)
+}
+
+// @Summary 设置来源成员
+// @Description 设置负责接待来源中待分配对话的客服，未设置成员时通知所有客服
+// @Accept json
+// @Produce json
+// @Param id path int true "来源ID"
+// @Param request body models.SetSourceMembersRequest true "来源成员"
+// @Success 200 {object} models.Response
+// @Failure 400 {object} models.Response
+// @Failure 404 {object} models.Response
+// @Failure 500 {object} models.Response
+// @Router /sources/{id}/members [put]
+func (h SourceHeadler) SetSourceMembers(c *gin.Context) {
+	var req models.SetSourceMembersRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "参数错误", err)
+		return
+	}
+
+	source, ok := h.findSource(c)
+	if !ok {
+		return
+	}
+
+	var agentIDs []uint
+	if len(req.AgentIDs) > 0 {
+		if err := database.DB.Model(&models.Agent{}).Where("id IN ?", req.AgentIDs).Pluck("id", &agentIDs).Error; err != nil {
+			response.InternalServerError(c, "设置来源成员失败", err)
+			return
+		}
+		if len(agentIDs) != len(uniqueIDs(req.AgentIDs)) {
+			response.BadRequest(c, "客服不存在", nil)
+			return
+		}
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("source_id = ?", source.ID).Delete(&models.SourceMember{}).Error; err != nil {
+			return err
+		}
+		for _, agentID := range agentIDs {
+			if err := tx.Create(&models.SourceMember{SourceID: source.ID, AgentID: agentID}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		response.InternalServerError(c, "设置来源成员失败", err)
+		return
+	}
+
+	response.Success(c, "设置来源成员成功", nil)
+}
+
+// findSource 根据路径参数查找启用的来源，失败时直接写入响应
+func (h SourceHeadler) findSource(c *gin.Context) (*models.CustomerServiceSource, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.BadRequest(c, "无效的来源ID", err)
+		return nil, false
+	}
+
+	var source models.CustomerServiceSource
+	if err := database.DB.Where("id = ? AND status = ?", id, 1).First(&source).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			response.NotFound(c, "来源不存在")
+		} else {
+			response.InternalServerError(c, "获取来源失败", err)
+		}
+		return nil, false
+	}
+	return &source, true
+}
+
+// uniqueIDs 去除重复的ID
+func uniqueIDs(ids []uint) []uint {
+	seen := make(map[uint]bool, len(ids))
+	result := make([]uint, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			result = append(result, id)
+		}
+	}
+	return result
+}
+
 // generateSourceKey 生成来源唯一标识
 func generateSourceKey(name string) string {
 	// 生成6位随机字符串
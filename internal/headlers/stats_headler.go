@@ -0,0 +1,63 @@
+package headlers
+
+import (
+	"support-plugin/internal/i18n"
+	"support-plugin/internal/models"
+	"support-plugin/internal/pkg/response"
+	"support-plugin/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type StatsHeadler struct{}
+
+var Stats = StatsHeadler{}
+
+// @Summary 获取统计报表
+// @Description 按日期范围、负责客服与来源筛选每日统计，返回汇总以及按日期、客服、来源分组的数据，默认最近30天
+// @Accept json
+// @Produce json
+// @Param start_date query string false "开始日期，格式 2006-01-02"
+// @Param end_date query string false "结束日期（含），格式 2006-01-02"
+// @Param agent_id query int false "负责客服ID"
+// @Param source_key query string false "来源标识"
+// @Success 200 {object} models.Response{data=models.StatsReport}
+// @Failure 400 {object} models.Response
+// @Failure 401 {object} models.Response
+// @Failure 500 {object} models.Response
+// @Router /stats [get]
+func (h StatsHeadler) GetStats(c *gin.Context) {
+	var query models.StatsQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		response.BadRequestWithCode(c, i18n.ErrCodeInvalidParams)
+		return
+	}
+
+	report, err := service.Stats.Report(query)
+	if err != nil {
+		if i18nErr, ok := err.(*i18n.ErrorInfo); ok {
+			response.BadRequestWithCode(c, i18nErr.Code)
+			return
+		}
+		response.ServerError(c, "获取统计数据失败", err)
+		return
+	}
+	response.Success(c, "获取统计数据成功", report)
+}
+
+// @Summary 重建统计数据
+// @Description 补齐缺失的统计事件并根据统计事件重新汇总每日统计
+// @Accept json
+// @Produce json
+// @Success 200 {object} models.Response{data=map[string]int}
+// @Failure 401 {object} models.Response
+// @Failure 500 {object} models.Response
+// @Router /stats/rebuild [post]
+func (h StatsHeadler) Rebuild(c *gin.Context) {
+	rows, err := service.Stats.Rebuild()
+	if err != nil {
+		response.ServerError(c, "重建统计数据失败", err)
+		return
+	}
+	response.Success(c, "重建统计数据成功", gin.H{"rows": rows})
+}
@@ -0,0 +1,119 @@
+package headlers
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"support-plugin/internal/i18n"
+	"support-plugin/internal/models"
+	bizErrors "support-plugin/internal/pkg/errors"
+	"support-plugin/internal/pkg/response"
+	"support-plugin/internal/service"
+)
+
+type TagHeadler struct{}
+
+var Tag = TagHeadler{}
+
+// @Summary 获取标签列表
+// @Description 获取全部对话标签
+// @Accept json
+// @Produce json
+// @Success 200 {object} models.Response{data=[]models.Tag}
+// @Failure 500 {object} models.Response
+// @Router /tags [get]
+func (h TagHeadler) List(c *gin.Context) {
+	tags, err := service.Tag.List()
+	if err != nil {
+		response.ServerError(c, "获取标签失败", err)
+		return
+	}
+	response.Success(c, "获取标签成功", tags)
+}
+
+// @Summary 创建标签
+// @Description 创建对话标签，可映射为DooTask任务优先级与任务标签
+// @Accept json
+// @Produce json
+// @Param request body models.TagRequest true "标签"
+// @Success 200 {object} models.Response{data=models.Tag}
+// @Failure 400 {object} models.Response
+// @Failure 500 {object} models.Response
+// @Router /tags [post]
+func (h TagHeadler) Create(c *gin.Context) {
+	var req models.TagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequestWithCode(c, i18n.ErrCodeInvalidParams)
+		return
+	}
+
+	tag, err := service.Tag.Create(req)
+	if err != nil {
+		h.error(c, "创建标签失败", err)
+		return
+	}
+	response.Success(c, "创建标签成功", tag)
+}
+
+// @Summary 更新标签
+// @Description 更新标签的名称、颜色、说明与DooTask映射
+// @Accept json
+// @Produce json
+// @Param id path int true "标签ID"
+// @Param request body models.TagRequest true "标签"
+// @Success 200 {object} models.Response{data=models.Tag}
+// @Failure 400 {object} models.Response
+// @Failure 500 {object} models.Response
+// @Router /tags/{id} [put]
+func (h TagHeadler) Update(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.BadRequestWithCode(c, i18n.ErrCodeInvalidParams)
+		return
+	}
+	var req models.TagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequestWithCode(c, i18n.ErrCodeInvalidParams)
+		return
+	}
+
+	tag, err := service.Tag.Update(uint(id), req)
+	if err != nil {
+		h.error(c, "更新标签失败", err)
+		return
+	}
+	response.Success(c, "更新标签成功", tag)
+}
+
+// @Summary 删除标签
+// @Description 删除标签，同时移除对话上的该标签
+// @Accept json
+// @Produce json
+// @Param id path int true "标签ID"
+// @Success 200 {object} models.Response
+// @Failure 400 {object} models.Response
+// @Failure 500 {object} models.Response
+// @Router /tags/{id} [delete]
+func (h TagHeadler) Delete(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.BadRequestWithCode(c, i18n.ErrCodeInvalidParams)
+		return
+	}
+
+	if err := service.Tag.Delete(uint(id)); err != nil {
+		h.error(c, "删除标签失败", err)
+		return
+	}
+	response.Success(c, "删除标签成功", nil)
+}
+
+// error 输出标签操作的错误信息
+func (h TagHeadler) error(c *gin.Context, message string, err error) {
+	if bizErr, ok := bizErrors.IsBusinessError(err); ok {
+		response.BadRequest(c, bizErr.Message, bizErr)
+		return
+	}
+	response.ServerError(c, message, err)
+}
@@ -25,6 +25,8 @@ const (
 	ErrCodeUnauthenticated   ErrorCode = "UNAUTHENTICATED"
 	ErrCodeUsernameExists    ErrorCode = "USERNAME_EXISTS"
 	ErrCodeInvalidParams     ErrorCode = "INVALID_PARAMS"
+	ErrCodeIdentityVerifyFailed ErrorCode = "IDENTITY_VERIFICATION_FAILED"
+	ErrCodeAnonymousNotAllowed  ErrorCode = "ANONYMOUS_NOT_ALLOWED"
 
 	// 对话相关错误
 	ErrCodeConversationNotFound ErrorCode = "CONVERSATION_NOT_FOUND"
@@ -12,6 +12,7 @@ import (
 	"support-plugin/internal/pkg/dootask"
 	"support-plugin/internal/pkg/logger"
 	"support-plugin/internal/pkg/response"
+	"support-plugin/internal/service"
 )
 
 // AgentAuthMiddleware 客服认证中间件
@@ -30,8 +31,7 @@ func AgentAuthMiddleware() gin.HandlerFunc {
 
 		if config.Cfg.App.Mode == "dootask" {
 			dootaskToken := Token(c)
-			dootaskService := dootask.NewIDootaskService()
-			userInfoResp, err := dootaskService.GetUserInfo(dootaskToken)
+			userInfoResp, err := dootask.UserCache.GetUserInfo(dootaskToken)
 			if err != nil {
 				// 检查是否为i18n错误
 				if i18nErr, ok := err.(*i18n.ErrorInfo); ok {
@@ -50,14 +50,26 @@ func AgentAuthMiddleware() gin.HandlerFunc {
 			c.Set("dootask_user_id", userInfoResp.Userid)
 			c.Set("is_admin", userInfoResp.IsAdmin())
 		} else {
-			authHeader := c.GetHeader("Authorization")
-			if authHeader == "" {
-				response.UnauthorizedWithCode(c, i18n.ErrCodeTokenInvalid)
+			tokenString := BearerToken(c)
+			if tokenString == "" {
+				response.UnauthorizedWithCode(c, i18n.ErrCodeUnauthenticated)
 				c.Abort()
 				return
 			}
-			c.Set("agent_id", 1)
-			c.Set("username", "客服0001")
+			claims, err := service.Auth.ParseToken(tokenString)
+			if err != nil {
+				if i18nErr, ok := err.(*i18n.ErrorInfo); ok {
+					response.UnauthorizedWithCode(c, i18nErr.Code)
+				} else {
+					response.UnauthorizedWithCode(c, i18n.ErrCodeTokenInvalid)
+				}
+				c.Abort()
+				return
+			}
+			c.Set("agent_id", claims.AgentID)
+			c.Set("username", claims.Username)
+			c.Set("session_id", claims.ID)
+			c.Set("is_admin", claims.IsAdmin)
 		}
 
 		// 将客服信息存储到上下文中
@@ -70,14 +82,12 @@ func AgentAuthMiddleware() gin.HandlerFunc {
 
 func AdminAuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		if config.Cfg.App.Mode == "dootask" {
-			isAdmin := c.GetBool("is_admin")
-			if !isAdmin {
+		isAdmin := c.GetBool("is_admin")
+		if !isAdmin {
 			response.ForbiddenWithCode(c, i18n.ErrCodePermissionDenied)
 			c.Abort()
 			return
 		}
-		}
 
 		// 将客服信息存储到上下文中
 		// c.Set("agent_id", claims.AgentID)
@@ -120,6 +130,18 @@ func Token(c *gin.Context) string {
 	return token
 }
 
+// BearerToken 获取独立模式的访问令牌（Authorization: Bearer，WebSocket通过token参数传递）
+func BearerToken(c *gin.Context) string {
+	authHeader := c.GetHeader("Authorization")
+	if token, found := strings.CutPrefix(authHeader, "Bearer "); found {
+		return strings.TrimSpace(token)
+	}
+	if authHeader != "" {
+		return strings.TrimSpace(authHeader)
+	}
+	return Input(c, "token")
+}
+
 // Version 获取Version（Header、Query、Cookie）
 func Version(c *gin.Context) string {
 	token := c.GetHeader("version")
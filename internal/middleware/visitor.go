@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+// VisitorCookieName 访客令牌Cookie名称
+const VisitorCookieName = "cs_visitor"
+
+// VisitorToken 获取访客令牌（Header、Query/Form、Cookie）
+func VisitorToken(c *gin.Context) string {
+	token := c.GetHeader("X-Visitor-Token")
+	if token == "" {
+		token = Input(c, "visitor_token")
+	}
+	if token == "" {
+		token = Cookie(c, VisitorCookieName)
+	}
+	return token
+}
+
+// SetVisitorCookie 将访客令牌写入Cookie，有效期一年
+func SetVisitorCookie(c *gin.Context, token string) {
+	c.SetCookie(VisitorCookieName, token, 365*24*3600, "/", "", IsSecure(c), true)
+}
+
+// IsSecure 判断当前请求是否为HTTPS
+func IsSecure(c *gin.Context) bool {
+	return Scheme(c) == "https://"
+}
+
+// ConversationToken 获取对话访问令牌（Header、Query/Form）
+// WebSocket无法设置自定义Header，通过conv_token参数传递
+func ConversationToken(c *gin.Context) string {
+	token := c.GetHeader("X-Conversation-Token")
+	if token == "" {
+		token = Input(c, "conv_token")
+	}
+	return token
+}
@@ -0,0 +1,79 @@
+package models
+
+import "gorm.io/gorm"
+
+// 客服在线状态
+const (
+	PresenceOnline  = "online"  // 在线，可接待新对话
+	PresenceAway    = "away"    // 离开，不分配新对话
+	PresenceBusy    = "busy"    // 忙碌，不分配新对话
+	PresenceOffline = "offline" // 离线：没有连接或手动隐身
+)
+
+// ValidPresenceStatus 是否为可手动设置的在线状态
+func ValidPresenceStatus(status string) bool {
+	switch status {
+	case PresenceOnline, PresenceAway, PresenceBusy, PresenceOffline:
+		return true
+	}
+	return false
+}
+
+// EffectivePresence 结合连接情况计算客服实际的在线状态，没有连接时始终为离线
+func EffectivePresence(manual string, connected bool) string {
+	if !connected {
+		return PresenceOffline
+	}
+	if manual == "" {
+		return PresenceOnline
+	}
+	return manual
+}
+
+// HasCapacity 客服当前接待的对话数是否低于上限
+func (a *Agent) HasCapacity(activeChats int64) bool {
+	return a.MaxConcurrentChats <= 0 || activeChats < int64(a.MaxConcurrentChats)
+}
+
+// CountActiveChats 统计客服进行中的对话数
+func CountActiveChats(db *gorm.DB) (map[uint]int64, error) {
+	type agentLoad struct {
+		AgentID uint
+		Total   int64
+	}
+	var loads []agentLoad
+	err := db.Model(&Conversations{}).
+		Select("agent_id, count(*) as total").
+		Where("status IN ? AND agent_id > 0", ActiveConversationStatuses).
+		Group("agent_id").
+		Scan(&loads).Error
+	if err != nil {
+		return nil, err
+	}
+
+	loadMap := make(map[uint]int64, len(loads))
+	for _, load := range loads {
+		loadMap[load.AgentID] = load.Total
+	}
+	return loadMap, nil
+}
+
+// AgentPresence 推送与查询使用的客服在线状态
+type AgentPresence struct {
+	AgentID            uint   `json:"agent_id"`
+	Name               string `json:"name"`
+	Avatar             string `json:"avatar"`
+	Status             string `json:"status"`                         // 实际在线状态
+	ActiveChats        int64  `json:"active_chats,omitempty"`         // 进行中的对话数，推送给客户时不返回
+	MaxConcurrentChats int    `json:"max_concurrent_chats,omitempty"` // 最大同时接待对话数，0表示不限制
+}
+
+// SetPresenceStatusRequest 设置在线状态请求结构体
+type SetPresenceStatusRequest struct {
+	Status string `json:"status" binding:"required"` // online, away, busy, offline
+}
+
+// SetMaxChatsRequest 设置最大同时接待对话数请求结构体
+type SetMaxChatsRequest struct {
+	MaxConcurrentChats *int `json:"max_concurrent_chats" binding:"required"` // 0表示不限制
+}
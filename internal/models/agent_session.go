@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// AgentSession 客服登录会话（独立模式），用于刷新令牌和注销
+type AgentSession struct {
+	ID               uint       `gorm:"primaryKey" json:"id"`
+	SessionID        string     `gorm:"column:session_id;size:64;uniqueIndex;not null" json:"session_id"` // 会话标识，写入JWT的jti
+	AgentID          uint       `gorm:"column:agent_id;index;not null" json:"agent_id"`                   // 客服ID
+	RefreshTokenHash string     `gorm:"column:refresh_token_hash;size:64;index" json:"-"`                 // 刷新令牌哈希
+	ExpiresAt        time.Time  `gorm:"column:expires_at" json:"expires_at"`                              // 刷新令牌过期时间
+	RevokedAt        *time.Time `gorm:"column:revoked_at" json:"revoked_at"`                              // 注销时间
+	IP               string     `gorm:"column:ip" json:"ip"`                                              // 登录IP
+	UserAgent        string     `gorm:"column:user_agent" json:"user_agent"`                              // 登录设备
+	CreatedAt        time.Time  `gorm:"column:created_at" json:"created_at"`                              // 创建时间
+	UpdatedAt        time.Time  `gorm:"column:updated_at" json:"updated_at"`                              // 更新时间
+}
+
+// TableName 指定表名
+func (m *AgentSession) TableName() string {
+	return "cs_agent_sessions"
+}
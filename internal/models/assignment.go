@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// AssignmentTimeout 自动分配后等待客服回复的截止时间，超时未回复转交备用客服
+// 截止时间入库保存，服务重启后仍可继续转交
+type AssignmentTimeout struct {
+	ID              uint      `gorm:"primaryKey" json:"id"`
+	ConversationID  uint      `gorm:"column:conversation_id;uniqueIndex;not null" json:"conversation_id"` // 对话ID
+	AgentID         uint      `gorm:"column:agent_id;not null" json:"agent_id"`                           // 被分配的客服ID
+	FallbackAgentID uint      `gorm:"column:fallback_agent_id;not null" json:"fallback_agent_id"`         // 备用客服ID
+	AssignedAt      time.Time `gorm:"column:assigned_at" json:"assigned_at"`                              // 分配时间，此后的公开回复视为已响应
+	DeadlineAt      time.Time `gorm:"column:deadline_at;index" json:"deadline_at"`                        // 截止时间
+}
+
+// TableName 指定表名
+func (m *AssignmentTimeout) TableName() string {
+	return "cs_assignment_timeouts"
+}
+
+// AssignmentCursor 轮询分配的游标，记录上一次轮询分配到的客服
+type AssignmentCursor struct {
+	Name      string    `gorm:"column:name;primaryKey;size:64" json:"name"` // 游标名称
+	AgentID   uint      `gorm:"column:agent_id;default:0" json:"agent_id"`  // 上一次分配到的客服ID
+	UpdatedAt time.Time `gorm:"column:updated_at" json:"updated_at"`        // 更新时间
+}
+
+// TableName 指定表名
+func (m *AssignmentCursor) TableName() string {
+	return "cs_assignment_cursors"
+}
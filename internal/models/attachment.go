@@ -0,0 +1,44 @@
+package models
+
+import "time"
+
+// Attachment 消息附件，文件内容保存在附件存储中
+type Attachment struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	Key            string    `gorm:"column:access_key;size:64;uniqueIndex;not null" json:"key"`    // 访问标识（随机字符串，用于下载地址）
+	ConversationID uint      `gorm:"column:conversation_id;index;not null" json:"conversation_id"` // 所属对话ID
+	MessageID      uint      `gorm:"column:message_id;index;default:0" json:"message_id"`          // 关联消息ID
+	Uploader       string    `gorm:"column:uploader;size:16;not null" json:"uploader"`             // 上传者类型：agent, customer
+	UploaderID     uint      `gorm:"column:uploader_id;default:0" json:"uploader_id"`              // 上传者ID
+	Name           string    `gorm:"column:name;size:255" json:"name"`                             // 原始文件名
+	MimeType       string    `gorm:"column:mime_type;size:128" json:"mime_type"`                   // 文件类型
+	Size           int64     `gorm:"column:size" json:"size"`                                      // 文件大小（字节）
+	StorageKey     string    `gorm:"column:storage_key;size:255;not null" json:"-"`                // 存储路径
+	ThumbnailKey   string    `gorm:"column:thumbnail_key;size:255" json:"-"`                       // 缩略图存储路径，非图片为空
+	ThumbnailMime  string    `gorm:"column:thumbnail_mime;size:32" json:"-"`                       // 缩略图文件类型
+	Width          int       `gorm:"column:width;default:0" json:"width"`                          // 图片宽度
+	Height         int       `gorm:"column:height;default:0" json:"height"`                        // 图片高度
+	CreatedAt      time.Time `gorm:"column:created_at" json:"created_at"`                          // 创建时间
+}
+
+// TableName 指定表名
+func (a *Attachment) TableName() string {
+	return "cs_attachments"
+}
+
+// AttachmentRef 消息元数据中的附件引用
+type AttachmentRef struct {
+	Key          string `json:"key"`
+	Name         string `json:"name"`
+	MimeType     string `json:"mime_type"`
+	Size         int64  `json:"size"`
+	URL          string `json:"url"`
+	ThumbnailURL string `json:"thumbnail_url,omitempty"`
+	Width        int    `json:"width,omitempty"`
+	Height       int    `json:"height,omitempty"`
+}
+
+// AttachmentMetadata 附件消息的元数据（Message.Metadata）
+type AttachmentMetadata struct {
+	Attachment AttachmentRef `json:"attachment"`
+}
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// AutoReplyTask 自动回复任务，持久化到期时间以便重启后继续执行
+type AutoReplyTask struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	ConversationID uint      `gorm:"column:conversation_id;not null;index" json:"conversation_id"` // 所属会话ID
+	Message        string    `gorm:"column:message;type:text" json:"message"`                      // 自动回复内容
+	DueAt          time.Time `gorm:"column:due_at;not null;index" json:"due_at"`                   // 到期时间
+	Status         string    `gorm:"column:status;default:'pending';index" json:"status"`          // 状态：pending, sending, sent, cancelled, failed
+	Attempts       int       `gorm:"column:attempts;default:0" json:"attempts"`                    // 发送失败次数
+	CreatedAt      time.Time `gorm:"column:created_at" json:"created_at"`                          // 创建时间
+	UpdatedAt      time.Time `gorm:"column:updated_at" json:"updated_at"`                          // 更新时间
+}
+
+// TableName 指定表名
+func (m *AutoReplyTask) TableName() string {
+	return "cs_auto_reply_tasks"
+}
@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// CannedResponse 快捷回复，客服输入 #快捷短语 或选择快捷回复时展开为预设内容
+// 内容支持变量：{{customer.name}}、{{customer.email}}、{{customer.phone}}、{{conversation.title}}、{{conversation.uuid}}、{{conversation.source}}
+type CannedResponse struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Shortcut  string    `gorm:"column:shortcut;size:64;uniqueIndex:idx_canned_shortcut;not null" json:"shortcut"`       // 快捷短语，不含 #
+	SourceKey string    `gorm:"column:source_key;size:64;uniqueIndex:idx_canned_shortcut;default:''" json:"source_key"` // 来源标识，为空表示全局可用
+	Title     string    `gorm:"column:title;size:100" json:"title"`                                                     // 标题
+	Content   string    `gorm:"column:content;type:text;not null" json:"content"`                                       // 回复内容
+	CreatedBy uint      `gorm:"column:created_by;default:0" json:"created_by"`                                          // 创建人客服ID
+	CreatedAt time.Time `gorm:"column:created_at" json:"created_at"`                                                    // 创建时间
+	UpdatedAt time.Time `gorm:"column:updated_at" json:"updated_at"`                                                    // 更新时间
+}
+
+// TableName 指定表名
+func (m *CannedResponse) TableName() string {
+	return "cs_canned_responses"
+}
+
+// CannedResponseRequest 创建或更新快捷回复请求结构
+type CannedResponseRequest struct {
+	Shortcut  string `json:"shortcut" binding:"required"` // 快捷短语，可带 # 前缀
+	SourceKey string `json:"source_key"`                  // 来源标识，为空表示全局可用
+	Title     string `json:"title"`                       // 标题
+	Content   string `json:"content" binding:"required"`  // 回复内容
+}
+
+// CannedResponseQuery 快捷回复列表查询参数
+type CannedResponseQuery struct {
+	SourceKey string `form:"source_key"` // 来源标识，指定时返回全局及该来源的快捷回复
+	Keyword   string `form:"keyword"`    // 按快捷短语、标题或内容搜索
+}
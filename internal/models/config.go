@@ -52,6 +52,9 @@ type AuthConfig struct {
 	TokenExpireHours       int    `json:"token_expire_hours" default:"24"`         // 令牌过期时间（小时）
 	RequireAgentAuth       bool   `json:"require_agent_auth" default:"true"`       // 是否要求客服认证
 	AllowCustomerAnonymous bool   `json:"allow_customer_anonymous" default:"true"` // 是否允许客户匿名访问
+	ConversationTokenHours int    `json:"conversation_token_hours" default:"720"`  // 对话访问令牌过期时间（小时）
+	VisitorTokenHours      int    `json:"visitor_token_hours" default:"720"`       // 访客令牌过期时间（小时），客户发起对话时续期
+	RefreshExpireHours     int    `json:"refresh_expire_hours" default:"720"`      // 刷新令牌过期时间（小时）
 }
 
 // config_key = customer_service_config
@@ -73,6 +76,9 @@ type CustomerServiceConfigData struct {
 	// 客服分配设置
 	AgentAssignment AgentAssignmentData `json:"agent_assignment"`
 
+	// 满意度评价设置
+	CSAT CSATData `json:"csat"`
+
 	// 界面设置
 	UI UIData `json:"ui"`
 
@@ -96,6 +102,11 @@ type WorkingHoursData struct {
 	StartTime string `json:"start_time"` // 格式: "HH:MM"
 	EndTime   string `json:"end_time"`   // 格式: "HH:MM"
 	WorkDays  []int  `json:"work_days"`  // 0-6, 0表示周日
+	Timezone  string `json:"timezone"`   // IANA时区，如 "Asia/Shanghai"，为空时使用服务器时区
+
+	// 例外日历，日期格式: "YYYY-MM-DD"
+	Holidays        []string `json:"holidays"`          // 节假日，当天不上班
+	SpecialWorkDays []string `json:"special_work_days"` // 调休上班日，当天按工作日处理
 }
 
 // 自动回复设置子结构
@@ -0,0 +1,74 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 对话参与者角色
+const (
+	ParticipantRoleOwner       = "owner"       // 负责客服，与对话的 AgentID 一致
+	ParticipantRoleParticipant = "participant" // 受邀协助的客服
+)
+
+// ConversationParticipant 对话参与客服，离开后保留记录
+type ConversationParticipant struct {
+	ID             uint       `gorm:"primaryKey" json:"id"`
+	ConversationID uint       `gorm:"column:conversation_id;uniqueIndex:idx_conversation_participant;not null" json:"conversation_id"` // 对话ID
+	AgentID        uint       `gorm:"column:agent_id;uniqueIndex:idx_conversation_participant;index;not null" json:"agent_id"`         // 客服ID
+	Role           string     `gorm:"column:role;size:16;default:'participant'" json:"role"`                                           // 角色：owner, participant
+	InvitedBy      uint       `gorm:"column:invited_by;default:0" json:"invited_by"`                                                   // 邀请或转接操作人客服ID，0表示系统分配
+	JoinedAt       time.Time  `gorm:"column:joined_at" json:"joined_at"`                                                               // 加入时间
+	LeftAt         *time.Time `gorm:"column:left_at" json:"left_at"`                                                                   // 离开时间，为空表示仍在对话中
+	AgentName      string     `gorm:"-" json:"agent_name"`                                                                             // 客服名称（不入库）
+	AgentAvatar    string     `gorm:"-" json:"agent_avatar"`                                                                           // 客服头像（不入库）
+	CreatedAt      time.Time  `gorm:"column:created_at" json:"created_at"`                                                             // 创建时间
+	UpdatedAt      time.Time  `gorm:"column:updated_at" json:"updated_at"`                                                             // 更新时间
+}
+
+// TableName 指定表名
+func (m *ConversationParticipant) TableName() string {
+	return "cs_conversation_participants"
+}
+
+// JoinConversation 将客服加入对话，已离开的客服重新加入；返回本次是否为新加入
+func JoinConversation(db *gorm.DB, conversationID, agentID uint, role string, invitedBy uint) (bool, error) {
+	now := time.Now()
+	var participant ConversationParticipant
+	err := db.Where("conversation_id = ? AND agent_id = ?", conversationID, agentID).First(&participant).Error
+	if err == gorm.ErrRecordNotFound {
+		participant = ConversationParticipant{
+			ConversationID: conversationID,
+			AgentID:        agentID,
+			Role:           role,
+			InvitedBy:      invitedBy,
+			JoinedAt:       now,
+		}
+		return true, db.Create(&participant).Error
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if participant.LeftAt == nil {
+		if participant.Role == role {
+			return false, nil
+		}
+		return false, db.Model(&participant).Update("role", role).Error
+	}
+	return true, db.Model(&participant).Updates(map[string]interface{}{
+		"role":       role,
+		"invited_by": invitedBy,
+		"joined_at":  now,
+		"left_at":    nil,
+	}).Error
+}
+
+// LeaveConversation 客服离开对话；返回客服此前是否在对话中
+func LeaveConversation(db *gorm.DB, conversationID, agentID uint) (bool, error) {
+	result := db.Model(&ConversationParticipant{}).
+		Where("conversation_id = ? AND agent_id = ? AND left_at IS NULL", conversationID, agentID).
+		Update("left_at", time.Now())
+	return result.RowsAffected > 0, result.Error
+}
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// ConversationRead 对话已读游标，记录每个参与者已读到的最后一条消息
+type ConversationRead struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	ConversationID uint      `gorm:"column:conversation_id;uniqueIndex:idx_conversation_reader;not null" json:"conversation_id"` // 对话ID
+	ReaderType     string    `gorm:"column:reader_type;size:16;uniqueIndex:idx_conversation_reader;not null" json:"reader_type"` // 读者类型：agent, customer
+	ReaderID       string    `gorm:"column:reader_id;size:64;uniqueIndex:idx_conversation_reader;not null" json:"reader_id"`     // 读者ID，客服为WebSocket客服标识，客户为客户ID
+	LastReadID     uint      `gorm:"column:last_read_id;default:0" json:"last_read_id"`                                          // 已读到的消息ID
+	ReadAt         time.Time `gorm:"column:read_at" json:"read_at"`                                                              // 最后已读时间
+	CreatedAt      time.Time `gorm:"column:created_at" json:"created_at"`                                                        // 创建时间
+	UpdatedAt      time.Time `gorm:"column:updated_at" json:"updated_at"`                                                        // 更新时间
+}
+
+// TableName 指定表名
+func (m *ConversationRead) TableName() string {
+	return "cs_conversation_reads"
+}
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"strconv"
 	"time"
 
 	"gorm.io/gorm"
@@ -8,17 +9,21 @@ import (
 
 // Agent 客服人员模型
 type Agent struct {
-	ID            uint           `gorm:"primaryKey" json:"id"`
-	Username      string         `gorm:"column:username;not null" json:"username"`      // 用户名
-	Name          string         `gorm:"column:name" json:"name"`                       // 显示名称
-	Avatar        string         `gorm:"column:avatar" json:"avatar"`                   // 头像URL
-	Token         string         `gorm:"column:token" json:"-"`                         // 认证令牌，JSON响应中不返回
-	DooTaskUserID int            `gorm:"column:dootask_user_id" json:"dootask_user_id"` // Dootask 用户ID
-	LastLogin     *time.Time     `gorm:"column:last_login" json:"last_login"`           // 最后登录时间
-	Status        string         `gorm:"column:status;default:'active'" json:"status"`  // 状态：active, inactive
-	CreatedAt     time.Time      `gorm:"column:created_at" json:"created_at"`           // 创建时间
-	UpdatedAt     time.Time      `gorm:"column:updated_at" json:"updated_at"`           // 更新时间
-	DeletedAt     gorm.DeletedAt `gorm:"column:deleted_at" json:"deleted_at"`           // 删除时间（软删除）
+	ID                 uint           `gorm:"primaryKey" json:"id"`
+	Username           string         `gorm:"column:username;not null" json:"username"`                               // 用户名
+	Name               string         `gorm:"column:name" json:"name"`                                                // 显示名称
+	Avatar             string         `gorm:"column:avatar" json:"avatar"`                                            // 头像URL
+	Token              string         `gorm:"column:token" json:"-"`                                                  // 认证令牌，JSON响应中不返回
+	PasswordHash       string         `gorm:"column:password_hash" json:"-"`                                          // 密码哈希（独立模式登录使用）
+	IsAdmin            bool           `gorm:"column:is_admin;default:false" json:"is_admin"`                          // 是否为管理员
+	DooTaskUserID      int            `gorm:"column:dootask_user_id" json:"dootask_user_id"`                          // Dootask 用户ID
+	LastLogin          *time.Time     `gorm:"column:last_login" json:"last_login"`                                    // 最后登录时间
+	Status             string         `gorm:"column:status;default:'active'" json:"status"`                           // 状态：active, inactive
+	PresenceStatus     string         `gorm:"column:presence_status;size:16;default:'online'" json:"presence_status"` // 手动设置的在线状态：online, away, busy, offline
+	MaxConcurrentChats int            `gorm:"column:max_concurrent_chats;default:0" json:"max_concurrent_chats"`      // 最大同时接待对话数，0表示不限制
+	CreatedAt          time.Time      `gorm:"column:created_at" json:"created_at"`                                    // 创建时间
+	UpdatedAt          time.Time      `gorm:"column:updated_at" json:"updated_at"`                                    // 更新时间
+	DeletedAt          gorm.DeletedAt `gorm:"column:deleted_at" json:"deleted_at"`                                    // 删除时间（软删除）
 }
 
 // TableName 指定表名
@@ -26,19 +31,29 @@ func (a *Agent) TableName() string {
 	return "cs_agents"
 }
 
+// WsKey 返回客服在WebSocket连接中的标识，绑定了DooTask用户时使用DooTask用户ID
+func (a *Agent) WsKey() string {
+	if a.DooTaskUserID > 0 {
+		return strconv.Itoa(a.DooTaskUserID)
+	}
+	return strconv.FormatUint(uint64(a.ID), 10)
+}
+
 // Customer 客户模型
 type Customer struct {
 	ID           uint           `gorm:"primaryKey" json:"id"`
-	UUID         string         `gorm:"column:uuid;uniqueIndex;not null" json:"uuid"`        // 客户唯一标识
-	Name         string         `gorm:"column:name" json:"name"`                             // 客户名称（可选）
-	Email        string         `gorm:"column:email" json:"email"`                           // 电子邮件（可选）
-	Phone        string         `gorm:"column:phone" json:"phone"`                           // 电话号码（可选）
-	IP           string         `gorm:"column:ip" json:"ip"`                                 // IP地址
-	UserAgent    string         `gorm:"column:user_agent;type:text" json:"user_agent"`       // 用户代理
-	CustomFields string         `gorm:"column:custom_fields;type:text" json:"custom_fields"` // 自定义字段（JSON格式）
-	CreatedAt    time.Time      `gorm:"column:created_at" json:"created_at"`                 // 创建时间
-	UpdatedAt    time.Time      `gorm:"column:updated_at" json:"updated_at"`                 // 更新时间
-	DeletedAt    gorm.DeletedAt `gorm:"column:deleted_at" json:"deleted_at"`                 // 删除时间（软删除）
+	UUID         string         `gorm:"column:uuid;uniqueIndex;not null" json:"uuid"`                      // 客户唯一标识
+	SourceKey    string         `gorm:"column:source_key;index:idx_customer_external" json:"source_key"`   // 已验证身份的来源标识
+	ExternalID   string         `gorm:"column:external_id;index:idx_customer_external" json:"external_id"` // 宿主站点的用户ID（已验证身份）
+	Name         string         `gorm:"column:name" json:"name"`                                           // 客户名称（可选）
+	Email        string         `gorm:"column:email" json:"email"`                                         // 电子邮件（可选）
+	Phone        string         `gorm:"column:phone" json:"phone"`                                         // 电话号码（可选）
+	IP           string         `gorm:"column:ip" json:"ip"`                                               // IP地址
+	UserAgent    string         `gorm:"column:user_agent;type:text" json:"user_agent"`                     // 用户代理
+	CustomFields string         `gorm:"column:custom_fields;type:text" json:"custom_fields"`               // 自定义字段（JSON格式）
+	CreatedAt    time.Time      `gorm:"column:created_at" json:"created_at"`                               // 创建时间
+	UpdatedAt    time.Time      `gorm:"column:updated_at" json:"updated_at"`                               // 更新时间
+	DeletedAt    gorm.DeletedAt `gorm:"column:deleted_at" json:"deleted_at"`                               // 删除时间（软删除）
 }
 
 // TableName 指定表名
@@ -48,14 +63,26 @@ func (c *Customer) TableName() string {
 
 // Message 消息结构体（优化版）
 type Message struct {
-	ID             uint      `gorm:"column:id;primaryKey;autoIncrement" json:"id"`           // 消息ID
-	ConversationID uint      `gorm:"column:conversation_id;not null" json:"conversation_id"` // 所属会话ID
-	Content        string    `gorm:"column:content;type:text;not null" json:"content"`       // 消息内容
-	Sender         string    `gorm:"column:sender;not null" json:"sender"`                   // 发送者类型('agent','customer')
-	SenderID       uint      `gorm:"column:sender_id;default:0" json:"sender_id"`            // 发送者ID
-	Type           string    `gorm:"column:type;default:'text'" json:"type"`                 // 消息类型：text, image, file, system
-	Metadata       string    `gorm:"column:metadata;type:text" json:"metadata"`              // 元数据（JSON格式，可存储附加信息）
-	CreatedAt      time.Time `gorm:"column:created_at" json:"created_at"`                    // 创建时间
+	ID             uint      `gorm:"column:id;primaryKey;autoIncrement" json:"id"`                 // 消息ID
+	ConversationID uint      `gorm:"column:conversation_id;not null" json:"conversation_id"`       // 所属会话ID
+	Content        string    `gorm:"column:content;type:text;not null" json:"content"`             // 消息内容
+	Sender         string    `gorm:"column:sender;not null" json:"sender"`                         // 发送者类型('agent','customer')
+	SenderID       uint      `gorm:"column:sender_id;default:0" json:"sender_id"`                  // 发送者ID
+	Type           string    `gorm:"column:type;default:'text'" json:"type"`                       // 消息类型：text, image, file, system
+	Metadata       string    `gorm:"column:metadata;type:text" json:"metadata"`                    // 元数据（JSON格式，可存储附加信息）
+	Visibility     string    `gorm:"column:visibility;size:16;default:'public'" json:"visibility"` // 可见范围：public, internal
+	CreatedAt      time.Time `gorm:"column:created_at" json:"created_at"`                          // 创建时间
+}
+
+// 消息可见范围
+const (
+	MessageVisibilityPublic   = "public"   // 客户与客服均可见
+	MessageVisibilityInternal = "internal" // 内部备注，仅客服可见
+)
+
+// IsInternal 是否为仅客服可见的内部备注
+func (m *Message) IsInternal() bool {
+	return m.Visibility == MessageVisibilityInternal
 }
 
 // TableName 指定表名
@@ -65,24 +92,56 @@ func (m *Message) TableName() string {
 
 // Conversations 会话结构体（优化版）
 type Conversations struct {
-	ID              uint           `gorm:"primaryKey" json:"id"`
-	Uuid            string         `gorm:"column:uuid;uniqueIndex;not null" json:"uuid"`
-	AgentID         uint           `gorm:"column:agent_id;default:0" json:"agent_id"`            // 客服ID
-	CustomerID      uint           `gorm:"column:customer_id;default:0" json:"customer_id"`      // 客户ID
-	Title           string         `gorm:"column:title" json:"title"`                            // 会话标题
-	Status          string         `gorm:"column:status;default:'open'" json:"status"`           // 状态：open, closed
-	Source          string         `gorm:"column:source;default:'widget'" json:"source"`         // 来源：widget, api, etc.
-	SourceKey       string         `gorm:"column:source_key;default:'widget'" json:"source_key"` // 来源：widget, api, etc.
-	LastMessage     string         `gorm:"column:last_message" json:"last_message"`              // 最后一条消息内容
-	LastMessageAt   *time.Time     `gorm:"column:last_message_at" json:"last_message_at"`        // 最后消息时间
-	DooTaskDialogID int            `gorm:"column:dootask_dialog_id" json:"dootask_dialog_id"`    // Dootask 会话ID
-	DooTaskTaskID   int            `gorm:"column:dootask_task_id" json:"dootask_task_id"`        // Dootask 任务ID
-	CreatedAt       time.Time      `gorm:"column:created_at" json:"created_at"`                  // 创建时间
-	UpdatedAt       time.Time      `gorm:"column:updated_at" json:"updated_at"`                  // 更新时间
-	DeletedAt       gorm.DeletedAt `gorm:"column:deleted_at" json:"deleted_at"`                  // 删除时间（软删除）
+	ID                 uint                   `gorm:"primaryKey" json:"id"`
+	Uuid               string                 `gorm:"column:uuid;uniqueIndex;not null" json:"uuid"`
+	AgentID            uint                   `gorm:"column:agent_id;default:0" json:"agent_id"`                 // 客服ID
+	CustomerID         uint                   `gorm:"column:customer_id;default:0" json:"customer_id"`           // 客户ID
+	Title              string                 `gorm:"column:title" json:"title"`                                 // 会话标题
+	Status             string                 `gorm:"column:status;default:'open'" json:"status"`                // 状态：open, closed
+	Source             string                 `gorm:"column:source;default:'widget'" json:"source"`              // 来源：widget, api, etc.
+	SourceKey          string                 `gorm:"column:source_key;default:'widget'" json:"source_key"`      // 来源：widget, api, etc.
+	LastMessage        string                 `gorm:"column:last_message" json:"last_message"`                   // 最后一条消息内容
+	LastMessageAt      *time.Time             `gorm:"column:last_message_at" json:"last_message_at"`             // 最后消息时间
+	DooTaskDialogID    int                    `gorm:"column:dootask_dialog_id" json:"dootask_dialog_id"`         // Dootask 会话ID
+	DooTaskTaskID      int                    `gorm:"column:dootask_task_id" json:"dootask_task_id"`             // Dootask 任务ID
+	TokenVersion       int                    `gorm:"column:token_version;default:0" json:"-"`                   // 访问令牌版本，递增后旧令牌失效
+	ClosedBy           uint                   `gorm:"column:closed_by;default:0" json:"closed_by"`               // 关闭对话的客服ID
+	ClosedAt           *time.Time             `gorm:"column:closed_at" json:"closed_at"`                         // 关闭时间
+	FirstResponseAt    *time.Time             `gorm:"column:first_response_at" json:"first_response_at"`         // 客服首次回复客户的时间
+	Priority           string                 `gorm:"column:priority;size:16;default:'normal'" json:"priority"`  // 优先级：low, normal, high, urgent
+	ReopenedAt         *time.Time             `gorm:"column:reopened_at" json:"reopened_at"`                     // 最近一次重新打开的时间
+	FirstResponseDueAt *time.Time             `gorm:"column:first_response_due_at" json:"first_response_due_at"` // SLA 首次响应截止时间
+	NextResponseDueAt  *time.Time             `gorm:"column:next_response_due_at" json:"next_response_due_at"`   // SLA 后续响应截止时间
+	ResolutionDueAt    *time.Time             `gorm:"column:resolution_due_at" json:"resolution_due_at"`         // SLA 解决截止时间
+	UnreadCount        int64                  `gorm:"-" json:"unread_count"`                                     // 当前客服未读消息数（不入库）
+	Tags               []Tag                  `gorm:"-" json:"tags,omitempty"`                                   // 对话标签（不入库）
+	Fields             map[string]interface{} `gorm:"-" json:"fields,omitempty"`                                 // 自定义字段取值（不入库）
+	CreatedAt          time.Time              `gorm:"column:created_at" json:"created_at"`                       // 创建时间
+	UpdatedAt          time.Time              `gorm:"column:updated_at" json:"updated_at"`                       // 更新时间
+	DeletedAt          gorm.DeletedAt         `gorm:"column:deleted_at" json:"deleted_at"`                       // 删除时间（软删除）
 }
 
 // TableName 指定表名
 func (m *Conversations) TableName() string {
 	return "cs_conversations"
 }
+
+// 对话状态
+const (
+	ConversationStatusOpen    = "open"    // 进行中
+	ConversationStatusOffline = "offline" // 非工作时间的离线留言，客服回复后恢复为进行中
+	ConversationStatusClosed  = "closed"  // 已关闭
+)
+
+// ActiveConversationStatuses 仍需客服接待的对话状态，计入客服负载、在线状态推送、自动回复与超时转交
+var ActiveConversationStatuses = []string{ConversationStatusOpen, ConversationStatusOffline}
+
+// IsActive 对话是否仍需客服接待
+func (m *Conversations) IsActive() bool {
+	for _, status := range ActiveConversationStatuses {
+		if m.Status == status {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,62 @@
+package models
+
+import "time"
+
+// 自定义字段类型
+const (
+	CustomFieldTypeText   = "text"   // 文本
+	CustomFieldTypeSelect = "select" // 单选，取值限定在选项中
+	CustomFieldTypeNumber = "number" // 数字
+	CustomFieldTypeDate   = "date"   // 日期，格式 2006-01-02
+)
+
+// CustomFieldDateLayout 日期类型自定义字段的取值格式
+const CustomFieldDateLayout = "2006-01-02"
+
+// CustomField 对话自定义字段定义，由管理员维护
+type CustomField struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Key       string    `gorm:"column:field_key;size:64;uniqueIndex;not null" json:"key"` // 字段标识，小写字母开头，只含小写字母、数字与下划线
+	Name      string    `gorm:"column:name;size:64;not null" json:"name"`                 // 显示名称
+	Type      string    `gorm:"column:type;size:16;not null" json:"type"`                 // 字段类型：text, select, number, date
+	Options   []string  `gorm:"column:options;type:text;serializer:json" json:"options"`  // 单选字段的选项
+	Required  bool      `gorm:"column:required;default:false" json:"required"`            // 是否必填，必填字段设置后不能清空
+	Sort      int       `gorm:"column:sort;default:0" json:"sort"`                        // 排序，越小越靠前
+	CreatedAt time.Time `gorm:"column:created_at" json:"created_at"`                      // 创建时间
+	UpdatedAt time.Time `gorm:"column:updated_at" json:"updated_at"`                      // 更新时间
+}
+
+// TableName 指定表名
+func (m *CustomField) TableName() string {
+	return "cs_custom_fields"
+}
+
+// ConversationFieldValue 对话的自定义字段取值，统一以字符串保存
+type ConversationFieldValue struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	ConversationID uint      `gorm:"column:conversation_id;uniqueIndex:idx_conversation_field;not null" json:"conversation_id"` // 对话ID
+	FieldID        uint      `gorm:"column:field_id;uniqueIndex:idx_conversation_field;index;not null" json:"field_id"`         // 字段ID
+	Value          string    `gorm:"column:value;type:text" json:"value"`                                                       // 字段取值
+	UpdatedBy      uint      `gorm:"column:updated_by;default:0" json:"updated_by"`                                             // 最后修改的客服ID
+	UpdatedAt      time.Time `gorm:"column:updated_at" json:"updated_at"`                                                       // 更新时间
+}
+
+// TableName 指定表名
+func (m *ConversationFieldValue) TableName() string {
+	return "cs_conversation_field_values"
+}
+
+// CustomFieldRequest 创建或更新自定义字段请求结构
+type CustomFieldRequest struct {
+	Key      string   `json:"key" binding:"required"`  // 字段标识
+	Name     string   `json:"name" binding:"required"` // 显示名称
+	Type     string   `json:"type" binding:"required"` // 字段类型：text, select, number, date
+	Options  []string `json:"options"`                 // 单选字段的选项
+	Required bool     `json:"required"`                // 是否必填
+	Sort     int      `json:"sort"`                    // 排序，越小越靠前
+}
+
+// SetConversationFieldsRequest 设置对话自定义字段请求结构，只修改提交的字段，取值为 null 或空字符串时清除
+type SetConversationFieldsRequest struct {
+	Fields map[string]interface{} `json:"fields" binding:"required"` // 字段标识到取值的映射
+}
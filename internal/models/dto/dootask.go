@@ -107,12 +107,58 @@ type CreateTaskResp struct {
 }
 
 type CreateTaskReq struct {
-	Name      string `json:"name"`
-	Content   string `json:"content"`
-	Owner     []int  `json:"owner"`
-	Assist    []int  `json:"assist"`
-	ProjectID int    `json:"project_id"`
-	ColumnID  int    `json:"column_id"`
+	Name      string    `json:"name"`
+	Content   string    `json:"content"`
+	Owner     []int     `json:"owner"`
+	Assist    []int     `json:"assist"`
+	ProjectID int       `json:"project_id"`
+	ColumnID  int       `json:"column_id"`
+	PLevel    int       `json:"p_level,omitempty"`
+	PName     string    `json:"p_name,omitempty"`
+	PColor    string    `json:"p_color,omitempty"`
+	TaskTag   []TaskTag `json:"task_tag,omitempty"`
+}
+
+// TaskTag 任务标签
+type TaskTag struct {
+	Name  string `json:"name"`
+	Color string `json:"color"`
+}
+
+// UpdateTaskLabelsReq 更新任务优先级与任务标签请求，PLevel 为0时不修改优先级
+type UpdateTaskLabelsReq struct {
+	TaskID  int       `json:"task_id"`
+	PLevel  int       `json:"p_level,omitempty"`
+	PName   string    `json:"p_name,omitempty"`
+	PColor  string    `json:"p_color,omitempty"`
+	TaskTag []TaskTag `json:"task_tag"`
+}
+
+// UpdateTaskAssistReq 更新任务协助人员请求
+type UpdateTaskAssistReq struct {
+	TaskID int   `json:"task_id"`
+	Assist []int `json:"assist"`
+}
+
+// UpdateTaskCompleteReq 标记任务完成状态请求，CompleteAt 为时间字符串表示完成，为 false 表示未完成
+type UpdateTaskCompleteReq struct {
+	TaskID     int         `json:"task_id"`
+	CompleteAt interface{} `json:"complete_at"`
+}
+
+// TaskInfoResp 任务详情（只解析需要的字段）
+type TaskInfoResp struct {
+	ID         int         `json:"id"`
+	Name       string      `json:"name"`
+	DialogID   int         `json:"dialog_id"`
+	CompleteAt interface{} `json:"complete_at"`
+	ArchivedAt interface{} `json:"archived_at"`
+}
+
+// Completed 任务是否已完成
+func (t *TaskInfoResp) Completed() bool {
+	completeAt, ok := t.CompleteAt.(string)
+	return ok && completeAt != ""
 }
 
 type TaskDialogResp struct {
@@ -0,0 +1,64 @@
+package models
+
+import "time"
+
+// 满意度评价方式
+const (
+	CSATTypeStars  = "stars"  // 1-5星
+	CSATTypeThumbs = "thumbs" // 赞(1)或踩(0)
+)
+
+// CSATData 对话关闭后的满意度评价设置
+type CSATData struct {
+	Enabled      bool   `json:"enabled"`
+	Type         string `json:"type"`          // 'stars' | 'thumbs'，默认 stars
+	Question     string `json:"question"`      // 展示给客户的问题
+	AllowComment bool   `json:"allow_comment"` // 是否允许填写评价内容
+}
+
+// ConversationRating 对话满意度评价，每个对话保存一条评价，重新打开后再次关闭时可重新评价并替换
+type ConversationRating struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	ConversationID uint      `gorm:"column:conversation_id;uniqueIndex;not null" json:"conversation_id"` // 对话ID
+	AgentID        uint      `gorm:"column:agent_id;default:0;index" json:"agent_id"`                    // 评价时的负责客服ID
+	SourceKey      string    `gorm:"column:source_key;size:64;index" json:"source_key"`                  // 来源标识
+	Type           string    `gorm:"column:type;size:16;not null" json:"type"`                           // 评价方式：stars, thumbs
+	Score          int       `gorm:"column:score;not null" json:"score"`                                 // 评分：星级为1-5，赞踩为1或0
+	Comment        string    `gorm:"column:comment;type:text" json:"comment"`                            // 评价内容
+	CreatedAt      time.Time `gorm:"column:created_at" json:"created_at"`                                // 评价时间
+}
+
+// TableName 指定表名
+func (m *ConversationRating) TableName() string {
+	return "cs_conversation_ratings"
+}
+
+// NormalizedScore 换算为1-5分，用于不同评价方式的统一统计，赞记为5分，踩记为1分
+func (m *ConversationRating) NormalizedScore() int {
+	if m.Type == CSATTypeThumbs {
+		if m.Score > 0 {
+			return 5
+		}
+		return 1
+	}
+	return m.Score
+}
+
+// Satisfied 是否为满意评价（4星及以上或赞）
+func (m *ConversationRating) Satisfied() bool {
+	return m.NormalizedScore() >= 4
+}
+
+// CSATSurvey 推送给客户的满意度评价邀请
+type CSATSurvey struct {
+	ConversationUUID string `json:"conversation_uuid"`
+	Type             string `json:"type"`
+	Question         string `json:"question"`
+	AllowComment     bool   `json:"allow_comment"`
+}
+
+// SubmitRatingRequest 提交满意度评价请求结构体
+type SubmitRatingRequest struct {
+	Score   *int   `json:"score" binding:"required"` // 星级为1-5，赞踩为1或0
+	Comment string `json:"comment"`                  // 评价内容，允许填写时有效
+}
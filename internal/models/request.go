@@ -2,28 +2,57 @@ package models
 
 // CreateConversationRequest 创建对话请求结构体
 type CreateConversationRequest struct {
-	AgentID    uint   `json:"agent_id"`
-	CustomerID uint   `json:"customer_id"`
-	Title      string `json:"title"`                   // 会话标题（可选）
-	Source     string `json:"source" default:"widget"` // 来源（可选）
+	Title        string                 `json:"title"`                   // 会话标题（可选）
+	Source       string                 `json:"source" default:"widget"` // 来源（可选）
+	VisitorToken string                 `json:"visitor_token"`           // 访客令牌（可选），用于识别回访客户
+	Name         string                 `json:"name"`                    // 客户名称（可选，售前表单；已验证身份时仅在签名覆盖时采用）
+	Email        string                 `json:"email"`                   // 电子邮件（可选，售前表单；已验证身份时仅在签名覆盖时采用）
+	Phone        string                 `json:"phone"`                   // 电话号码（可选，售前表单）
+	CustomFields map[string]interface{} `json:"custom_fields"`           // 自定义字段（可选，售前表单）
+	UserID       string                 `json:"user_id"`                 // 宿主站点用户ID（可选，身份验证）
+	UserHash     string                 `json:"user_hash"`               // HMAC-SHA256(来源密钥, user_id) 签名；同时传入姓名或邮箱时可签名 user_id + "\n" + name + "\n" + email
 }
 
 // SendMessageRequest 发送消息请求结构体
 type SendMessageRequest struct {
 	UUID     string `json:"uuid" binding:"required"`
 	Content  string `json:"content" binding:"required"`
-	Sender   string `json:"sender" binding:"required"` // "agent" 或 "customer"
-	Type     string `json:"type" default:"text"`       // 消息类型：text, image, file, system
+	Type     string `json:"type" default:"text"`       // 消息类型：text, image, file，发送者固定为客户
 	Metadata string `json:"metadata"`                  // 元数据（JSON格式，可选）
 }
 
 // SendMessageRequest 发送消息请求结构体
 type SendMessageByAgentRequest struct {
 	ID       int `json:"id" binding:"required"`
-	Content  string `json:"content" binding:"required"`
+	Content  string `json:"content"`                   // 消息内容，指定快捷回复时可为空
 	Sender   string `json:"sender" binding:"required"` // "agent" 或 "customer"
 	Type     string `json:"type" default:"text"`       // 消息类型：text, image, file, system
 	Metadata string `json:"metadata"`                  // 元数据（JSON格式，可选）
+	Internal bool   `json:"internal"`                  // 是否为内部备注，内部备注仅客服可见
+	CannedID uint   `json:"canned_id"`                 // 快捷回复ID，指定时使用展开后的快捷回复作为消息内容
+}
+
+// MarkReadRequest 标记已读请求结构体
+type MarkReadRequest struct {
+	MessageID uint `json:"message_id" binding:"required"` // 已读到的消息ID
+}
+
+// TransferConversationRequest 转接对话请求结构体
+type TransferConversationRequest struct {
+	AgentID uint   `json:"agent_id" binding:"required"` // 接手的客服ID
+	Note    string `json:"note"`                        // 转接备注（仅客服可见）
+}
+
+// InviteAgentRequest 邀请客服加入对话请求结构体
+type InviteAgentRequest struct {
+	AgentID uint `json:"agent_id" binding:"required"` // 受邀客服ID
+}
+
+// MessageHistoryQuery 消息历史查询参数，before_id 与 after_id 均为空时返回最新消息
+type MessageHistoryQuery struct {
+	BeforeID uint `form:"before_id"` // 向前翻页：返回该消息之前的消息
+	AfterID  uint `form:"after_id"`  // 同步模式：返回该消息之后的消息，用于断线重连补齐
+	Limit    int  `form:"limit"`     // 返回数量，默认20，最大100
 }
 
 // AgentLoginRequest 客服登录请求结构体
@@ -32,6 +61,20 @@ type AgentLoginRequest struct {
 	Password string `json:"password" binding:"required"` // 密码
 }
 
+// AgentRefreshRequest 刷新令牌请求结构体
+type AgentRefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"` // 刷新令牌
+}
+
+// CreateAgentRequest 创建客服账号请求结构体
+type CreateAgentRequest struct {
+	Username string `json:"username" binding:"required"` // 用户名
+	Password string `json:"password" binding:"required"` // 密码
+	Name     string `json:"name"`                        // 显示名称
+	Avatar   string `json:"avatar"`                      // 头像URL
+	IsAdmin  bool   `json:"is_admin"`                    // 是否为管理员
+}
+
 // AgentTokenRequest 客服令牌验证请求结构体
 type AgentTokenRequest struct {
 	Token string `json:"token" binding:"required"` // 认证令牌
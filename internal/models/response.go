@@ -17,9 +17,19 @@ type PaginationData struct {
 	Items    interface{} `json:"items"`     // 分页项目数据
 }
 
+// CursorData 游标分页数据结构
+type CursorData struct {
+	Items   interface{} `json:"items"`    // 数据项，按时间正序排列
+	HasMore bool        `json:"has_more"` // 查询方向上是否还有更多数据
+	FirstID uint        `json:"first_id"` // 本页第一条的ID，作为 before_id 继续向前翻页
+	LastID  uint        `json:"last_id"`  // 本页最后一条的ID，作为 after_id 继续同步
+}
+
 // ConversationResponse 对话响应数据
 type ConversationResponse struct {
-	UUID string `json:"uuid"` // 对话UUID
+	UUID         string `json:"uuid"`          // 对话UUID
+	VisitorToken string `json:"visitor_token"` // 访客令牌，回访时携带以识别客户
+	Token        string `json:"token"`         // 对话访问令牌，访问该对话的消息和WebSocket时携带
 }
 
 // MessageListResponse 消息列表响应数据
@@ -30,12 +40,15 @@ type MessageListResponse struct {
 
 // AgentLoginResponse 客服登录响应数据
 type AgentLoginResponse struct {
-	Token     string `json:"token"`      // 认证令牌
-	AgentID   uint   `json:"agent_id"`   // 客服ID
-	Username  string `json:"username"`   // 用户名
-	Name      string `json:"name"`       // 显示名称
-	Avatar    string `json:"avatar"`     // 头像URL
-	ExpiresAt int64  `json:"expires_at"` // 过期时间戳
+	Token            string `json:"token"`              // 认证令牌
+	RefreshToken     string `json:"refresh_token"`      // 刷新令牌
+	AgentID          uint   `json:"agent_id"`           // 客服ID
+	Username         string `json:"username"`           // 用户名
+	Name             string `json:"name"`               // 显示名称
+	Avatar           string `json:"avatar"`             // 头像URL
+	IsAdmin          bool   `json:"is_admin"`           // 是否为管理员
+	ExpiresAt        int64  `json:"expires_at"`         // 过期时间戳
+	RefreshExpiresAt int64  `json:"refresh_expires_at"` // 刷新令牌过期时间戳
 }
 
 // AgentInfoResponse 客服信息响应数据
@@ -45,6 +58,7 @@ type AgentInfoResponse struct {
 	Name     string `json:"name"`     // 显示名称
 	Avatar   string `json:"avatar"`   // 头像URL
 	Status   string `json:"status"`   // 状态
+	IsAdmin  bool   `json:"is_admin"` // 是否为管理员
 }
 
 type ServerConfigResp struct {
@@ -52,3 +66,11 @@ type ServerConfigResp struct {
 	DockerUrl string `json:"docker_url"`
 	Mode      string `json:"mode"`
 }
+
+// AvailabilityResponse 客服在线状态响应数据
+type AvailabilityResponse struct {
+	Online         bool             `json:"online"`          // 当前是否在工作时间内
+	AgentOnline    bool             `json:"agent_online"`    // 是否有在线且可接待的客服
+	OfflineMessage string           `json:"offline_message"` // 离线提示语，仅离线时返回
+	WorkingHours   WorkingHoursData `json:"working_hours"`   // 生效的工作时间设置
+}
@@ -0,0 +1,51 @@
+package models
+
+import "time"
+
+// 检索文档类型
+const (
+	SearchKindMessage      = "message"      // 消息内容
+	SearchKindConversation = "conversation" // 对话标题、客户信息与DooTask任务等元数据
+)
+
+// SearchDocument 全文检索文档，由消息与对话元数据生成，全文索引建立在 content 上
+type SearchDocument struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	Kind           string    `gorm:"column:kind;size:16;uniqueIndex:idx_search_ref;not null" json:"kind"` // 文档类型：message, conversation
+	RefID          uint      `gorm:"column:ref_id;uniqueIndex:idx_search_ref;not null" json:"ref_id"`     // 消息ID或对话ID
+	ConversationID uint      `gorm:"column:conversation_id;index;not null" json:"conversation_id"`        // 所属对话ID
+	Content        string    `gorm:"column:content;type:text" json:"content"`                             // 检索内容
+	CreatedAt      time.Time `gorm:"column:created_at;index" json:"created_at"`                           // 消息发送时间或对话创建时间
+}
+
+// TableName 指定表名
+func (m *SearchDocument) TableName() string {
+	return "cs_search_documents"
+}
+
+// SearchQuery 全文检索请求参数
+type SearchQuery struct {
+	Q         string `form:"q" binding:"required"` // 关键词
+	SourceKey string `form:"source_key"`           // 来源标识
+	AgentID   uint   `form:"agent_id"`             // 负责客服ID
+	Status    string `form:"status"`               // 对话状态
+	Kind      string `form:"kind"`                 // 文档类型：message, conversation，为空时不限
+	StartDate string `form:"start_date"`           // 开始日期，格式 2006-01-02
+	EndDate   string `form:"end_date"`             // 结束日期（含），格式 2006-01-02
+	Page      int    `form:"page"`                 // 页码，默认1
+	PageSize  int    `form:"page_size"`            // 每页数量，默认20，最大100
+}
+
+// SearchHit 检索结果，Snippet 中的匹配内容以 <mark></mark> 标记
+type SearchHit struct {
+	Kind             string    `json:"kind"`              // 命中的文档类型
+	ConversationID   uint      `json:"conversation_id"`   // 对话ID
+	ConversationUUID string    `json:"conversation_uuid"` // 对话UUID
+	MessageID        uint      `json:"message_id"`        // 消息ID，命中对话元数据时为0
+	Snippet          string    `json:"snippet"`           // 高亮摘要
+	Title            string    `json:"title"`             // 对话标题
+	Status           string    `json:"status"`            // 对话状态
+	SourceKey        string    `json:"source_key"`        // 来源标识
+	AgentID          uint      `json:"agent_id"`          // 负责客服ID
+	CreatedAt        time.Time `json:"created_at"`        // 消息发送时间或对话创建时间
+}
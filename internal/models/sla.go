@@ -0,0 +1,83 @@
+package models
+
+import "time"
+
+// 对话优先级
+const (
+	PriorityLow    = "low"    // 低
+	PriorityNormal = "normal" // 普通
+	PriorityHigh   = "high"   // 高
+	PriorityUrgent = "urgent" // 紧急
+)
+
+// Priorities 全部对话优先级及显示名称
+var Priorities = map[string]string{
+	PriorityLow:    "低",
+	PriorityNormal: "普通",
+	PriorityHigh:   "高",
+	PriorityUrgent: "紧急",
+}
+
+// SLA 考核指标
+const (
+	SLAMetricFirstResponse = "first_response" // 首次响应
+	SLAMetricNextResponse  = "next_response"  // 后续响应
+	SLAMetricResolution    = "resolution"     // 解决
+)
+
+// SLAMetricNames SLA 考核指标的显示名称
+var SLAMetricNames = map[string]string{
+	SLAMetricFirstResponse: "首次响应",
+	SLAMetricNextResponse:  "后续响应",
+	SLAMetricResolution:    "解决",
+}
+
+// SLA 提醒级别
+const (
+	SLALevelWarning  = "warning"  // 即将超时
+	SLALevelBreached = "breached" // 已超时
+)
+
+// SLATarget SLA 时限（分钟），0表示不考核
+type SLATarget struct {
+	FirstResponse int `json:"first_response"` // 首次响应时限：对话创建到客服首次公开回复
+	NextResponse  int `json:"next_response"`  // 后续响应时限：客户发送消息到客服再次公开回复
+	Resolution    int `json:"resolution"`     // 解决时限：对话创建或重新打开到关闭
+}
+
+// SLAData 来源的 SLA 策略
+type SLAData struct {
+	Enabled          bool                 `json:"enabled"`
+	WorkingHoursOnly bool                 `json:"working_hours_only"` // 是否只计算工作时间
+	WarningBefore    int                  `json:"warning_before"`     // 到期前多少分钟提醒，0表示不提醒
+	SLATarget                             // 默认时限
+	Priorities       map[string]SLATarget `json:"priorities"` // 按优先级覆盖的时限，未配置的优先级使用默认时限
+}
+
+// Target 获取指定优先级的 SLA 时限
+func (d SLAData) Target(priority string) SLATarget {
+	if target, ok := d.Priorities[priority]; ok {
+		return target
+	}
+	return d.SLATarget
+}
+
+// SLAAlert SLA 提醒记录，同一截止时间的同一级别提醒只发送一次
+type SLAAlert struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	ConversationID uint      `gorm:"column:conversation_id;uniqueIndex:idx_sla_alert;not null" json:"conversation_id"` // 对话ID
+	Metric         string    `gorm:"column:metric;size:32;uniqueIndex:idx_sla_alert;not null" json:"metric"`           // 考核指标：first_response, next_response, resolution
+	Level          string    `gorm:"column:level;size:16;uniqueIndex:idx_sla_alert;not null" json:"level"`             // 提醒级别：warning, breached
+	DueAt          time.Time `gorm:"column:due_at;uniqueIndex:idx_sla_alert;not null" json:"due_at"`                   // 截止时间
+	CreatedAt      time.Time `gorm:"column:created_at" json:"created_at"`                                              // 提醒时间
+}
+
+// TableName 指定表名
+func (m *SLAAlert) TableName() string {
+	return "cs_sla_alerts"
+}
+
+// SetPriorityRequest 设置对话优先级请求结构
+type SetPriorityRequest struct {
+	Priority string `json:"priority" binding:"required"` // 优先级：low, normal, high, urgent
+}
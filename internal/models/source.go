@@ -1,6 +1,7 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 
 	"gorm.io/gorm"
@@ -8,18 +9,20 @@ import (
 
 // CustomerServiceSource 客服来源模型
 type CustomerServiceSource struct {
-	ID        uint           `gorm:"primaryKey" json:"id"`
-	Name      string         `gorm:"column:name;not null;size:100" json:"name"`                        // 来源名称
-	SourceKey string         `gorm:"column:source_key;not null;uniqueIndex;size:50" json:"source_key"` // 来源唯一标识
-	TaskID    *int           `gorm:"column:task_id" json:"task_id"`                                    // DooTask任务ID
-	DialogID  *int           `gorm:"column:dialog_id" json:"dialog_id"`                                // DooTask对话ID
-	ProjectID *int           `gorm:"column:project_id" json:"project_id"`                              // DooTask项目ID
-	ColumnID  int            `gorm:"column:column_id;default:0" json:"column_id"`                      // DooTask列ID
-	Config    string         `gorm:"column:config;type:text" json:"config"`                            // 来源配置JSON
-	Status    int            `gorm:"column:status;default:1" json:"status"`                            // 状态：1-启用，0-禁用
-	CreatedAt time.Time      `gorm:"column:created_at" json:"created_at"`
-	UpdatedAt time.Time      `gorm:"column:updated_at" json:"updated_at"`
-	DeletedAt gorm.DeletedAt `gorm:"column:deleted_at;index" json:"-"`
+	ID                   uint           `gorm:"primaryKey" json:"id"`
+	Name                 string         `gorm:"column:name;not null;size:100" json:"name"`                               // 来源名称
+	SourceKey            string         `gorm:"column:source_key;not null;uniqueIndex;size:50" json:"source_key"`        // 来源唯一标识
+	TaskID               *int           `gorm:"column:task_id" json:"task_id"`                                           // DooTask任务ID
+	DialogID             *int           `gorm:"column:dialog_id" json:"dialog_id"`                                       // DooTask对话ID
+	ProjectID            *int           `gorm:"column:project_id" json:"project_id"`                                     // DooTask项目ID
+	ColumnID             int            `gorm:"column:column_id;default:0" json:"column_id"`                             // DooTask列ID
+	Config               string         `gorm:"column:config;type:text" json:"config"`                                   // 来源配置JSON
+	Status               int            `gorm:"column:status;default:1" json:"status"`                                   // 状态：1-启用，0-禁用
+	Secret               string         `gorm:"column:secret;size:64" json:"secret"`                                     // 身份验证密钥，宿主站点用其对用户ID签名
+	IdentityVerification bool           `gorm:"column:identity_verification;default:false" json:"identity_verification"` // 身份验证模式：开启后只接受携带有效签名的用户，拒绝匿名对话
+	CreatedAt            time.Time      `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt            time.Time      `gorm:"column:updated_at" json:"updated_at"`
+	DeletedAt            gorm.DeletedAt `gorm:"column:deleted_at;index" json:"-"`
 }
 
 // TableName 指定表名
@@ -42,10 +45,28 @@ type CustomerServiceSourceConfig struct {
 	// 客服分配设置
 	AgentAssignment AgentAssignmentData `json:"agent_assignment"`
 
+	// 满意度评价设置
+	CSAT CSATData `json:"csat"`
+
+	// SLA 设置
+	SLA SLAData `json:"sla"`
+
 	// 界面设置
 	UI UIData `json:"ui"`
 }
 
+// GetConfig 解析来源配置JSON，未配置时返回空配置
+func (s *CustomerServiceSource) GetConfig() (*CustomerServiceSourceConfig, error) {
+	cfg := &CustomerServiceSourceConfig{}
+	if s.Config == "" {
+		return cfg, nil
+	}
+	if err := json.Unmarshal([]byte(s.Config), cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
 // CreateSourceRequest 创建来源请求结构
 type CreateSourceRequest struct {
 	Name      string                      `json:"name" binding:"required"`       // 来源名称
@@ -54,6 +75,8 @@ type CreateSourceRequest struct {
 	ProjectID *int                        `json:"project_id" binding:"required"` // DooTask项目ID
 	ColumnID  int                         `json:"column_id"`                     // DooTask列ID
 	Config    CustomerServiceSourceConfig `json:"config" binding:"required"`     // 来源配置
+
+	IdentityVerification bool `json:"identity_verification"` // 是否开启身份验证模式
 }
 
 // CreateSourceResponse 创建来源响应结构
@@ -65,4 +88,5 @@ type CreateSourceResponse struct {
 	DialogID  *int   `json:"dialog_id"`
 	ProjectID *int   `json:"project_id"`
 	ColumnID  int    `json:"column_id"`
+	Secret    string `json:"secret"`
 }
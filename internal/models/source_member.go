@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// SourceMember 来源成员，负责接待该来源中待分配对话的客服
+type SourceMember struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	SourceID  uint      `gorm:"column:source_id;uniqueIndex:idx_source_member;not null" json:"source_id"`     // 来源ID
+	AgentID   uint      `gorm:"column:agent_id;uniqueIndex:idx_source_member;index;not null" json:"agent_id"` // 客服ID
+	CreatedAt time.Time `gorm:"column:created_at" json:"created_at"`                                          // 创建时间
+}
+
+// TableName 指定表名
+func (m *SourceMember) TableName() string {
+	return "cs_source_members"
+}
+
+// SetSourceMembersRequest 设置来源成员请求结构
+type SetSourceMembersRequest struct {
+	AgentIDs []uint `json:"agent_ids"` // 客服ID列表，为空表示不限制，待分配对话通知所有客服
+}
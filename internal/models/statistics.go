@@ -1,23 +1,315 @@
 package models
 
-import "time"
+import (
+	"time"
 
-// ChatStatistics 聊天统计结构体
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// StatisticsDateLayout 统计日期格式
+const StatisticsDateLayout = "2006-01-02"
+
+// ChatStatistics 聊天统计结构体，每天按负责客服与来源各保存一行
 type ChatStatistics struct {
 	ID                  uint      `gorm:"primaryKey" json:"id"`
-	Date                time.Time `gorm:"column:date;not null" json:"date"`                                  // 统计日期
-	AgentID             uint      `gorm:"column:agent_id;default:0" json:"agent_id"`                         // 客服ID，0表示所有客服
-	NewConversations    int       `gorm:"column:new_conversations;default:0" json:"new_conversations"`       // 新会话数
-	ClosedConversations int       `gorm:"column:closed_conversations;default:0" json:"closed_conversations"` // 关闭会话数
-	TotalMessages       int       `gorm:"column:total_messages;default:0" json:"total_messages"`             // 消息总数
-	AgentMessages       int       `gorm:"column:agent_messages;default:0" json:"agent_messages"`             // 客服消息数
-	CustomerMessages    int       `gorm:"column:customer_messages;default:0" json:"customer_messages"`       // 客户消息数
-	AvgResponseTime     int       `gorm:"column:avg_response_time;default:0" json:"avg_response_time"`       // 平均响应时间（秒）
-	CreatedAt           time.Time `gorm:"column:created_at" json:"created_at"`                               // 创建时间
-	UpdatedAt           time.Time `gorm:"column:updated_at" json:"updated_at"`                               // 更新时间
+	Date                string    `gorm:"column:date;size:10;not null;uniqueIndex:idx_statistics_dim" json:"date"`               // 统计日期，格式 2006-01-02
+	AgentID             uint      `gorm:"column:agent_id;default:0;uniqueIndex:idx_statistics_dim" json:"agent_id"`              // 负责客服ID，0表示未分配客服
+	SourceKey           string    `gorm:"column:source_key;size:64;default:'';uniqueIndex:idx_statistics_dim" json:"source_key"` // 来源标识
+	NewConversations    int       `gorm:"column:new_conversations;default:0" json:"new_conversations"`                           // 新会话数
+	ClosedConversations int       `gorm:"column:closed_conversations;default:0" json:"closed_conversations"`                     // 关闭会话数
+	TotalMessages       int       `gorm:"column:total_messages;default:0" json:"total_messages"`                                 // 消息总数
+	AgentMessages       int       `gorm:"column:agent_messages;default:0" json:"agent_messages"`                                 // 客服消息数
+	CustomerMessages    int       `gorm:"column:customer_messages;default:0" json:"customer_messages"`                           // 客户消息数
+	FirstResponseCount  int       `gorm:"column:first_response_count;default:0" json:"first_response_count"`                     // 已首次响应的会话数
+	FirstResponseTime   int64     `gorm:"column:first_response_time;default:0" json:"first_response_time"`                       // 首次响应总时长（秒）
+	ResolutionTime      int64     `gorm:"column:resolution_time;default:0" json:"resolution_time"`                               // 关闭会话的处理总时长（秒）
+	CSATCount           int       `gorm:"column:csat_count;default:0" json:"csat_count"`                                         // 满意度评价数
+	CSATScore           int       `gorm:"column:csat_score;default:0" json:"csat_score"`                                         // 满意度评价总分（按1-5分换算）
+	CSATSatisfied       int       `gorm:"column:csat_satisfied;default:0" json:"csat_satisfied"`                                 // 满意评价数
+	CreatedAt           time.Time `gorm:"column:created_at" json:"created_at"`                                                   // 创建时间
+	UpdatedAt           time.Time `gorm:"column:updated_at" json:"updated_at"`                                                   // 更新时间
 }
 
 // TableName 指定表名
 func (m *ChatStatistics) TableName() string {
 	return "cs_statistics"
 }
+
+// StatisticsDate 统计日期，按服务器本地时区划分
+func StatisticsDate(t time.Time) string {
+	return t.Local().Format(StatisticsDateLayout)
+}
+
+// IncrStatistics 将增量累加到对应日期、客服与来源的统计行，不存在时创建
+func IncrStatistics(db *gorm.DB, delta ChatStatistics) error {
+	now := time.Now()
+	delta.ID = 0
+	delta.CreatedAt = now
+	delta.UpdatedAt = now
+	return db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "date"}, {Name: "agent_id"}, {Name: "source_key"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"new_conversations":    gorm.Expr("new_conversations + ?", delta.NewConversations),
+			"closed_conversations": gorm.Expr("closed_conversations + ?", delta.ClosedConversations),
+			"total_messages":       gorm.Expr("total_messages + ?", delta.TotalMessages),
+			"agent_messages":       gorm.Expr("agent_messages + ?", delta.AgentMessages),
+			"customer_messages":    gorm.Expr("customer_messages + ?", delta.CustomerMessages),
+			"first_response_count": gorm.Expr("first_response_count + ?", delta.FirstResponseCount),
+			"first_response_time":  gorm.Expr("first_response_time + ?", delta.FirstResponseTime),
+			"resolution_time":      gorm.Expr("resolution_time + ?", delta.ResolutionTime),
+			"csat_count":           gorm.Expr("csat_count + ?", delta.CSATCount),
+			"csat_score":           gorm.Expr("csat_score + ?", delta.CSATScore),
+			"csat_satisfied":       gorm.Expr("csat_satisfied + ?", delta.CSATSatisfied),
+			"updated_at":           now,
+		}),
+	}).Create(&delta).Error
+}
+
+// 统计事件类型，每个事件按 (类型, 关联ID) 只保存一行
+const (
+	StatisticsKindCreated = "created" // 新会话，关联对话ID
+	StatisticsKindMessage = "message" // 消息，关联消息ID
+	StatisticsKindClosed  = "closed"  // 关闭会话，关联对话ID，重新打开后撤销
+	StatisticsKindRating  = "rating"  // 满意度评价，关联评价ID
+)
+
+// StatisticsEvent 计入统计的单个事件及其归属的客服与来源
+// 每日统计由事件汇总而来，实时统计与重建统计使用同一份事件，重复处理同一事件不会重复计数
+type StatisticsEvent struct {
+	ID                  uint      `gorm:"primaryKey" json:"id"`
+	Kind                string    `gorm:"column:kind;size:16;not null;uniqueIndex:idx_statistics_event" json:"kind"` // 事件类型
+	RefID               uint      `gorm:"column:ref_id;not null;uniqueIndex:idx_statistics_event" json:"ref_id"`     // 关联的对话、消息或评价ID
+	ConversationID      uint      `gorm:"column:conversation_id;index" json:"conversation_id"`                       // 对话ID
+	Date                string    `gorm:"column:date;size:10;not null;index" json:"date"`                            // 统计日期
+	AgentID             uint      `gorm:"column:agent_id;default:0" json:"agent_id"`                                 // 事件发生时负责或执行操作的客服ID
+	SourceKey           string    `gorm:"column:source_key;size:64;default:''" json:"source_key"`                    // 来源标识
+	NewConversations    int       `gorm:"column:new_conversations;default:0" json:"new_conversations"`
+	ClosedConversations int       `gorm:"column:closed_conversations;default:0" json:"closed_conversations"`
+	TotalMessages       int       `gorm:"column:total_messages;default:0" json:"total_messages"`
+	AgentMessages       int       `gorm:"column:agent_messages;default:0" json:"agent_messages"`
+	CustomerMessages    int       `gorm:"column:customer_messages;default:0" json:"customer_messages"`
+	FirstResponseCount  int       `gorm:"column:first_response_count;default:0" json:"first_response_count"`
+	FirstResponseTime   int64     `gorm:"column:first_response_time;default:0" json:"first_response_time"`
+	ResolutionTime      int64     `gorm:"column:resolution_time;default:0" json:"resolution_time"`
+	CSATCount           int       `gorm:"column:csat_count;default:0" json:"csat_count"`
+	CSATScore           int       `gorm:"column:csat_score;default:0" json:"csat_score"`
+	CSATSatisfied       int       `gorm:"column:csat_satisfied;default:0" json:"csat_satisfied"`
+	CreatedAt           time.Time `gorm:"column:created_at" json:"created_at"`
+}
+
+// TableName 指定表名
+func (m *StatisticsEvent) TableName() string {
+	return "cs_statistics_events"
+}
+
+// CreatedStatistics 新会话事件，归属创建时的负责客服
+func CreatedStatistics(conversation *Conversations) StatisticsEvent {
+	return StatisticsEvent{
+		Kind:             StatisticsKindCreated,
+		RefID:            conversation.ID,
+		ConversationID:   conversation.ID,
+		Date:             StatisticsDate(conversation.CreatedAt),
+		AgentID:          conversation.AgentID,
+		SourceKey:        conversation.SourceKey,
+		NewConversations: 1,
+	}
+}
+
+// MessageStatistics 消息事件，客服消息归属发送的客服，客户消息归属当时的负责客服
+// 内部备注不计入统计，返回false
+func MessageStatistics(conversation *Conversations, message *Message) (StatisticsEvent, bool) {
+	if message.IsInternal() {
+		return StatisticsEvent{}, false
+	}
+	event := StatisticsEvent{
+		Kind:           StatisticsKindMessage,
+		RefID:          message.ID,
+		ConversationID: conversation.ID,
+		Date:           StatisticsDate(message.CreatedAt),
+		AgentID:        conversation.AgentID,
+		SourceKey:      conversation.SourceKey,
+		TotalMessages:  1,
+	}
+	switch message.Sender {
+	case "customer":
+		event.CustomerMessages = 1
+	case "agent":
+		event.AgentMessages = 1
+		if message.SenderID > 0 {
+			event.AgentID = message.SenderID
+		}
+	}
+	return event, true
+}
+
+// AddFirstResponse 将首次响应计入客服回复消息的事件，时长为距客户第一条消息的秒数
+func (m *StatisticsEvent) AddFirstResponse(customerAt, respondedAt time.Time) {
+	seconds := int64(respondedAt.Sub(customerAt).Seconds())
+	if seconds < 0 {
+		seconds = 0
+	}
+	m.FirstResponseCount = 1
+	m.FirstResponseTime = seconds
+}
+
+// ClosedStatistics 关闭会话事件，归属关闭对话的客服，非客服关闭时归属负责客服
+// 对话未关闭时返回false
+func ClosedStatistics(conversation *Conversations) (StatisticsEvent, bool) {
+	if conversation.Status != ConversationStatusClosed || conversation.ClosedAt == nil {
+		return StatisticsEvent{}, false
+	}
+	event := StatisticsEvent{
+		Kind:                StatisticsKindClosed,
+		RefID:               conversation.ID,
+		ConversationID:      conversation.ID,
+		Date:                StatisticsDate(*conversation.ClosedAt),
+		AgentID:             conversation.AgentID,
+		SourceKey:           conversation.SourceKey,
+		ClosedConversations: 1,
+		ResolutionTime:      int64(conversation.ClosedAt.Sub(conversation.CreatedAt).Seconds()),
+	}
+	if conversation.ClosedBy > 0 {
+		event.AgentID = conversation.ClosedBy
+	}
+	return event, true
+}
+
+// RatingStatistics 满意度评价事件，归属评价记录中的负责客服与来源
+func RatingStatistics(rating *ConversationRating) StatisticsEvent {
+	event := StatisticsEvent{
+		Kind:           StatisticsKindRating,
+		RefID:          rating.ID,
+		ConversationID: rating.ConversationID,
+		Date:           StatisticsDate(rating.CreatedAt),
+		AgentID:        rating.AgentID,
+		SourceKey:      rating.SourceKey,
+		CSATCount:      1,
+		CSATScore:      rating.NormalizedScore(),
+	}
+	if rating.Satisfied() {
+		event.CSATSatisfied = 1
+	}
+	return event
+}
+
+// delta 事件对每日统计的增量，negate 为 true 时返回撤销该事件的增量
+func (m *StatisticsEvent) delta(negate bool) ChatStatistics {
+	sign := 1
+	if negate {
+		sign = -1
+	}
+	return ChatStatistics{
+		Date:                m.Date,
+		AgentID:             m.AgentID,
+		SourceKey:           m.SourceKey,
+		NewConversations:    sign * m.NewConversations,
+		ClosedConversations: sign * m.ClosedConversations,
+		TotalMessages:       sign * m.TotalMessages,
+		AgentMessages:       sign * m.AgentMessages,
+		CustomerMessages:    sign * m.CustomerMessages,
+		FirstResponseCount:  sign * m.FirstResponseCount,
+		FirstResponseTime:   int64(sign) * m.FirstResponseTime,
+		ResolutionTime:      int64(sign) * m.ResolutionTime,
+		CSATCount:           sign * m.CSATCount,
+		CSATScore:           sign * m.CSATScore,
+		CSATSatisfied:       sign * m.CSATSatisfied,
+	}
+}
+
+// sameAs 两个事件的归属与计数是否相同
+func (m *StatisticsEvent) sameAs(other *StatisticsEvent) bool {
+	return m.delta(false) == other.delta(false)
+}
+
+// RecordStatistics 保存统计事件并累加到每日统计
+// 同一事件已保存时先撤销旧的计数再计入新的计数，内容相同则不做任何修改
+func RecordStatistics(db *gorm.DB, event StatisticsEvent) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var existing StatisticsEvent
+		if err := tx.Where("kind = ? AND ref_id = ?", event.Kind, event.RefID).Limit(1).Find(&existing).Error; err != nil {
+			return err
+		}
+		if existing.ID > 0 {
+			if existing.sameAs(&event) {
+				return nil
+			}
+			if err := tx.Delete(&existing).Error; err != nil {
+				return err
+			}
+			if err := IncrStatistics(tx, existing.delta(true)); err != nil {
+				return err
+			}
+		}
+		event.ID = 0
+		event.CreatedAt = time.Now()
+		if err := tx.Create(&event).Error; err != nil {
+			return err
+		}
+		return IncrStatistics(tx, event.delta(false))
+	})
+}
+
+// RetractStatistics 撤销已保存的统计事件，事件不存在时不做任何修改
+func RetractStatistics(db *gorm.DB, kind string, refID uint) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var existing StatisticsEvent
+		if err := tx.Where("kind = ? AND ref_id = ?", kind, refID).Limit(1).Find(&existing).Error; err != nil {
+			return err
+		}
+		if existing.ID == 0 {
+			return nil
+		}
+		if result := tx.Delete(&existing); result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		return IncrStatistics(tx, existing.delta(true))
+	})
+}
+
+// StatsQuery 统计查询参数，日期格式为 2006-01-02
+type StatsQuery struct {
+	StartDate string `form:"start_date"` // 开始日期，默认为30天前
+	EndDate   string `form:"end_date"`   // 结束日期（含），默认为今天
+	AgentID   uint   `form:"agent_id"`   // 负责客服ID
+	SourceKey string `form:"source_key"` // 来源标识
+}
+
+// StatsSummary 统计汇总，按日期、客服或来源分组时填写对应的分组字段
+type StatsSummary struct {
+	Date                 string  `json:"date,omitempty"`          // 统计日期
+	AgentID              *uint   `json:"agent_id,omitempty"`      // 负责客服ID，0表示未分配客服
+	AgentName            string  `json:"agent_name,omitempty"`    // 客服名称
+	SourceKey            string  `json:"source_key,omitempty"`    // 来源标识
+	SourceName           string  `json:"source_name,omitempty"`   // 来源名称
+	NewConversations     int64   `json:"new_conversations"`       // 新会话数
+	ClosedConversations  int64   `json:"closed_conversations"`    // 关闭会话数
+	TotalMessages        int64   `json:"total_messages"`          // 消息总数
+	AgentMessages        int64   `json:"agent_messages"`          // 客服消息数
+	CustomerMessages     int64   `json:"customer_messages"`       // 客户消息数
+	FirstResponseCount   int64   `json:"first_response_count"`    // 已首次响应的会话数
+	AvgFirstResponseTime int64   `json:"avg_first_response_time"` // 平均首次响应时长（秒）
+	AvgResolutionTime    int64   `json:"avg_resolution_time"`     // 平均处理时长（秒）
+	CSATCount            int64   `json:"csat_count"`              // 满意度评价数
+	AvgCSAT              float64 `json:"avg_csat"`                // 平均满意度评分（1-5分）
+	CSATSatisfiedRate    float64 `json:"csat_satisfied_rate"`     // 满意率（百分比）
+}
+
+// StatsReport 统计报表
+type StatsReport struct {
+	StartDate string          `json:"start_date"`
+	EndDate   string          `json:"end_date"`
+	Summary   StatsSummary    `json:"summary"` // 时间范围内的汇总
+	Daily     []StatsSummary  `json:"daily"`   // 按日期汇总
+	Agents    []StatsSummary  `json:"agents"`  // 按客服汇总
+	Sources   []StatsSummary  `json:"sources"` // 按来源汇总
+	Tags      []StatsTagCount `json:"tags"`    // 按标签统计的新会话数
+}
+
+// StatsTagCount 按标签统计时间范围内创建的会话数
+type StatsTagCount struct {
+	TagID         uint   `json:"tag_id"`        // 标签ID
+	Name          string `json:"name"`          // 标签名称
+	Color         string `json:"color"`         // 标签颜色
+	Conversations int64  `json:"conversations"` // 带有该标签的会话数
+}
@@ -0,0 +1,95 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Tag 对话标签，由管理员维护，客服在对话中设置
+// 对话创建DooTask任务时，标签可映射为任务优先级与任务标签
+type Tag struct {
+	ID              uint      `gorm:"primaryKey" json:"id"`
+	Name            string    `gorm:"column:name;size:64;uniqueIndex;not null" json:"name"`      // 标签名称
+	Color           string    `gorm:"column:color;size:16" json:"color"`                         // 标签颜色，如 #2D8CF0
+	Description     string    `gorm:"column:description;size:255" json:"description"`            // 说明
+	DooTaskPriority int       `gorm:"column:dootask_priority;default:0" json:"dootask_priority"` // 映射的DooTask任务优先级（1-4），0表示不映射
+	DooTaskLabel    bool      `gorm:"column:dootask_label;default:false" json:"dootask_label"`   // 是否同步为DooTask任务标签
+	Sort            int       `gorm:"column:sort;default:0" json:"sort"`                         // 排序，越小越靠前
+	CreatedAt       time.Time `gorm:"column:created_at" json:"created_at"`                       // 创建时间
+	UpdatedAt       time.Time `gorm:"column:updated_at" json:"updated_at"`                       // 更新时间
+}
+
+// TableName 指定表名
+func (m *Tag) TableName() string {
+	return "cs_tags"
+}
+
+// ConversationTag 对话与标签的关联
+type ConversationTag struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	ConversationID uint      `gorm:"column:conversation_id;uniqueIndex:idx_conversation_tag;not null" json:"conversation_id"` // 对话ID
+	TagID          uint      `gorm:"column:tag_id;uniqueIndex:idx_conversation_tag;index;not null" json:"tag_id"`             // 标签ID
+	CreatedBy      uint      `gorm:"column:created_by;default:0" json:"created_by"`                                           // 设置标签的客服ID
+	CreatedAt      time.Time `gorm:"column:created_at" json:"created_at"`                                                     // 创建时间
+}
+
+// TableName 指定表名
+func (m *ConversationTag) TableName() string {
+	return "cs_conversation_tags"
+}
+
+// DooTaskPriority DooTask任务优先级
+type DooTaskPriority struct {
+	Level int    `json:"p_level"`
+	Name  string `json:"p_name"`
+	Color string `json:"p_color"`
+}
+
+// DooTaskPriorities DooTask默认的任务优先级设置
+var DooTaskPriorities = map[int]DooTaskPriority{
+	1: {Level: 1, Name: "重要且紧急", Color: "#ED4014"},
+	2: {Level: 2, Name: "重要不紧急", Color: "#F16B62"},
+	3: {Level: 3, Name: "紧急不重要", Color: "#19C919"},
+	4: {Level: 4, Name: "不重要不紧急", Color: "#2D8CF0"},
+}
+
+// LoadConversationTags 按对话ID加载标签，标签按排序与名称排列
+func LoadConversationTags(db *gorm.DB, conversationIDs ...uint) (map[uint][]Tag, error) {
+	result := make(map[uint][]Tag, len(conversationIDs))
+	if len(conversationIDs) == 0 {
+		return result, nil
+	}
+	var rows []struct {
+		ConversationID uint
+		Tag
+	}
+	err := db.Table("cs_conversation_tags AS ct").
+		Select("ct.conversation_id, t.*").
+		Joins("JOIN cs_tags AS t ON t.id = ct.tag_id").
+		Where("ct.conversation_id IN ?", conversationIDs).
+		Order("t.sort ASC, t.name ASC").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		result[row.ConversationID] = append(result[row.ConversationID], row.Tag)
+	}
+	return result, nil
+}
+
+// TagRequest 创建或更新标签请求结构
+type TagRequest struct {
+	Name            string `json:"name" binding:"required"` // 标签名称
+	Color           string `json:"color"`                   // 标签颜色，如 #2D8CF0
+	Description     string `json:"description"`             // 说明
+	DooTaskPriority int    `json:"dootask_priority"`        // 映射的DooTask任务优先级（1-4），0表示不映射
+	DooTaskLabel    bool   `json:"dootask_label"`           // 是否同步为DooTask任务标签
+	Sort            int    `json:"sort"`                    // 排序，越小越靠前
+}
+
+// SetConversationTagsRequest 设置对话标签请求结构，整体替换对话的标签
+type SetConversationTagsRequest struct {
+	TagIDs []uint `json:"tag_ids"` // 标签ID列表，为空时清除全部标签
+}
@@ -84,7 +84,7 @@ func InitDB() {
 	DB = db
 	log.Println("数据库连接成功")
 
-	db.AutoMigrate(&models.CSConfig{}, &models.Agent{}, &models.Customer{}, &models.Message{}, &models.Conversations{}, &models.CustomerServiceSource{})
+	db.AutoMigrate(&models.CSConfig{}, &models.Agent{}, &models.Customer{}, &models.Message{}, &models.Conversations{}, &models.CustomerServiceSource{}, &models.AutoReplyTask{}, &models.AgentSession{}, &models.ConversationRead{}, &models.Attachment{}, &models.ConversationParticipant{}, &models.SourceMember{}, &models.ChatStatistics{}, &models.ConversationRating{}, &models.CannedResponse{}, &models.SearchDocument{}, &models.Tag{}, &models.ConversationTag{}, &models.CustomField{}, &models.ConversationFieldValue{}, &models.SLAAlert{}, &models.AssignmentTimeout{}, &models.AssignmentCursor{}, &models.StatisticsEvent{})
 }
 
 // GetDB 获取数据库连接
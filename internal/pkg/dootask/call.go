@@ -24,6 +24,11 @@ type IDootaskService interface {
 	GetVersoinInfo() (*dto.VersionInfoResp, error)
 	CreateTask(token string, task *dto.CreateTaskReq) (*dto.CreateTaskResp, error)
 	OpenTaskDialog(token string, taskId int) (*dto.TaskDialogResp, error)
+	UpdateTaskAssist(token string, taskId int, assist []int) error
+	GetTask(token string, taskId int) (*dto.TaskInfoResp, error)
+	CompleteTask(token string, taskId int) error
+	UncompleteTask(token string, taskId int) error
+	UpdateTaskLabels(token string, req *dto.UpdateTaskLabelsReq) error
 }
 
 func NewIDootaskService() IDootaskService {
@@ -136,6 +141,88 @@ func (d *DootaskService) OpenTaskDialog(token string, taskId int) (*dto.TaskDial
 	return taskDialogResp, nil
 }
 
+// UpdateTaskAssist 设置任务协助人员（整体替换），协助人员会同时加入任务对话
+func (d *DootaskService) UpdateTaskAssist(token string, taskId int, assist []int) error {
+	url := fmt.Sprintf("%s%s?token=%s", config.Cfg.DooTask.Url, "/api/project/task/update", token)
+	result, err := d.client.Post(url, &dto.UpdateTaskAssistReq{
+		TaskID: taskId,
+		Assist: assist,
+	})
+	if err != nil {
+		return &i18n.ErrorInfo{
+			Code:    i18n.ErrCodeDooTaskRequestFailed,
+			Message: err.Error(),
+		}
+	}
+	_, err = d.UnmarshalAndCheckResponse(result)
+	return err
+}
+
+// UpdateTaskLabels 更新任务优先级与任务标签（整体替换）
+func (d *DootaskService) UpdateTaskLabels(token string, req *dto.UpdateTaskLabelsReq) error {
+	url := fmt.Sprintf("%s%s?token=%s", config.Cfg.DooTask.Url, "/api/project/task/update", token)
+	result, err := d.client.Post(url, req)
+	if err != nil {
+		return &i18n.ErrorInfo{
+			Code:    i18n.ErrCodeDooTaskRequestFailed,
+			Message: err.Error(),
+		}
+	}
+	_, err = d.UnmarshalAndCheckResponse(result)
+	return err
+}
+
+// GetTask 获取任务详情
+func (d *DootaskService) GetTask(token string, taskId int) (*dto.TaskInfoResp, error) {
+	url := fmt.Sprintf("%s%s?task_id=%d&token=%s", config.Cfg.DooTask.Url, "/api/project/task/one", taskId, token)
+	result, err := d.client.Get(url)
+	if err != nil {
+		return nil, &i18n.ErrorInfo{
+			Code:    i18n.ErrCodeDooTaskRequestFailed,
+			Message: err.Error(),
+		}
+	}
+	resp, err := d.UnmarshalAndCheckResponse(result)
+	if err != nil {
+		return nil, err
+	}
+	taskInfoResp := new(dto.TaskInfoResp)
+	if err := common.MapToStruct(resp, taskInfoResp); err != nil {
+		return nil, &i18n.ErrorInfo{
+			Code:    i18n.ErrCodeInternalError,
+			Message: err.Error(),
+		}
+	}
+	return taskInfoResp, nil
+}
+
+// CompleteTask 标记任务已完成
+func (d *DootaskService) CompleteTask(token string, taskId int) error {
+	return d.updateTaskComplete(token, taskId, time.Now().Format("2006-01-02 15:04:05"))
+}
+
+// UncompleteTask 标记任务未完成
+func (d *DootaskService) UncompleteTask(token string, taskId int) error {
+	return d.updateTaskComplete(token, taskId, false)
+}
+
+// updateTaskComplete 更新任务完成状态
+func (d *DootaskService) updateTaskComplete(token string, taskId int, completeAt interface{}) error {
+	url := fmt.Sprintf("%s%s?token=%s", config.Cfg.DooTask.Url, "/api/project/task/update", token)
+	result, err := d.client.Post(url, &dto.UpdateTaskCompleteReq{
+		TaskID:     taskId,
+		CompleteAt: completeAt,
+	})
+	if err != nil {
+		return &i18n.ErrorInfo{
+			Code:    i18n.ErrCodeDooTaskRequestFailed,
+			Message: err.Error(),
+		}
+	}
+	_, err = d.UnmarshalAndCheckResponse(result)
+	return err
+}
+
 // 解码并检查返回数据
 func (d *DootaskService) UnmarshalAndCheckResponse(resp []byte) (map[string]interface{}, error) {
 	var ret map[string]interface{}
@@ -0,0 +1,157 @@
+package dootask
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"golang.org/x/sync/singleflight"
+
+	"support-plugin/internal/config"
+	"support-plugin/internal/i18n"
+	"support-plugin/internal/models/dto"
+	"support-plugin/internal/pkg/logger"
+)
+
+// 缓存条目超过该数量时清理过期条目
+const userCacheSweepSize = 1000
+
+// userCacheEntry 用户信息缓存条目
+type userCacheEntry struct {
+	info      *dto.UserInfoResp // 最近一次成功获取的用户信息
+	err       error             // 令牌无效时缓存的错误
+	expiresAt time.Time         // 缓存过期时间
+	fetchedAt time.Time         // 最近一次成功获取的时间
+}
+
+// UserInfoCache 缓存令牌对应的DooTask用户信息
+type UserInfoCache struct {
+	service IDootaskService
+	entries map[string]*userCacheEntry
+	group   singleflight.Group
+	mutex   sync.Mutex
+}
+
+// UserCache 全局用户信息缓存
+var UserCache = NewUserInfoCache(NewIDootaskService())
+
+// NewUserInfoCache 创建用户信息缓存
+func NewUserInfoCache(service IDootaskService) *UserInfoCache {
+	return &UserInfoCache{
+		service: service,
+		entries: make(map[string]*userCacheEntry),
+	}
+}
+
+// GetUserInfo 获取令牌对应的用户信息
+// 有效期内直接返回缓存；并发请求同一令牌时只请求一次DooTask；
+// DooTask暂时不可用时，在宽限期内返回上一次获取到的用户信息
+func (u *UserInfoCache) GetUserInfo(token string) (*dto.UserInfoResp, error) {
+	if token == "" {
+		return nil, &i18n.ErrorInfo{
+			Code:    i18n.ErrCodeTokenInvalid,
+			Message: "Token is required",
+		}
+	}
+	key := u.key(token)
+
+	u.mutex.Lock()
+	entry, ok := u.entries[key]
+	u.mutex.Unlock()
+	if ok && time.Now().Before(entry.expiresAt) {
+		return entry.info, entry.err
+	}
+
+	result, err, _ := u.group.Do(key, func() (interface{}, error) {
+		return u.fetch(key, token)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.(*dto.UserInfoResp), nil
+}
+
+// Invalidate 移除令牌的缓存
+func (u *UserInfoCache) Invalidate(token string) {
+	u.mutex.Lock()
+	delete(u.entries, u.key(token))
+	u.mutex.Unlock()
+}
+
+// fetch 请求DooTask并更新缓存
+func (u *UserInfoCache) fetch(key, token string) (*dto.UserInfoResp, error) {
+	info, err := u.service.GetUserInfo(token)
+	now := time.Now()
+
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	previous := u.entries[key]
+	switch {
+	case err == nil:
+		u.entries[key] = &userCacheEntry{
+			info:      info,
+			expiresAt: now.Add(u.seconds(config.Cfg.DooTask.UserCacheTTL)),
+			fetchedAt: now,
+		}
+	case u.isRejected(err):
+		// DooTask明确拒绝的令牌，短时间内不再重复请求
+		u.entries[key] = &userCacheEntry{
+			err:       err,
+			expiresAt: now.Add(u.seconds(config.Cfg.DooTask.UserCacheNegative)),
+		}
+	case previous != nil && previous.info != nil && now.Sub(previous.fetchedAt) < u.seconds(config.Cfg.DooTask.UserCacheGrace):
+		// DooTask不可用，宽限期内沿用上一次的结果，并推迟下次重试
+		logger.App.Warn("DooTask暂时不可用，使用缓存的用户信息",
+			zap.Duration("age", now.Sub(previous.fetchedAt)),
+			zap.Error(err))
+		previous.expiresAt = now.Add(u.seconds(config.Cfg.DooTask.UserCacheNegative))
+		info, err = previous.info, nil
+	default:
+		return nil, err
+	}
+
+	if len(u.entries) > userCacheSweepSize {
+		u.sweep(now)
+	}
+	return info, err
+}
+
+// isRejected 判断错误是否为DooTask对令牌的明确拒绝（而非网络或服务异常）
+func (u *UserInfoCache) isRejected(err error) bool {
+	i18nErr, ok := err.(*i18n.ErrorInfo)
+	if !ok {
+		return false
+	}
+	return i18nErr.Code == i18n.ErrCodeDooTaskRequestFailedWithErr || i18nErr.Code == i18n.ErrCodeTokenInvalid
+}
+
+// sweep 清理已过期且超出宽限期的条目，调用方需持有锁
+func (u *UserInfoCache) sweep(now time.Time) {
+	grace := u.seconds(config.Cfg.DooTask.UserCacheGrace)
+	for key, entry := range u.entries {
+		if now.Before(entry.expiresAt) {
+			continue
+		}
+		if entry.info != nil && now.Sub(entry.fetchedAt) < grace {
+			continue
+		}
+		delete(u.entries, key)
+	}
+}
+
+// key 缓存键使用令牌哈希，避免在内存中保留原始令牌
+func (u *UserInfoCache) key(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// seconds 将配置的秒数转换为时间间隔
+func (u *UserInfoCache) seconds(value int) time.Duration {
+	if value < 0 {
+		value = 0
+	}
+	return time.Duration(value) * time.Second
+}
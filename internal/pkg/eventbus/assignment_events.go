@@ -0,0 +1,356 @@
+package eventbus
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"support-plugin/internal/i18n"
+	"support-plugin/internal/models"
+	"support-plugin/internal/pkg/database"
+	"support-plugin/internal/pkg/logger"
+	"support-plugin/internal/pkg/websocket"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 客服分配方式
+const (
+	AssignmentMethodRoundRobin = "round-robin"
+	AssignmentMethodLeastBusy  = "least-busy"
+	AssignmentMethodManual     = "manual"
+)
+
+// 轮询分配游标名称
+const assignmentCursorRoundRobin = "round-robin"
+
+// 检查到期转交的间隔，用于接续服务重启前未处理的转交
+const assignmentSweepInterval = 30 * time.Second
+
+// AssignmentEventHandlers 客服分配事件处理器
+type AssignmentEventHandlers struct {
+	// 串行化选择与写入，避免多个工作协程同时分配到同一客服
+	mutex sync.Mutex
+}
+
+// NewAssignmentEventHandlers 创建客服分配事件处理器
+func NewAssignmentEventHandlers() *AssignmentEventHandlers {
+	return &AssignmentEventHandlers{}
+}
+
+// HandleConversationCreated 对话创建后按配置的策略分配客服
+func (h *AssignmentEventHandlers) HandleConversationCreated(ctx context.Context, event Event) error {
+	convEvent, ok := event.(*ConversationCreatedEvent)
+	if !ok {
+		return &i18n.ErrorInfo{
+			Code:    i18n.ErrCodeEventTypeError,
+			Message: "事件类型错误: 期望 ConversationCreatedEvent",
+		}
+	}
+
+	var conversation models.Conversations
+	if err := database.DB.First(&conversation, convEvent.ConversationID).Error; err != nil {
+		logger.App.Error("查询对话信息失败",
+			zap.Uint("conversationID", convEvent.ConversationID),
+			zap.Error(err))
+		return err
+	}
+
+	settings := h.resolveSettings(conversation.SourceKey)
+	if settings.Method == AssignmentMethodManual {
+		logger.App.Info("客服分配方式为手动，通知来源客服", zap.Uint("conversationID", conversation.ID))
+		websocket.BroadcastToConversationAgents(conversation.Uuid, conversation, websocket.MessageTypeNewConversation)
+		return nil
+	}
+
+	h.mutex.Lock()
+	agent, err := h.pickAgent(settings.Method)
+	if err == nil && agent != nil {
+		err = h.assign(&conversation, agent)
+	}
+	h.mutex.Unlock()
+
+	if err != nil || agent == nil {
+		// 没有可分配的客服时对话保持待分配，通知来源客服手动接待
+		logger.App.Warn("未能自动分配客服，对话保持待分配并通知来源客服",
+			zap.Uint("conversationID", conversation.ID),
+			zap.String("method", settings.Method),
+			zap.Error(err))
+		websocket.BroadcastToConversationAgents(conversation.Uuid, conversation, websocket.MessageTypeNewConversation)
+		return err
+	}
+
+	logger.App.Info("对话已分配客服",
+		zap.Uint("conversationID", conversation.ID),
+		zap.Uint("agentID", agent.ID),
+		zap.String("method", settings.Method))
+	websocket.SendToAgent(agent.WsKey(), conversation, websocket.MessageTypeNewConversation)
+
+	h.scheduleTimeout(conversation.ID, agent.ID, settings)
+	return nil
+}
+
+// resolveSettings 合并系统配置与来源配置，来源配置优先
+func (h *AssignmentEventHandlers) resolveSettings(sourceKey string) models.AgentAssignmentData {
+	settings := models.AgentAssignmentData{Method: AssignmentMethodRoundRobin}
+
+	if systemConfig, err := models.LoadConfig[models.CustomerServiceConfigData](database.DB, models.CSConfigKeySystem); err == nil {
+		settings = systemConfig.AgentAssignment
+	}
+
+	var source models.CustomerServiceSource
+	if err := database.DB.Where("source_key = ?", sourceKey).First(&source).Error; err == nil {
+		if sourceConfig, err := source.GetConfig(); err == nil {
+			override := sourceConfig.AgentAssignment
+			if override.Method != "" {
+				settings.Method = override.Method
+			}
+			if override.Timeout > 0 {
+				settings.Timeout = override.Timeout
+			}
+			if override.FallbackAgentId != nil {
+				settings.FallbackAgentId = override.FallbackAgentId
+			}
+		}
+	}
+
+	if settings.Method == "" {
+		settings.Method = AssignmentMethodRoundRobin
+	}
+	return settings
+}
+
+// pickAgent 按分配方式选出客服，没有可用客服时返回nil
+func (h *AssignmentEventHandlers) pickAgent(method string) (*models.Agent, error) {
+	var agents []models.Agent
+	if err := database.DB.Where("status = ?", "active").Order("id ASC").Find(&agents).Error; err != nil {
+		return nil, err
+	}
+	loads, err := models.CountActiveChats(database.DB)
+	if err != nil {
+		return nil, err
+	}
+	agents = h.availableAgents(agents, loads)
+	if len(agents) == 0 {
+		return nil, nil
+	}
+
+	switch method {
+	case AssignmentMethodLeastBusy:
+		return h.pickLeastBusy(agents, loads), nil
+	default:
+		return h.pickRoundRobin(agents), nil
+	}
+}
+
+// availableAgents 筛选可接待新对话的客服
+func (h *AssignmentEventHandlers) availableAgents(agents []models.Agent, loads map[uint]int64) []models.Agent {
+	return selectAvailable(agents, loads, websocket.Presence().Agents)
+}
+
+// selectAvailable 只保留已连接、在线状态为在线且未满负荷的客服，负荷按进行中与离线留言的对话计算
+// 没有可用客服时返回空，对话保持待分配并通知来源客服，不会分配给未连接的客服
+func selectAvailable(agents []models.Agent, loads map[uint]int64, connections map[string]int) []models.Agent {
+	var available []models.Agent
+	for _, agent := range agents {
+		if models.EffectivePresence(agent.PresenceStatus, connections[agent.WsKey()] > 0) != models.PresenceOnline {
+			continue
+		}
+		if !agent.HasCapacity(loads[agent.ID]) {
+			continue
+		}
+		available = append(available, agent)
+	}
+	return available
+}
+
+// pickRoundRobin 轮询分配：按游标选择上一次轮询分配到的客服之后的下一位
+// 游标只在轮询分配时前移，转接与超时转交不影响轮询顺序
+func (h *AssignmentEventHandlers) pickRoundRobin(agents []models.Agent) *models.Agent {
+	var cursor models.AssignmentCursor
+	if err := database.DB.Where("name = ?", assignmentCursorRoundRobin).Limit(1).Find(&cursor).Error; err != nil {
+		logger.App.Warn("读取轮询分配游标失败", zap.Error(err))
+	}
+	agent := nextRoundRobin(agents, cursor.AgentID)
+
+	err := database.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "name"}},
+		DoUpdates: clause.AssignmentColumns([]string{"agent_id", "updated_at"}),
+	}).Create(&models.AssignmentCursor{Name: assignmentCursorRoundRobin, AgentID: agent.ID}).Error
+	if err != nil {
+		logger.App.Warn("更新轮询分配游标失败", zap.Error(err))
+	}
+	return agent
+}
+
+// nextRoundRobin 在按ID升序排列的客服中选择ID大于 lastID 的第一位，没有时回到第一位
+func nextRoundRobin(agents []models.Agent, lastID uint) *models.Agent {
+	for i := range agents {
+		if agents[i].ID > lastID {
+			return &agents[i]
+		}
+	}
+	return &agents[0]
+}
+
+// pickLeastBusy 最少负载分配：选择当前进行中对话最少的客服
+func (h *AssignmentEventHandlers) pickLeastBusy(agents []models.Agent, loads map[uint]int64) *models.Agent {
+	picked := &agents[0]
+	for i := range agents {
+		if loads[agents[i].ID] < loads[picked.ID] {
+			picked = &agents[i]
+		}
+	}
+	return picked
+}
+
+// assign 将对话分配给指定客服，并更新对话参与者
+func (h *AssignmentEventHandlers) assign(conversation *models.Conversations, agent *models.Agent) error {
+	previousID := conversation.AgentID
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(conversation).Update("agent_id", agent.ID).Error; err != nil {
+			return err
+		}
+		if previousID > 0 && previousID != agent.ID {
+			if _, err := models.LeaveConversation(tx, conversation.ID, previousID); err != nil {
+				return err
+			}
+		}
+		_, err := models.JoinConversation(tx, conversation.ID, agent.ID, models.ParticipantRoleOwner, 0)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	conversation.AgentID = agent.ID
+	return nil
+}
+
+// scheduleTimeout 记录等待回复的截止时间，超时未回复时转交给备用客服
+func (h *AssignmentEventHandlers) scheduleTimeout(conversationID, agentID uint, settings models.AgentAssignmentData) {
+	if settings.Timeout <= 0 || settings.FallbackAgentId == nil {
+		return
+	}
+	fallbackID := uint(*settings.FallbackAgentId)
+	if fallbackID == agentID {
+		return
+	}
+
+	now := time.Now()
+	timeout := time.Duration(settings.Timeout) * time.Second
+	err := database.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "conversation_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"agent_id", "fallback_agent_id", "assigned_at", "deadline_at"}),
+	}).Create(&models.AssignmentTimeout{
+		ConversationID:  conversationID,
+		AgentID:         agentID,
+		FallbackAgentID: fallbackID,
+		AssignedAt:      now,
+		DeadlineAt:      now.Add(timeout),
+	}).Error
+	if err != nil {
+		logger.App.Error("记录转交截止时间失败", zap.Uint("conversationID", conversationID), zap.Error(err))
+		return
+	}
+
+	// 本节点到期即处理，服务重启后由定时检查接续
+	time.AfterFunc(timeout, func() {
+		h.handleTimeout(conversationID)
+	})
+}
+
+// sweepTimeouts 处理已到期的转交
+func (h *AssignmentEventHandlers) sweepTimeouts() {
+	defer func() {
+		if err := recover(); err != nil {
+			logger.App.Error("检查到期转交 panic", zap.Any("error", err))
+		}
+	}()
+
+	var conversationIDs []uint
+	err := database.DB.Model(&models.AssignmentTimeout{}).
+		Where("deadline_at <= ?", time.Now()).
+		Pluck("conversation_id", &conversationIDs).Error
+	if err != nil {
+		logger.App.Error("查询到期转交失败", zap.Error(err))
+		return
+	}
+	for _, conversationID := range conversationIDs {
+		h.handleTimeout(conversationID)
+	}
+}
+
+// handleTimeout 领取到期的转交记录，客服未回复则转交备用客服
+// 领取即删除记录，多节点或定时检查与计时器同时到期时只处理一次
+func (h *AssignmentEventHandlers) handleTimeout(conversationID uint) {
+	var timeout models.AssignmentTimeout
+	err := database.DB.Where("conversation_id = ? AND deadline_at <= ?", conversationID, time.Now()).
+		Limit(1).Find(&timeout).Error
+	if err != nil || timeout.ID == 0 {
+		return
+	}
+	if database.DB.Delete(&models.AssignmentTimeout{}, timeout.ID).RowsAffected == 0 {
+		return
+	}
+	agentID, fallbackID := timeout.AgentID, timeout.FallbackAgentID
+
+	var conversation models.Conversations
+	if err := database.DB.First(&conversation, conversationID).Error; err != nil {
+		return
+	}
+	// 对话已关闭或已被转交，不再处理
+	if !conversation.IsActive() || conversation.AgentID != agentID {
+		return
+	}
+
+	// 内部备注不算回复
+	var replies int64
+	database.DB.Model(&models.Message{}).
+		Where("conversation_id = ? AND sender = ? AND created_at >= ?", conversationID, "agent", timeout.AssignedAt).
+		Where("visibility <> ?", models.MessageVisibilityInternal).
+		Count(&replies)
+	if replies > 0 {
+		return
+	}
+
+	var fallback models.Agent
+	if err := database.DB.Where("id = ? AND status = ?", fallbackID, "active").First(&fallback).Error; err != nil {
+		logger.App.Warn("备用客服不可用，跳过转交",
+			zap.Uint("conversationID", conversationID),
+			zap.Uint("fallbackAgentID", fallbackID))
+		return
+	}
+
+	h.mutex.Lock()
+	err = h.assign(&conversation, &fallback)
+	h.mutex.Unlock()
+	if err != nil {
+		logger.App.Error("转交备用客服失败", zap.Uint("conversationID", conversationID), zap.Error(err))
+		return
+	}
+
+	logger.App.Info("客服超时未回复，对话已转交备用客服",
+		zap.Uint("conversationID", conversationID),
+		zap.Uint("fromAgentID", agentID),
+		zap.Uint("toAgentID", fallback.ID))
+	websocket.SendToAgent(fallback.WsKey(), conversation, websocket.MessageTypeConversationAssigned)
+}
+
+// RegisterAssignmentEventHandlers 注册客服分配事件处理器
+func RegisterAssignmentEventHandlers() {
+	handlers := NewAssignmentEventHandlers()
+
+	GlobalEventBus.Subscribe(EventTypeConversationCreated, handlers.HandleConversationCreated)
+
+	go func() {
+		ticker := time.NewTicker(assignmentSweepInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			handlers.sweepTimeouts()
+		}
+	}()
+
+	logger.App.Info("客服分配事件处理器注册完成")
+}
@@ -0,0 +1,97 @@
+package eventbus
+
+import (
+	"testing"
+
+	"support-plugin/internal/models"
+)
+
+func TestNextRoundRobin(t *testing.T) {
+	agents := []models.Agent{{ID: 2}, {ID: 5}, {ID: 9}}
+
+	tests := []struct {
+		name   string
+		lastID uint
+		want   uint
+	}{
+		{"无游标时从第一位开始", 0, 2},
+		{"选择游标之后的下一位", 2, 5},
+		{"游标客服已不可用时选择其后的客服", 6, 9},
+		{"到达末尾后回到第一位", 9, 2},
+		{"游标大于全部客服ID时回到第一位", 12, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := nextRoundRobin(agents, tt.lastID); got.ID != tt.want {
+				t.Errorf("nextRoundRobin(%d) = %d, want %d", tt.lastID, got.ID, tt.want)
+			}
+		})
+	}
+}
+
+func TestSelectAvailable(t *testing.T) {
+	agents := []models.Agent{
+		{ID: 1, PresenceStatus: models.PresenceOnline},
+		{ID: 2, PresenceStatus: models.PresenceOnline, MaxConcurrentChats: 2},
+		{ID: 3, PresenceStatus: models.PresenceAway},
+		{ID: 4, PresenceStatus: models.PresenceOnline},
+		{ID: 5, PresenceStatus: models.PresenceOnline, DooTaskUserID: 105},
+		{ID: 6},
+	}
+
+	tests := []struct {
+		name        string
+		loads       map[uint]int64
+		connections map[string]int
+		want        []uint
+	}{
+		{"只分配给已连接的在线客服", nil, map[string]int{"1": 1, "3": 1, "105": 2, "6": 1}, []uint{1, 5, 6}},
+		{"DooTask客服按DooTask用户ID匹配连接", nil, map[string]int{"5": 1}, nil},
+		{"达到上限的客服不参与分配", map[uint]int64{2: 2}, map[string]int{"1": 1, "2": 1}, []uint{1}},
+		{"未达到上限的客服参与分配", map[uint]int64{2: 1}, map[string]int{"2": 1}, []uint{2}},
+		{"没有客服连接时不退回到未连接的客服", nil, map[string]int{}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := selectAvailable(agents, tt.loads, tt.connections)
+			if len(got) != len(tt.want) {
+				t.Fatalf("selectAvailable() = %v, want %v", agentIDs(got), tt.want)
+			}
+			for i := range got {
+				if got[i].ID != tt.want[i] {
+					t.Fatalf("selectAvailable() = %v, want %v", agentIDs(got), tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestPickLeastBusy(t *testing.T) {
+	h := NewAssignmentEventHandlers()
+	agents := []models.Agent{{ID: 1}, {ID: 2}, {ID: 3}}
+
+	tests := []struct {
+		name  string
+		loads map[uint]int64
+		want  uint
+	}{
+		{"选择负载最少的客服", map[uint]int64{1: 3, 2: 1, 3: 2}, 2},
+		{"负载相同时选择ID最小的客服", map[uint]int64{1: 1, 2: 1, 3: 1}, 1},
+		{"没有进行中对话的客服负载为0", map[uint]int64{1: 2, 2: 1}, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := h.pickLeastBusy(agents, tt.loads); got.ID != tt.want {
+				t.Errorf("pickLeastBusy() = %d, want %d", got.ID, tt.want)
+			}
+		})
+	}
+}
+
+func agentIDs(agents []models.Agent) []uint {
+	ids := make([]uint, len(agents))
+	for i := range agents {
+		ids[i] = agents[i].ID
+	}
+	return ids
+}
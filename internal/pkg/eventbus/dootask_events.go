@@ -17,8 +17,12 @@ import (
 
 // 事件类型常量
 const (
-	EventTypeConversationCreated = "conversation.created"
-	EventTypeMessageCreated      = "message.created"
+	EventTypeConversationCreated  = "conversation.created"
+	EventTypeMessageCreated       = "message.created"
+	EventTypeConversationClosed   = "conversation.closed"
+	EventTypeConversationReopened = "conversation.reopened"
+	EventTypeRatingSubmitted      = "rating.submitted"
+	EventTypeTagsUpdated          = "conversation.tags_updated"
 )
 
 // ConversationCreatedEvent 对话创建事件
@@ -91,6 +95,128 @@ func (e *MessageCreatedEvent) GetTimestamp() time.Time {
 	return time.Now()
 }
 
+// ConversationClosedEvent 对话关闭事件
+type ConversationClosedEvent struct {
+	ConversationID uint
+	ClosedBy       uint // 关闭对话的客服ID，0表示非客服关闭
+}
+
+// NewConversationClosedEvent 创建对话关闭事件
+func NewConversationClosedEvent(conversationID, closedBy uint) *ConversationClosedEvent {
+	return &ConversationClosedEvent{
+		ConversationID: conversationID,
+		ClosedBy:       closedBy,
+	}
+}
+
+// GetType 实现Event接口
+func (e *ConversationClosedEvent) GetType() string {
+	return EventTypeConversationClosed
+}
+
+// GetData 实现Event接口
+func (e *ConversationClosedEvent) GetData() interface{} {
+	return map[string]interface{}{
+		"conversation_id": e.ConversationID,
+		"closed_by":       e.ClosedBy,
+	}
+}
+
+// GetTimestamp 实现Event接口
+func (e *ConversationClosedEvent) GetTimestamp() time.Time {
+	return time.Now()
+}
+
+// ConversationReopenedEvent 对话重新打开事件
+type ConversationReopenedEvent struct {
+	ConversationID uint
+}
+
+// NewConversationReopenedEvent 创建对话重新打开事件
+func NewConversationReopenedEvent(conversationID uint) *ConversationReopenedEvent {
+	return &ConversationReopenedEvent{
+		ConversationID: conversationID,
+	}
+}
+
+// GetType 实现Event接口
+func (e *ConversationReopenedEvent) GetType() string {
+	return EventTypeConversationReopened
+}
+
+// GetData 实现Event接口
+func (e *ConversationReopenedEvent) GetData() interface{} {
+	return map[string]interface{}{
+		"conversation_id": e.ConversationID,
+	}
+}
+
+// GetTimestamp 实现Event接口
+func (e *ConversationReopenedEvent) GetTimestamp() time.Time {
+	return time.Now()
+}
+
+// RatingSubmittedEvent 满意度评价提交事件
+type RatingSubmittedEvent struct {
+	RatingID       uint
+	ConversationID uint
+}
+
+// NewRatingSubmittedEvent 创建满意度评价提交事件
+func NewRatingSubmittedEvent(ratingID, conversationID uint) *RatingSubmittedEvent {
+	return &RatingSubmittedEvent{
+		RatingID:       ratingID,
+		ConversationID: conversationID,
+	}
+}
+
+// GetType 实现Event接口
+func (e *RatingSubmittedEvent) GetType() string {
+	return EventTypeRatingSubmitted
+}
+
+// GetData 实现Event接口
+func (e *RatingSubmittedEvent) GetData() interface{} {
+	return map[string]interface{}{
+		"rating_id":       e.RatingID,
+		"conversation_id": e.ConversationID,
+	}
+}
+
+// GetTimestamp 实现Event接口
+func (e *RatingSubmittedEvent) GetTimestamp() time.Time {
+	return time.Now()
+}
+
+// TagsUpdatedEvent 对话标签变更事件
+type TagsUpdatedEvent struct {
+	ConversationID uint
+}
+
+// NewTagsUpdatedEvent 创建对话标签变更事件
+func NewTagsUpdatedEvent(conversationID uint) *TagsUpdatedEvent {
+	return &TagsUpdatedEvent{
+		ConversationID: conversationID,
+	}
+}
+
+// GetType 实现Event接口
+func (e *TagsUpdatedEvent) GetType() string {
+	return EventTypeTagsUpdated
+}
+
+// GetData 实现Event接口
+func (e *TagsUpdatedEvent) GetData() interface{} {
+	return map[string]interface{}{
+		"conversation_id": e.ConversationID,
+	}
+}
+
+// GetTimestamp 实现Event接口
+func (e *TagsUpdatedEvent) GetTimestamp() time.Time {
+	return time.Now()
+}
+
 // DooTaskEventHandlers DooTask事件处理器集合
 type DooTaskEventHandlers struct{}
 
@@ -140,6 +266,15 @@ func (h *DooTaskEventHandlers) HandleConversationCreated(ctx context.Context, ev
 		return nil
 	}
 
+	// 客服分配处理器先于本处理器执行，已分配的客服作为任务协助人员
+	assist := []int{}
+	if conversation.AgentID > 0 {
+		var agent models.Agent
+		if err := database.DB.First(&agent, conversation.AgentID).Error; err == nil && agent.DooTaskUserID > 0 {
+			assist = append(assist, agent.DooTaskUserID)
+		}
+	}
+
 	// TODO: 在这里实现DooTask任务创建逻辑
 	// 可以直接调用DooTask API或者通过其他方式实现
 	// 示例：
@@ -148,7 +283,7 @@ func (h *DooTaskEventHandlers) HandleConversationCreated(ctx context.Context, ev
 		Name:      fmt.Sprintf("[%s] - %s", source.Name, conversation.Title),
 		Content:   fmt.Sprintf("来源: %s", source.Name),
 		ColumnID:  source.ColumnID,
-		Assist:    []int{},
+		Assist:    assist,
 		Owner:     []int{*customerServiceConfigData.DooTaskIntegration.BotId},
 	}
 	// 调用DooTask API创建任务...
@@ -168,14 +303,27 @@ func (h *DooTaskEventHandlers) HandleConversationCreated(ctx context.Context, ev
 		zap.Any("taskID", createTaskResp.ID),
 		zap.Any("dialogID", openTaskDialogResp.DialogID))
 
-	// 更新会话信息
+	// 更新会话信息，只写入任务字段，避免覆盖创建任务期间对话的其他变更
 	conversation.DooTaskTaskID = createTaskResp.ID
 	conversation.DooTaskDialogID = openTaskDialogResp.DialogID
 	logger.App.Debug("更新对话信息", zap.Any("conversation", conversation))
-	if err := database.DB.Save(&conversation).Error; err != nil {
+	err = database.DB.Model(&conversation).Updates(map[string]interface{}{
+		"dootask_task_id":   conversation.DooTaskTaskID,
+		"dootask_dialog_id": conversation.DooTaskDialogID,
+	}).Error
+	if err != nil {
 		logger.App.Error("更新对话信息失败", zap.Error(err))
 		return err
 	}
+
+	// 创建任务期间设置的标签因任务尚未关联而未同步，此时补充同步
+	var tagged int64
+	database.DB.Model(&models.ConversationTag{}).Where("conversation_id = ?", conversation.ID).Count(&tagged)
+	if tagged > 0 {
+		if err := GlobalEventBus.Publish(NewTagsUpdatedEvent(conversation.ID)); err != nil {
+			logger.App.Error("发布对话标签变更事件失败", zap.Uint("conversationID", conversation.ID), zap.Error(err))
+		}
+	}
 	return nil
 }
 
@@ -218,12 +366,77 @@ func (h *DooTaskEventHandlers) HandleMessageCreated(ctx context.Context, event E
 		zap.String("sourceName", source.Name),
 		zap.String("content", msgEvent.Content))
 
-	// TODO: 在这里可以添加消息相关的业务逻辑
-	// 比如消息统计、通知处理等
+	// 消息统计由 StatisticsEventHandlers 处理
 
 	return nil
 }
 
+// HandleTagsUpdated 处理对话标签变更事件，将标签映射同步到已创建的DooTask任务
+// 不再映射优先级时保留任务原有的优先级
+func (h *DooTaskEventHandlers) HandleTagsUpdated(ctx context.Context, event Event) error {
+	tagsEvent, ok := event.(*TagsUpdatedEvent)
+	if !ok {
+		return &i18n.ErrorInfo{
+			Code:    i18n.ErrCodeEventTypeError,
+			Message: "事件类型错误: 期望 TagsUpdatedEvent",
+		}
+	}
+
+	var conversation models.Conversations
+	if err := database.DB.First(&conversation, tagsEvent.ConversationID).Error; err != nil {
+		logger.App.Error("查询对话信息失败",
+			zap.Uint("conversationID", tagsEvent.ConversationID),
+			zap.Error(err))
+		return err
+	}
+	if conversation.DooTaskTaskID == 0 {
+		return nil
+	}
+	customerServiceConfigData, err := models.LoadConfig[models.CustomerServiceConfigData](database.DB, models.CSConfigKeySystem)
+	if err != nil || customerServiceConfigData.DooTaskIntegration.BotToken == "" {
+		logger.App.Info("DooTask机器人未配置，跳过任务标签同步")
+		return nil
+	}
+
+	priority, taskTags, err := taskLabels(conversation.ID)
+	if err != nil {
+		return err
+	}
+	req := &dto.UpdateTaskLabelsReq{
+		TaskID:  conversation.DooTaskTaskID,
+		TaskTag: taskTags,
+	}
+	if priority != nil {
+		req.PLevel = priority.Level
+		req.PName = priority.Name
+		req.PColor = priority.Color
+	}
+	if err := dootask.NewIDootaskService().UpdateTaskLabels(customerServiceConfigData.DooTaskIntegration.BotToken, req); err != nil {
+		logger.App.Error("同步DooTask任务标签失败", zap.Int("taskID", conversation.DooTaskTaskID), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// taskLabels 根据对话标签计算DooTask任务优先级与任务标签，多个标签映射优先级时取最高（级别最小）的优先级
+func taskLabels(conversationID uint) (*models.DooTaskPriority, []dto.TaskTag, error) {
+	tagMap, err := models.LoadConversationTags(database.DB, conversationID)
+	if err != nil {
+		return nil, nil, err
+	}
+	var priority *models.DooTaskPriority
+	taskTags := []dto.TaskTag{}
+	for _, tag := range tagMap[conversationID] {
+		if p, ok := models.DooTaskPriorities[tag.DooTaskPriority]; ok && (priority == nil || p.Level < priority.Level) {
+			priority = &p
+		}
+		if tag.DooTaskLabel {
+			taskTags = append(taskTags, dto.TaskTag{Name: tag.Name, Color: tag.Color})
+		}
+	}
+	return priority, taskTags, nil
+}
+
 // RegisterDooTaskEventHandlers 注册DooTask事件处理器
 func RegisterDooTaskEventHandlers() {
 	handlers := NewDooTaskEventHandlers()
@@ -234,5 +447,8 @@ func RegisterDooTaskEventHandlers() {
 	// 注册消息创建事件处理器
 	GlobalEventBus.Subscribe(EventTypeMessageCreated, handlers.HandleMessageCreated)
 
+	// 注册对话标签变更事件处理器
+	GlobalEventBus.Subscribe(EventTypeTagsUpdated, handlers.HandleTagsUpdated)
+
 	logger.App.Info("DooTask事件处理器注册完成")
 }
@@ -24,9 +24,18 @@ func RegisterAllEventHandlers() {
 		return
 	}
 
+	// 注册客服分配事件处理器，先于DooTask事件处理器执行，创建任务时已确定负责客服
+	RegisterAssignmentEventHandlers()
+
 	// 注册DooTask事件处理器
 	RegisterDooTaskEventHandlers()
 
+	// 注册统计事件处理器
+	RegisterStatisticsEventHandlers()
+
+	// 注册SLA事件处理器
+	RegisterSLAEventHandlers()
+
 	logger.App.Info("所有事件处理器已注册")
 }
 
@@ -0,0 +1,195 @@
+package eventbus
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"support-plugin/internal/config"
+	"support-plugin/internal/i18n"
+	"support-plugin/internal/models"
+	"support-plugin/internal/pkg/database"
+	"support-plugin/internal/pkg/dootask"
+	"support-plugin/internal/pkg/logger"
+	"support-plugin/internal/pkg/websocket"
+
+	"go.uber.org/zap"
+)
+
+// SLA 事件类型
+const (
+	EventTypeSLAWarning  = "sla.warning"
+	EventTypeSLABreached = "sla.breached"
+)
+
+// SLAEvent SLA 即将超时或已超时事件
+type SLAEvent struct {
+	Level          string
+	ConversationID uint
+	Metric         string
+	DueAt          time.Time
+}
+
+// NewSLAEvent 创建 SLA 事件，level 为 warning 或 breached
+func NewSLAEvent(level string, conversationID uint, metric string, dueAt time.Time) *SLAEvent {
+	return &SLAEvent{
+		Level:          level,
+		ConversationID: conversationID,
+		Metric:         metric,
+		DueAt:          dueAt,
+	}
+}
+
+// GetType 实现Event接口
+func (e *SLAEvent) GetType() string {
+	if e.Level == models.SLALevelBreached {
+		return EventTypeSLABreached
+	}
+	return EventTypeSLAWarning
+}
+
+// GetData 实现Event接口
+func (e *SLAEvent) GetData() interface{} {
+	return map[string]interface{}{
+		"level":           e.Level,
+		"conversation_id": e.ConversationID,
+		"metric":          e.Metric,
+		"due_at":          e.DueAt,
+	}
+}
+
+// GetTimestamp 实现Event接口
+func (e *SLAEvent) GetTimestamp() time.Time {
+	return time.Now()
+}
+
+// SLARefresher 重新计算对话的 SLA 到期时间，由service层注册
+type SLARefresher func(conversationID uint)
+
+var slaRefresher SLARefresher
+
+// SetSLARefresher 注册 SLA 到期时间计算函数
+func SetSLARefresher(refresher SLARefresher) {
+	slaRefresher = refresher
+}
+
+// SLAEventHandlers SLA 事件处理器
+type SLAEventHandlers struct{}
+
+// NewSLAEventHandlers 创建 SLA 事件处理器
+func NewSLAEventHandlers() *SLAEventHandlers {
+	return &SLAEventHandlers{}
+}
+
+// HandleSLAEvent 通过WebSocket提醒对话相关客服，超时时同时通知来源的DooTask对话
+func (h *SLAEventHandlers) HandleSLAEvent(ctx context.Context, event Event) error {
+	slaEvent, ok := event.(*SLAEvent)
+	if !ok {
+		return &i18n.ErrorInfo{
+			Code:    i18n.ErrCodeEventTypeError,
+			Message: "事件类型错误: 期望 SLAEvent",
+		}
+	}
+
+	var conversation models.Conversations
+	if err := database.DB.First(&conversation, slaEvent.ConversationID).Error; err != nil {
+		logger.App.Error("查询对话信息失败",
+			zap.Uint("conversationID", slaEvent.ConversationID),
+			zap.Error(err))
+		return err
+	}
+
+	websocket.BroadcastToConversationAgents(conversation.Uuid, map[string]interface{}{
+		"conversation_id":   conversation.ID,
+		"conversation_uuid": conversation.Uuid,
+		"title":             conversation.Title,
+		"priority":          conversation.Priority,
+		"level":             slaEvent.Level,
+		"metric":            slaEvent.Metric,
+		"due_at":            slaEvent.DueAt,
+	}, websocket.MessageTypeSLAAlert)
+
+	if slaEvent.Level == models.SLALevelBreached {
+		h.notifySource(&conversation, slaEvent)
+	}
+	return nil
+}
+
+// HandleConversationChanged 对话创建、收到消息、关闭或重新打开后重新计算 SLA 到期时间
+func (h *SLAEventHandlers) HandleConversationChanged(ctx context.Context, event Event) error {
+	var conversationID uint
+	switch e := event.(type) {
+	case *ConversationCreatedEvent:
+		conversationID = e.ConversationID
+	case *MessageCreatedEvent:
+		conversationID = e.ConversationID
+	case *ConversationClosedEvent:
+		conversationID = e.ConversationID
+	case *ConversationReopenedEvent:
+		conversationID = e.ConversationID
+	default:
+		return &i18n.ErrorInfo{
+			Code:    i18n.ErrCodeEventTypeError,
+			Message: "事件类型错误: 期望对话事件",
+		}
+	}
+
+	if slaRefresher != nil {
+		slaRefresher(conversationID)
+	}
+	return nil
+}
+
+// notifySource 由机器人在来源的DooTask对话中发送超时提醒
+func (h *SLAEventHandlers) notifySource(conversation *models.Conversations, slaEvent *SLAEvent) {
+	var source models.CustomerServiceSource
+	if err := database.DB.Where("source_key = ?", conversation.SourceKey).First(&source).Error; err != nil {
+		return
+	}
+	if source.DialogID == nil || *source.DialogID <= 0 {
+		return
+	}
+	customerServiceConfigData, err := models.LoadConfig[models.CustomerServiceConfigData](database.DB, models.CSConfigKeySystem)
+	if err != nil || customerServiceConfigData.DooTaskIntegration.BotToken == "" {
+		return
+	}
+
+	content := fmt.Sprintf("[SLA超时] %s（优先级：%s）%s已超时，截止时间 %s",
+		conversation.Title,
+		models.Priorities[conversation.Priority],
+		models.SLAMetricNames[slaEvent.Metric],
+		slaEvent.DueAt.Local().Format("2006-01-02 15:04"))
+
+	botToken := customerServiceConfigData.DooTaskIntegration.BotToken
+	robot := dootask.DootaskRobot{
+		Webhook: config.Cfg.DooTask.WebHook,
+		Token:   botToken,
+		Version: config.Cfg.DooTask.Version,
+	}
+	robot.Message = &dootask.DootaskMessage{
+		Text:     content,
+		DialogId: fmt.Sprintf("%d", *source.DialogID),
+		Token:    botToken,
+		Version:  config.Cfg.DooTask.Version,
+	}
+	if _, err := robot.SendMsg(); err != nil {
+		logger.App.Error("发送SLA超时提醒失败",
+			zap.Uint("conversationID", conversation.ID),
+			zap.Int("dialogID", *source.DialogID),
+			zap.Error(err))
+	}
+}
+
+// RegisterSLAEventHandlers 注册 SLA 事件处理器
+func RegisterSLAEventHandlers() {
+	handlers := NewSLAEventHandlers()
+
+	GlobalEventBus.Subscribe(EventTypeSLAWarning, handlers.HandleSLAEvent)
+	GlobalEventBus.Subscribe(EventTypeSLABreached, handlers.HandleSLAEvent)
+	GlobalEventBus.Subscribe(EventTypeConversationCreated, handlers.HandleConversationChanged)
+	GlobalEventBus.Subscribe(EventTypeMessageCreated, handlers.HandleConversationChanged)
+	GlobalEventBus.Subscribe(EventTypeConversationClosed, handlers.HandleConversationChanged)
+	GlobalEventBus.Subscribe(EventTypeConversationReopened, handlers.HandleConversationChanged)
+
+	logger.App.Info("SLA事件处理器注册完成")
+}
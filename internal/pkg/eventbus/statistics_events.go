@@ -0,0 +1,175 @@
+package eventbus
+
+import (
+	"context"
+
+	"support-plugin/internal/i18n"
+	"support-plugin/internal/models"
+	"support-plugin/internal/pkg/database"
+	"support-plugin/internal/pkg/logger"
+
+	"go.uber.org/zap"
+)
+
+// StatisticsEventHandlers 统计事件处理器，将对话与消息事件记录为统计事件并累加到每日统计
+// 每个事件只计入一次，重复处理或乱序处理不会重复计数
+type StatisticsEventHandlers struct{}
+
+// NewStatisticsEventHandlers 创建统计事件处理器
+func NewStatisticsEventHandlers() *StatisticsEventHandlers {
+	return &StatisticsEventHandlers{}
+}
+
+// HandleConversationCreated 统计新会话
+func (h *StatisticsEventHandlers) HandleConversationCreated(ctx context.Context, event Event) error {
+	convEvent, ok := event.(*ConversationCreatedEvent)
+	if !ok {
+		return &i18n.ErrorInfo{
+			Code:    i18n.ErrCodeEventTypeError,
+			Message: "事件类型错误: 期望 ConversationCreatedEvent",
+		}
+	}
+
+	var conversation models.Conversations
+	if err := database.DB.First(&conversation, convEvent.ConversationID).Error; err != nil {
+		return err
+	}
+	return h.record(models.CreatedStatistics(&conversation))
+}
+
+// HandleMessageCreated 统计消息数，客服在客户发言后的第一条回复计入首次响应时长
+func (h *StatisticsEventHandlers) HandleMessageCreated(ctx context.Context, event Event) error {
+	msgEvent, ok := event.(*MessageCreatedEvent)
+	if !ok {
+		return &i18n.ErrorInfo{
+			Code:    i18n.ErrCodeEventTypeError,
+			Message: "事件类型错误: 期望 MessageCreatedEvent",
+		}
+	}
+
+	var message models.Message
+	if err := database.DB.First(&message, msgEvent.MessageID).Error; err != nil {
+		return err
+	}
+	var conversation models.Conversations
+	if err := database.DB.First(&conversation, message.ConversationID).Error; err != nil {
+		return err
+	}
+
+	stats, ok := models.MessageStatistics(&conversation, &message)
+	if !ok {
+		return nil
+	}
+	if message.Sender == "agent" {
+		h.firstResponse(&conversation, &message, &stats)
+	}
+	return h.record(stats)
+}
+
+// HandleConversationClosed 统计关闭会话数与处理时长
+func (h *StatisticsEventHandlers) HandleConversationClosed(ctx context.Context, event Event) error {
+	closedEvent, ok := event.(*ConversationClosedEvent)
+	if !ok {
+		return &i18n.ErrorInfo{
+			Code:    i18n.ErrCodeEventTypeError,
+			Message: "事件类型错误: 期望 ConversationClosedEvent",
+		}
+	}
+	return h.syncClosed(closedEvent.ConversationID)
+}
+
+// HandleConversationReopened 重新打开的对话撤销关闭统计，再次关闭时按新的关闭时间计入
+func (h *StatisticsEventHandlers) HandleConversationReopened(ctx context.Context, event Event) error {
+	reopenedEvent, ok := event.(*ConversationReopenedEvent)
+	if !ok {
+		return &i18n.ErrorInfo{
+			Code:    i18n.ErrCodeEventTypeError,
+			Message: "事件类型错误: 期望 ConversationReopenedEvent",
+		}
+	}
+	return h.syncClosed(reopenedEvent.ConversationID)
+}
+
+// HandleRatingSubmitted 统计满意度评价，按评价记录中的负责客服与来源归类
+func (h *StatisticsEventHandlers) HandleRatingSubmitted(ctx context.Context, event Event) error {
+	ratingEvent, ok := event.(*RatingSubmittedEvent)
+	if !ok {
+		return &i18n.ErrorInfo{
+			Code:    i18n.ErrCodeEventTypeError,
+			Message: "事件类型错误: 期望 RatingSubmittedEvent",
+		}
+	}
+
+	var rating models.ConversationRating
+	if err := database.DB.First(&rating, ratingEvent.RatingID).Error; err != nil {
+		return err
+	}
+	return h.record(models.RatingStatistics(&rating))
+}
+
+// syncClosed 按对话当前状态同步关闭统计：已关闭时计入，否则撤销
+// 关闭与重新打开事件可能乱序处理，以对话当前状态为准
+func (h *StatisticsEventHandlers) syncClosed(conversationID uint) error {
+	var conversation models.Conversations
+	if err := database.DB.First(&conversation, conversationID).Error; err != nil {
+		return err
+	}
+	if stats, ok := models.ClosedStatistics(&conversation); ok {
+		return h.record(stats)
+	}
+	if err := models.RetractStatistics(database.DB, models.StatisticsKindClosed, conversation.ID); err != nil {
+		logger.App.Error("撤销关闭统计失败", zap.Uint("conversationID", conversation.ID), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// firstResponse 客服消息是客户第一条消息之后的第一条回复时计入首次响应，并记录对话的首次响应时间
+// 根据消息记录判断，重复处理同一消息结果相同
+func (h *StatisticsEventHandlers) firstResponse(conversation *models.Conversations, message *models.Message, stats *models.StatisticsEvent) {
+	var first models.Message
+	err := database.DB.Where("conversation_id = ? AND sender = ? AND visibility <> ? AND id < ?", conversation.ID, "customer", models.MessageVisibilityInternal, message.ID).
+		Order("id ASC").
+		First(&first).Error
+	if err != nil {
+		return
+	}
+	var replied int64
+	database.DB.Model(&models.Message{}).
+		Where("conversation_id = ? AND sender = ? AND visibility <> ? AND id > ? AND id < ?", conversation.ID, "agent", models.MessageVisibilityInternal, first.ID, message.ID).
+		Count(&replied)
+	if replied > 0 {
+		return
+	}
+
+	stats.AddFirstResponse(first.CreatedAt, message.CreatedAt)
+	database.DB.Model(&models.Conversations{}).
+		Where("id = ? AND first_response_at IS NULL", conversation.ID).
+		Update("first_response_at", message.CreatedAt)
+}
+
+// record 保存统计事件并累加到每日统计
+func (h *StatisticsEventHandlers) record(stats models.StatisticsEvent) error {
+	if err := models.RecordStatistics(database.DB, stats); err != nil {
+		logger.App.Error("更新统计数据失败",
+			zap.String("kind", stats.Kind),
+			zap.Uint("refID", stats.RefID),
+			zap.String("date", stats.Date),
+			zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// RegisterStatisticsEventHandlers 注册统计事件处理器
+func RegisterStatisticsEventHandlers() {
+	handlers := NewStatisticsEventHandlers()
+
+	GlobalEventBus.Subscribe(EventTypeConversationCreated, handlers.HandleConversationCreated)
+	GlobalEventBus.Subscribe(EventTypeMessageCreated, handlers.HandleMessageCreated)
+	GlobalEventBus.Subscribe(EventTypeConversationClosed, handlers.HandleConversationClosed)
+	GlobalEventBus.Subscribe(EventTypeConversationReopened, handlers.HandleConversationReopened)
+	GlobalEventBus.Subscribe(EventTypeRatingSubmitted, handlers.HandleRatingSubmitted)
+
+	logger.App.Info("统计事件处理器注册完成")
+}
@@ -1,39 +1,66 @@
 package initialize
 
 import (
+	"fmt"
 	"log"
+	"os"
 	"time"
 
+	"support-plugin/internal/config"
 	"support-plugin/internal/models"
 	"support-plugin/internal/pkg/database"
+	"support-plugin/internal/service"
+	"support-plugin/internal/utils/common"
 )
 
-// InitDefaultAgent 初始化默认客服账号
+// InitDefaultAgent 初始化默认管理员账号（仅独立模式，DooTask模式由DooTask管理员管理）
 func InitDefaultAgent() {
+	if config.Cfg.App.Mode == "dootask" {
+		return
+	}
 	db := database.GetDB()
 
-	// 检查是否已存在客服账号
+	// 检查是否已存在管理员账号
 	var count int64
-	db.Model(&models.Agent{}).Count(&count)
+	db.Model(&models.Agent{}).Where("is_admin = ?", true).Count(&count)
 	if count > 0 {
-		log.Println("已存在客服账号，跳过初始化默认账号")
+		log.Println("已存在管理员账号，跳过初始化默认账号")
+		return
+	}
+
+	password := config.Cfg.App.AdminPassword
+	generated := password == ""
+	if generated {
+		password = common.SecureRandString(8)
+	}
+	passwordHash, err := service.Auth.HashPassword(password)
+	if err != nil {
+		log.Printf("生成默认管理员密码失败: %v\n", err)
 		return
 	}
 
 	defaultAgent := models.Agent{
-		Username:  "admin",
-		Name:      "系统管理员",
-		Avatar:    "",
-		Status:    "active",
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
+		Username:     "admin",
+		Name:         "系统管理员",
+		Avatar:       "",
+		PasswordHash: passwordHash,
+		IsAdmin:      true,
+		Status:       "active",
+		CreatedAt:    time.Now(),
+		UpdatedAt:    time.Now(),
 	}
 
 	result := db.Create(&defaultAgent)
 	if result.Error != nil {
-		log.Printf("创建默认客服账号失败: %v\n", result.Error)
+		log.Printf("创建默认管理员账号失败: %v\n", result.Error)
 		return
 	}
 
-	log.Println("已创建默认客服账号: admin / admin123")
+	if generated {
+		// 随机密码只输出一次到标准错误，不写入日志
+		fmt.Fprintf(os.Stderr, "已创建默认管理员账号: admin / %s ，请登录后尽快修改密码\n", password)
+		log.Println("已创建默认管理员账号: admin（随机密码已输出到标准错误）")
+	} else {
+		log.Println("已创建默认管理员账号: admin（密码见配置 app.admin_password）")
+	}
 }
@@ -18,6 +18,9 @@ func InitDefaultConfig() {
 	
 	// 初始化系统配置
 	initSystemConfig(db)
+
+	// 初始化认证配置
+	initAuthConfig(db)
 }
 
 // initDooTaskChatConfig 初始化DooTaskChat配置
@@ -91,6 +94,12 @@ func initSystemConfig(db *gorm.DB) {
 			Timeout:         300, // 5分钟
 			FallbackAgentId: nil,
 		},
+		CSAT: models.CSATData{
+			Enabled:      false,
+			Type:         models.CSATTypeStars,
+			Question:     "请对本次服务进行评价",
+			AllowComment: true,
+		},
 		UI: models.UIData{
 			PrimaryColor:       "#007bff",
 			LogoUrl:            "",
@@ -118,3 +127,52 @@ func initSystemConfig(db *gorm.DB) {
 	}
 	log.Println("系统配置初始化完成")
 }
+
+// initAuthConfig 初始化认证配置，确保存在用于签名的密钥
+func initAuthConfig(db *gorm.DB) {
+	var existing models.CSConfig
+	if err := db.Where("config_key = ?", models.CSConfigKeyAuth).First(&existing).Error; err == nil {
+		authConfig, err := models.LoadConfig[models.AuthConfig](db, models.CSConfigKeyAuth)
+		if err != nil || authConfig.JWTSecret != "" {
+			log.Println("认证配置已存在")
+			return
+		}
+		// 补全缺失的签名密钥
+		authConfig.JWTSecret = common.SecureRandString(64)
+		jsonBytes, err := json.Marshal(authConfig)
+		if err != nil {
+			log.Printf("序列化认证配置失败: %v", err)
+			return
+		}
+		if err := db.Model(&existing).Update("config_json", string(jsonBytes)).Error; err != nil {
+			log.Printf("更新认证配置失败: %v", err)
+			return
+		}
+		log.Println("认证配置签名密钥已生成")
+		return
+	}
+
+	defaultAuthConfig := models.AuthConfig{
+		JWTSecret:              common.SecureRandString(64),
+		TokenExpireHours:       24,
+		RequireAgentAuth:       true,
+		AllowCustomerAnonymous: true,
+		ConversationTokenHours: 720,
+		RefreshExpireHours:     720,
+	}
+	jsonBytes, err := json.Marshal(defaultAuthConfig)
+	if err != nil {
+		log.Printf("序列化认证配置失败: %v", err)
+		return
+	}
+
+	authConfig := models.CSConfig{
+		ConfigKey:  models.CSConfigKeyAuth,
+		ConfigJSON: string(jsonBytes),
+	}
+	if err := db.Create(&authConfig).Error; err != nil {
+		log.Printf("创建认证配置失败: %v", err)
+		return
+	}
+	log.Println("认证配置初始化完成")
+}
@@ -13,11 +13,17 @@ func Init() {
 	// 初始化i18n
 	InitI18n()
 
-	// 初始化默认客服账号
-	// InitDefaultAgent()
-
 	InitDefaultConfig()
 
+	// 初始化默认管理员账号
+	InitDefaultAgent()
+
+	// 初始化全文检索
+	InitSearch()
+
+	// 回填统计数据
+	InitStatistics()
+
 	log.Println("初始化操作完成")
 }
 
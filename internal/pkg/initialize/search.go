@@ -0,0 +1,16 @@
+package initialize
+
+import (
+	"log"
+
+	"support-plugin/internal/pkg/database"
+	"support-plugin/internal/pkg/search"
+	"support-plugin/internal/service"
+)
+
+// InitSearch 选择全文检索实现，首次启用时根据历史消息与对话生成检索文档
+func InitSearch() {
+	log.Println("初始化全文检索...")
+	search.Init(database.DB)
+	service.Search.BackfillIfEmpty()
+}
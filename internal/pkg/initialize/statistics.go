@@ -0,0 +1,13 @@
+package initialize
+
+import (
+	"log"
+
+	"support-plugin/internal/service"
+)
+
+// InitStatistics 首次启用统计时根据历史对话与消息回填统计数据
+func InitStatistics() {
+	log.Println("检查统计数据...")
+	service.Stats.BackfillIfEmpty()
+}
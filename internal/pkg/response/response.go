@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"support-plugin/internal/i18n"
 	"support-plugin/internal/models"
+	bizErrors "support-plugin/internal/pkg/errors"
 
 	"github.com/gin-gonic/gin"
 )
@@ -95,6 +96,16 @@ func SuccessWithPagination(c *gin.Context, message string, items interface{}, to
 	Success(c, message, pagination)
 }
 
+// SuccessWithCursor 带游标分页的成功响应
+func SuccessWithCursor(c *gin.Context, message string, items interface{}, hasMore bool, firstID, lastID uint) {
+	Success(c, message, models.CursorData{
+		Items:   items,
+		HasMore: hasMore,
+		FirstID: firstID,
+		LastID:  lastID,
+	})
+}
+
 // GetLanguageFromContext 从上下文获取语言
 func GetLanguageFromContext(c *gin.Context) i18n.Language {
 	// 优先从查询参数获取语言
@@ -189,3 +200,20 @@ func NotFoundWithCode(c *gin.Context, code i18n.ErrorCode, args ...interface{})
 func InternalServerErrorWithCode(c *gin.Context, code i18n.ErrorCode, args ...interface{}) {
 	ErrorWithCodeAndStatus(c, http.StatusInternalServerError, code, args...)
 }
+
+// ServiceError 按service层返回的错误输出响应：无权限为403，其他错误代码与业务错误为400，其余为500
+func ServiceError(c *gin.Context, message string, err error) {
+	if i18nErr, ok := err.(*i18n.ErrorInfo); ok {
+		if i18nErr.Code == i18n.ErrCodePermissionDenied {
+			ForbiddenWithCode(c, i18nErr.Code)
+			return
+		}
+		BadRequestWithCode(c, i18nErr.Code)
+		return
+	}
+	if bizErr, ok := bizErrors.IsBusinessError(err); ok {
+		BadRequest(c, bizErr.Message, bizErr)
+		return
+	}
+	ServerError(c, message, err)
+}
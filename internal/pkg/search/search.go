@@ -0,0 +1,396 @@
+package search
+
+import (
+	"html"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"support-plugin/internal/config"
+	"support-plugin/internal/models"
+	"support-plugin/internal/pkg/logger"
+)
+
+// 数据库生成摘要时使用的匹配标记，输出前转义内容并替换为 <mark></mark>
+const (
+	markStart = "\x02"
+	markEnd   = "\x03"
+)
+
+// 摘要在匹配内容前后保留的字符数
+const snippetContext = 30
+
+// Backend 全文检索实现，所有实现都基于 cs_search_documents 表（查询别名为 d）
+type Backend interface {
+	// Name 实现名称
+	Name() string
+	// Setup 建立全文索引，返回错误时退回 LIKE 检索
+	Setup(db *gorm.DB) error
+	// Match 在查询上追加关键词匹配与相关度排序
+	// 返回数据库生成高亮摘要的表达式，为空时由 Snippet 根据文档内容生成
+	Match(db *gorm.DB, keyword string) (*gorm.DB, string)
+	// Rebuild 检索文档全部重建后刷新全文索引
+	Rebuild(db *gorm.DB) error
+}
+
+// Default 当前使用的全文检索实现
+var Default Backend = &likeBackend{}
+
+// Init 根据配置与数据库类型选择全文检索实现，并注册检索文档的同步回调
+func Init(db *gorm.DB) {
+	var backend Backend = &likeBackend{}
+	if config.Cfg.Search.Backend != "like" {
+		switch config.Cfg.DB.Type {
+		case "sqlite":
+			backend = &sqliteBackend{}
+		case "mysql":
+			backend = &mysqlBackend{}
+		}
+	}
+	if err := backend.Setup(db); err != nil {
+		hint := ""
+		if backend.Name() == (&sqliteBackend{}).Name() {
+			hint = "SQLite 全文检索需使用 -tags sqlite_fts5 编译"
+		}
+		logger.App.Warn("全文索引不可用，使用 LIKE 检索", zap.String("backend", backend.Name()), zap.String("hint", hint), zap.Error(err))
+		backend = &likeBackend{}
+		backend.Setup(db)
+	}
+	Default = backend
+	logger.App.Info("全文检索已启用", zap.String("backend", backend.Name()))
+
+	registerCallbacks(db)
+}
+
+// MessageDocument 由消息生成检索文档，系统消息不建立索引
+func MessageDocument(message *models.Message) *models.SearchDocument {
+	if message.ID == 0 || message.Sender == "system" || strings.TrimSpace(message.Content) == "" {
+		return nil
+	}
+	return &models.SearchDocument{
+		Kind:           models.SearchKindMessage,
+		RefID:          message.ID,
+		ConversationID: message.ConversationID,
+		Content:        message.Content,
+		CreatedAt:      message.CreatedAt,
+	}
+}
+
+// ConversationDocument 由对话标题、UUID、客户信息与DooTask任务ID生成检索文档
+func ConversationDocument(conversation *models.Conversations, customer *models.Customer) *models.SearchDocument {
+	fields := []string{conversation.Title, conversation.Uuid}
+	if conversation.DooTaskTaskID > 0 {
+		fields = append(fields, strconv.Itoa(conversation.DooTaskTaskID))
+	}
+	if customer != nil {
+		fields = append(fields, customer.Name, customer.Email, customer.Phone, customer.ExternalID)
+	}
+	lines := make([]string, 0, len(fields))
+	for _, field := range fields {
+		if field = strings.TrimSpace(field); field != "" {
+			lines = append(lines, field)
+		}
+	}
+	return &models.SearchDocument{
+		Kind:           models.SearchKindConversation,
+		RefID:          conversation.ID,
+		ConversationID: conversation.ID,
+		Content:        strings.Join(lines, "\n"),
+		CreatedAt:      conversation.CreatedAt,
+	}
+}
+
+// Save 写入或更新检索文档
+func Save(db *gorm.DB, documents ...*models.SearchDocument) error {
+	list := make([]*models.SearchDocument, 0, len(documents))
+	for _, document := range documents {
+		if document != nil {
+			list = append(list, document)
+		}
+	}
+	if len(list) == 0 {
+		return nil
+	}
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "kind"}, {Name: "ref_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"conversation_id", "content", "created_at"}),
+	}).Create(&list).Error
+}
+
+// IndexConversation 重新生成对话的元数据检索文档
+func IndexConversation(db *gorm.DB, conversationID uint) error {
+	document, err := conversationDocument(db, conversationID)
+	if err != nil {
+		return err
+	}
+	return Save(db, document)
+}
+
+// conversationDocument 读取对话与客户生成元数据检索文档
+func conversationDocument(db *gorm.DB, conversationID uint) (*models.SearchDocument, error) {
+	var conversation models.Conversations
+	if err := db.First(&conversation, conversationID).Error; err != nil {
+		return nil, err
+	}
+	var customer *models.Customer
+	if conversation.CustomerID > 0 {
+		customer = &models.Customer{}
+		if err := db.Unscoped().First(customer, conversation.CustomerID).Error; err != nil {
+			customer = nil
+		}
+	}
+	return ConversationDocument(&conversation, customer), nil
+}
+
+// saveChanged 只写入内容与已保存文档不同的检索文档，避免未变化的文档重复写入全文索引
+func saveChanged(db *gorm.DB, documents ...*models.SearchDocument) error {
+	changed := make([]*models.SearchDocument, 0, len(documents))
+	for _, document := range documents {
+		if document == nil {
+			continue
+		}
+		var existing models.SearchDocument
+		err := db.Select("id", "conversation_id", "content").
+			Where("kind = ? AND ref_id = ?", document.Kind, document.RefID).
+			Limit(1).Find(&existing).Error
+		if err != nil {
+			return err
+		}
+		if existing.ID > 0 && existing.ConversationID == document.ConversationID && existing.Content == document.Content {
+			continue
+		}
+		changed = append(changed, document)
+	}
+	return Save(db, changed...)
+}
+
+// Snippet 截取内容中第一个匹配关键词附近的文字并高亮，内容已做HTML转义
+func Snippet(content, keyword string) string {
+	runes := []rune(content)
+	lower := []rune(strings.ToLower(content))
+	needle := []rune(strings.ToLower(keyword))
+	// 大小写转换改变了字符数时不做忽略大小写匹配
+	if len(lower) != len(runes) {
+		lower = runes
+		needle = []rune(keyword)
+	}
+
+	index := -1
+	if len(needle) > 0 {
+		for i := 0; i+len(needle) <= len(lower); i++ {
+			if string(lower[i:i+len(needle)]) == string(needle) {
+				index = i
+				break
+			}
+		}
+	}
+	if index < 0 {
+		end := min(len(runes), snippetContext*2)
+		text := html.EscapeString(string(runes[:end]))
+		if end < len(runes) {
+			text += "…"
+		}
+		return text
+	}
+
+	start := max(0, index-snippetContext)
+	end := min(len(runes), index+len(needle)+snippetContext)
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	b.WriteString(html.EscapeString(string(runes[start:index])))
+	b.WriteString("<mark>")
+	b.WriteString(html.EscapeString(string(runes[index : index+len(needle)])))
+	b.WriteString("</mark>")
+	b.WriteString(html.EscapeString(string(runes[index+len(needle) : end])))
+	if end < len(runes) {
+		b.WriteString("…")
+	}
+	return b.String()
+}
+
+// Highlight 转义数据库生成的摘要，并将匹配标记替换为 <mark></mark>
+func Highlight(snippet string) string {
+	escaped := html.EscapeString(snippet)
+	escaped = strings.ReplaceAll(escaped, markStart, "<mark>")
+	return strings.ReplaceAll(escaped, markEnd, "</mark>")
+}
+
+// likeBackend 使用 LIKE 逐行匹配，不依赖数据库的全文索引能力
+type likeBackend struct{}
+
+func (b *likeBackend) Name() string { return "like" }
+
+func (b *likeBackend) Setup(db *gorm.DB) error {
+	// 曾启用过 FTS5 的 SQLite 数据库需移除同步触发器，避免写入检索文档时报错
+	if db.Dialector.Name() == "sqlite" {
+		dropSQLiteTriggers(db)
+	}
+	return nil
+}
+
+func (b *likeBackend) Match(db *gorm.DB, keyword string) (*gorm.DB, string) {
+	return likeMatch(db, keyword), ""
+}
+
+func (b *likeBackend) Rebuild(db *gorm.DB) error { return nil }
+
+// likeMatch 以子串方式匹配检索内容，按时间倒序排列
+func likeMatch(db *gorm.DB, keyword string) *gorm.DB {
+	escaped := strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(keyword)
+	return db.Where("d.content LIKE ? ESCAPE '!'", "%"+escaped+"%").Order("d.created_at DESC")
+}
+
+// phrase 将关键词转为 FTS5 的短语查询
+func phrase(keyword string) string {
+	return `"` + strings.ReplaceAll(keyword, `"`, `""`) + `"`
+}
+
+// runeCount 关键词的字符数
+func runeCount(keyword string) int {
+	return utf8.RuneCountInString(keyword)
+}
+
+// registerCallbacks 在消息、对话与客户写入后同步检索文档，同步失败只记录日志，不影响原操作
+func registerCallbacks(db *gorm.DB) {
+	callback := func(created bool) func(db *gorm.DB) {
+		return func(db *gorm.DB) {
+			if db.Error != nil || db.Statement.Schema == nil {
+				return
+			}
+			table := db.Statement.Schema.Table
+			if indexedColumns[table] == nil {
+				return
+			}
+			// 在同一连接（事务）中写入检索文档
+			tx := db.Session(&gorm.Session{NewDB: true, SkipHooks: true})
+			if err := syncDocuments(tx, table, db.Statement, created); err != nil {
+				logger.App.Error("同步检索文档失败", zap.String("table", table), zap.Error(err))
+			}
+		}
+	}
+	db.Callback().Create().After("gorm:create").Register("search:sync_create", callback(true))
+	db.Callback().Update().After("gorm:update").Register("search:sync_update", callback(false))
+}
+
+// 参与检索的字段，只更新其他字段时无需重建文档
+var indexedColumns = map[string]map[string]bool{
+	"cs_messages":      {"content": true, "sender": true, "conversation_id": true},
+	"cs_conversations": {"title": true, "uuid": true, "dootask_task_id": true, "customer_id": true},
+	"cs_customers":     {"name": true, "email": true, "phone": true, "external_id": true},
+}
+
+// updatesIndexed 根据更新的字段判断是否可能修改了参与检索的字段
+// 无法从语句判断时（如 Save 整条记录）返回true，由 saveChanged 比较文档内容
+func updatesIndexed(table string, statement *gorm.Statement) bool {
+	columns := indexedColumns[table]
+	if len(statement.Selects) > 0 {
+		for _, column := range statement.Selects {
+			if column == "*" || columns[column] {
+				return true
+			}
+			if field := statement.Schema.LookUpField(column); field != nil && columns[field.DBName] {
+				return true
+			}
+		}
+		return false
+	}
+	if updates, ok := statement.Dest.(map[string]interface{}); ok {
+		for column := range updates {
+			if columns[column] {
+				return true
+			}
+			if field := statement.Schema.LookUpField(column); field != nil && columns[field.DBName] {
+				return true
+			}
+		}
+		return false
+	}
+
+	// Updates(struct) 只更新非零值字段
+	dest := reflect.ValueOf(statement.Dest)
+	if dest.Kind() != reflect.Ptr || dest.Elem().Kind() != reflect.Struct || statement.Dest == statement.Model {
+		return true
+	}
+	for _, field := range statement.Schema.Fields {
+		if !columns[field.DBName] {
+			continue
+		}
+		if _, zero := field.ValueOf(statement.Context, dest.Elem()); !zero {
+			return true
+		}
+	}
+	return false
+}
+
+// syncDocuments 根据写入的记录更新检索文档，更新记录时只写入内容变化的文档
+func syncDocuments(tx *gorm.DB, table string, statement *gorm.Statement, created bool) error {
+	save := Save
+	if !created {
+		if !updatesIndexed(table, statement) {
+			return nil
+		}
+		save = saveChanged
+	}
+
+	var err error
+	each(statement.ReflectValue, func(value interface{}) {
+		if err != nil {
+			return
+		}
+		switch record := value.(type) {
+		case *models.Message:
+			err = save(tx, MessageDocument(record))
+		case *models.Conversations:
+			if record.ID > 0 {
+				err = indexConversation(tx, record.ID, save)
+			}
+		case *models.Customer:
+			if record.ID == 0 {
+				return
+			}
+			var ids []uint
+			if err = tx.Model(&models.Conversations{}).Where("customer_id = ?", record.ID).Pluck("id", &ids).Error; err != nil {
+				return
+			}
+			for _, id := range ids {
+				if err = indexConversation(tx, id, save); err != nil {
+					return
+				}
+			}
+		}
+	})
+	return err
+}
+
+// indexConversation 使用指定的写入方式重新生成对话的元数据检索文档
+func indexConversation(tx *gorm.DB, conversationID uint, save func(*gorm.DB, ...*models.SearchDocument) error) error {
+	document, err := conversationDocument(tx, conversationID)
+	if err != nil {
+		return err
+	}
+	return save(tx, document)
+}
+
+// each 遍历写入的单条记录或批量记录
+func each(value reflect.Value, fn func(interface{})) {
+	for value.Kind() == reflect.Ptr {
+		value = value.Elem()
+	}
+	switch value.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			each(value.Index(i), fn)
+		}
+	case reflect.Struct:
+		if value.CanAddr() {
+			fn(value.Addr().Interface())
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package search
+
+import (
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// MySQL ngram 分词默认按2个字符切分，更短的关键词使用 LIKE 匹配
+const mysqlMinKeywordLength = 2
+
+// mysqlBackend 使用 InnoDB FULLTEXT 索引与 ngram 分词，支持中文等无空格分词的语言
+type mysqlBackend struct{}
+
+func (b *mysqlBackend) Name() string { return "mysql-fulltext" }
+
+func (b *mysqlBackend) Setup(db *gorm.DB) error {
+	var count int64
+	err := db.Raw(`SELECT COUNT(*) FROM information_schema.statistics
+	WHERE table_schema = DATABASE() AND table_name = 'cs_search_documents' AND index_name = 'ft_search_content'`).
+		Scan(&count).Error
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	return db.Exec("ALTER TABLE cs_search_documents ADD FULLTEXT INDEX ft_search_content (content) WITH PARSER ngram").Error
+}
+
+func (b *mysqlBackend) Match(db *gorm.DB, keyword string) (*gorm.DB, string) {
+	if runeCount(keyword) < mysqlMinKeywordLength {
+		return likeMatch(db, keyword), ""
+	}
+	// 布尔模式的短语不支持转义双引号
+	query := `"` + strings.ReplaceAll(keyword, `"`, " ") + `"`
+	db = db.Where("MATCH(d.content) AGAINST (? IN BOOLEAN MODE)", query).
+		Order(clause.Expr{SQL: "MATCH(d.content) AGAINST (? IN BOOLEAN MODE) DESC", Vars: []interface{}{query}}).
+		Order("d.created_at DESC")
+	return db, ""
+}
+
+// Rebuild InnoDB 全文索引随数据自动维护，无需重建
+func (b *mysqlBackend) Rebuild(db *gorm.DB) error { return nil }
@@ -0,0 +1,81 @@
+package search
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SQLite FTS5 的 trigram 分词要求查询至少3个字符，更短的关键词使用 LIKE 匹配
+const sqliteMinKeywordLength = 3
+
+// 同步 cs_search_documents 与 FTS5 索引的触发器
+var sqliteTriggers = map[string]string{
+	"cs_search_documents_ai": `CREATE TRIGGER cs_search_documents_ai AFTER INSERT ON cs_search_documents BEGIN
+	INSERT INTO cs_search_fts(rowid, content) VALUES (new.id, new.content);
+END`,
+	"cs_search_documents_ad": `CREATE TRIGGER cs_search_documents_ad AFTER DELETE ON cs_search_documents BEGIN
+	INSERT INTO cs_search_fts(cs_search_fts, rowid, content) VALUES ('delete', old.id, old.content);
+END`,
+	"cs_search_documents_au": `CREATE TRIGGER cs_search_documents_au AFTER UPDATE ON cs_search_documents BEGIN
+	INSERT INTO cs_search_fts(cs_search_fts, rowid, content) VALUES ('delete', old.id, old.content);
+	INSERT INTO cs_search_fts(rowid, content) VALUES (new.id, new.content);
+END`,
+}
+
+// sqliteBackend 使用 FTS5 外部内容表与 trigram 分词，支持中文等无空格分词的语言
+// 需使用 -tags sqlite_fts5 编译 go-sqlite3
+type sqliteBackend struct{}
+
+func (b *sqliteBackend) Name() string { return "sqlite-fts5" }
+
+func (b *sqliteBackend) Setup(db *gorm.DB) error {
+	err := db.Exec(`CREATE VIRTUAL TABLE IF NOT EXISTS cs_search_fts USING fts5(
+	content, content='cs_search_documents', content_rowid='id', tokenize='trigram')`).Error
+	if err != nil {
+		return err
+	}
+	// 已存在的虚拟表不会加载 FTS5 模块，查询一次以确认当前编译支持 FTS5
+	if err := db.Exec("SELECT rowid FROM cs_search_fts LIMIT 0").Error; err != nil {
+		return err
+	}
+
+	// 触发器缺失时，期间写入的文档未进入索引，创建后重建索引
+	created := false
+	for name, statement := range sqliteTriggers {
+		var count int64
+		db.Raw("SELECT COUNT(*) FROM sqlite_master WHERE type = 'trigger' AND name = ?", name).Scan(&count)
+		if count > 0 {
+			continue
+		}
+		if err := db.Exec(statement).Error; err != nil {
+			return err
+		}
+		created = true
+	}
+	if created {
+		return b.Rebuild(db)
+	}
+	return nil
+}
+
+func (b *sqliteBackend) Match(db *gorm.DB, keyword string) (*gorm.DB, string) {
+	if runeCount(keyword) < sqliteMinKeywordLength {
+		return likeMatch(db, keyword), ""
+	}
+	db = db.Joins("JOIN cs_search_fts ON cs_search_fts.rowid = d.id").
+		Where("cs_search_fts MATCH ?", phrase(keyword)).
+		Order(clause.Expr{SQL: "bm25(cs_search_fts)"}).
+		Order("d.created_at DESC")
+	return db, "snippet(cs_search_fts, 0, '" + markStart + "', '" + markEnd + "', '…', 24)"
+}
+
+func (b *sqliteBackend) Rebuild(db *gorm.DB) error {
+	return db.Exec("INSERT INTO cs_search_fts(cs_search_fts) VALUES ('rebuild')").Error
+}
+
+// dropSQLiteTriggers 移除 FTS5 同步触发器
+func dropSQLiteTriggers(db *gorm.DB) {
+	for name := range sqliteTriggers {
+		db.Exec("DROP TRIGGER IF EXISTS " + name)
+	}
+}
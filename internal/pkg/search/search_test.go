@@ -0,0 +1,71 @@
+package search
+
+import (
+	"testing"
+
+	"go.uber.org/zap"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+
+	"support-plugin/internal/models"
+	"support-plugin/internal/pkg/logger"
+)
+
+func TestSyncDocumentsOnlyWhenIndexedFieldsChange(t *testing.T) {
+	logger.App = zap.NewNop()
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: gormlogger.Discard})
+	if err != nil {
+		t.Fatalf("打开测试数据库失败: %v", err)
+	}
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+	if err := db.AutoMigrate(&models.Conversations{}, &models.Customer{}, &models.Message{}, &models.SearchDocument{}); err != nil {
+		t.Fatalf("初始化测试表失败: %v", err)
+	}
+	registerCallbacks(db)
+
+	// 统计写入检索文档的次数
+	writes := 0
+	db.Callback().Create().After("gorm:create").Register("test:count_documents", func(db *gorm.DB) {
+		if db.Error == nil && db.Statement.Schema != nil && db.Statement.Schema.Table == "cs_search_documents" {
+			writes++
+		}
+	})
+
+	customer := models.Customer{Name: "张三", Email: "zhangsan@example.com"}
+	db.Create(&customer)
+	conversation := models.Conversations{Uuid: "conv-1", CustomerID: customer.ID, Title: "退款咨询", Status: "open"}
+	db.Create(&conversation)
+	message := models.Message{ConversationID: conversation.ID, Sender: "customer", Content: "订单未到账"}
+	db.Create(&message)
+
+	steps := []struct {
+		name   string
+		update func()
+		writes int
+	}{
+		{"更新消息元数据", func() { db.Model(&message).Update("metadata", "{}") }, 0},
+		{"更新对话状态", func() { db.Model(&conversation).Updates(map[string]interface{}{"status": "closed"}) }, 0},
+		{"以结构体更新对话优先级", func() { db.Model(&conversation).Updates(models.Conversations{Priority: "high"}) }, 0},
+		{"保存未变化的客户", func() { db.Save(&customer) }, 0},
+		{"保存未变化的对话", func() { db.Save(&conversation) }, 0},
+		{"修改消息内容", func() { db.Model(&message).Update("content", "订单已到账") }, 1},
+		{"修改对话标题", func() { db.Model(&conversation).Updates(models.Conversations{Title: "到账查询"}) }, 1},
+		{"保存修改后的客户", func() { customer.Phone = "13800000000"; db.Save(&customer) }, 1},
+	}
+	for _, step := range steps {
+		writes = 0
+		step.update()
+		if writes != step.writes {
+			t.Errorf("%s: 写入检索文档 %d 次, want %d", step.name, writes, step.writes)
+		}
+	}
+
+	var document models.SearchDocument
+	db.Where("kind = ? AND ref_id = ?", models.SearchKindConversation, conversation.ID).First(&document)
+	if document.Content != "到账查询\nconv-1\n张三\nzhangsan@example.com\n13800000000" {
+		t.Errorf("对话检索文档 = %q", document.Content)
+	}
+}
@@ -0,0 +1,61 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+
+	"support-plugin/internal/config"
+)
+
+// S3Storage S3兼容对象存储（AWS S3、MinIO、OSS等）
+type S3Storage struct {
+	client *minio.Client
+	bucket string
+}
+
+// NewS3Storage 创建S3兼容存储，存储桶需预先创建
+func NewS3Storage(storageCfg config.StorageConfig) (*S3Storage, error) {
+	if storageCfg.Endpoint == "" || storageCfg.Bucket == "" {
+		return nil, errors.New("s3 storage requires endpoint and bucket")
+	}
+	client, err := minio.New(storageCfg.Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(storageCfg.AccessKey, storageCfg.SecretKey, ""),
+		Secure: storageCfg.UseSSL,
+		Region: storageCfg.Region,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &S3Storage{client: client, bucket: storageCfg.Bucket}, nil
+}
+
+func (s *S3Storage) Save(key string, reader io.Reader, size int64, contentType string) error {
+	_, err := s.client.PutObject(context.Background(), s.bucket, key, reader, size, minio.PutObjectOptions{
+		ContentType: contentType,
+	})
+	return err
+}
+
+func (s *S3Storage) Open(key string) (io.ReadCloser, error) {
+	object, err := s.client.GetObject(context.Background(), s.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+	// GetObject 不会立即请求，通过 Stat 确认对象存在
+	if _, err := object.Stat(); err != nil {
+		object.Close()
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return object, nil
+}
+
+func (s *S3Storage) Delete(key string) error {
+	return s.client.RemoveObject(context.Background(), s.bucket, key, minio.RemoveObjectOptions{})
+}
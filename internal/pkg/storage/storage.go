@@ -0,0 +1,45 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+
+	"support-plugin/internal/config"
+)
+
+// ErrNotFound 存储对象不存在
+var ErrNotFound = errors.New("storage: object not found")
+
+// Storage 附件存储接口，key 为存储内的相对路径
+type Storage interface {
+	// Save 保存对象，size 未知时传 -1
+	Save(key string, reader io.Reader, size int64, contentType string) error
+	// Open 打开对象用于读取，调用方负责关闭
+	Open(key string) (io.ReadCloser, error)
+	// Delete 删除对象，对象不存在时不返回错误
+	Delete(key string) error
+}
+
+// Default 全局附件存储
+var Default Storage
+
+// InitStorage 根据配置初始化附件存储
+func InitStorage() {
+	storageCfg := config.Cfg.Storage
+
+	var err error
+	switch storageCfg.Type {
+	case "", "local":
+		Default, err = NewLocalStorage(storageCfg.Dir)
+	case "s3":
+		Default, err = NewS3Storage(storageCfg)
+	default:
+		err = fmt.Errorf("不支持的存储类型: %s", storageCfg.Type)
+	}
+	if err != nil {
+		log.Fatalf("初始化附件存储失败: %v", err)
+	}
+	log.Printf("附件存储初始化成功: %s\n", storageCfg.Type)
+}
@@ -0,0 +1,132 @@
+package websocket
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+
+	"go.uber.org/zap"
+
+	"support-plugin/internal/config"
+	"support-plugin/internal/pkg/logger"
+)
+
+// 跨节点推送的投递方式
+const (
+	EnvelopeConversation       = "conversation"        // 推送给会话中的客户端
+	EnvelopeAllAgents          = "all_agents"          // 推送给所有客服
+	EnvelopeAgent              = "agent"               // 推送给指定客服的所有连接
+	EnvelopeConversationAgents = "conversation_agents" // 推送给与对话相关的客服
+	EnvelopeDisconnect         = "disconnect"          // 断开会话中的客户连接
+	EnvelopePresence           = "presence"            // 节点在线连接的变化或全量统计
+	EnvelopePresenceLeave      = "presence_leave"      // 节点下线，清除其在线连接
+	EnvelopePresenceSync       = "presence_sync"       // 新节点请求其他节点发布全量统计
+)
+
+// Envelope 节点间转发的推送，由各节点投递给本地连接
+type Envelope struct {
+	Kind     string          `json:"kind"`
+	Target   string          `json:"target,omitempty"`   // 会话UUID或客服ID
+	Audience *Audience       `json:"audience,omitempty"` // 对话相关客服，由发起节点计算
+	Payload  json.RawMessage `json:"payload,omitempty"`  // 序列化后的 Message
+	Origin   string          `json:"origin"`             // 发起节点
+}
+
+// PresenceSnapshot 在线连接统计
+type PresenceSnapshot struct {
+	Agents    map[string]int `json:"agents"`    // 客服ID -> 连接数
+	Customers map[string]int `json:"customers"` // 会话UUID -> 客户连接数
+}
+
+// Backplane 跨节点推送通道，单节点部署使用内存实现，多副本部署使用Redis实现
+type Backplane interface {
+	// Publish 发布推送，所有节点（包括自身）都会收到
+	Publish(envelope *Envelope) error
+	// Subscribe 注册推送处理函数，只调用一次
+	Subscribe(handler func(*Envelope)) error
+	// Close 关闭推送通道
+	Close() error
+}
+
+// DefaultBackplane 全局推送通道
+var DefaultBackplane Backplane = NewMemoryBackplane()
+
+// NodeID 当前节点标识
+var NodeID = defaultNodeID()
+
+// InitBackplane 根据配置初始化推送通道
+func InitBackplane() {
+	wsCfg := config.Cfg.WebSocket
+	if wsCfg.NodeID != "" {
+		NodeID = wsCfg.NodeID
+	}
+
+	var err error
+	switch wsCfg.Backplane {
+	case "", "memory":
+		DefaultBackplane = NewMemoryBackplane()
+	case "redis":
+		DefaultBackplane, err = NewRedisBackplane(config.Cfg.Redis, wsCfg)
+	default:
+		err = fmt.Errorf("不支持的推送通道类型: %s", wsCfg.Backplane)
+	}
+	if err != nil {
+		log.Fatalf("初始化WebSocket推送通道失败: %v", err)
+	}
+	if err := DefaultBackplane.Subscribe(WebSocketManager.dispatch); err != nil {
+		log.Fatalf("订阅WebSocket推送通道失败: %v", err)
+	}
+	// 请求其他节点发布全量统计，无需等待下一次续期
+	WebSocketManager.publish(&Envelope{Kind: EnvelopePresenceSync})
+	log.Printf("WebSocket推送通道初始化成功: %s, 节点: %s\n", wsCfg.Backplane, NodeID)
+}
+
+// defaultNodeID 按主机名与进程号生成节点标识
+func defaultNodeID() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "node"
+	}
+	return fmt.Sprintf("%s-%d", hostname, os.Getpid())
+}
+
+// publish 经推送通道发布，发布失败时退回到只投递给本节点
+func (m *Manager) publish(envelope *Envelope) {
+	envelope.Origin = NodeID
+	if err := DefaultBackplane.Publish(envelope); err != nil {
+		logger.App.Error("发布跨节点推送失败，仅投递到本节点",
+			zap.String("kind", envelope.Kind),
+			zap.String("target", envelope.Target),
+			zap.Error(err))
+		m.dispatch(envelope)
+	}
+}
+
+// dispatch 将推送通道收到的推送投递给本节点的连接
+func (m *Manager) dispatch(envelope *Envelope) {
+	switch envelope.Kind {
+	case EnvelopeConversation:
+		m.deliverConversation(envelope.Target, envelope.Payload)
+	case EnvelopeAllAgents:
+		m.deliverAllAgents(envelope.Payload)
+	case EnvelopeAgent:
+		m.deliverAgent(envelope.Target, envelope.Payload)
+	case EnvelopeConversationAgents:
+		m.deliverConversationAgents(envelope.Target, envelope.Audience, envelope.Payload)
+	case EnvelopeDisconnect:
+		m.disconnectCustomers(envelope.Target)
+	case EnvelopePresence, EnvelopePresenceLeave, EnvelopePresenceSync:
+		m.dispatchPresence(envelope)
+	default:
+		logger.App.Warn("忽略未知类型的跨节点推送", zap.String("kind", envelope.Kind))
+	}
+}
+
+// ShutdownBackplane 通知其他节点清除本节点的在线状态并关闭推送通道
+func ShutdownBackplane() {
+	if err := DefaultBackplane.Publish(&Envelope{Kind: EnvelopePresenceLeave, Origin: NodeID}); err != nil {
+		logger.App.Warn("清除节点在线状态失败", zap.Error(err))
+	}
+	DefaultBackplane.Close()
+}
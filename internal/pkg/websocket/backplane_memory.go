@@ -0,0 +1,35 @@
+package websocket
+
+import "sync"
+
+// MemoryBackplane 单节点推送通道，发布时直接投递给本节点
+type MemoryBackplane struct {
+	mutex   sync.RWMutex
+	handler func(*Envelope)
+}
+
+// NewMemoryBackplane 创建单节点推送通道
+func NewMemoryBackplane() *MemoryBackplane {
+	return &MemoryBackplane{}
+}
+
+func (b *MemoryBackplane) Publish(envelope *Envelope) error {
+	b.mutex.RLock()
+	handler := b.handler
+	b.mutex.RUnlock()
+	if handler != nil {
+		handler(envelope)
+	}
+	return nil
+}
+
+func (b *MemoryBackplane) Subscribe(handler func(*Envelope)) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.handler = handler
+	return nil
+}
+
+func (b *MemoryBackplane) Close() error {
+	return nil
+}
@@ -0,0 +1,84 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+
+	"support-plugin/internal/config"
+	"support-plugin/internal/pkg/logger"
+)
+
+// RedisBackplane 基于Redis发布订阅的跨节点推送通道
+// 在线状态同样经发布订阅在节点间同步，节点异常退出后由其他节点按有效期清除
+type RedisBackplane struct {
+	client  *redis.Client
+	channel string
+	pubsub  *redis.PubSub
+}
+
+// NewRedisBackplane 创建Redis推送通道
+func NewRedisBackplane(redisCfg config.RedisConfig, wsCfg config.WebSocketConfig) (*RedisBackplane, error) {
+	client := redis.NewClient(&redis.Options{
+		Addr:     fmt.Sprintf("%s:%d", redisCfg.Host, redisCfg.Port),
+		Password: redisCfg.Password,
+		DB:       redisCfg.DB,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("连接Redis失败: %w", err)
+	}
+
+	prefix := wsCfg.Prefix
+	if prefix == "" {
+		prefix = "cs:ws"
+	}
+	return &RedisBackplane{
+		client:  client,
+		channel: prefix + ":events",
+	}, nil
+}
+
+func (b *RedisBackplane) Publish(envelope *Envelope) error {
+	data, err := json.Marshal(envelope)
+	if err != nil {
+		return err
+	}
+	return b.client.Publish(context.Background(), b.channel, data).Err()
+}
+
+func (b *RedisBackplane) Subscribe(handler func(*Envelope)) error {
+	ctx := context.Background()
+	b.pubsub = b.client.Subscribe(ctx, b.channel)
+	// 等待订阅确认，确保启动后发布的推送不会丢失
+	if _, err := b.pubsub.Receive(ctx); err != nil {
+		return err
+	}
+
+	go func() {
+		// 连接断开时 go-redis 会自动重连并重新订阅
+		for msg := range b.pubsub.Channel() {
+			var envelope Envelope
+			if err := json.Unmarshal([]byte(msg.Payload), &envelope); err != nil {
+				logger.App.Warn("解析跨节点推送失败", zap.Error(err))
+				continue
+			}
+			handler(&envelope)
+		}
+	}()
+	return nil
+}
+
+func (b *RedisBackplane) Close() error {
+	if b.pubsub != nil {
+		b.pubsub.Close()
+	}
+	return b.client.Close()
+}
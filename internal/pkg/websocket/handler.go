@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"support-plugin/internal/config"
 	"support-plugin/internal/pkg/dootask"
 	"support-plugin/internal/pkg/logger"
 	"time"
@@ -25,7 +26,7 @@ const (
 	pingPeriod = (pongWait * 9) / 10
 
 	// 最大消息大小
-	maxMessageSize = 512
+	maxMessageSize = 64 * 1024
 )
 
 var upgrader = websocket.Upgrader{
@@ -53,7 +54,17 @@ func ServeWs(c *gin.Context) {
 		return
 	}
 	agentID := "0"
-	if clientType == "agent" {
+	isAdmin := false
+	if clientType == "agent" && config.Cfg.App.Mode != "dootask" {
+		// 独立模式的登录令牌已在路由处理函数中校验
+		id := c.GetUint("agent_id")
+		if id == 0 {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			return
+		}
+		agentID = fmt.Sprintf("%d", id)
+		isAdmin = c.GetBool("is_admin")
+	} else if clientType == "agent" {
 		// 客服端需要验证Token
 		// TODO: 验证Token
 		token := c.Query("token")
@@ -62,13 +73,13 @@ func ServeWs(c *gin.Context) {
 			return
 		}
 
-		dootaskService := dootask.NewIDootaskService()
-		userInfoResp, err := dootaskService.GetUserInfo(token)
+		userInfoResp, err := dootask.UserCache.GetUserInfo(token)
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid token 2"})
 			return
 		}
 		agentID = fmt.Sprintf("%d", userInfoResp.Userid)
+		isAdmin = userInfoResp.IsAdmin()
 	}
 
 	// 升级HTTP连接为WebSocket连接
@@ -85,6 +96,7 @@ func ServeWs(c *gin.Context) {
 		ConvUUID:   convUUID,
 		ClientType: clientType,
 		AgentID:    agentID,
+		IsAdmin:    isAdmin,
 	}
 	// 注册新客户端
 	logger.App.Info("准备发送客户端到注册通道",
@@ -104,6 +116,10 @@ func ServeWs(c *gin.Context) {
 // readPump 从WebSocket连接读取消息
 func (c *Client) readPump() {
 	defer func() {
+		// 连接断开时结束输入状态
+		if c.typing {
+			c.handleTyping(&TypingRequest{ConvUUID: c.ConvUUID, Typing: false})
+		}
 		WebSocketManager.Unregister <- c
 		c.Conn.Close()
 	}()
@@ -121,19 +137,13 @@ func (c *Client) readPump() {
 			break
 		}
 
-		// 解析消息
-		var msg Message
-		if err := json.Unmarshal(message, &msg.Data); err != nil {
+		// 解析客户端消息
+		var msg ClientMessage
+		if err := json.Unmarshal(message, &msg); err != nil {
 			log.Printf("error unmarshalling message: %v", err)
 			continue
 		}
-
-		// 设置发送者和会话UUID
-		msg.Sender = c.ClientType
-		msg.ConvUUID = c.ConvUUID
-
-		// 广播消息
-		WebSocketManager.Broadcast <- &msg
+		c.handleClientMessage(&msg)
 	}
 }
 
@@ -208,6 +218,12 @@ func BroadcastToAllAgents(data interface{}, msgType MessageType) {
 	WebSocketManager.SendToAllAgents(data, msgType)
 }
 
+// SendToAgent 向指定客服推送消息
+func SendToAgent(agentID string, data interface{}, msgType MessageType) {
+	logger.App.Info("准备向客服推送消息", zap.String("agentID", agentID), zap.Any("msgType", msgType))
+	WebSocketManager.SendToAgent(agentID, data, msgType)
+}
+
 // BroadcastMessage 向特定会话广播消息
 func BroadcastMessage(convUUID string, data interface{}, msgType MessageType) error {
 	logger.App.Info("准备广播消息", zap.String("ConvUUID", convUUID), zap.Any("msgType", msgType))
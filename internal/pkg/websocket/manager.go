@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"support-plugin/internal/pkg/logger"
 	"sync"
+	"time"
 
 	"github.com/gorilla/websocket"
 	"go.uber.org/zap"
@@ -16,18 +17,29 @@ type Client struct {
 	ConvUUID   string // 关联的会话UUID
 	ClientType string // 客户端类型："agent"或"customer"
 	AgentID    string // 客服ID (如果ClientType是agent)
+	IsAdmin    bool   // 是否为管理员，管理员可订阅全部对话
+
+	// 输入状态节流，仅在readPump协程中读写
+	typing   bool
+	typingAt time.Time
 }
 
 // Manager 管理所有WebSocket连接
 type Manager struct {
-	Clients        map[*Client]bool
-	ConvClients    map[string][]*Client // 按会话UUID组织的客户端
-	AgentClients   []*Client            // 所有客服连接
-	Register       chan *Client
-	Unregister     chan *Client
-	Broadcast      chan *Message
-	AgentBroadcast chan *Message
-	mutex          sync.RWMutex
+	Clients      map[*Client]bool
+	ConvClients  map[string][]*Client        // 按会话UUID组织的客户端
+	AgentClients []*Client                   // 所有客服连接
+	AgentIndex   map[string]map[*Client]bool // 按客服ID组织的客服连接，同一客服可有多个标签页
+	Subscribers  map[*Client]bool            // 订阅全部对话的管理员连接
+	Register     chan *Client
+	Unregister   chan *Client
+	Broadcast    chan *Message
+	mutex        sync.RWMutex
+
+	// 本地连接变化时通知在线状态上报协程
+	presenceChanged chan struct{}
+	// 各节点在线连接统计的本地缓存
+	presence *clusterPresence
 }
 
 // Message 表示通过WebSocket发送的消息
@@ -56,18 +68,36 @@ const (
 	MessageTypeNewConversation MessageType = "new_conversation"
 	// MessageTypeNewMessage 新消息通知
 	MessageTypeNewMessage MessageType = "new_message"
+	// MessageTypeConversationAssigned 会话分配通知
+	MessageTypeConversationAssigned MessageType = "conversation_assigned"
+	// MessageTypeMessageRead 消息已读回执
+	MessageTypeMessageRead MessageType = "message_read"
+	// MessageTypeConversationTransferred 会话转接通知
+	MessageTypeConversationTransferred MessageType = "conversation_transferred"
+	// MessageTypeConversationInvited 会话邀请通知
+	MessageTypeConversationInvited MessageType = "conversation_invited"
+	// MessageTypeSubscribed 全部对话订阅状态
+	MessageTypeSubscribed MessageType = "subscribed"
+	// MessageTypeCSATSurvey 满意度评价邀请
+	MessageTypeCSATSurvey MessageType = "csat_survey"
+	// MessageTypeSLAAlert SLA即将超时或已超时提醒
+	MessageTypeSLAAlert MessageType = "sla_alert"
 )
 
 // NewManager 创建一个新的WebSocket管理器
 func NewManager() *Manager {
 	return &Manager{
-		Clients:        make(map[*Client]bool),
-		ConvClients:    make(map[string][]*Client),
-		AgentClients:   make([]*Client, 0),
-		Register:       make(chan *Client, 1000),
-		Unregister:     make(chan *Client, 1000),  // 增加缓冲区大小
-		Broadcast:      make(chan *Message, 1000), // 增加缓冲区大小
-		AgentBroadcast: make(chan *Message, 1000), // 增加缓冲区大小,
+		Clients:      make(map[*Client]bool),
+		ConvClients:  make(map[string][]*Client),
+		AgentClients: make([]*Client, 0),
+		AgentIndex:   make(map[string]map[*Client]bool),
+		Subscribers:  make(map[*Client]bool),
+		Register:     make(chan *Client, 1000),
+		Unregister:   make(chan *Client, 1000),  // 增加缓冲区大小
+		Broadcast:    make(chan *Message, 1000), // 增加缓冲区大小
+
+		presenceChanged: make(chan struct{}, 1),
+		presence:        newClusterPresence(),
 	}
 }
 
@@ -79,6 +109,8 @@ func (m *Manager) Start() {
 		}
 	}()
 
+	go m.reportPresence()
+
 	for {
 		select {
 		case client := <-m.Register:
@@ -89,9 +121,6 @@ func (m *Manager) Start() {
 
 		case message := <-m.Broadcast:
 			m.handleBroadcast(message)
-
-		case message := <-m.AgentBroadcast:
-			m.handleAgentBroadcast(message)
 		}
 
 	}
@@ -126,8 +155,14 @@ func (m *Manager) handleRegister(client *Client) {
 			zap.String("agentID", client.AgentID),
 			zap.String("remoteAddr", client.Conn.RemoteAddr().String()))
 		m.AgentClients = append(m.AgentClients, client)
+		if m.AgentIndex[client.AgentID] == nil {
+			m.AgentIndex[client.AgentID] = make(map[*Client]bool)
+		}
+		m.AgentIndex[client.AgentID][client] = true
 	}
 
+	m.notifyPresence()
+
 	logger.App.Info("Manager 完成新客户端处理",
 		zap.String("remoteAddr", client.Conn.RemoteAddr().String()),
 		zap.String("ClientType", client.ClientType))
@@ -153,6 +188,7 @@ func (m *Manager) handleUnregister(client *Client) {
 		if client.ClientType == "agent" {
 			m.removeFromAgentClients(client)
 		}
+		m.notifyPresence()
 	}
 
 	logger.App.Info("Manager 完成客户端注销处理",
@@ -189,6 +225,14 @@ func (m *Manager) removeFromAgentClients(client *Client) {
 			break
 		}
 	}
+
+	if clients, ok := m.AgentIndex[client.AgentID]; ok {
+		delete(clients, client)
+		if len(clients) == 0 {
+			delete(m.AgentIndex, client.AgentID)
+		}
+	}
+	delete(m.Subscribers, client)
 }
 
 // handleBroadcast 处理广播消息
@@ -233,19 +277,14 @@ func (m *Manager) handleBroadcast(message *Message) {
 	}
 }
 
-// handleAgentBroadcast 处理客服端广播消息
-func (m *Manager) handleAgentBroadcast(message *Message) {
+// deliverAllAgents 向本节点的所有客服连接投递消息
+func (m *Manager) deliverAllAgents(msgBytes []byte) {
 	m.mutex.RLock()
 	defer m.mutex.RUnlock()
 
 	// 收集需要移除的客户端
 	var failedClients []*Client
 
-	msgBytes, err := json.Marshal(message)
-	if err != nil {
-		return
-	}
-
 	// 向所有客服广播新会话或新消息通知
 	for _, client := range m.AgentClients {
 		select {
@@ -268,9 +307,7 @@ func (m *Manager) cleanupFailedClients(clients []*Client) {
 	logger.App.Warn("存在发送失败的客户端，开始清理",
 		zap.Int("failedCount", len(clients)))
 	for _, client := range clients {
-		// 关闭发送通道
-		close(client.Send)
-		// 通过 Unregister 通道移除客户端
+		// 通过 Unregister 通道移除客户端，发送通道由 handleUnregister 统一关闭
 		select {
 		case m.Unregister <- client:
 		default:
@@ -281,8 +318,17 @@ func (m *Manager) cleanupFailedClients(clients []*Client) {
 	}
 }
 
-// SendToConversation 向特定会话的所有客户端发送消息
+// SendToConversation 向特定会话的所有客户端发送消息，经推送通道投递到所有节点
 func (m *Manager) SendToConversation(convUUID string, message []byte) {
+	m.publish(&Envelope{
+		Kind:    EnvelopeConversation,
+		Target:  convUUID,
+		Payload: message,
+	})
+}
+
+// deliverConversation 向本节点中会话的客户端投递消息
+func (m *Manager) deliverConversation(convUUID string, message []byte) {
 	logger.App.Info("开始向会话发送消息",
 		zap.String("convUUID", convUUID),
 		zap.Int("messageLength", len(message)))
@@ -291,7 +337,7 @@ func (m *Manager) SendToConversation(convUUID string, message []byte) {
 	clients, ok := m.ConvClients[convUUID]
 	if !ok {
 		m.mutex.RUnlock()
-		logger.App.Warn("未找到会话对应的客户端",
+		logger.App.Debug("本节点未找到会话对应的客户端",
 			zap.String("convUUID", convUUID))
 		return
 	}
@@ -367,11 +413,83 @@ func (m *Manager) SendToAllAgents(data interface{}, messasgeType MessageType) {
 		logger.App.Error("Failed to marshal new conversation data content", zap.Error(err))
 		return
 	}
-	message := &Message{
+	msgBytes, err := json.Marshal(&Message{
 		Data: json.RawMessage(dataBytes),
 		Type: messasgeType,
+	})
+	if err != nil {
+		return
+	}
+	m.publish(&Envelope{
+		Kind:    EnvelopeAllAgents,
+		Payload: msgBytes,
+	})
+}
+
+// SendToAgent 向指定客服的所有连接发送消息
+func (m *Manager) SendToAgent(agentID string, data interface{}, messageType MessageType) {
+	dataBytes, err := json.Marshal(data)
+	if err != nil {
+		logger.App.Error("Failed to marshal agent message data content", zap.Error(err))
+		return
 	}
-	m.AgentBroadcast <- message
+	msgBytes, err := json.Marshal(&Message{
+		Data: json.RawMessage(dataBytes),
+		Type: messageType,
+	})
+	if err != nil {
+		return
+	}
+
+	m.publish(&Envelope{
+		Kind:    EnvelopeAgent,
+		Target:  agentID,
+		Payload: msgBytes,
+	})
+	logger.App.Info("客服消息已发布",
+		zap.String("agentID", agentID),
+		zap.Any("msgType", messageType))
+}
+
+// deliverAgent 向本节点中指定客服的所有连接投递消息
+func (m *Manager) deliverAgent(agentID string, msgBytes []byte) {
+	m.mutex.RLock()
+	clientCount := len(m.AgentIndex[agentID])
+	failedClients := m.deliver(m.AgentIndex[agentID], msgBytes)
+	m.mutex.RUnlock()
+
+	if len(failedClients) > 0 {
+		go m.cleanupFailedClients(failedClients)
+	}
+
+	logger.App.Debug("客服消息发送完成",
+		zap.String("agentID", agentID),
+		zap.Int("clientCount", clientCount))
+}
+
+// DisconnectCustomers 断开所有节点中会话的客户连接，连接关闭后由readPump完成注销
+func (m *Manager) DisconnectCustomers(convUUID string) {
+	m.publish(&Envelope{
+		Kind:   EnvelopeDisconnect,
+		Target: convUUID,
+	})
+}
+
+// disconnectCustomers 断开本节点中会话的客户连接
+func (m *Manager) disconnectCustomers(convUUID string) {
+	m.mutex.RLock()
+	clients := make([]*Client, len(m.ConvClients[convUUID]))
+	copy(clients, m.ConvClients[convUUID])
+	m.mutex.RUnlock()
+
+	for _, client := range clients {
+		if client.ClientType == "customer" {
+			client.Conn.Close()
+		}
+	}
+	logger.App.Info("已断开会话客户端连接",
+		zap.String("convUUID", convUUID),
+		zap.Int("clientCount", len(clients)))
 }
 
 // GetAgentClientsCount 获取客服连接数量
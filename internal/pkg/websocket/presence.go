@@ -0,0 +1,314 @@
+package websocket
+
+import (
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+
+	"support-plugin/internal/config"
+	"support-plugin/internal/pkg/logger"
+)
+
+// AgentPresenceHandler 客服在所有节点上的连接上线或全部断开后调用，由service层注册
+type AgentPresenceHandler func(agentID string)
+
+var agentPresenceHandler AgentPresenceHandler
+
+// SetAgentPresenceHandler 注册客服连接变化的处理函数
+func SetAgentPresenceHandler(handler AgentPresenceHandler) {
+	agentPresenceHandler = handler
+}
+
+// PresenceUpdate 节点经推送通道发布的在线连接变化
+// Full 为 true 时为全量统计，否则只包含变化的条目，连接数为0表示已全部断开
+type PresenceUpdate struct {
+	Full      bool           `json:"full,omitempty"`
+	Agents    map[string]int `json:"agents,omitempty"`
+	Customers map[string]int `json:"customers,omitempty"`
+}
+
+// empty 增量更新没有任何变化
+func (u *PresenceUpdate) empty() bool {
+	return !u.Full && len(u.Agents) == 0 && len(u.Customers) == 0
+}
+
+// nodePresence 缓存的单个节点在线连接统计
+type nodePresence struct {
+	snapshot  *PresenceSnapshot
+	expiresAt time.Time
+}
+
+// clusterPresence 各节点在线连接统计的本地缓存，由推送通道上的更新维护
+// 汇总结果在变化时重建，读取时无需访问推送通道
+type clusterPresence struct {
+	mutex sync.RWMutex
+	nodes map[string]*nodePresence
+	total *PresenceSnapshot
+}
+
+func newClusterPresence() *clusterPresence {
+	return &clusterPresence{
+		nodes: map[string]*nodePresence{},
+		total: &PresenceSnapshot{Agents: map[string]int{}, Customers: map[string]int{}},
+	}
+}
+
+// snapshot 所有存活节点的汇总统计，返回值只读
+func (p *clusterPresence) snapshot() *PresenceSnapshot {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return p.total
+}
+
+// apply 应用节点的在线连接更新并续期，返回在线状态发生变化的客服
+func (p *clusterPresence) apply(nodeID string, update *PresenceUpdate, expiresAt time.Time) []string {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	node, ok := p.nodes[nodeID]
+	if !ok || update.Full {
+		node = &nodePresence{snapshot: &PresenceSnapshot{Agents: map[string]int{}, Customers: map[string]int{}}}
+		p.nodes[nodeID] = node
+	}
+	node.expiresAt = expiresAt
+	mergeCounts(node.snapshot.Agents, update.Agents)
+	mergeCounts(node.snapshot.Customers, update.Customers)
+	return p.rebuild()
+}
+
+// remove 移除已下线的节点，返回在线状态发生变化的客服
+func (p *clusterPresence) remove(nodeID string) []string {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if _, ok := p.nodes[nodeID]; !ok {
+		return nil
+	}
+	delete(p.nodes, nodeID)
+	return p.rebuild()
+}
+
+// expire 移除超过有效期未续期的其他节点，返回在线状态发生变化的客服
+func (p *clusterPresence) expire(self string, now time.Time) []string {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	expired := false
+	for nodeID, node := range p.nodes {
+		if nodeID != self && now.After(node.expiresAt) {
+			logger.App.Warn("节点在线状态已过期", zap.String("nodeID", nodeID))
+			delete(p.nodes, nodeID)
+			expired = true
+		}
+	}
+	if !expired {
+		return nil
+	}
+	return p.rebuild()
+}
+
+// leader 当前节点是否为存活节点中标识最小的节点，其他节点下线引起的变化由该节点统一处理
+func (p *clusterPresence) leader(self string) bool {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	for nodeID := range p.nodes {
+		if nodeID < self {
+			return false
+		}
+	}
+	return true
+}
+
+// rebuild 重建汇总统计，调用方需持有写锁
+func (p *clusterPresence) rebuild() []string {
+	total := &PresenceSnapshot{Agents: map[string]int{}, Customers: map[string]int{}}
+	for _, node := range p.nodes {
+		for agentID, count := range node.snapshot.Agents {
+			total.Agents[agentID] += count
+		}
+		for convUUID, count := range node.snapshot.Customers {
+			total.Customers[convUUID] += count
+		}
+	}
+
+	var changed []string
+	for agentID := range total.Agents {
+		if p.total.Agents[agentID] == 0 {
+			changed = append(changed, agentID)
+		}
+	}
+	for agentID := range p.total.Agents {
+		if total.Agents[agentID] == 0 {
+			changed = append(changed, agentID)
+		}
+	}
+	p.total = total
+	return changed
+}
+
+// mergeCounts 合并连接数，连接数为0的条目删除
+func mergeCounts(target, changes map[string]int) {
+	for key, count := range changes {
+		if count > 0 {
+			target[key] = count
+		} else {
+			delete(target, key)
+		}
+	}
+}
+
+// presenceDiff 计算本节点两次统计之间的增量
+func presenceDiff(last, current *PresenceSnapshot) *PresenceUpdate {
+	return &PresenceUpdate{
+		Agents:    diffCounts(last.Agents, current.Agents),
+		Customers: diffCounts(last.Customers, current.Customers),
+	}
+}
+
+// diffCounts 计算连接数的变化，已断开的条目记为0
+func diffCounts(last, current map[string]int) map[string]int {
+	changes := map[string]int{}
+	for key, count := range current {
+		if last[key] != count {
+			changes[key] = count
+		}
+	}
+	for key := range last {
+		if _, ok := current[key]; !ok {
+			changes[key] = 0
+		}
+	}
+	return changes
+}
+
+// presenceResync 其他节点请求全量统计时置位，下次上报发布全量统计
+var presenceResync atomic.Bool
+
+// notifyPresence 标记本地连接已变化，由上报协程合并处理
+func (m *Manager) notifyPresence() {
+	select {
+	case m.presenceChanged <- struct{}{}:
+	default:
+	}
+}
+
+// reportPresence 连接变化时发布增量，每隔在线状态有效期的三分之一发布全量统计续期并清理过期节点
+func (m *Manager) reportPresence() {
+	ttl := presenceTTL()
+	ticker := time.NewTicker(ttl / 3)
+	defer ticker.Stop()
+
+	last := &PresenceSnapshot{Agents: map[string]int{}, Customers: map[string]int{}}
+	for {
+		full := false
+		select {
+		case <-m.presenceChanged:
+			full = presenceResync.Swap(false)
+		case <-ticker.C:
+			full = true
+		}
+		now := time.Now()
+		current := m.localPresence()
+		update := presenceDiff(last, current)
+		if full {
+			update = &PresenceUpdate{Full: true, Agents: current.Agents, Customers: current.Customers}
+		}
+		last = current
+		if update.empty() {
+			continue
+		}
+
+		// 先更新本地缓存，处理函数读取到的在线状态已包含本次变化
+		changed := m.presence.apply(NodeID, update, now.Add(ttl))
+		if payload, err := json.Marshal(update); err == nil {
+			m.publish(&Envelope{Kind: EnvelopePresence, Payload: payload})
+		}
+		m.handlePresenceChanges(changed)
+
+		if full {
+			expired := m.presence.expire(NodeID, now)
+			if len(expired) > 0 && m.presence.leader(NodeID) {
+				m.handlePresenceChanges(expired)
+			}
+		}
+	}
+}
+
+// dispatchPresence 处理其他节点发布的在线状态
+// 节点自身的变化由该节点处理，下线节点引起的变化由存活节点中的主节点处理
+func (m *Manager) dispatchPresence(envelope *Envelope) {
+	if envelope.Origin == NodeID {
+		return
+	}
+	switch envelope.Kind {
+	case EnvelopePresence:
+		var update PresenceUpdate
+		if err := json.Unmarshal(envelope.Payload, &update); err != nil {
+			logger.App.Warn("解析节点在线状态失败", zap.String("nodeID", envelope.Origin), zap.Error(err))
+			return
+		}
+		m.presence.apply(envelope.Origin, &update, time.Now().Add(presenceTTL()))
+	case EnvelopePresenceLeave:
+		changed := m.presence.remove(envelope.Origin)
+		if m.presence.leader(NodeID) {
+			m.handlePresenceChanges(changed)
+		}
+	case EnvelopePresenceSync:
+		presenceResync.Store(true)
+		m.notifyPresence()
+	}
+}
+
+// handlePresenceChanges 通知在线状态发生变化的客服
+func (m *Manager) handlePresenceChanges(agentIDs []string) {
+	if agentPresenceHandler == nil {
+		return
+	}
+	for _, agentID := range agentIDs {
+		go agentPresenceHandler(agentID)
+	}
+}
+
+// localPresence 统计本节点的客服与客户连接
+func (m *Manager) localPresence() *PresenceSnapshot {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	snapshot := &PresenceSnapshot{
+		Agents:    make(map[string]int, len(m.AgentIndex)),
+		Customers: make(map[string]int, len(m.ConvClients)),
+	}
+	for agentID, clients := range m.AgentIndex {
+		snapshot.Agents[agentID] = len(clients)
+	}
+	for convUUID, clients := range m.ConvClients {
+		snapshot.Customers[convUUID] = len(clients)
+	}
+	return snapshot
+}
+
+// presenceTTL 在线状态有效期
+func presenceTTL() time.Duration {
+	if config.Cfg != nil && config.Cfg.WebSocket.PresenceTTL > 0 {
+		return time.Duration(config.Cfg.WebSocket.PresenceTTL) * time.Second
+	}
+	return 30 * time.Second
+}
+
+// Presence 所有存活节点的在线连接统计，读取本地缓存，返回值只读
+func Presence() *PresenceSnapshot {
+	return WebSocketManager.presence.snapshot()
+}
+
+// IsAgentOnline 客服是否在任一节点上有连接
+func IsAgentOnline(agentID string) bool {
+	return Presence().Agents[agentID] > 0
+}
+
+// IsCustomerOnline 会话的客户是否在任一节点上有连接
+func IsCustomerOnline(convUUID string) bool {
+	return Presence().Customers[convUUID] > 0
+}
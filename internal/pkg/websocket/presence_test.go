@@ -0,0 +1,77 @@
+package websocket
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"support-plugin/internal/pkg/logger"
+)
+
+func TestClusterPresence(t *testing.T) {
+	logger.App = zap.NewNop()
+	now := time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC)
+	ttl := 30 * time.Second
+	p := newClusterPresence()
+
+	steps := []struct {
+		name    string
+		run     func() []string
+		changed []string
+		online  map[string]int
+	}{
+		{"节点A全量上报", func() []string {
+			return p.apply("a", &PresenceUpdate{Full: true, Agents: map[string]int{"1": 1, "2": 2}}, now.Add(ttl))
+		}, []string{"1", "2"}, map[string]int{"1": 1, "2": 2}},
+		{"节点B上线同一客服", func() []string {
+			return p.apply("b", &PresenceUpdate{Agents: map[string]int{"2": 1, "3": 1}}, now.Add(ttl))
+		}, []string{"3"}, map[string]int{"1": 1, "2": 3, "3": 1}},
+		{"节点A增量断开", func() []string {
+			return p.apply("a", &PresenceUpdate{Agents: map[string]int{"1": 0, "2": 0}}, now.Add(2*ttl))
+		}, []string{"1"}, map[string]int{"2": 1, "3": 1}},
+		{"节点B未续期被清除", func() []string {
+			return p.expire("a", now.Add(ttl+time.Second))
+		}, []string{"2", "3"}, map[string]int{}},
+		{"节点A全量上报覆盖旧统计", func() []string {
+			return p.apply("a", &PresenceUpdate{Full: true, Agents: map[string]int{"4": 1}}, now.Add(3*ttl))
+		}, []string{"4"}, map[string]int{"4": 1}},
+		{"节点A下线", func() []string {
+			return p.remove("a")
+		}, []string{"4"}, map[string]int{}},
+	}
+	for _, step := range steps {
+		changed := step.run()
+		sort.Strings(changed)
+		if len(changed) != len(step.changed) {
+			t.Fatalf("%s: changed = %v, want %v", step.name, changed, step.changed)
+		}
+		for i := range changed {
+			if changed[i] != step.changed[i] {
+				t.Fatalf("%s: changed = %v, want %v", step.name, changed, step.changed)
+			}
+		}
+		agents := p.snapshot().Agents
+		if len(agents) != len(step.online) {
+			t.Fatalf("%s: agents = %v, want %v", step.name, agents, step.online)
+		}
+		for agentID, count := range step.online {
+			if agents[agentID] != count {
+				t.Fatalf("%s: agents = %v, want %v", step.name, agents, step.online)
+			}
+		}
+	}
+}
+
+func TestClusterPresenceLeader(t *testing.T) {
+	p := newClusterPresence()
+	p.apply("node-b", &PresenceUpdate{Full: true}, time.Now().Add(time.Minute))
+	p.apply("node-c", &PresenceUpdate{Full: true}, time.Now().Add(time.Minute))
+	if !p.leader("node-a") {
+		t.Error("标识最小的节点应为主节点")
+	}
+	if p.leader("node-c") {
+		t.Error("存在标识更小的节点时不应为主节点")
+	}
+}
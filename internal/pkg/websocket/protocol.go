@@ -0,0 +1,167 @@
+package websocket
+
+import (
+	"encoding/json"
+	"time"
+
+	"go.uber.org/zap"
+
+	"support-plugin/internal/pkg/logger"
+)
+
+// 同一输入状态的最短推送间隔，状态变化时不受限制
+const typingThrottle = 2 * time.Second
+
+// ClientMessageType 客户端发往服务端的消息类型
+type ClientMessageType string
+
+const (
+	// ClientMessageTyping 输入状态
+	ClientMessageTyping ClientMessageType = "typing"
+	// ClientMessageRead 已读回执
+	ClientMessageRead ClientMessageType = "read"
+	// ClientMessageSubscribe 管理员订阅全部对话
+	ClientMessageSubscribe ClientMessageType = "subscribe"
+)
+
+// ClientMessage 客户端发往服务端的消息
+type ClientMessage struct {
+	Type ClientMessageType `json:"type"`
+	Data json.RawMessage   `json:"data"`
+}
+
+// TypingRequest 输入状态消息内容，客服端需指定会话UUID
+type TypingRequest struct {
+	ConvUUID string `json:"conv_uuid"`
+	Typing   bool   `json:"typing"`
+}
+
+// ReadRequest 已读回执消息内容，客服端需指定会话UUID
+type ReadRequest struct {
+	ConvUUID  string `json:"conv_uuid"`
+	MessageID uint   `json:"message_id"`
+}
+
+// TypingStatus 推送给对方的输入状态
+type TypingStatus struct {
+	ConvUUID string `json:"conv_uuid"`
+	Typing   bool   `json:"typing"`
+	AgentID  string `json:"agent_id,omitempty"`
+}
+
+// ReadReceiptHandler 持久化已读回执并通知对方，由service层注册
+type ReadReceiptHandler func(convUUID, readerType, readerID string, messageID uint) error
+
+var readReceiptHandler ReadReceiptHandler
+
+// SetReadReceiptHandler 注册已读回执处理函数
+func SetReadReceiptHandler(handler ReadReceiptHandler) {
+	readReceiptHandler = handler
+}
+
+// AgentAccessChecker 校验客服能否在对话中上报输入状态与已读回执，由service层注册
+type AgentAccessChecker func(convUUID, agentKey string, isAdmin bool) bool
+
+var agentAccessChecker AgentAccessChecker
+
+// SetAgentAccessChecker 注册客服对话权限校验函数
+func SetAgentAccessChecker(checker AgentAccessChecker) {
+	agentAccessChecker = checker
+}
+
+// handleClientMessage 处理客户端消息，未知类型直接忽略
+func (c *Client) handleClientMessage(msg *ClientMessage) {
+	switch msg.Type {
+	case ClientMessageTyping:
+		var req TypingRequest
+		if err := json.Unmarshal(msg.Data, &req); err != nil {
+			return
+		}
+		c.handleTyping(&req)
+	case ClientMessageRead:
+		var req ReadRequest
+		if err := json.Unmarshal(msg.Data, &req); err != nil || req.MessageID == 0 {
+			return
+		}
+		c.handleRead(&req)
+	case ClientMessageSubscribe:
+		var req SubscribeRequest
+		if err := json.Unmarshal(msg.Data, &req); err != nil {
+			return
+		}
+		c.handleSubscribe(&req)
+	default:
+		logger.App.Debug("忽略未知类型的客户端消息",
+			zap.String("type", string(msg.Type)),
+			zap.String("ClientType", c.ClientType))
+	}
+}
+
+// handleTyping 转发输入状态：客户输入通知客服，客服输入通知会话中的客户
+func (c *Client) handleTyping(req *TypingRequest) {
+	convUUID := c.convUUID(req.ConvUUID)
+	if convUUID == "" || !c.canAct(convUUID) {
+		return
+	}
+
+	// 节流：状态未变化时在间隔内不重复推送
+	now := time.Now()
+	if req.Typing == c.typing && now.Sub(c.typingAt) < typingThrottle {
+		return
+	}
+	c.typing = req.Typing
+	c.typingAt = now
+
+	status := TypingStatus{
+		ConvUUID: convUUID,
+		Typing:   req.Typing,
+	}
+	if c.ClientType == "agent" {
+		status.AgentID = c.AgentID
+		BroadcastMessage(convUUID, status, MessageTypeAgentTypingStatus)
+		return
+	}
+	WebSocketManager.SendToConversationAgents(convUUID, status, MessageTypeCustomerTypingStatus)
+}
+
+// handleRead 处理已读回执
+func (c *Client) handleRead(req *ReadRequest) {
+	convUUID := c.convUUID(req.ConvUUID)
+	if convUUID == "" || readReceiptHandler == nil || !c.canAct(convUUID) {
+		return
+	}
+
+	readerID := ""
+	if c.ClientType == "agent" {
+		readerID = c.AgentID
+	}
+	if err := readReceiptHandler(convUUID, c.ClientType, readerID, req.MessageID); err != nil {
+		logger.App.Warn("处理已读回执失败",
+			zap.String("convUUID", convUUID),
+			zap.String("ClientType", c.ClientType),
+			zap.Uint("messageID", req.MessageID),
+			zap.Error(err))
+	}
+}
+
+// convUUID 客户端只能操作连接时的会话，客服端可指定任意会话
+func (c *Client) convUUID(requested string) string {
+	if c.ClientType == "agent" && requested != "" {
+		return requested
+	}
+	return c.ConvUUID
+}
+
+// canAct 客户端连接已通过对话令牌校验；客服端须为对话的负责客服、参与客服或管理员
+func (c *Client) canAct(convUUID string) bool {
+	if c.ClientType != "agent" {
+		return true
+	}
+	if agentAccessChecker != nil && agentAccessChecker(convUUID, c.AgentID, c.IsAdmin) {
+		return true
+	}
+	logger.App.Warn("拒绝客服在无权限的对话中上报状态",
+		zap.String("convUUID", convUUID),
+		zap.String("agentID", c.AgentID))
+	return false
+}
@@ -0,0 +1,169 @@
+package websocket
+
+import (
+	"encoding/json"
+
+	"go.uber.org/zap"
+
+	"support-plugin/internal/pkg/logger"
+)
+
+// Audience 对话相关推送的客服接收范围
+type Audience struct {
+	AgentIDs []string // 接收推送的客服ID：负责客服、参与客服或来源成员
+	All      bool     // 推送给所有在线客服，如未配置成员的来源中待分配的对话
+}
+
+// AudienceResolver 根据对话UUID计算接收推送的客服，由service层注册
+type AudienceResolver func(convUUID string) (*Audience, error)
+
+var audienceResolver AudienceResolver
+
+// SetAudienceResolver 注册对话推送范围的计算函数
+func SetAudienceResolver(resolver AudienceResolver) {
+	audienceResolver = resolver
+}
+
+// SubscribeRequest 订阅消息内容，管理员开启后接收全部对话的推送
+type SubscribeRequest struct {
+	All bool `json:"all"`
+}
+
+// SendToConversationAgents 向与对话相关的客服推送消息，订阅全部对话的管理员同时接收
+func (m *Manager) SendToConversationAgents(convUUID string, data interface{}, messageType MessageType) {
+	dataBytes, err := json.Marshal(data)
+	if err != nil {
+		logger.App.Error("Failed to marshal conversation agent message data content", zap.Error(err))
+		return
+	}
+	msgBytes, err := json.Marshal(&Message{
+		ConvUUID: convUUID,
+		Data:     json.RawMessage(dataBytes),
+		Type:     messageType,
+	})
+	if err != nil {
+		return
+	}
+
+	// 推送范围需要查询数据库，在加锁前计算；计算失败时退回到推送给所有客服
+	audience := &Audience{All: true}
+	if audienceResolver != nil {
+		if resolved, err := audienceResolver(convUUID); err == nil {
+			audience = resolved
+		} else {
+			logger.App.Warn("计算对话推送范围失败，推送给所有客服",
+				zap.String("convUUID", convUUID),
+				zap.Error(err))
+		}
+	}
+
+	m.publish(&Envelope{
+		Kind:     EnvelopeConversationAgents,
+		Target:   convUUID,
+		Audience: audience,
+		Payload:  msgBytes,
+	})
+}
+
+// deliverConversationAgents 向本节点中与对话相关的客服连接投递消息
+func (m *Manager) deliverConversationAgents(convUUID string, audience *Audience, msgBytes []byte) {
+	if audience == nil {
+		audience = &Audience{All: true}
+	}
+
+	m.mutex.RLock()
+	targets := make(map[*Client]bool)
+	if audience.All {
+		for _, client := range m.AgentClients {
+			targets[client] = true
+		}
+	} else {
+		for _, agentID := range audience.AgentIDs {
+			for client := range m.AgentIndex[agentID] {
+				targets[client] = true
+			}
+		}
+		for client := range m.Subscribers {
+			targets[client] = true
+		}
+	}
+	failedClients := m.deliver(targets, msgBytes)
+	m.mutex.RUnlock()
+
+	if len(failedClients) > 0 {
+		go m.cleanupFailedClients(failedClients)
+	}
+
+	logger.App.Debug("对话客服消息发送完成",
+		zap.String("convUUID", convUUID),
+		zap.Bool("all", audience.All),
+		zap.Int("clientCount", len(targets)))
+}
+
+// deliver 非阻塞地向客户端发送消息，返回发送失败的客户端，调用方需持有读锁
+func (m *Manager) deliver(clients map[*Client]bool, msgBytes []byte) []*Client {
+	var failedClients []*Client
+	for client := range clients {
+		select {
+		case client.Send <- msgBytes:
+			// 发送成功
+		default:
+			failedClients = append(failedClients, client)
+		}
+	}
+	return failedClients
+}
+
+// setSubscription 设置管理员连接是否订阅全部对话，非管理员连接不允许订阅
+func (m *Manager) setSubscription(client *Client, all bool) bool {
+	if client.ClientType != "agent" || !client.IsAdmin {
+		return false
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if _, ok := m.Clients[client]; !ok {
+		return false
+	}
+	if all {
+		m.Subscribers[client] = true
+	} else {
+		delete(m.Subscribers, client)
+	}
+	return true
+}
+
+// handleSubscribe 处理管理员的全部对话订阅请求，并回复当前订阅状态
+func (c *Client) handleSubscribe(req *SubscribeRequest) {
+	if !WebSocketManager.setSubscription(c, req.All) {
+		logger.App.Warn("拒绝非管理员订阅全部对话",
+			zap.String("agentID", c.AgentID),
+			zap.String("ClientType", c.ClientType))
+		req.All = false
+	}
+
+	dataBytes, err := json.Marshal(req)
+	if err != nil {
+		return
+	}
+	msgBytes, err := json.Marshal(&Message{
+		Data: json.RawMessage(dataBytes),
+		Type: MessageTypeSubscribed,
+	})
+	if err != nil {
+		return
+	}
+
+	WebSocketManager.mutex.RLock()
+	defer WebSocketManager.mutex.RUnlock()
+	if _, ok := WebSocketManager.Clients[c]; ok {
+		WebSocketManager.deliver(map[*Client]bool{c: true}, msgBytes)
+	}
+}
+
+// BroadcastToConversationAgents 向与对话相关的客服推送消息
+func BroadcastToConversationAgents(convUUID string, data interface{}, msgType MessageType) {
+	logger.App.Info("准备向对话相关客服推送消息", zap.String("ConvUUID", convUUID), zap.Any("msgType", msgType))
+	WebSocketManager.SendToConversationAgents(convUUID, data, msgType)
+}
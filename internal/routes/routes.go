@@ -6,7 +6,6 @@ import (
 	"support-plugin/internal/config"
 	"support-plugin/internal/headlers"
 	"support-plugin/internal/middleware"
-	"support-plugin/internal/pkg/websocket"
 	"support-plugin/internal/web"
 
 	_ "support-plugin/docs"
@@ -31,21 +30,25 @@ func RegisterRoutes(r *gin.Engine) {
 			dooTaskRoutes.POST("/:chatKey/chat", headlers.DooTask.Chat)
 		}
 
-		// // 认证相关路由
-		// authRoutes := v1.Group("/auth")
-		// {
-		// 	// 客服登录
-		// 	authRoutes.POST("/login", headlers.Auth.Login)
-
-		// 	// 需要认证的路由
-		// 	authProtected := authRoutes.Group("", middleware.AgentAuthMiddleware())
-		// 	{
-		// 		// 获取当前客服信息
-		// 		authProtected.GET("/me", headlers.Auth.GetCurrentAgent)
-		// 		// 创建客服账号
-		// 		authProtected.POST("/agents", headlers.Auth.CreateAgent)
-		// 	}
-		// }
+		// 认证相关路由（独立模式）
+		authRoutes := v1.Group("/auth")
+		{
+			// 客服登录
+			authRoutes.POST("/login", headlers.Auth.Login)
+			// 刷新令牌
+			authRoutes.POST("/refresh", headlers.Auth.Refresh)
+
+			// 需要认证的路由
+			authProtected := authRoutes.Group("", middleware.AgentAuthMiddleware())
+			{
+				// 获取当前客服信息
+				authProtected.GET("/me", headlers.Auth.GetCurrentAgent)
+				// 退出登录
+				authProtected.POST("/logout", headlers.Auth.Logout)
+				// 创建客服账号
+				authProtected.POST("/agents", middleware.AdminAuthMiddleware(), headlers.Auth.CreateAgent)
+			}
+		}
 
 		// 服务器配置相关路由（无需认证）
 		v1.GET("/server/config", middleware.AgentAuthMiddleware(), headlers.Config.GetServerConfig)
@@ -76,6 +79,71 @@ func RegisterRoutes(r *gin.Engine) {
 			sourceRoutes.PUT("/:id", headlers.Source.UpdateSource)
 			// 删除来源
 			sourceRoutes.DELETE("/:id", headlers.Source.DeleteSource)
+			// 重置身份验证密钥
+			sourceRoutes.POST("/:id/secret", headlers.Source.ResetSecret)
+			// 获取来源成员
+			sourceRoutes.GET("/:id/members", headlers.Source.GetSourceMembers)
+			// 设置来源成员
+			sourceRoutes.PUT("/:id/members", headlers.Source.SetSourceMembers)
+		}
+
+		// 统计相关路由
+		statsRoutes := v1.Group("/stats", middleware.AgentAuthMiddleware(), middleware.AdminAuthMiddleware())
+		{
+			// 获取统计报表
+			statsRoutes.GET("", headlers.Stats.GetStats)
+			// 重建统计数据
+			statsRoutes.POST("/rebuild", headlers.Stats.Rebuild)
+		}
+
+		// 快捷回复相关路由，客服可查看，管理员可维护
+		cannedRoutes := v1.Group("/canned-responses", middleware.AgentAuthMiddleware())
+		{
+			// 获取快捷回复列表
+			cannedRoutes.GET("", headlers.CannedResponse.List)
+			// 获取快捷回复
+			cannedRoutes.GET("/:id", headlers.CannedResponse.Get)
+			// 创建快捷回复
+			cannedRoutes.POST("", middleware.AdminAuthMiddleware(), headlers.CannedResponse.Create)
+			// 更新快捷回复
+			cannedRoutes.PUT("/:id", middleware.AdminAuthMiddleware(), headlers.CannedResponse.Update)
+			// 删除快捷回复
+			cannedRoutes.DELETE("/:id", middleware.AdminAuthMiddleware(), headlers.CannedResponse.Delete)
+		}
+
+		// 对话标签相关路由
+		tagRoutes := v1.Group("/tags", middleware.AgentAuthMiddleware())
+		{
+			// 获取标签列表
+			tagRoutes.GET("", headlers.Tag.List)
+			// 创建标签
+			tagRoutes.POST("", middleware.AdminAuthMiddleware(), headlers.Tag.Create)
+			// 更新标签
+			tagRoutes.PUT("/:id", middleware.AdminAuthMiddleware(), headlers.Tag.Update)
+			// 删除标签
+			tagRoutes.DELETE("/:id", middleware.AdminAuthMiddleware(), headlers.Tag.Delete)
+		}
+
+		// 对话自定义字段相关路由
+		customFieldRoutes := v1.Group("/custom-fields", middleware.AgentAuthMiddleware())
+		{
+			// 获取自定义字段列表
+			customFieldRoutes.GET("", headlers.CustomField.List)
+			// 创建自定义字段
+			customFieldRoutes.POST("", middleware.AdminAuthMiddleware(), headlers.CustomField.Create)
+			// 更新自定义字段
+			customFieldRoutes.PUT("/:id", middleware.AdminAuthMiddleware(), headlers.CustomField.Update)
+			// 删除自定义字段
+			customFieldRoutes.DELETE("/:id", middleware.AdminAuthMiddleware(), headlers.CustomField.Delete)
+		}
+
+		// 全文检索相关路由
+		searchRoutes := v1.Group("/search", middleware.AgentAuthMiddleware())
+		{
+			// 检索消息与对话
+			searchRoutes.GET("", headlers.Search.Search)
+			// 重建检索索引
+			searchRoutes.POST("/rebuild", middleware.AdminAuthMiddleware(), headlers.Search.Rebuild)
 		}
 
 		// 对话相关路由
@@ -86,20 +154,32 @@ func RegisterRoutes(r *gin.Engine) {
 			chatRoutes.POST("", headlers.ChatPublic.CreateConversation)
 			// 发送消息
 			chatRoutes.POST("/messages", headlers.ChatPublic.SendMessage)
+			// 上传附件
+			chatRoutes.POST("/attachments", headlers.Attachment.UploadByCustomer)
+			// 下载附件
+			chatRoutes.GET("/attachments/:key", headlers.Attachment.Download)
+			// 获取图片缩略图
+			chatRoutes.GET("/attachments/:key/thumbnail", headlers.Attachment.Thumbnail)
+			// 获取客服在线状态
+			chatRoutes.GET("/availability", headlers.ChatPublic.GetAvailability)
+			// 获取访客的历史对话
+			chatRoutes.GET("/customer/conversations", headlers.ChatPublic.GetCustomerConversations)
 			// 获取对话消息列表
 			chatRoutes.GET("/:uuid/messages", headlers.ChatPublic.GetMessages)
+			// 提交满意度评价
+			chatRoutes.POST("/:uuid/rating", headlers.ChatPublic.SubmitRating)
 			// 获取对话信息
 			chatRoutes.GET("/:uuid", headlers.ChatPublic.GetConversation)
 			// WebSocket连接
-			chatRoutes.GET("/ws", func(c *gin.Context) {
-				websocket.ServeWs(c)
-			})
+			chatRoutes.GET("/ws", headlers.ChatPublic.ServeWs)
 
 			// 需要客服认证的路由
 			chatProtected := chatRoutes.Group("/agent", middleware.AgentAuthMiddleware())
 			{
 				// 发送消息
 				chatProtected.POST("/messages", headlers.ChatAgent.SendMessageByAgent)
+				// 上传附件
+				chatProtected.POST("/attachments", headlers.Attachment.UploadByAgent)
 				// 获取客服的所有对话
 				chatProtected.GET("/conversations", headlers.ChatAgent.GetAgentConversations)
 				// 根据UUID获取对话信息
@@ -110,6 +190,24 @@ func RegisterRoutes(r *gin.Engine) {
 				chatProtected.PUT("/conversations/:id/close", headlers.ChatAgent.CloseConversation)
 				// 重新打开对话
 				chatProtected.PUT("/conversations/:id/reopen", headlers.ChatAgent.ReopenConversation)
+				// 转接对话
+				chatProtected.PUT("/conversations/:id/transfer", headlers.ChatAgent.TransferConversation)
+				// 邀请客服加入对话
+				chatProtected.POST("/conversations/:id/invite", headlers.ChatAgent.InviteAgent)
+				// 离开对话
+				chatProtected.PUT("/conversations/:id/leave", headlers.ChatAgent.LeaveConversation)
+				// 获取对话参与客服
+				chatProtected.GET("/:id/participants", headlers.ChatAgent.GetParticipants)
+				// 标记消息已读
+				chatProtected.PUT("/conversations/:id/read", headlers.ChatAgent.MarkRead)
+				// 设置对话标签
+				chatProtected.PUT("/conversations/:id/tags", headlers.ChatAgent.SetConversationTags)
+				// 设置对话自定义字段
+				chatProtected.PUT("/conversations/:id/fields", headlers.ChatAgent.SetConversationFields)
+				// 设置对话优先级
+				chatProtected.PUT("/conversations/:id/priority", headlers.ChatAgent.SetConversationPriority)
+				// 吊销对话访问令牌
+				chatProtected.PUT("/conversations/:id/revoke-token", headlers.ChatAgent.RevokeConversationToken)
 			}
 		}
 
@@ -118,6 +216,10 @@ func RegisterRoutes(r *gin.Engine) {
 		{
 			// 验证客服身份
 			agentVerifyRoutes.GET("/verify", headlers.Agent.Verify)
+			// 获取客服在线状态
+			agentVerifyRoutes.GET("/presence", headlers.Agent.Presence)
+			// 设置当前客服的在线状态
+			agentVerifyRoutes.PUT("/status", headlers.Agent.SetStatus)
 		}
 
 		// 客服管理接口（需要管理员权限）
@@ -129,6 +231,8 @@ func RegisterRoutes(r *gin.Engine) {
 			agentRoutes.PUT("", headlers.Agent.Edit)
 
 			agentRoutes.DELETE("/:id", headlers.Agent.Delete)
+			// 设置客服最大同时接待对话数
+			agentRoutes.PUT("/:id/max-chats", headlers.Agent.SetMaxChats)
 		}
 	}
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerfiles.Handler))
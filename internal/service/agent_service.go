@@ -1,6 +1,8 @@
 package service
 
 import (
+	"support-plugin/internal/config"
+	"support-plugin/internal/i18n"
 	"support-plugin/internal/models"
 	"support-plugin/internal/pkg/database"
 )
@@ -13,5 +15,35 @@ func (a *AgentService) Delete(agentID uint) error {
 	err := database.GetDB().Delete(&models.Agent{
 		ID: agentID,
 	}).Error
-	return err
+	if err != nil {
+		return err
+	}
+	// 删除客服后其登录会话立即失效
+	return Auth.RevokeAgentSessions(agentID)
+}
+
+// FindByAuthID 根据认证中间件中的客服ID查找客服，DooTask模式下为DooTask用户ID
+func (a *AgentService) FindByAuthID(authID uint) (*models.Agent, error) {
+	var agent models.Agent
+	db := database.GetDB()
+	if config.Cfg.App.Mode == "dootask" {
+		db = db.Where("dootask_user_id = ?", authID)
+	} else {
+		db = db.Where("id = ?", authID)
+	}
+	if err := db.First(&agent).Error; err != nil {
+		return nil, &i18n.ErrorInfo{
+			Code:    i18n.ErrCodeAgentNotFound,
+			Message: "agent not found",
+		}
+	}
+	return &agent, nil
+}
+
+// DisplayName 客服的显示名称
+func (a *AgentService) DisplayName(agent *models.Agent) string {
+	if agent.Name != "" {
+		return agent.Name
+	}
+	return agent.Username
 }
@@ -0,0 +1,362 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+
+	"support-plugin/internal/config"
+	"support-plugin/internal/i18n"
+	"support-plugin/internal/models"
+	"support-plugin/internal/pkg/database"
+	bizErrors "support-plugin/internal/pkg/errors"
+	"support-plugin/internal/pkg/logger"
+	"support-plugin/internal/pkg/storage"
+	"support-plugin/internal/utils/common"
+)
+
+const (
+	// 缩略图最长边
+	thumbnailMaxSide = 320
+	// 超过该像素数的图片不生成缩略图，避免解码占用过多内存
+	thumbnailMaxPixels = 40 * 1000 * 1000
+)
+
+type AttachmentService struct{}
+
+var Attachment = &AttachmentService{}
+
+// Upload 保存上传的文件并以附件消息的形式发送到对话中
+// uploader 为 customer 或 agent，baseURL 用于拼接附件的访问地址
+func (s *AttachmentService) Upload(conversation *models.Conversations, uploader string, uploaderID uint, fileHeader *multipart.FileHeader, caption, baseURL string) (*models.Message, error) {
+	if conversation.Status == "closed" {
+		return nil, bizErrors.ErrConversationClosed
+	}
+	if fileHeader.Size > s.MaxSize() {
+		return nil, &i18n.ErrorInfo{
+			Code:    i18n.ErrCodeFileSizeExceeded,
+			Message: "file size exceeded limit",
+		}
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	mimeType, err := s.detectMimeType(file, fileHeader.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, err
+	}
+	if !s.isAllowed(mimeType) {
+		return nil, &i18n.ErrorInfo{
+			Code:    i18n.ErrCodeFileFormatError,
+			Message: "file type is not allowed: " + mimeType,
+		}
+	}
+
+	key := common.SecureRandString(32)
+	attachment := models.Attachment{
+		Key:            key,
+		ConversationID: conversation.ID,
+		Uploader:       uploader,
+		UploaderID:     uploaderID,
+		Name:           s.cleanName(fileHeader.Filename),
+		MimeType:       mimeType,
+		Size:           fileHeader.Size,
+		StorageKey:     fmt.Sprintf("attachments/%s/%s", time.Now().Format("2006/01/02"), key),
+		CreatedAt:      time.Now(),
+	}
+	if err := storage.Default.Save(attachment.StorageKey, file, fileHeader.Size, mimeType); err != nil {
+		logger.App.Error("保存附件失败", zap.Uint("conversationID", conversation.ID), zap.Error(err))
+		return nil, &i18n.ErrorInfo{
+			Code:    i18n.ErrCodeFileUploadFailed,
+			Message: "failed to save file",
+		}
+	}
+
+	// 图片生成缩略图，失败时不影响附件本身
+	if s.isThumbnailable(mimeType) {
+		if err := s.createThumbnail(&attachment, file); err != nil {
+			logger.App.Warn("生成缩略图失败", zap.String("key", key), zap.Error(err))
+		}
+	}
+
+	if err := database.DB.Create(&attachment).Error; err != nil {
+		s.removeFiles(&attachment)
+		return nil, err
+	}
+
+	msgType := "file"
+	if strings.HasPrefix(mimeType, "image/") {
+		msgType = "image"
+	}
+	content := strings.TrimSpace(caption)
+	if content == "" {
+		content = s.label(msgType, attachment.Name)
+	}
+	metadata, err := json.Marshal(models.AttachmentMetadata{Attachment: s.ref(&attachment, baseURL)})
+	if err != nil {
+		return nil, err
+	}
+
+	var message *models.Message
+	if uploader == "customer" {
+		message, err = ChatPublic.SendMessage(conversation.Uuid, content, msgType, string(metadata))
+	} else {
+		agent, _ := Agent.FindByAuthID(uploaderID)
+		message, err = ChatAgent.SendMessageByAgent(conversation.ID, content, msgType, string(metadata), agent)
+	}
+	if err != nil {
+		database.DB.Delete(&attachment)
+		s.removeFiles(&attachment)
+		return nil, err
+	}
+
+	database.DB.Model(&attachment).Update("message_id", message.ID)
+	return message, nil
+}
+
+// Open 根据访问标识打开附件，thumbnail 为 true 时打开缩略图
+// 返回的 size 为 -1 表示大小未知
+func (s *AttachmentService) Open(key string, thumbnail bool) (*models.Attachment, io.ReadCloser, string, int64, error) {
+	var attachment models.Attachment
+	if key == "" || database.DB.Where("access_key = ?", key).First(&attachment).Error != nil {
+		return nil, nil, "", 0, &i18n.ErrorInfo{
+			Code:    i18n.ErrCodeFileNotFound,
+			Message: "attachment not found",
+		}
+	}
+
+	storageKey, mimeType, size := attachment.StorageKey, attachment.MimeType, attachment.Size
+	if thumbnail {
+		if attachment.ThumbnailKey == "" {
+			return nil, nil, "", 0, &i18n.ErrorInfo{
+				Code:    i18n.ErrCodeFileNotFound,
+				Message: "thumbnail not found",
+			}
+		}
+		storageKey, mimeType, size = attachment.ThumbnailKey, attachment.ThumbnailMime, -1
+	}
+
+	reader, err := storage.Default.Open(storageKey)
+	if err == storage.ErrNotFound {
+		return nil, nil, "", 0, &i18n.ErrorInfo{
+			Code:    i18n.ErrCodeFileNotFound,
+			Message: "attachment file is missing",
+		}
+	}
+	if err != nil {
+		return nil, nil, "", 0, err
+	}
+	return &attachment, reader, mimeType, size, nil
+}
+
+// DooTaskText 生成转发到DooTask对话的消息文本，附件消息附带下载地址
+func (s *AttachmentService) DooTaskText(message *models.Message) string {
+	if (message.Type != "image" && message.Type != "file") || message.Metadata == "" {
+		return message.Content
+	}
+	var metadata models.AttachmentMetadata
+	if err := json.Unmarshal([]byte(message.Metadata), &metadata); err != nil || metadata.Attachment.URL == "" {
+		return message.Content
+	}
+
+	label := s.label(message.Type, metadata.Attachment.Name)
+	if message.Content == label {
+		return fmt.Sprintf("%s\n%s", label, metadata.Attachment.URL)
+	}
+	return fmt.Sprintf("%s\n%s\n%s", message.Content, label, metadata.Attachment.URL)
+}
+
+// BaseURL 附件访问地址前缀，优先使用配置的地址
+func (s *AttachmentService) BaseURL(requestBaseURL string) string {
+	if publicURL := config.Cfg.Storage.PublicURL; publicURL != "" {
+		return strings.TrimRight(publicURL, "/")
+	}
+	return strings.TrimRight(requestBaseURL, "/")
+}
+
+// MaxSize 单个附件的大小上限（字节）
+func (s *AttachmentService) MaxSize() int64 {
+	maxSizeMB := config.Cfg.Storage.MaxSizeMB
+	if maxSizeMB <= 0 {
+		maxSizeMB = 20
+	}
+	return int64(maxSizeMB) << 20
+}
+
+// ref 生成消息元数据中的附件引用
+func (s *AttachmentService) ref(attachment *models.Attachment, baseURL string) models.AttachmentRef {
+	url := fmt.Sprintf("%s/api/v1/chat/attachments/%s", s.BaseURL(baseURL), attachment.Key)
+	ref := models.AttachmentRef{
+		Key:      attachment.Key,
+		Name:     attachment.Name,
+		MimeType: attachment.MimeType,
+		Size:     attachment.Size,
+		URL:      url,
+		Width:    attachment.Width,
+		Height:   attachment.Height,
+	}
+	if attachment.ThumbnailKey != "" {
+		ref.ThumbnailURL = url + "/thumbnail"
+	}
+	return ref
+}
+
+// detectMimeType 根据文件内容识别类型，不信任客户端声明的类型
+// Office文档等容器格式会被识别为zip或二进制流，此时才采用声明的非图片、非文本类型
+func (s *AttachmentService) detectMimeType(file multipart.File, declared string) (string, error) {
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	mimeType := s.baseMimeType(http.DetectContentType(head[:n]))
+	declared = s.baseMimeType(declared)
+	if (mimeType == "application/zip" || mimeType == "application/octet-stream") &&
+		declared != "" && !strings.HasPrefix(declared, "image/") && !strings.HasPrefix(declared, "text/") &&
+		s.isAllowed(declared) {
+		mimeType = declared
+	}
+	return mimeType, nil
+}
+
+// isAllowed 检查类型是否在允许上传的列表中
+func (s *AttachmentService) isAllowed(mimeType string) bool {
+	for _, pattern := range strings.Split(config.Cfg.Storage.AllowedTypes, ",") {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		if strings.HasSuffix(pattern, "*") {
+			if strings.HasPrefix(mimeType, strings.TrimSuffix(pattern, "*")) {
+				return true
+			}
+		} else if mimeType == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// isThumbnailable 是否为可生成缩略图的图片类型
+func (s *AttachmentService) isThumbnailable(mimeType string) bool {
+	switch mimeType {
+	case "image/jpeg", "image/png", "image/gif", "image/webp":
+		return true
+	}
+	return false
+}
+
+// createThumbnail 按最长边缩放生成缩略图，JPEG原图输出JPEG，其余输出PNG以保留透明度
+func (s *AttachmentService) createThumbnail(attachment *models.Attachment, file multipart.File) error {
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	imageConfig, _, err := image.DecodeConfig(file)
+	if err != nil {
+		return err
+	}
+	attachment.Width, attachment.Height = imageConfig.Width, imageConfig.Height
+	if imageConfig.Width*imageConfig.Height > thumbnailMaxPixels {
+		return fmt.Errorf("image too large: %dx%d", imageConfig.Width, imageConfig.Height)
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	src, _, err := image.Decode(file)
+	if err != nil {
+		return err
+	}
+
+	width, height := imageConfig.Width, imageConfig.Height
+	if width > thumbnailMaxSide || height > thumbnailMaxSide {
+		if width >= height {
+			width, height = thumbnailMaxSide, height*thumbnailMaxSide/width
+		} else {
+			width, height = width*thumbnailMaxSide/height, thumbnailMaxSide
+		}
+	}
+	width, height = max(width, 1), max(height, 1)
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, src.Bounds(), draw.Over, nil)
+
+	var buf bytes.Buffer
+	thumbnailMime := "image/png"
+	if attachment.MimeType == "image/jpeg" {
+		thumbnailMime = "image/jpeg"
+		err = jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 80})
+	} else {
+		err = png.Encode(&buf, dst)
+	}
+	if err != nil {
+		return err
+	}
+
+	thumbnailKey := attachment.StorageKey + "_thumb"
+	if err := storage.Default.Save(thumbnailKey, &buf, int64(buf.Len()), thumbnailMime); err != nil {
+		return err
+	}
+	attachment.ThumbnailKey = thumbnailKey
+	attachment.ThumbnailMime = thumbnailMime
+	return nil
+}
+
+// removeFiles 删除附件及缩略图文件
+func (s *AttachmentService) removeFiles(attachment *models.Attachment) {
+	if err := storage.Default.Delete(attachment.StorageKey); err != nil {
+		logger.App.Warn("删除附件文件失败", zap.String("key", attachment.StorageKey), zap.Error(err))
+	}
+	if attachment.ThumbnailKey != "" {
+		storage.Default.Delete(attachment.ThumbnailKey)
+	}
+}
+
+// label 附件消息的默认文本
+func (s *AttachmentService) label(msgType, name string) string {
+	if msgType == "image" {
+		return "[图片] " + name
+	}
+	return "[文件] " + name
+}
+
+// cleanName 去除文件名中的路径并限制长度
+func (s *AttachmentService) cleanName(name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+	if name == "." || name == "/" {
+		name = "file"
+	}
+	if runes := []rune(name); len(runes) > 200 {
+		name = string(runes[len(runes)-200:])
+	}
+	return name
+}
+
+// baseMimeType 去掉类型中的参数部分，如 "text/plain; charset=utf-8"
+func (s *AttachmentService) baseMimeType(mimeType string) string {
+	if i := strings.Index(mimeType, ";"); i >= 0 {
+		mimeType = mimeType[:i]
+	}
+	return strings.ToLower(strings.TrimSpace(mimeType))
+}
@@ -0,0 +1,271 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+
+	"support-plugin/internal/i18n"
+	"support-plugin/internal/models"
+	"support-plugin/internal/pkg/database"
+	"support-plugin/internal/pkg/logger"
+	"support-plugin/internal/utils/common"
+)
+
+// 令牌默认有效期（小时）
+const (
+	defaultTokenExpireHours   = 24
+	defaultRefreshExpireHours = 720
+)
+
+// AgentClaims 客服访问令牌载荷，ID（jti）为登录会话标识
+type AgentClaims struct {
+	AgentID  uint   `json:"agent_id"`
+	Username string `json:"username"`
+	IsAdmin  bool   `json:"is_admin"`
+	jwt.RegisteredClaims
+}
+
+type AuthService struct{}
+
+var Auth = &AuthService{}
+
+// HashPassword 生成密码哈希
+func (s *AuthService) HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// Login 校验用户名和密码，创建登录会话并签发令牌
+func (s *AuthService) Login(username, password, ip, userAgent string) (*models.AgentLoginResponse, error) {
+	var agent models.Agent
+	if err := database.DB.Where("username = ?", username).First(&agent).Error; err != nil {
+		return nil, &i18n.ErrorInfo{
+			Code:    i18n.ErrCodeInvalidCredentials,
+			Message: "invalid username or password",
+		}
+	}
+	if agent.PasswordHash == "" || bcrypt.CompareHashAndPassword([]byte(agent.PasswordHash), []byte(password)) != nil {
+		logger.App.Warn("客服登录失败", zap.String("username", username), zap.String("ip", ip))
+		return nil, &i18n.ErrorInfo{
+			Code:    i18n.ErrCodeInvalidCredentials,
+			Message: "invalid username or password",
+		}
+	}
+	if agent.Status != "active" {
+		return nil, &i18n.ErrorInfo{
+			Code:    i18n.ErrCodeAccountDisabled,
+			Message: "account is disabled",
+		}
+	}
+
+	authConfig, err := s.authConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken := common.SecureRandString(32)
+	session := models.AgentSession{
+		SessionID:        uuid.New().String(),
+		AgentID:          agent.ID,
+		RefreshTokenHash: s.hashRefreshToken(refreshToken),
+		ExpiresAt:        time.Now().Add(s.refreshTTL(authConfig)),
+		IP:               ip,
+		UserAgent:        userAgent,
+	}
+	if err := database.DB.Create(&session).Error; err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	database.DB.Model(&agent).Update("last_login", &now)
+	logger.App.Info("客服登录成功", zap.Uint("agentID", agent.ID), zap.String("ip", ip))
+
+	return s.issue(authConfig, &agent, &session, refreshToken)
+}
+
+// Refresh 使用刷新令牌换取新的访问令牌，刷新令牌同时轮换
+func (s *AuthService) Refresh(refreshToken string) (*models.AgentLoginResponse, error) {
+	var session models.AgentSession
+	err := database.DB.Where("refresh_token_hash = ?", s.hashRefreshToken(refreshToken)).First(&session).Error
+	if err != nil || session.RevokedAt != nil {
+		return nil, &i18n.ErrorInfo{
+			Code:    i18n.ErrCodeTokenInvalid,
+			Message: "refresh token is invalid",
+		}
+	}
+	if time.Now().After(session.ExpiresAt) {
+		return nil, &i18n.ErrorInfo{
+			Code:    i18n.ErrCodeTokenExpired,
+			Message: "refresh token has expired",
+		}
+	}
+
+	var agent models.Agent
+	if err := database.DB.First(&agent, session.AgentID).Error; err != nil || agent.Status != "active" {
+		return nil, &i18n.ErrorInfo{
+			Code:    i18n.ErrCodeAccountDisabled,
+			Message: "account is disabled",
+		}
+	}
+
+	authConfig, err := s.authConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	newRefreshToken := common.SecureRandString(32)
+	// 按旧哈希条件更新，避免同一刷新令牌被并发使用两次
+	result := database.DB.Model(&session).
+		Where("refresh_token_hash = ?", session.RefreshTokenHash).
+		Updates(map[string]interface{}{
+			"refresh_token_hash": s.hashRefreshToken(newRefreshToken),
+			"expires_at":         time.Now().Add(s.refreshTTL(authConfig)),
+		})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, &i18n.ErrorInfo{
+			Code:    i18n.ErrCodeTokenInvalid,
+			Message: "refresh token has already been used",
+		}
+	}
+
+	return s.issue(authConfig, &agent, &session, newRefreshToken)
+}
+
+// Logout 注销登录会话，会话下的访问令牌和刷新令牌立即失效
+func (s *AuthService) Logout(sessionID string) error {
+	now := time.Now()
+	return database.DB.Model(&models.AgentSession{}).
+		Where("session_id = ? AND revoked_at IS NULL", sessionID).
+		Update("revoked_at", &now).Error
+}
+
+// RevokeAgentSessions 注销客服的所有登录会话，用于禁用或删除客服账号
+func (s *AuthService) RevokeAgentSessions(agentID uint) error {
+	now := time.Now()
+	return database.DB.Model(&models.AgentSession{}).
+		Where("agent_id = ? AND revoked_at IS NULL", agentID).
+		Update("revoked_at", &now).Error
+}
+
+// ParseToken 校验访问令牌并确认登录会话未被注销，返回的管理员标识取自客服当前记录
+func (s *AuthService) ParseToken(tokenString string) (*AgentClaims, error) {
+	authConfig, err := s.authConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	claims := &AgentClaims{}
+	_, err = jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(authConfig.JWTSecret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return nil, &i18n.ErrorInfo{
+				Code:    i18n.ErrCodeTokenExpired,
+				Message: "token has expired",
+			}
+		}
+		return nil, &i18n.ErrorInfo{
+			Code:    i18n.ErrCodeTokenInvalid,
+			Message: "token is invalid",
+		}
+	}
+
+	var session models.AgentSession
+	if err := database.DB.Where("session_id = ?", claims.ID).First(&session).Error; err != nil || session.RevokedAt != nil {
+		return nil, &i18n.ErrorInfo{
+			Code:    i18n.ErrCodeTokenInvalid,
+			Message: "session has been revoked",
+		}
+	}
+
+	// 管理员权限以客服记录为准，角色变更后无需重新登录即可生效
+	var agent models.Agent
+	if err := database.DB.Select("id", "is_admin").First(&agent, claims.AgentID).Error; err != nil {
+		return nil, &i18n.ErrorInfo{
+			Code:    i18n.ErrCodeTokenInvalid,
+			Message: "agent not found",
+		}
+	}
+	claims.IsAdmin = agent.IsAdmin
+	return claims, nil
+}
+
+// issue 签发访问令牌并组装登录响应
+func (s *AuthService) issue(authConfig *models.AuthConfig, agent *models.Agent, session *models.AgentSession, refreshToken string) (*models.AgentLoginResponse, error) {
+	hours := authConfig.TokenExpireHours
+	if hours <= 0 {
+		hours = defaultTokenExpireHours
+	}
+	now := time.Now()
+	expiresAt := now.Add(time.Duration(hours) * time.Hour)
+
+	claims := AgentClaims{
+		AgentID:  agent.ID,
+		Username: agent.Username,
+		IsAdmin:  agent.IsAdmin,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        session.SessionID,
+			Subject:   strconv.FormatUint(uint64(agent.ID), 10),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(authConfig.JWTSecret))
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.AgentLoginResponse{
+		Token:            token,
+		RefreshToken:     refreshToken,
+		AgentID:          agent.ID,
+		Username:         agent.Username,
+		Name:             agent.Name,
+		Avatar:           agent.Avatar,
+		IsAdmin:          agent.IsAdmin,
+		ExpiresAt:        expiresAt.Unix(),
+		RefreshExpiresAt: session.ExpiresAt.Unix(),
+	}, nil
+}
+
+// authConfig 获取认证配置，签名密钥缺失时返回错误
+func (s *AuthService) authConfig() (*models.AuthConfig, error) {
+	authConfig, err := models.LoadConfig[models.AuthConfig](database.DB, models.CSConfigKeyAuth)
+	if err != nil || authConfig.JWTSecret == "" {
+		return nil, &i18n.ErrorInfo{
+			Code:    i18n.ErrCodeConfigNotFound,
+			Message: "auth secret is not configured",
+		}
+	}
+	return authConfig, nil
+}
+
+// refreshTTL 刷新令牌有效期
+func (s *AuthService) refreshTTL(authConfig *models.AuthConfig) time.Duration {
+	hours := authConfig.RefreshExpireHours
+	if hours <= 0 {
+		hours = defaultRefreshExpireHours
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+// hashRefreshToken 刷新令牌只保存哈希
+func (s *AuthService) hashRefreshToken(refreshToken string) string {
+	sum := sha256.Sum256([]byte(refreshToken))
+	return hex.EncodeToString(sum[:])
+}
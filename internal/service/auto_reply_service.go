@@ -0,0 +1,189 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	gormLogger "gorm.io/gorm/logger"
+
+	"support-plugin/internal/models"
+	"support-plugin/internal/pkg/database"
+	"support-plugin/internal/pkg/logger"
+)
+
+// 自动回复任务轮询间隔
+const autoReplyPollInterval = 10 * time.Second
+
+// 自动回复发送失败后的重试
+const (
+	autoReplyMaxAttempts  = 3                // 最多尝试次数，超过后标记为失败
+	autoReplyRetryDelay   = 30 * time.Second // 每次失败后顺延的时间
+	autoReplyClaimTimeout = 2 * time.Minute  // 发送中的任务超过该时间未完成视为节点中断，重新放回待执行
+)
+
+type AutoReplyService struct {
+	once sync.Once
+}
+
+var AutoReply = &AutoReplyService{}
+
+// Start 启动自动回复调度器，重启后会继续处理已到期的任务
+func (s *AutoReplyService) Start() {
+	s.once.Do(func() {
+		go func() {
+			defer func() {
+				if err := recover(); err != nil {
+					logger.App.Error("自动回复调度器 panic", zap.Any("error", err))
+				}
+			}()
+
+			ticker := time.NewTicker(autoReplyPollInterval)
+			defer ticker.Stop()
+			for range ticker.C {
+				s.processDueTasks()
+			}
+		}()
+		logger.App.Info("自动回复调度器已启动")
+	})
+}
+
+// Schedule 客户发送消息后登记自动回复任务，已有待执行任务时不重复登记
+func (s *AutoReplyService) Schedule(conversation *models.Conversations, source *models.CustomerServiceSource) {
+	autoReply := s.resolve(source)
+	if !autoReply.Enabled || autoReply.Message == "" {
+		return
+	}
+
+	var pending int64
+	database.DB.Model(&models.AutoReplyTask{}).
+		Where("conversation_id = ? AND status = ?", conversation.ID, "pending").
+		Count(&pending)
+	if pending > 0 {
+		return
+	}
+
+	task := models.AutoReplyTask{
+		ConversationID: conversation.ID,
+		Message:        autoReply.Message,
+		DueAt:          time.Now().Add(time.Duration(autoReply.Delay) * time.Second),
+		Status:         "pending",
+	}
+	if err := database.DB.Create(&task).Error; err != nil {
+		logger.App.Error("登记自动回复任务失败", zap.Uint("conversationID", conversation.ID), zap.Error(err))
+	}
+}
+
+// Cancel 取消对话中所有待执行的自动回复任务
+func (s *AutoReplyService) Cancel(conversationID uint) {
+	database.DB.Model(&models.AutoReplyTask{}).
+		Where("conversation_id = ? AND status = ?", conversationID, "pending").
+		Update("status", "cancelled")
+}
+
+// resolve 获取生效的自动回复设置，来源配置优先于系统配置
+func (s *AutoReplyService) resolve(source *models.CustomerServiceSource) models.AutoReplyData {
+	var autoReply models.AutoReplyData
+	if systemConfig, err := models.LoadConfig[models.CustomerServiceConfigData](database.DB, models.CSConfigKeySystem); err == nil {
+		autoReply = systemConfig.AutoReply
+	}
+	if sourceConfig, err := source.GetConfig(); err == nil && sourceConfig.AutoReply.Enabled {
+		autoReply = sourceConfig.AutoReply
+	}
+	return autoReply
+}
+
+// processDueTasks 处理所有已到期的自动回复任务
+func (s *AutoReplyService) processDueTasks() {
+	var tasks []models.AutoReplyTask
+	// 轮询查询频繁，不输出SQL日志
+	db := database.DB.Session(&gorm.Session{Logger: database.DB.Logger.LogMode(gormLogger.Warn)})
+
+	// 发送过程中节点中断的任务重新放回待执行
+	db.Model(&models.AutoReplyTask{}).
+		Where("status = ? AND updated_at < ?", "sending", time.Now().Add(-autoReplyClaimTimeout)).
+		Update("status", "pending")
+
+	if err := db.Where("status = ? AND due_at <= ?", "pending", time.Now()).Find(&tasks).Error; err != nil {
+		logger.App.Error("查询自动回复任务失败", zap.Error(err))
+		return
+	}
+
+	for i := range tasks {
+		s.process(&tasks[i])
+	}
+}
+
+// process 执行单个自动回复任务
+func (s *AutoReplyService) process(task *models.AutoReplyTask) {
+	// 先抢占任务，避免重复发送，发送成功后才标记为已发送
+	result := database.DB.Model(&models.AutoReplyTask{}).
+		Where("id = ? AND status = ?", task.ID, "pending").
+		Update("status", "sending")
+	if result.Error != nil || result.RowsAffected == 0 {
+		return
+	}
+
+	// 对话已删除时取消任务，查询失败按发送失败重试，避免任务停留在发送中被反复放回
+	var conversation models.Conversations
+	if err := database.DB.First(&conversation, task.ConversationID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			database.DB.Model(task).Update("status", "cancelled")
+			return
+		}
+		s.fail(task, err)
+		return
+	}
+	if !conversation.IsActive() {
+		database.DB.Model(task).Update("status", "cancelled")
+		return
+	}
+
+	// 期间客服已回复则不再自动回复，内部备注不算回复
+	var replies int64
+	database.DB.Model(&models.Message{}).
+		Where("conversation_id = ? AND sender = ? AND created_at >= ?", conversation.ID, "agent", task.CreatedAt).
+		Where("visibility <> ?", models.MessageVisibilityInternal).
+		Count(&replies)
+	if replies > 0 {
+		database.DB.Model(task).Update("status", "cancelled")
+		return
+	}
+
+	message, err := ChatPublic.postSystemMessage(&conversation, task.Message, "")
+	if err != nil {
+		s.fail(task, err)
+		return
+	}
+	database.DB.Model(task).Update("status", "sent")
+	logger.App.Info("自动回复已发送", zap.Uint("conversationID", conversation.ID), zap.Uint("messageID", message.ID))
+
+	// 同步到DooTask任务对话
+	if conversation.DooTaskDialogID > 0 && conversation.DooTaskTaskID > 0 {
+		customerServiceConfigData, err := models.LoadConfig[models.CustomerServiceConfigData](database.DB, models.CSConfigKeySystem)
+		if err != nil {
+			return
+		}
+		content := fmt.Sprintf("[自动回复]\n%s", message.Content)
+		go ChatPublic.sendToBot(customerServiceConfigData, content, fmt.Sprintf("%d", conversation.DooTaskDialogID))
+	}
+}
+
+// fail 记录发送失败，未超过最多尝试次数时顺延后重试
+func (s *AutoReplyService) fail(task *models.AutoReplyTask, err error) {
+	attempts := task.Attempts + 1
+	updates := map[string]interface{}{"attempts": attempts, "status": "failed"}
+	if attempts < autoReplyMaxAttempts {
+		updates["status"] = "pending"
+		updates["due_at"] = time.Now().Add(time.Duration(attempts) * autoReplyRetryDelay)
+	}
+	database.DB.Model(task).Updates(updates)
+	logger.App.Error("发送自动回复失败",
+		zap.Uint("conversationID", task.ConversationID),
+		zap.Int("attempts", attempts),
+		zap.Any("status", updates["status"]),
+		zap.Error(err))
+}
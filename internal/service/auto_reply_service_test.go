@@ -0,0 +1,23 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"support-plugin/internal/models"
+)
+
+func TestAutoReplyProcessDeletedConversation(t *testing.T) {
+	db := useTestDB(t, &models.Conversations{}, &models.AutoReplyTask{})
+
+	task := models.AutoReplyTask{ConversationID: 1, Message: "稍后回复您", DueAt: time.Now(), Status: "pending"}
+	db.Create(&task)
+
+	AutoReply.process(&task)
+
+	var stored models.AutoReplyTask
+	db.First(&stored, task.ID)
+	if stored.Status != "cancelled" {
+		t.Errorf("对话不存在时任务状态 = %s, want cancelled", stored.Status)
+	}
+}
@@ -0,0 +1,144 @@
+package service
+
+import (
+	"time"
+
+	"go.uber.org/zap"
+
+	"support-plugin/internal/models"
+	"support-plugin/internal/pkg/database"
+	bizErrors "support-plugin/internal/pkg/errors"
+	"support-plugin/internal/pkg/logger"
+)
+
+type AvailabilityService struct{}
+
+var Availability = &AvailabilityService{}
+
+// GetAvailability 获取指定来源当前的在线状态
+func (s *AvailabilityService) GetAvailability(sourceKey string) (*models.AvailabilityResponse, error) {
+	if sourceKey == "" {
+		sourceKey = "widget"
+	}
+	var source models.CustomerServiceSource
+	if err := database.DB.Where("source_key = ?", sourceKey).First(&source).Error; err != nil {
+		return nil, bizErrors.ErrSourceNotFound
+	}
+
+	workingHours, offlineMessage := s.resolve(&source)
+	online := s.IsWorkingTime(workingHours, time.Now())
+
+	resp := &models.AvailabilityResponse{
+		Online:       online,
+		AgentOnline:  online && Presence.AnyAvailable(source.SourceKey),
+		WorkingHours: workingHours,
+	}
+	if !online {
+		resp.OfflineMessage = offlineMessage
+	}
+	return resp, nil
+}
+
+// CheckSource 判断来源当前是否在工作时间内，离线时同时返回离线提示语
+func (s *AvailabilityService) CheckSource(source *models.CustomerServiceSource) (bool, string) {
+	workingHours, offlineMessage := s.resolve(source)
+	return s.IsWorkingTime(workingHours, time.Now()), offlineMessage
+}
+
+// resolve 获取生效的工作时间和离线提示语，来源配置优先于系统配置
+func (s *AvailabilityService) resolve(source *models.CustomerServiceSource) (models.WorkingHoursData, string) {
+	var workingHours models.WorkingHoursData
+	var offlineMessage string
+
+	if systemConfig, err := models.LoadConfig[models.CustomerServiceConfigData](database.DB, models.CSConfigKeySystem); err == nil {
+		workingHours = systemConfig.WorkingHours
+		offlineMessage = systemConfig.OfflineMessage
+	}
+
+	if sourceConfig, err := source.GetConfig(); err == nil {
+		if sourceConfig.WorkingHours.Enabled {
+			workingHours = sourceConfig.WorkingHours
+		}
+		if sourceConfig.OfflineMessage != "" {
+			offlineMessage = sourceConfig.OfflineMessage
+		}
+	}
+
+	return workingHours, offlineMessage
+}
+
+// IsWorkingTime 判断给定时间是否在工作时间内，未启用工作时间时始终在线
+func (s *AvailabilityService) IsWorkingTime(workingHours models.WorkingHoursData, now time.Time) bool {
+	if !workingHours.Enabled {
+		return true
+	}
+
+	return s.isWorkingTimeIn(workingHours, s.location(workingHours), now)
+}
+
+// location 获取工作时间的时区，未配置或无效时使用服务器时区
+func (s *AvailabilityService) location(workingHours models.WorkingHoursData) *time.Location {
+	if workingHours.Timezone == "" {
+		return time.Local
+	}
+	loc, err := time.LoadLocation(workingHours.Timezone)
+	if err != nil {
+		logger.App.Warn("工作时间时区无效，使用服务器时区", zap.String("timezone", workingHours.Timezone), zap.Error(err))
+		return time.Local
+	}
+	return loc
+}
+
+// isWorkingTimeIn 在指定时区下判断给定时间是否在工作时间内
+func (s *AvailabilityService) isWorkingTimeIn(workingHours models.WorkingHoursData, loc *time.Location, now time.Time) bool {
+	now = now.In(loc)
+	if !s.isWorkDay(workingHours, now) {
+		return false
+	}
+
+	startMinutes, endMinutes, ok := s.workingMinutes(workingHours)
+	if !ok {
+		logger.App.Warn("工作时间格式无效，按非工作时间处理",
+			zap.String("startTime", workingHours.StartTime),
+			zap.String("endTime", workingHours.EndTime))
+		return false
+	}
+
+	minutes := now.Hour()*60 + now.Minute()
+	if startMinutes <= endMinutes {
+		return minutes >= startMinutes && minutes < endMinutes
+	}
+	// 跨夜班次，如 22:00 - 06:00
+	return minutes >= startMinutes || minutes < endMinutes
+}
+
+// isWorkDay 判断 day 所在日期是否为工作日，节假日优先于调休上班日与每周工作日
+func (s *AvailabilityService) isWorkDay(workingHours models.WorkingHoursData, day time.Time) bool {
+	date := day.Format("2006-01-02")
+	for _, holiday := range workingHours.Holidays {
+		if holiday == date {
+			return false
+		}
+	}
+	for _, special := range workingHours.SpecialWorkDays {
+		if special == date {
+			return true
+		}
+	}
+	for _, weekday := range workingHours.WorkDays {
+		if time.Weekday(weekday) == day.Weekday() {
+			return true
+		}
+	}
+	return false
+}
+
+// workingMinutes 解析上下班时间，返回距当天零点的分钟数，格式无效时返回 false
+func (s *AvailabilityService) workingMinutes(workingHours models.WorkingHoursData) (int, int, bool) {
+	start, errStart := time.Parse("15:04", workingHours.StartTime)
+	end, errEnd := time.Parse("15:04", workingHours.EndTime)
+	if errStart != nil || errEnd != nil {
+		return 0, 0, false
+	}
+	return start.Hour()*60 + start.Minute(), end.Hour()*60 + end.Minute(), true
+}
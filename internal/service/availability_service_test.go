@@ -0,0 +1,94 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"support-plugin/internal/models"
+)
+
+// 东八区，2026-10-19 为周一
+var testLocation = time.FixedZone("UTC+8", 8*3600)
+
+func testTime(value string) time.Time {
+	t, err := time.ParseInLocation("2006-01-02 15:04", value, testLocation)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestIsWorkingTimeIn(t *testing.T) {
+	weekdays := models.WorkingHoursData{
+		Enabled:         true,
+		StartTime:       "09:00",
+		EndTime:         "18:00",
+		WorkDays:        []int{1, 2, 3, 4, 5},
+		Holidays:        []string{"2026-10-21"},
+		SpecialWorkDays: []string{"2026-10-24"},
+	}
+	overnight := models.WorkingHoursData{
+		Enabled:   true,
+		StartTime: "22:00",
+		EndTime:   "06:00",
+		WorkDays:  []int{0, 1, 2, 3, 4, 5, 6},
+	}
+	invalid := models.WorkingHoursData{
+		Enabled:   true,
+		StartTime: "9点",
+		EndTime:   "18:00",
+		WorkDays:  []int{1, 2, 3, 4, 5},
+	}
+
+	tests := []struct {
+		name         string
+		workingHours models.WorkingHoursData
+		now          string
+		want         bool
+	}{
+		{"工作日上班时间内", weekdays, "2026-10-19 10:30", true},
+		{"上班时间开始时", weekdays, "2026-10-19 09:00", true},
+		{"上班前", weekdays, "2026-10-19 08:59", false},
+		{"下班时间不计入", weekdays, "2026-10-19 18:00", false},
+		{"周末", weekdays, "2026-10-18 10:00", false},
+		{"节假日", weekdays, "2026-10-21 10:00", false},
+		{"周末调休上班", weekdays, "2026-10-24 10:00", true},
+		{"跨夜班次开始后", overnight, "2026-10-19 23:00", true},
+		{"跨夜班次次日凌晨", overnight, "2026-10-20 05:59", true},
+		{"跨夜班次结束后", overnight, "2026-10-20 12:00", false},
+		{"时间格式无效按非工作时间处理", invalid, "2026-10-19 10:00", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Availability.isWorkingTimeIn(tt.workingHours, testLocation, testTime(tt.now))
+			if got != tt.want {
+				t.Errorf("isWorkingTimeIn(%s) = %v, want %v", tt.now, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsWorkingTimeConvertsTimezone(t *testing.T) {
+	workingHours := models.WorkingHoursData{
+		Enabled:   true,
+		StartTime: "09:00",
+		EndTime:   "18:00",
+		WorkDays:  []int{1, 2, 3, 4, 5},
+		Timezone:  "Asia/Shanghai",
+	}
+	if _, err := time.LoadLocation(workingHours.Timezone); err != nil {
+		t.Skip("缺少时区数据")
+	}
+
+	// 周一 UTC 02:00 为上海时间 10:00
+	if !Availability.IsWorkingTime(workingHours, time.Date(2026, 10, 19, 2, 0, 0, 0, time.UTC)) {
+		t.Error("上海时间10:00应在工作时间内")
+	}
+	// 周一 UTC 12:00 为上海时间 20:00
+	if Availability.IsWorkingTime(workingHours, time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)) {
+		t.Error("上海时间20:00不应在工作时间内")
+	}
+	if !Availability.IsWorkingTime(models.WorkingHoursData{}, time.Now()) {
+		t.Error("未启用工作时间时应始终在线")
+	}
+}
@@ -0,0 +1,423 @@
+package service
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"support-plugin/internal/config"
+	"support-plugin/internal/i18n"
+	"support-plugin/internal/models"
+	"support-plugin/internal/pkg/database"
+	"support-plugin/internal/pkg/dootask"
+	bizErrors "support-plugin/internal/pkg/errors"
+	"support-plugin/internal/pkg/logger"
+	"support-plugin/internal/utils/common"
+)
+
+// /history 命令默认与最大的消息条数
+const (
+	botHistoryDefaultLimit = 10
+	botHistoryMaxLimit     = 50
+)
+
+// botCommandHelp 机器人命令说明
+const botCommandHelp = "可用命令：\n" +
+	"/close 关闭对话\n" +
+	"/reopen 重新打开对话\n" +
+	"/transfer @客服 转接对话\n" +
+	"/note 内容 记录内部备注（不发送给客户）\n" +
+	"/history [条数] 查看最近消息\n" +
+	"/info 查看客户信息\n" +
+	"/canned 查看可用的快捷回复\n" +
+	"#快捷短语 向客户发送快捷回复"
+
+// botCommands 机器人支持的命令，其他以 / 开头的消息按普通回复发送给客户
+var botCommands = map[string]bool{
+	"close":    true,
+	"reopen":   true,
+	"transfer": true,
+	"note":     true,
+	"history":  true,
+	"info":     true,
+	"canned":   true,
+	"help":     true,
+}
+
+var (
+	botTagPattern     = regexp.MustCompile(`<[^>]*>`)
+	botCommandPattern = regexp.MustCompile(`^/([A-Za-z]+)(?:\s+([\s\S]*))?$`)
+	botMentionPattern = regexp.MustCompile(`data-id="(\d+)"`)
+)
+
+// BotCommandService DooTask任务对话中的客服命令
+type BotCommandService struct{}
+
+var BotCommand = &BotCommandService{}
+
+// Handle 执行DooTask任务对话中的命令，并在任务对话中回复执行结果
+// 消息不是支持的命令时返回false，由调用方按普通回复发送给客户
+// 命令只能由对话的负责客服、参与客服或管理员执行
+func (s *BotCommandService) Handle(conversation *models.Conversations, message *dootask.DootaskMessage) bool {
+	command, args, ok := s.parse(message.Text)
+	if !ok || !botCommands[command] {
+		return false
+	}
+
+	userID := uint(common.StringToInt(message.MsgUid))
+	operator, err := s.authorize(conversation, userID)
+	if err != nil {
+		logger.App.Warn("拒绝执行DooTask机器人命令",
+			zap.Uint("conversationID", conversation.ID),
+			zap.String("command", command),
+			zap.Uint("dootaskUserID", userID))
+		s.reply(conversation, fmt.Sprintf("[命令失败] /%s：%s", command, s.errorText(err)))
+		return true
+	}
+	logger.App.Info("执行DooTask机器人命令",
+		zap.Uint("conversationID", conversation.ID),
+		zap.String("command", command),
+		zap.Uint("dootaskUserID", userID))
+
+	var reply string
+	switch command {
+	case "close":
+		err = ChatAgent.CloseConversation(int(conversation.ID), s.authID(userID, operator))
+	case "reopen":
+		err = ChatAgent.ReopenConversation(int(conversation.ID), s.authID(userID, operator))
+	case "transfer":
+		reply, err = s.transfer(conversation, operator, message.Text, args)
+	case "note":
+		reply, err = s.note(conversation, args, operator)
+	case "history":
+		reply, err = s.history(conversation, args)
+	case "info":
+		reply, err = s.info(conversation)
+	case "canned":
+		reply, err = s.canned(conversation)
+	case "help":
+		reply = botCommandHelp
+	}
+	if err != nil {
+		reply = fmt.Sprintf("[命令失败] /%s：%s", command, s.errorText(err))
+	}
+	// 关闭与重新打开成功后由任务同步在任务对话中说明，无需重复回复
+	if reply != "" {
+		s.reply(conversation, reply)
+	}
+	return true
+}
+
+// Shortcut 消息为 #快捷短语 时将展开后的快捷回复发送给客户，并在任务对话中回显发送的内容
+// 没有匹配的快捷回复时返回false，由调用方按普通回复发送
+func (s *BotCommandService) Shortcut(conversation *models.Conversations, message *dootask.DootaskMessage) bool {
+	plain := s.plain(message.Text)
+	content, ok := CannedResponse.ExpandShortcut(conversation, plain)
+	if !ok {
+		return false
+	}
+	if _, err := ChatAgent.SendMessageByAgent(conversation.ID, content, "text", "dootask", s.Sender(message)); err != nil {
+		s.reply(conversation, fmt.Sprintf("[快捷回复失败] %s：%s", plain, s.errorText(err)))
+		return true
+	}
+	s.reply(conversation, fmt.Sprintf("[快捷回复] 已发送 %s\n%s", plain, content))
+	return true
+}
+
+// parse 去除消息中的HTML标签后解析命令名称与参数
+func (s *BotCommandService) parse(text string) (string, string, bool) {
+	matches := botCommandPattern.FindStringSubmatch(s.plain(text))
+	if matches == nil {
+		return "", "", false
+	}
+	return strings.ToLower(matches[1]), strings.TrimSpace(matches[2]), true
+}
+
+// plain 去除DooTask消息中的HTML标签
+func (s *BotCommandService) plain(text string) string {
+	return strings.TrimSpace(html.UnescapeString(botTagPattern.ReplaceAllString(text, " ")))
+}
+
+// Sender 发送DooTask消息的客服，未登记为客服时为nil
+func (s *BotCommandService) Sender(message *dootask.DootaskMessage) *models.Agent {
+	return s.operator(uint(common.StringToInt(message.MsgUid)))
+}
+
+// authorize 查找执行命令的客服，DooTask用户须登记为启用的客服，且为对话的负责客服、参与客服或管理员
+func (s *BotCommandService) authorize(conversation *models.Conversations, userID uint) (*models.Agent, error) {
+	operator, err := Agent.FindByAuthID(s.authID(userID, s.operator(userID)))
+	if err != nil || userID == 0 || operator.Status != "active" {
+		return nil, &i18n.ErrorInfo{
+			Code:    i18n.ErrCodePermissionDenied,
+			Message: "only agents can run bot commands",
+		}
+	}
+	if err := Participant.authorize(conversation, operator, operator.IsAdmin); err != nil {
+		return nil, err
+	}
+	return operator, nil
+}
+
+// operator 根据DooTask用户ID查找客服，未登记为客服时为nil
+func (s *BotCommandService) operator(userID uint) *models.Agent {
+	if userID == 0 {
+		return nil
+	}
+	var agent models.Agent
+	if err := database.DB.Where("dootask_user_id = ?", userID).First(&agent).Error; err != nil {
+		return nil
+	}
+	return &agent
+}
+
+// authID 换算为对话服务使用的客服认证ID，DooTask模式下为DooTask用户ID
+func (s *BotCommandService) authID(userID uint, operator *models.Agent) uint {
+	if config.Cfg.App.Mode == "dootask" {
+		return userID
+	}
+	if operator == nil {
+		return 0
+	}
+	return operator.ID
+}
+
+// transfer 转接对话，目标客服可以是DooTask中@提及的用户，也可以是客服用户名或名称
+func (s *BotCommandService) transfer(conversation *models.Conversations, operator *models.Agent, text, args string) (string, error) {
+	var target models.Agent
+	if matches := botMentionPattern.FindStringSubmatch(text); matches != nil {
+		if err := database.DB.Where("dootask_user_id = ?", matches[1]).First(&target).Error; err != nil {
+			return "", bizErrors.NewBusinessError("AGENT_NOT_FOUND", "被提及的用户不是客服", nil)
+		}
+	} else {
+		name := strings.TrimPrefix(strings.TrimSpace(args), "@")
+		if name == "" {
+			return "", bizErrors.NewBusinessError("INVALID_COMMAND", "请使用 /transfer @客服 指定转接对象", nil)
+		}
+		if err := database.DB.Where("username = ? OR name = ?", name, name).First(&target).Error; err != nil {
+			return "", bizErrors.NewBusinessError("AGENT_NOT_FOUND", fmt.Sprintf("找不到客服 %s", name), nil)
+		}
+	}
+
+	// 转接结果由参与者服务在任务对话中说明
+	if _, err := Participant.Transfer(conversation.ID, operator, operator.IsAdmin, target.ID, ""); err != nil {
+		return "", err
+	}
+	return "", nil
+}
+
+// note 记录内部备注，备注仅客服可见，不发送给客户
+func (s *BotCommandService) note(conversation *models.Conversations, content string, operator *models.Agent) (string, error) {
+	if content == "" {
+		return "", bizErrors.NewBusinessError("INVALID_COMMAND", "请使用 /note 内容 记录备注", nil)
+	}
+	if _, err := ChatAgent.SendNote(conversation.ID, content, "dootask", operator); err != nil {
+		return "", err
+	}
+	return "[内部备注] 已保存，仅客服可见，不会发送给客户", nil
+}
+
+// history 获取对话最近的消息
+func (s *BotCommandService) history(conversation *models.Conversations, args string) (string, error) {
+	limit := botHistoryDefaultLimit
+	if args != "" {
+		n, err := strconv.Atoi(args)
+		if err != nil || n <= 0 {
+			return "", bizErrors.NewBusinessError("INVALID_COMMAND", "请使用 /history [条数] 查看最近消息", nil)
+		}
+		limit = n
+	}
+	if limit > botHistoryMaxLimit {
+		limit = botHistoryMaxLimit
+	}
+
+	messages, hasMore, err := MessageHistory.List(conversation.ID, models.MessageHistoryQuery{Limit: limit})
+	if err != nil {
+		return "", err
+	}
+	if len(messages) == 0 {
+		return "[历史消息] 暂无消息", nil
+	}
+
+	customerName := "客户"
+	var customer models.Customer
+	if err := database.DB.Where("id = ?", conversation.CustomerID).First(&customer).Error; err == nil && customer.Name != "" {
+		customerName = customer.Name
+	}
+	agentNames := map[uint]string{}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "[历史消息] 最近%d条", len(messages))
+	if hasMore {
+		b.WriteString("（更早的消息请在客服后台查看）")
+	}
+	for i := range messages {
+		message := &messages[i]
+		var sender string
+		switch message.Sender {
+		case "customer":
+			sender = customerName
+		case "agent":
+			sender = "客服"
+			if message.SenderID > 0 {
+				if _, ok := agentNames[message.SenderID]; !ok {
+					var agent models.Agent
+					if err := database.DB.Unscoped().Where("id = ?", message.SenderID).First(&agent).Error; err == nil {
+						agentNames[message.SenderID] = Agent.DisplayName(&agent)
+					}
+				}
+				if name := agentNames[message.SenderID]; name != "" {
+					sender = name
+				}
+			}
+		default:
+			sender = "系统"
+		}
+		if message.IsInternal() {
+			sender += "（内部备注）"
+		}
+		fmt.Fprintf(&b, "\n%s %s：%s", message.CreatedAt.Format("01-02 15:04"), sender, Attachment.DooTaskText(message))
+	}
+	return b.String(), nil
+}
+
+// info 获取客户与对话的基本信息，任务对话中的联系方式脱敏显示，完整信息在客服后台查看
+func (s *BotCommandService) info(conversation *models.Conversations) (string, error) {
+	var customer models.Customer
+	if err := database.DB.Unscoped().Where("id = ?", conversation.CustomerID).First(&customer).Error; err != nil {
+		return "", bizErrors.NewBusinessError("CUSTOMER_NOT_FOUND", "客户不存在", nil)
+	}
+
+	sourceName := conversation.SourceKey
+	var source models.CustomerServiceSource
+	if err := database.DB.Where("source_key = ?", conversation.SourceKey).First(&source).Error; err == nil {
+		sourceName = source.Name
+	}
+	agentName := "未分配"
+	if conversation.AgentID > 0 {
+		var agent models.Agent
+		if err := database.DB.Unscoped().Where("id = ?", conversation.AgentID).First(&agent).Error; err == nil {
+			agentName = Agent.DisplayName(&agent)
+		}
+	}
+
+	lines := []string{
+		"[客户信息]",
+		"名称：" + s.orNone(customer.Name),
+		"邮箱：" + s.orNone(s.maskEmail(customer.Email)),
+		"电话：" + s.orNone(s.maskPhone(customer.Phone)),
+	}
+	lines = append(lines,
+		"来源："+s.orNone(sourceName),
+		"状态："+conversation.Status,
+		"优先级："+s.orNone(models.Priorities[conversation.Priority]),
+		"负责客服："+agentName,
+		"创建时间："+conversation.CreatedAt.Format("2006-01-02 15:04:05"),
+	)
+	if tagMap, err := models.LoadConversationTags(database.DB, conversation.ID); err == nil && len(tagMap[conversation.ID]) > 0 {
+		names := make([]string, 0, len(tagMap[conversation.ID]))
+		for _, tag := range tagMap[conversation.ID] {
+			names = append(names, tag.Name)
+		}
+		lines = append(lines, "标签："+strings.Join(names, "、"))
+	}
+	for _, due := range []struct {
+		metric string
+		at     *time.Time
+	}{
+		{models.SLAMetricFirstResponse, conversation.FirstResponseDueAt},
+		{models.SLAMetricNextResponse, conversation.NextResponseDueAt},
+		{models.SLAMetricResolution, conversation.ResolutionDueAt},
+	} {
+		if due.at != nil {
+			lines = append(lines, models.SLAMetricNames[due.metric]+"截止："+due.at.Local().Format("2006-01-02 15:04"))
+		}
+	}
+	lines = append(lines, "完整的客户信息请在客服后台查看")
+	return strings.Join(lines, "\n"), nil
+}
+
+// canned 获取对话来源可用的快捷回复
+func (s *BotCommandService) canned(conversation *models.Conversations) (string, error) {
+	responses, err := CannedResponse.List(models.CannedResponseQuery{SourceKey: conversation.SourceKey})
+	if err != nil {
+		return "", err
+	}
+	if len(responses) == 0 {
+		return "[快捷回复] 暂无可用的快捷回复", nil
+	}
+	// 来源专属的快捷回复覆盖同名的全局快捷回复
+	overridden := map[string]bool{}
+	for _, response := range responses {
+		if response.SourceKey != "" {
+			overridden[response.Shortcut] = true
+		}
+	}
+	lines := []string{"[快捷回复] 发送 #快捷短语 即可回复客户"}
+	for _, response := range responses {
+		if response.SourceKey == "" && overridden[response.Shortcut] {
+			continue
+		}
+		title := response.Title
+		if title == "" {
+			// 没有标题时显示内容开头
+			content := []rune(response.Content)
+			title = string(content[:min(len(content), 20)])
+		}
+		lines = append(lines, fmt.Sprintf("#%s %s", response.Shortcut, title))
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// reply 在任务对话中回复命令执行结果
+func (s *BotCommandService) reply(conversation *models.Conversations, content string) {
+	if conversation.DooTaskDialogID <= 0 {
+		return
+	}
+	customerServiceConfigData, err := models.LoadConfig[models.CustomerServiceConfigData](database.DB, models.CSConfigKeySystem)
+	if err != nil {
+		return
+	}
+	ChatAgent.sendToBot(customerServiceConfigData, content, fmt.Sprintf("%d", conversation.DooTaskDialogID))
+}
+
+// errorText 命令执行失败时展示的错误信息
+func (s *BotCommandService) errorText(err error) string {
+	switch e := err.(type) {
+	case *bizErrors.BusinessError:
+		return e.Message
+	case *i18n.ErrorInfo:
+		return i18n.T(i18n.DefaultLanguage, string(e.Code))
+	}
+	return "执行失败，请稍后重试"
+}
+
+// maskEmail 邮箱只显示用户名首字符与域名
+func (s *BotCommandService) maskEmail(email string) string {
+	name, domain, ok := strings.Cut(email, "@")
+	if !ok || name == "" {
+		return s.maskPhone(email)
+	}
+	return string([]rune(name)[:1]) + "***@" + domain
+}
+
+// maskPhone 只显示末尾4位
+func (s *BotCommandService) maskPhone(phone string) string {
+	runes := []rune(phone)
+	if len(runes) <= 4 {
+		return strings.Repeat("*", len(runes))
+	}
+	return "****" + string(runes[len(runes)-4:])
+}
+
+// orNone 空值显示为“未填写”
+func (s *BotCommandService) orNone(value string) string {
+	if value == "" {
+		return "未填写"
+	}
+	return value
+}
@@ -0,0 +1,85 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"support-plugin/internal/config"
+	"support-plugin/internal/i18n"
+	"support-plugin/internal/models"
+	"support-plugin/internal/pkg/dootask"
+)
+
+func TestBotCommandAuthorize(t *testing.T) {
+	previous := config.Cfg
+	config.Cfg = &config.Config{App: config.AppConfig{Mode: "dootask"}}
+	t.Cleanup(func() { config.Cfg = previous })
+
+	db := useTestDB(t, &models.Agent{}, &models.ConversationParticipant{})
+	db.Create(&models.Agent{ID: 10, Username: "owner", DooTaskUserID: 100, Status: "active"})
+	db.Create(&models.Agent{ID: 11, Username: "participant", DooTaskUserID: 101, Status: "active"})
+	db.Create(&models.Agent{ID: 12, Username: "other", DooTaskUserID: 102, Status: "active"})
+	db.Create(&models.Agent{ID: 13, Username: "admin", DooTaskUserID: 103, Status: "active", IsAdmin: true})
+	db.Create(&models.Agent{ID: 14, Username: "disabled", DooTaskUserID: 104, Status: "inactive", IsAdmin: true})
+	db.Create(&models.ConversationParticipant{ConversationID: 1, AgentID: 11, Role: models.ParticipantRoleParticipant, JoinedAt: time.Now()})
+
+	conversation := &models.Conversations{ID: 1, AgentID: 10}
+	tests := []struct {
+		name    string
+		userID  uint
+		allowed bool
+	}{
+		{"负责客服", 100, true},
+		{"参与客服", 101, true},
+		{"管理员", 103, true},
+		{"无关客服", 102, false},
+		{"已停用的客服", 104, false},
+		{"未登记为客服的任务成员", 200, false},
+		{"缺少发送者", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			operator, err := BotCommand.authorize(conversation, tt.userID)
+			if tt.allowed {
+				if err != nil || operator == nil || uint(operator.DooTaskUserID) != tt.userID {
+					t.Errorf("authorize() = %v, %v", operator, err)
+				}
+				return
+			}
+			var info *i18n.ErrorInfo
+			if operator != nil || !errors.As(err, &info) || info.Code != i18n.ErrCodePermissionDenied {
+				t.Errorf("authorize() = %v, %v, want %s", operator, err, i18n.ErrCodePermissionDenied)
+			}
+		})
+	}
+}
+
+func TestBotCommandHandleForwardsUnknown(t *testing.T) {
+	conversation := &models.Conversations{ID: 1}
+	for _, text := range []string{"你好", "/etc/hosts 已修改", "/unknown 参数"} {
+		if BotCommand.Handle(conversation, &dootask.DootaskMessage{Text: text, MsgUid: "100"}) {
+			t.Errorf("Handle(%q) = true, want false", text)
+		}
+	}
+}
+
+func TestBotCommandMask(t *testing.T) {
+	tests := []struct {
+		value string
+		email string
+		phone string
+	}{
+		{"alice@example.com", "a***@example.com", "****.com"},
+		{"13812345678", "****5678", "****5678"},
+		{"123", "***", "***"},
+	}
+	for _, tt := range tests {
+		if got := BotCommand.maskEmail(tt.value); got != tt.email {
+			t.Errorf("maskEmail(%q) = %q, want %q", tt.value, got, tt.email)
+		}
+		if got := BotCommand.maskPhone(tt.value); got != tt.phone {
+			t.Errorf("maskPhone(%q) = %q, want %q", tt.value, got, tt.phone)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package service
+
+import (
+	"regexp"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"support-plugin/internal/models"
+	"support-plugin/internal/pkg/database"
+	bizErrors "support-plugin/internal/pkg/errors"
+	"support-plugin/internal/pkg/logger"
+)
+
+var (
+	cannedShortcutPattern = regexp.MustCompile(`^[\p{L}\p{N}_-]{1,64}$`)
+	cannedVariablePattern = regexp.MustCompile(`\{\{\s*([a-z]+\.[a-z_]+)\s*\}\}`)
+	cannedMessagePattern  = regexp.MustCompile(`^#([^\s#]+)$`)
+)
+
+var errCannedResponseNotFound = bizErrors.NewBusinessError("CANNED_RESPONSE_NOT_FOUND", "快捷回复不存在", nil)
+
+// CannedResponseService 快捷回复的管理与展开
+type CannedResponseService struct{}
+
+var CannedResponse = &CannedResponseService{}
+
+// List 获取快捷回复列表，指定来源时返回全局及该来源的快捷回复
+func (s *CannedResponseService) List(query models.CannedResponseQuery) ([]models.CannedResponse, error) {
+	db := database.DB.Model(&models.CannedResponse{})
+	if query.SourceKey != "" {
+		db = db.Where("source_key = ? OR source_key = ?", "", query.SourceKey)
+	}
+	if keyword := strings.TrimSpace(query.Keyword); keyword != "" {
+		like := "%" + keyword + "%"
+		db = db.Where("shortcut LIKE ? OR title LIKE ? OR content LIKE ?", like, like, like)
+	}
+	responses := []models.CannedResponse{}
+	err := db.Order("source_key ASC, shortcut ASC").Find(&responses).Error
+	return responses, err
+}
+
+// Get 根据ID获取快捷回复
+func (s *CannedResponseService) Get(id uint) (*models.CannedResponse, error) {
+	var response models.CannedResponse
+	if err := database.DB.First(&response, id).Error; err != nil {
+		return nil, errCannedResponseNotFound
+	}
+	return &response, nil
+}
+
+// Create 创建快捷回复
+func (s *CannedResponseService) Create(req models.CannedResponseRequest, createdBy uint) (*models.CannedResponse, error) {
+	response := models.CannedResponse{CreatedBy: createdBy}
+	if err := s.apply(&response, req); err != nil {
+		return nil, err
+	}
+	if err := database.DB.Create(&response).Error; err != nil {
+		return nil, err
+	}
+	logger.App.Info("快捷回复已创建", zap.Uint("id", response.ID), zap.String("shortcut", response.Shortcut))
+	return &response, nil
+}
+
+// Update 更新快捷回复
+func (s *CannedResponseService) Update(id uint, req models.CannedResponseRequest) (*models.CannedResponse, error) {
+	response, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.apply(response, req); err != nil {
+		return nil, err
+	}
+	if err := database.DB.Save(response).Error; err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// Delete 删除快捷回复
+func (s *CannedResponseService) Delete(id uint) error {
+	result := database.DB.Delete(&models.CannedResponse{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errCannedResponseNotFound
+	}
+	return nil
+}
+
+// Expand 将指定快捷回复展开为对话中的回复内容，快捷回复必须为全局或属于对话来源
+func (s *CannedResponseService) Expand(conversation *models.Conversations, id uint) (string, error) {
+	response, err := s.Get(id)
+	if err != nil {
+		return "", err
+	}
+	if response.SourceKey != "" && response.SourceKey != conversation.SourceKey {
+		return "", bizErrors.NewBusinessError("CANNED_RESPONSE_NOT_AVAILABLE", "该快捷回复不适用于此对话的来源", nil)
+	}
+	return s.Render(response.Content, conversation), nil
+}
+
+// ExpandShortcut 消息内容仅为 #快捷短语 时展开为对应的快捷回复，来源专属的快捷回复优先于全局
+func (s *CannedResponseService) ExpandShortcut(conversation *models.Conversations, text string) (string, bool) {
+	matches := cannedMessagePattern.FindStringSubmatch(strings.TrimSpace(text))
+	if matches == nil {
+		return "", false
+	}
+	var response models.CannedResponse
+	err := database.DB.Where("shortcut = ? AND (source_key = ? OR source_key = ?)", strings.ToLower(matches[1]), "", conversation.SourceKey).
+		Order("source_key DESC").
+		First(&response).Error
+	if err != nil {
+		return "", false
+	}
+	return s.Render(response.Content, conversation), true
+}
+
+// Render 替换快捷回复内容中的客户与对话变量，未知变量保持原样
+func (s *CannedResponseService) Render(content string, conversation *models.Conversations) string {
+	if !strings.Contains(content, "{{") {
+		return content
+	}
+	var customer *models.Customer
+	return cannedVariablePattern.ReplaceAllStringFunc(content, func(match string) string {
+		name := cannedVariablePattern.FindStringSubmatch(match)[1]
+		if strings.HasPrefix(name, "customer.") && customer == nil {
+			customer = &models.Customer{}
+			database.DB.Unscoped().Where("id = ?", conversation.CustomerID).First(customer)
+		}
+		switch name {
+		case "customer.name":
+			return customer.Name
+		case "customer.email":
+			return customer.Email
+		case "customer.phone":
+			return customer.Phone
+		case "conversation.title":
+			return conversation.Title
+		case "conversation.uuid":
+			return conversation.Uuid
+		case "conversation.source":
+			return conversation.SourceKey
+		}
+		return match
+	})
+}
+
+// apply 校验请求并写入快捷回复，快捷短语统一去掉 # 前缀并转为小写
+func (s *CannedResponseService) apply(response *models.CannedResponse, req models.CannedResponseRequest) error {
+	shortcut := strings.ToLower(strings.TrimPrefix(strings.TrimSpace(req.Shortcut), "#"))
+	if !cannedShortcutPattern.MatchString(shortcut) {
+		return bizErrors.NewBusinessError("INVALID_SHORTCUT", "快捷短语只能包含文字、数字、下划线和连字符，且不超过64个字符", nil)
+	}
+	content := strings.TrimSpace(req.Content)
+	if content == "" {
+		return bizErrors.NewBusinessError("INVALID_CANNED_CONTENT", "回复内容不能为空", nil)
+	}
+	sourceKey := strings.TrimSpace(req.SourceKey)
+	if sourceKey != "" {
+		var count int64
+		database.DB.Model(&models.CustomerServiceSource{}).Where("source_key = ?", sourceKey).Count(&count)
+		if count == 0 {
+			return bizErrors.NewBusinessError("SOURCE_NOT_FOUND", "来源不存在", nil)
+		}
+	}
+
+	var exists int64
+	database.DB.Model(&models.CannedResponse{}).
+		Where("shortcut = ? AND source_key = ? AND id <> ?", shortcut, sourceKey, response.ID).
+		Count(&exists)
+	if exists > 0 {
+		return bizErrors.NewBusinessError("SHORTCUT_EXISTS", "该范围内已存在相同的快捷短语", nil)
+	}
+
+	response.Shortcut = shortcut
+	response.SourceKey = sourceKey
+	response.Title = strings.TrimSpace(req.Title)
+	response.Content = content
+	return nil
+}
@@ -66,7 +66,7 @@ func (s *ChatService) CreateConversationWithDetails(agentID, customerID uint, ti
 	conversation.Title = title
 
 	// 异步广播到WebSocket客户端
-	go websocket.BroadcastToAllAgents(conversation, websocket.MessageTypeNewConversation)
+	go websocket.BroadcastToConversationAgents(conversation.Uuid, conversation, websocket.MessageTypeNewConversation)
 
 	return uuidStr, nil
 }
@@ -161,7 +161,7 @@ func (s *ChatService) SendMessageWithDetails(conversationUUID, content, sender,
 
 	if sender == "customer" {
 		// 通过WebSocket广播消息
-		go websocket.BroadcastToAllAgents(message, websocket.MessageTypeNewMessage)
+		go websocket.BroadcastToConversationAgents(conversation.Uuid, message, websocket.MessageTypeNewMessage)
 		go func(content, dialogID string) {
 			CustomerServiceConfigData, err := models.LoadConfig[models.CustomerServiceConfigData](database.DB, models.CSConfigKeySystem)
 			if err != nil {
@@ -285,7 +285,7 @@ func (s *ChatService) SendMessageWithDetailsByID(conversationUUID int, content,
 
 	if sender == "customer" {
 		// 通过WebSocket广播消息
-		go websocket.BroadcastToAllAgents(message, websocket.MessageTypeNewMessage)
+		go websocket.BroadcastToConversationAgents(conversation.Uuid, message, websocket.MessageTypeNewMessage)
 		go func(content, dialogID string) {
 			CustomerServiceConfigData, err := models.LoadConfig[models.CustomerServiceConfigData](database.DB, models.CSConfigKeySystem)
 			if err != nil {
@@ -324,74 +324,34 @@ func (s *ChatService) SendMessageWithDetailsByID(conversationUUID int, content,
 	return &message, nil
 }
 
-// GetMessages 获取对话消息列表
-func (s *ChatService) GetMessageListByConversationUUID(conversationUUID string, page, pageSize int) ([]models.Message, int64, error) {
+// GetMessageListByConversationUUID 获取对话消息列表
+func (s *ChatService) GetMessageListByConversationUUID(conversationUUID string, query models.MessageHistoryQuery) ([]models.Message, bool, error) {
 	// 查找对话
 	var conversation models.Conversations
 	result := database.DB.Where("uuid = ?", conversationUUID).First(&conversation)
 	if result.Error != nil {
-		return nil, 0, &i18n.ErrorInfo{
+		return nil, false, &i18n.ErrorInfo{
 			Code:    i18n.ErrCodeConversationNotFound,
 			Message: "对话不存在",
 		}
 	}
 
-	// 计算总数
-	var total int64
-	database.DB.Model(&models.Message{}).Where("conversation_id = ?", conversation.ID).Count(&total)
-
-	// 查询最新的消息
-	var messages []models.Message
-	result = database.DB.Where("conversation_id = ?", conversation.ID).
-		Order("created_at DESC"). // 降序排列，获取最新消息
-		Limit(pageSize).          // 限制返回数量
-		Find(&messages)
-
-	// 反转切片，使消息按时间正序排列
-	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
-		messages[i], messages[j] = messages[j], messages[i]
-	}
-
-	if result.Error != nil {
-		return nil, 0, result.Error
-	}
-
-	return messages, total, nil
+	return MessageHistory.ListPublic(conversation.ID, query)
 }
 
-// GetMessages 获取对话消息列表
-func (s *ChatService) GetMessageListByConversationID(conversationID, page, pageSize int) ([]models.Message, int64, error) {
+// GetMessageListByConversationID 获取对话消息列表
+func (s *ChatService) GetMessageListByConversationID(conversationID int, query models.MessageHistoryQuery) ([]models.Message, bool, error) {
 	// 查找对话
 	var conversation models.Conversations
 	result := database.DB.Where("id = ?", conversationID).First(&conversation)
 	if result.Error != nil {
-		return nil, 0, &i18n.ErrorInfo{
+		return nil, false, &i18n.ErrorInfo{
 			Code:    i18n.ErrCodeConversationNotFound,
 			Message: "对话不存在",
 		}
 	}
 
-	// 计算总数
-	var total int64
-	database.DB.Model(&models.Message{}).Where("conversation_id = ?", conversation.ID).Count(&total)
-
-	// 查询最新的消息
-	var messages []models.Message
-	result = database.DB.Where("conversation_id = ?", conversation.ID).
-		Order("created_at DESC"). // 降序排列，获取最新消息
-		Limit(pageSize).          // 限制返回数量
-		Find(&messages)
-
-	// 反转切片，使消息按时间正序排列
-	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
-		messages[i], messages[j] = messages[j], messages[i]
-	}
-
-	if result.Error != nil {
-		return nil, 0, result.Error
-	}
-
-	return messages, total, nil
+	return MessageHistory.List(conversation.ID, query)
 }
 
 // GetConversationByUUID 通过UUID获取对话
@@ -2,11 +2,18 @@ package service
 
 import (
 	"fmt"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+
 	"support-plugin/internal/config"
 	"support-plugin/internal/models"
 	"support-plugin/internal/pkg/database"
 	"support-plugin/internal/pkg/dootask"
 	bizErrors "support-plugin/internal/pkg/errors"
+	"support-plugin/internal/pkg/eventbus"
+	"support-plugin/internal/pkg/logger"
 	"support-plugin/internal/pkg/websocket"
 )
 
@@ -14,8 +21,8 @@ type ChatAgentService struct{}
 
 var ChatAgent = &ChatAgentService{}
 
-// SendMessageByAgent 客服发送消息
-func (s *ChatAgentService) SendMessageByAgent(conversationID uint, content, msgType, metadata string) (*models.Message, error) {
+// SendMessageByAgent 客服发送消息，agent 为发送消息的客服，未登记为客服时为空
+func (s *ChatAgentService) SendMessageByAgent(conversationID uint, content, msgType, metadata string, agent *models.Agent) (*models.Message, error) {
 	// 查找对话
 	var conversation models.Conversations
 	result := database.DB.Where("id = ?", conversationID).First(&conversation)
@@ -36,6 +43,9 @@ func (s *ChatAgentService) SendMessageByAgent(conversationID uint, content, msgT
 		Type:           msgType,
 		Metadata:       metadata,
 	}
+	if agent != nil {
+		message.SenderID = agent.ID
+	}
 
 	// 保存消息
 	result = database.DB.Create(&message)
@@ -44,17 +54,37 @@ func (s *ChatAgentService) SendMessageByAgent(conversationID uint, content, msgT
 	}
 
 	// 更新对话的最后消息信息
-	database.DB.Model(&conversation).Updates(map[string]interface{}{
+	updates := map[string]interface{}{
 		"last_message":    content,
 		"last_message_at": message.CreatedAt,
-	})
+	}
+	// 客服回复离线留言后，对话恢复为进行中
+	if conversation.Status == "offline" {
+		updates["status"] = "open"
+	}
+	database.DB.Model(&conversation).Updates(updates)
+
+	// 客服已回复，取消待执行的自动回复
+	AutoReply.Cancel(conversation.ID)
+
+	if eventbus.GlobalEventBus != nil {
+		messageEvent := eventbus.NewMessageCreatedEvent(message.ID, conversation.ID, content, message.Sender, message.Type)
+		if err := eventbus.GlobalEventBus.Publish(messageEvent); err != nil {
+			logger.App.Error("发布消息创建事件失败", zap.Uint("messageID", message.ID), zap.Error(err))
+		}
+	}
 
 	go websocket.BroadcastMessage(conversation.Uuid, map[string]interface{}{
-		"content": content,
+		"id":         message.ID,
+		"content":    content,
+		"sender":     message.Sender,
+		"type":       message.Type,
+		"metadata":   message.Metadata,
+		"created_at": message.CreatedAt,
 	}, websocket.MessageTypeNewMessage)
 
 	if conversation.DooTaskDialogID > 0 && conversation.DooTaskTaskID > 0 && metadata != "dootask"{
-		content := fmt.Sprintf("[从系统回复]\n%s", message.Content)
+		content := fmt.Sprintf("[从系统回复]\n%s", Attachment.DooTaskText(&message))
 		go func(content string, dialogId int) {
 			customerServiceConfigData, err := models.LoadConfig[models.CustomerServiceConfigData](database.DB, models.CSConfigKeySystem)
 			if err != nil {
@@ -68,8 +98,51 @@ func (s *ChatAgentService) SendMessageByAgent(conversationID uint, content, msgT
 	return &message, nil
 }
 
-// GetAgentConversations 获取客服的对话列表
-func (s *ChatAgentService) GetAgentConversations(agentID uint, page, pageSize int, status, keyword string) ([]models.Conversations, int64, error) {
+// SendNote 客服添加内部备注，备注仅推送给客服并同步到DooTask任务对话，不发送给客户
+// 内部备注不更新对话的最后消息、不取消自动回复，也不计入消息统计；已关闭的对话同样可以添加
+func (s *ChatAgentService) SendNote(conversationID uint, content, metadata string, agent *models.Agent) (*models.Message, error) {
+	var conversation models.Conversations
+	if err := database.DB.Where("id = ?", conversationID).First(&conversation).Error; err != nil {
+		return nil, bizErrors.ErrConversationNotFound
+	}
+
+	message := models.Message{
+		ConversationID: conversationID,
+		Content:        content,
+		Sender:         "agent",
+		Type:           "text",
+		Metadata:       metadata,
+		Visibility:     models.MessageVisibilityInternal,
+	}
+	if agent != nil {
+		message.SenderID = agent.ID
+	}
+	if err := database.DB.Create(&message).Error; err != nil {
+		return nil, err
+	}
+
+	go websocket.BroadcastToConversationAgents(conversation.Uuid, message, websocket.MessageTypeNewMessage)
+
+	if conversation.DooTaskDialogID > 0 && conversation.DooTaskTaskID > 0 && metadata != "dootask" {
+		name := "管理员"
+		if agent != nil {
+			name = Agent.DisplayName(agent)
+		}
+		content := fmt.Sprintf("[内部备注] %s\n%s", name, content)
+		go func(content string, dialogId int) {
+			customerServiceConfigData, err := models.LoadConfig[models.CustomerServiceConfigData](database.DB, models.CSConfigKeySystem)
+			if err != nil {
+				return
+			}
+			s.sendToBot(customerServiceConfigData, content, fmt.Sprintf("%d", dialogId))
+		}(content, conversation.DooTaskDialogID)
+	}
+
+	return &message, nil
+}
+
+// GetAgentConversations 获取客服的对话列表，指定标签时只返回带有全部这些标签的对话
+func (s *ChatAgentService) GetAgentConversations(agentID uint, page, pageSize int, status, keyword string, tagIDs []uint) ([]models.Conversations, int64, error) {
 	var conversations []models.Conversations
 	var total int64
 
@@ -86,12 +159,36 @@ func (s *ChatAgentService) GetAgentConversations(agentID uint, page, pageSize in
 		query = query.Where("title LIKE ? OR last_message LIKE ?", "%"+keyword+"%", "%"+keyword+"%")
 	}
 
+	// 添加标签筛选
+	if len(tagIDs) > 0 {
+		tagged := database.DB.Model(&models.ConversationTag{}).
+			Select("conversation_id").
+			Where("tag_id IN ?", tagIDs).
+			Group("conversation_id").
+			Having("COUNT(DISTINCT tag_id) = ?", len(tagIDs))
+		query = query.Where("id IN (?)", tagged)
+	}
+
 	// 获取总数
 	query.Count(&total)
 
 	// 分页查询
 	offset := (page - 1) * pageSize
 	err := query.Offset(offset).Limit(pageSize).Order("updated_at DESC").Find(&conversations).Error
+	if err != nil {
+		return conversations, total, err
+	}
+
+	// 统计当前客服的未读消息数
+	if err := ReadReceipt.FillUnreadCounts(strconv.FormatUint(uint64(agentID), 10), conversations); err != nil {
+		return conversations, total, err
+	}
+
+	// 填充标签与自定义字段
+	if err := Tag.FillTags(conversations); err != nil {
+		return conversations, total, err
+	}
+	err = CustomField.FillValues(conversations)
 
 	return conversations, total, err
 }
@@ -103,6 +200,23 @@ func (s *ChatAgentService) GetConversationByUUID(uuid string) (*models.Conversat
 	if result.Error != nil {
 		return nil, bizErrors.ErrConversationNotFound
 	}
+	conversations := []models.Conversations{conversation}
+	if err := Tag.FillTags(conversations); err != nil {
+		return nil, err
+	}
+	if err := CustomField.FillValues(conversations); err != nil {
+		return nil, err
+	}
+	return &conversations[0], nil
+}
+
+// GetConversationByID 根据ID获取对话信息
+func (s *ChatAgentService) GetConversationByID(id uint) (*models.Conversations, error) {
+	var conversation models.Conversations
+	result := database.DB.First(&conversation, id)
+	if result.Error != nil {
+		return nil, bizErrors.ErrConversationNotFound
+	}
 	return &conversation, nil
 }
 
@@ -116,22 +230,22 @@ func (s *ChatAgentService) GetConversationByDooTasDialogID(dialogID int) (*model
 	return &conversation, nil
 }
 
-// GetMessageListByConversationID 根据对话ID获取消息列表
-func (s *ChatAgentService) GetMessageListByConversationID(conversationID int, page, pageSize int) ([]models.Message, int64, error) {
-	var messages []models.Message
-	var total int64
-
-	// 构建查询
-	query := database.DB.Model(&models.Message{}).Where("conversation_id = ?", conversationID)
-
-	// 获取总数
-	query.Count(&total)
-
-	// 分页查询
-	offset := (page - 1) * pageSize
-	err := query.Offset(offset).Limit(pageSize).Order("created_at ASC").Find(&messages).Error
+// GetMessageListByConversationID 根据对话ID获取消息列表，支持 before_id / after_id 游标
+func (s *ChatAgentService) GetMessageListByConversationID(conversationID int, query models.MessageHistoryQuery) ([]models.Message, bool, error) {
+	var conversation models.Conversations
+	if err := database.DB.First(&conversation, conversationID).Error; err != nil {
+		return nil, false, bizErrors.ErrConversationNotFound
+	}
+	return MessageHistory.List(conversation.ID, query)
+}
 
-	return messages, total, err
+// MarkRead 标记客服在对话中的已读位置
+func (s *ChatAgentService) MarkRead(id int, agentID uint, messageID uint) (*models.ConversationRead, error) {
+	var conversation models.Conversations
+	if err := database.DB.First(&conversation, id).Error; err != nil {
+		return nil, bizErrors.ErrConversationNotFound
+	}
+	return ReadReceipt.MarkRead(&conversation, "agent", strconv.FormatUint(uint64(agentID), 10), messageID)
 }
 
 // CloseConversation 关闭对话
@@ -148,15 +262,54 @@ func (s *ChatAgentService) CloseConversation(id int, agentID uint) error {
 		return bizErrors.NewBusinessError("CONVERSATION_ALREADY_CLOSED", "对话已经关闭", nil)
 	}
 
-	// 更新对话状态为关闭
-	result = database.DB.Model(&conversation).Updates(map[string]interface{}{
-		"status":   "closed",
-		"agent_id": agentID, // 记录关闭对话的客服
-	})
+	// 负责客服保持不变，另行记录关闭人，找不到客服时按非客服关闭处理
+	agent, _ := Agent.FindByAuthID(agentID)
+	if err := s.closeConversation(&conversation, agent); err != nil {
+		return err
+	}
 
+	// 同步完成DooTask任务
+	go TaskSync.Closed(&conversation, agent)
+
+	return nil
+}
+
+// closeConversation 将对话标记为关闭并通知客户，agent 为空表示非客服关闭
+func (s *ChatAgentService) closeConversation(conversation *models.Conversations, agent *models.Agent) error {
+	var closedBy uint
+	if agent != nil {
+		closedBy = agent.ID
+	}
+	// 条件更新，避免与DooTask任务同步同时关闭时重复处理
+	result := database.DB.Model(&models.Conversations{}).
+		Where("id = ? AND status <> ?", conversation.ID, "closed").
+		Updates(map[string]interface{}{
+			"status":    "closed",
+			"closed_by": closedBy,
+			"closed_at": time.Now(),
+		})
 	if result.Error != nil {
 		return result.Error
 	}
+	if result.RowsAffected == 0 {
+		return bizErrors.NewBusinessError("CONVERSATION_ALREADY_CLOSED", "对话已经关闭", nil)
+	}
+	conversation.Status = "closed"
+
+	AutoReply.Cancel(conversation.ID)
+
+	if eventbus.GlobalEventBus != nil {
+		if err := eventbus.GlobalEventBus.Publish(eventbus.NewConversationClosedEvent(conversation.ID, closedBy)); err != nil {
+			logger.App.Error("发布对话关闭事件失败", zap.Uint("conversationID", conversation.ID), zap.Error(err))
+		}
+	}
+
+	if _, err := ChatPublic.postSystemMessage(conversation, "对话已结束", ""); err != nil {
+		logger.App.Error("发送对话关闭提示失败", zap.Uint("conversationID", conversation.ID), zap.Error(err))
+	}
+
+	// 邀请客户评价本次服务
+	go CSAT.Offer(conversation)
 
 	return nil
 }
@@ -171,20 +324,36 @@ func (s *ChatAgentService) ReopenConversation(id int, agentID uint) error {
 	}
 
 	// 检查对话是否已经打开
-	if conversation.Status == "open" {
+	if conversation.IsActive() {
 		return bizErrors.NewBusinessError("CONVERSATION_ALREADY_OPEN", "对话已经打开", nil)
 	}
 
-	// 更新对话状态为打开
+	// 更新对话状态为打开，负责客服保持不变
 	result = database.DB.Model(&conversation).Updates(map[string]interface{}{
-		"status":   "open",
-		"agent_id": agentID, // 记录重新打开对话的客服
+		"status":    "open",
+		"closed_by":   0,
+		"closed_at":   nil,
+		"reopened_at": time.Now(),
 	})
 
 	if result.Error != nil {
 		return result.Error
 	}
 
+	if eventbus.GlobalEventBus != nil {
+		if err := eventbus.GlobalEventBus.Publish(eventbus.NewConversationReopenedEvent(conversation.ID)); err != nil {
+			logger.App.Error("发布对话重新打开事件失败", zap.Uint("conversationID", conversation.ID), zap.Error(err))
+		}
+	}
+
+	if _, err := ChatPublic.postSystemMessage(&conversation, "对话已重新打开", ""); err != nil {
+		logger.App.Error("发送对话重新打开提示失败", zap.Uint("conversationID", conversation.ID), zap.Error(err))
+	}
+
+	// 同步将DooTask任务标记为未完成
+	agent, _ := Agent.FindByAuthID(agentID)
+	go TaskSync.Reopened(&conversation, agent)
+
 	return nil
 }
 
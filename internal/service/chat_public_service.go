@@ -22,8 +22,8 @@ type ChatPublicService struct{}
 
 var ChatPublic = ChatPublicService{}
 
-// CreateConversation 创建新对话
-func (s *ChatPublicService) CreateConversation(agentID, customerID uint, title, source string) (string, error) {
+// CreateConversation 创建新对话，对话创建时不指定客服，由分配处理器决定负责客服
+func (s *ChatPublicService) CreateConversation(customerID uint, title, source string) (string, error) {
 	// 生成UUID
 	uuidStr := uuid.New().String()
 	csSource := models.CustomerServiceSource{}
@@ -43,7 +43,6 @@ func (s *ChatPublicService) CreateConversation(agentID, customerID uint, title,
 	// 创建对话记录
 	conversation := models.Conversations{
 		Uuid:       uuidStr,
-		AgentID:    agentID,
 		CustomerID: customerID,
 		Title:      title,
 		Source:     csSource.Name,
@@ -64,25 +63,33 @@ func (s *ChatPublicService) CreateConversation(agentID, customerID uint, title,
 	})
 	conversation.Title = title
 
-	// 广播新对话给所有客服
-	go websocket.BroadcastToAllAgents(conversation, websocket.MessageTypeNewConversation)
-
-	// 异步发布对话创建事件到事件总线
+	// 异步发布对话创建事件到事件总线，由分配处理器决定通知哪些客服
 	if eventbus.GlobalEventBus != nil {
 		fmt.Println("发布对话创建事件")
 		conversationEvent := eventbus.NewConversationCreatedEvent(conversation.ID)
 		if err := eventbus.GlobalEventBus.Publish(conversationEvent); err != nil {
 			logger.App.Error("发布对话创建事件失败", zap.Uint("会话ID", conversation.ID), zap.Error(err))
+			// 事件未能发布时直接通知来源客服
+			go websocket.BroadcastToConversationAgents(conversation.Uuid, conversation, websocket.MessageTypeNewConversation)
 		}
 	} else {
 		fmt.Println("GlobalEventBusGlobalEventBus发布对话创建事件")
+		go websocket.BroadcastToConversationAgents(conversation.Uuid, conversation, websocket.MessageTypeNewConversation)
+	}
+
+	// 对话创建事件发布后再发送欢迎语，事件处理方先知道对话再收到消息
+	s.sendWelcomeMessage(&conversation, &csSource)
+
+	// 非工作时间创建的对话转为留言模式
+	if online, offlineMessage := Availability.CheckSource(&csSource); !online {
+		s.markOffline(&conversation, offlineMessage)
 	}
 
 	return uuidStr, nil
 }
 
-// SendMessage 发送消息
-func (s *ChatPublicService) SendMessage(conversationUUID, content, sender, msgType, metadata string) (*models.Message, error) {
+// SendMessage 以客户身份发送消息
+func (s *ChatPublicService) SendMessage(conversationUUID, content, msgType, metadata string) (*models.Message, error) {
 	// 查找对话
 	var conversation models.Conversations
 	result := database.DB.Where("uuid = ?", conversationUUID).First(&conversation)
@@ -114,7 +121,7 @@ func (s *ChatPublicService) SendMessage(conversationUUID, content, sender, msgTy
 	message := models.Message{
 		ConversationID: conversation.ID,
 		Content:        content,
-		Sender:         sender,
+		Sender:         "customer",
 		Type:           msgType,
 		Metadata:       metadata,
 		CreatedAt:      now,
@@ -133,68 +140,141 @@ func (s *ChatPublicService) SendMessage(conversationUUID, content, sender, msgTy
 		"last_message_at": now,
 	})
 
-	// 如果是客户发送的消息，需要通知客服和机器人
-	if sender == "customer" {
-		// 通过WebSocket广播消息给所有客服
-		go websocket.BroadcastToAllAgents(message, websocket.MessageTypeNewMessage)
+	if eventbus.GlobalEventBus != nil {
+		messageEvent := eventbus.NewMessageCreatedEvent(message.ID, conversation.ID, content, message.Sender, message.Type)
+		if err := eventbus.GlobalEventBus.Publish(messageEvent); err != nil {
+			logger.App.Error("发布消息创建事件失败", zap.Uint("messageID", message.ID), zap.Error(err))
+		}
+	}
+
+	// 非工作时间发送的消息转为留言模式
+	if conversation.Status == "open" {
+		if online, offlineMessage := Availability.CheckSource(&csSource); !online {
+			s.markOffline(&conversation, offlineMessage)
+		}
+	}
 
-		// 发送消息到机器人
-		// go s.sendToBot(content, dialogID, conversation.Title)
-		go s.sendDooTaskMessage(&message, &conversation, &csSource)
+	// 登记延迟自动回复，客服在此期间回复则取消
+	if conversation.Status == "open" {
+		AutoReply.Schedule(&conversation, &csSource)
 	}
 
+	// 通过WebSocket推送消息给对话相关客服
+	go websocket.BroadcastToConversationAgents(conversation.Uuid, message, websocket.MessageTypeNewMessage)
+
+	// 发送消息到机器人
+	// go s.sendToBot(content, dialogID, conversation.Title)
+	go s.sendDooTaskMessage(&message, &conversation, &csSource)
+
 	return &message, nil
 }
 
-// GetMessages 获取对话消息列表
-func (s *ChatPublicService) GetMessages(conversationUUID string, page, pageSize int) ([]models.Message, int64, error) {
+// GetMessages 获取对话消息列表，支持 before_id / after_id 游标
+func (s *ChatPublicService) GetMessages(conversationUUID string, query models.MessageHistoryQuery) ([]models.Message, bool, error) {
 	// 查找对话
 	var conversation models.Conversations
 	result := database.DB.Where("uuid = ?", conversationUUID).First(&conversation)
 	if result.Error != nil {
-		return nil, 0, bizErrors.ErrConversationNotFound
+		return nil, false, bizErrors.ErrConversationNotFound
 	}
 
-	// 检查对话状态 - 对于获取消息，即使关闭的对话也应该能查看历史消息
-	// 但可以在这里添加其他业务逻辑
-	if conversation.Status == "closed" {
-		// 可以选择返回错误或者允许查看历史消息
-		// return nil, 0, errors.New("对话已关闭")
-		// 这里我们允许查看已关闭对话的历史消息
+	// 已关闭的对话同样允许查看历史消息，内部备注不返回给客户
+	return MessageHistory.ListPublic(conversation.ID, query)
+}
+
+// GetConversation 通过UUID获取对话
+func (s *ChatPublicService) GetConversation(uuid string) (*models.Conversations, error) {
+	var conversation models.Conversations
+	result := database.DB.Where("uuid = ?", uuid).First(&conversation)
+	if result.Error != nil {
+		return nil, bizErrors.ErrConversationNotFound
 	}
 
-	// 计算总数
-	var total int64
-	database.DB.Model(&models.Message{}).Where("conversation_id = ?", conversation.ID).Count(&total)
+	return &conversation, nil
+}
 
-	// 查询最新的消息
-	var messages []models.Message
-	result = database.DB.Where("conversation_id = ?", conversation.ID).
-		Order("created_at DESC"). // 降序排列，获取最新消息
-		Limit(pageSize).          // 限制返回数量
-		Find(&messages)
+// markOffline 将对话标记为离线留言状态，并发送离线提示语
+func (s *ChatPublicService) markOffline(conversation *models.Conversations, offlineMessage string) {
+	if err := database.DB.Model(conversation).Update("status", "offline").Error; err != nil {
+		logger.App.Error("标记离线对话失败", zap.Uint("conversationID", conversation.ID), zap.Error(err))
+		return
+	}
+	conversation.Status = "offline"
 
-	// 反转切片，使消息按时间正序排列
-	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
-		messages[i], messages[j] = messages[j], messages[i]
+	if offlineMessage == "" {
+		return
 	}
+	if _, err := s.postSystemMessage(conversation, offlineMessage, ""); err != nil {
+		logger.App.Error("发送离线提示语失败", zap.Uint("conversationID", conversation.ID), zap.Error(err))
+	}
+}
 
-	if result.Error != nil {
-		return nil, 0, result.Error
+// sendWelcomeMessage 发送欢迎语，优先级：来源配置 > 系统配置 > 欢迎语配置
+func (s *ChatPublicService) sendWelcomeMessage(conversation *models.Conversations, source *models.CustomerServiceSource) {
+	var welcomeText string
+	var metadata string
+
+	// 展示延迟交由客户端处理，客户端连接后拉取历史消息即可获得欢迎语
+	welcomeConfig, err := models.LoadConfig[models.WelcomeConfig](database.DB, models.CSConfigKeyWelcome)
+	if err == nil && welcomeConfig.ShowDelaySec > 0 {
+		metadata = fmt.Sprintf(`{"show_delay_sec":%d}`, welcomeConfig.ShowDelaySec)
 	}
 
-	return messages, total, nil
+	if sourceConfig, err := source.GetConfig(); err == nil && sourceConfig.WelcomeMessage != "" {
+		welcomeText = sourceConfig.WelcomeMessage
+	} else if systemConfig, err := models.LoadConfig[models.CustomerServiceConfigData](database.DB, models.CSConfigKeySystem); err == nil && systemConfig.WelcomeMessage != "" {
+		welcomeText = systemConfig.WelcomeMessage
+	} else if welcomeConfig != nil && welcomeConfig.Enabled {
+		welcomeText = welcomeConfig.Text
+	}
+
+	if welcomeText == "" {
+		return
+	}
+	if _, err := s.postSystemMessage(conversation, welcomeText, metadata); err != nil {
+		logger.App.Error("发送欢迎语失败", zap.Uint("conversationID", conversation.ID), zap.Error(err))
+	}
 }
 
-// GetConversation 通过UUID获取对话
-func (s *ChatPublicService) GetConversation(uuid string) (*models.Conversations, error) {
-	var conversation models.Conversations
-	result := database.DB.Where("uuid = ?", uuid).First(&conversation)
-	if result.Error != nil {
-		return nil, bizErrors.ErrConversationNotFound
+// postSystemMessage 以系统身份在对话中发送消息并推送给客户端
+func (s *ChatPublicService) postSystemMessage(conversation *models.Conversations, content, metadata string) (*models.Message, error) {
+	now := time.Now()
+	message := models.Message{
+		ConversationID: conversation.ID,
+		Content:        content,
+		Sender:         "system",
+		Type:           "system",
+		Metadata:       metadata,
+		CreatedAt:      now,
+	}
+	if err := database.DB.Create(&message).Error; err != nil {
+		return nil, err
 	}
 
-	return &conversation, nil
+	database.DB.Model(conversation).Updates(map[string]interface{}{
+		"updated_at":      now,
+		"last_message":    content,
+		"last_message_at": now,
+	})
+
+	data := map[string]interface{}{
+		"id":         message.ID,
+		"content":    message.Content,
+		"sender":     message.Sender,
+		"type":       message.Type,
+		"metadata":   message.Metadata,
+		"created_at": message.CreatedAt,
+	}
+	go websocket.BroadcastMessage(conversation.Uuid, data, websocket.MessageTypeNewMessage)
+
+	if eventbus.GlobalEventBus != nil {
+		messageEvent := eventbus.NewMessageCreatedEvent(message.ID, conversation.ID, content, message.Sender, message.Type)
+		if err := eventbus.GlobalEventBus.Publish(messageEvent); err != nil {
+			logger.App.Error("发布消息创建事件失败", zap.Uint("messageID", message.ID), zap.Error(err))
+		}
+	}
+
+	return &message, nil
 }
 
 func (s *ChatPublicService) sendDooTaskMessage(message *models.Message, conversation *models.Conversations, source *models.CustomerServiceSource) {
@@ -203,16 +283,18 @@ func (s *ChatPublicService) sendDooTaskMessage(message *models.Message, conversa
 		return
 	}
 	// if customerServiceConfigData
+	// 附件消息附带下载地址，便于在DooTask中查看
+	text := Attachment.DooTaskText(message)
 	hasTask := false
 	if conversation.DooTaskDialogID != 0 && conversation.DooTaskTaskID != 0 {
 		hasTask = true
-		s.sendToBot(customerServiceConfigData, message.Content, fmt.Sprintf("%d", conversation.DooTaskDialogID))
+		s.sendToBot(customerServiceConfigData, text, fmt.Sprintf("%d", conversation.DooTaskDialogID))
 	}
 	content := ""
 	if !hasTask {
-		content = fmt.Sprintf("【%s】\n有一条新消息:\n%s", conversation.Title, message.Content)
+		content = fmt.Sprintf("【%s】\n有一条新消息:\n%s", conversation.Title, text)
 	} else {
-		content = fmt.Sprintf("[任务ID:%d][%s]\n有一条新消息:\n%s", conversation.DooTaskTaskID, conversation.Title, message.Content)
+		content = fmt.Sprintf("[任务ID:%d][%s]\n有一条新消息:\n%s", conversation.DooTaskTaskID, conversation.Title, text)
 	}
 	s.sendToBot(customerServiceConfigData, content, fmt.Sprintf("%d", *source.DialogID))
 }
@@ -0,0 +1,146 @@
+package service
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"support-plugin/internal/i18n"
+	"support-plugin/internal/models"
+	"support-plugin/internal/pkg/database"
+	bizErrors "support-plugin/internal/pkg/errors"
+	"support-plugin/internal/pkg/logger"
+	"support-plugin/internal/pkg/websocket"
+	"support-plugin/internal/utils/common"
+)
+
+// 对话访问令牌默认有效期（小时）
+const defaultConversationTokenHours = 720
+
+type ConversationTokenService struct{}
+
+var ConversationToken = &ConversationTokenService{}
+
+// Issue 为对话签发访问令牌，格式为 "<版本>.<过期时间戳>.<签名>"
+func (s *ConversationTokenService) Issue(conversation *models.Conversations) (string, error) {
+	secret, err := Customer.secret()
+	if err != nil {
+		return "", err
+	}
+	hours := defaultConversationTokenHours
+	if authConfig, err := models.LoadConfig[models.AuthConfig](database.DB, models.CSConfigKeyAuth); err == nil && authConfig.ConversationTokenHours > 0 {
+		hours = authConfig.ConversationTokenHours
+	}
+	return s.issue(secret, conversation, time.Now().Add(time.Duration(hours)*time.Hour)), nil
+}
+
+// Verify 校验对话访问令牌，通过后返回对应的对话
+func (s *ConversationTokenService) Verify(conversationUUID, token string) (*models.Conversations, error) {
+	if len(strings.Split(token, ".")) != 3 {
+		return nil, &i18n.ErrorInfo{
+			Code:    i18n.ErrCodeTokenInvalid,
+			Message: "conversation token is invalid",
+		}
+	}
+	secret, err := Customer.secret()
+	if err != nil {
+		return nil, err
+	}
+
+	var conversation models.Conversations
+	if err := database.DB.Where("uuid = ?", conversationUUID).First(&conversation).Error; err != nil {
+		return nil, &i18n.ErrorInfo{
+			Code:    i18n.ErrCodeConversationNotFound,
+			Message: "conversation not found",
+		}
+	}
+	if err := s.check(secret, &conversation, token, time.Now()); err != nil {
+		return nil, err
+	}
+	return &conversation, nil
+}
+
+// Renew 使用客户端持有的令牌续签对话访问令牌
+// 旧令牌须签名有效、未过期且版本与对话当前版本一致，已吊销的令牌不能换取新令牌
+func (s *ConversationTokenService) Renew(conversation *models.Conversations, token string) (string, error) {
+	secret, err := Customer.secret()
+	if err != nil {
+		return "", err
+	}
+	if err := s.check(secret, conversation, token, time.Now()); err != nil {
+		return "", err
+	}
+	return s.Issue(conversation)
+}
+
+// issue 按指定过期时间签发令牌
+func (s *ConversationTokenService) issue(secret string, conversation *models.Conversations, expireAt time.Time) string {
+	payload := fmt.Sprintf("%d.%d", conversation.TokenVersion, expireAt.Unix())
+	return payload + "." + common.HmacSha256(secret, s.signData(conversation.Uuid, payload))
+}
+
+// check 校验令牌的签名、有效期以及版本
+func (s *ConversationTokenService) check(secret string, conversation *models.Conversations, token string, now time.Time) error {
+	parts := strings.Split(token, ".")
+	if token == "" || len(parts) != 3 {
+		return &i18n.ErrorInfo{
+			Code:    i18n.ErrCodeTokenInvalid,
+			Message: "conversation token is invalid",
+		}
+	}
+	version, errVersion := strconv.Atoi(parts[0])
+	expireAt, errExpire := strconv.ParseInt(parts[1], 10, 64)
+	if errVersion != nil || errExpire != nil {
+		return &i18n.ErrorInfo{
+			Code:    i18n.ErrCodeTokenInvalid,
+			Message: "conversation token is malformed",
+		}
+	}
+	payload := parts[0] + "." + parts[1]
+	if !common.HmacSha256Verify(secret, s.signData(conversation.Uuid, payload), parts[2]) {
+		return &i18n.ErrorInfo{
+			Code:    i18n.ErrCodeTokenInvalid,
+			Message: "conversation token does not match",
+		}
+	}
+	// 令牌版本落后说明已被吊销
+	if version != conversation.TokenVersion {
+		return &i18n.ErrorInfo{
+			Code:    i18n.ErrCodeTokenInvalid,
+			Message: "conversation token has been revoked",
+		}
+	}
+	if now.Unix() > expireAt {
+		return &i18n.ErrorInfo{
+			Code:    i18n.ErrCodeTokenExpired,
+			Message: "conversation token has expired",
+		}
+	}
+	return nil
+}
+
+// Revoke 吊销对话已签发的所有访问令牌，并断开已建立的客户端连接
+func (s *ConversationTokenService) Revoke(conversationID uint) error {
+	var conversation models.Conversations
+	if err := database.DB.First(&conversation, conversationID).Error; err != nil {
+		return bizErrors.ErrConversationNotFound
+	}
+	err := database.DB.Model(&conversation).
+		UpdateColumn("token_version", gorm.Expr("token_version + 1")).Error
+	if err != nil {
+		return err
+	}
+
+	websocket.WebSocketManager.DisconnectCustomers(conversation.Uuid)
+	logger.App.Info("对话访问令牌已吊销", zap.Uint("conversationID", conversationID))
+	return nil
+}
+
+// signData 令牌签名内容，绑定对话UUID防止令牌用于其他对话
+func (s *ConversationTokenService) signData(conversationUUID, payload string) string {
+	return "conv:" + conversationUUID + ":" + payload
+}
@@ -0,0 +1,56 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"support-plugin/internal/i18n"
+	"support-plugin/internal/models"
+)
+
+func TestConversationTokenCheck(t *testing.T) {
+	const secret = "conversation-secret"
+	now := testTime("2026-10-19 10:00")
+	conversation := &models.Conversations{Uuid: "8c1d2e3f-4a5b-4c6d-8e7f-9a0b1c2d3e4f", TokenVersion: 2}
+	revoked := &models.Conversations{Uuid: conversation.Uuid, TokenVersion: 3}
+	other := &models.Conversations{Uuid: "0f9e8d7c-6b5a-4d3c-8b2a-1f0e9d8c7b6a", TokenVersion: 2}
+
+	valid := ConversationToken.issue(secret, conversation, now.Add(time.Hour))
+	stale := ConversationToken.issue(secret, &models.Conversations{Uuid: conversation.Uuid, TokenVersion: 1}, now.Add(time.Hour))
+	parts := strings.Split(valid, ".")
+	bumped := "3." + parts[1] + "." + parts[2]
+
+	tests := []struct {
+		name         string
+		conversation *models.Conversations
+		token        string
+		now          time.Time
+		wantCode     i18n.ErrorCode
+	}{
+		{"有效令牌", conversation, valid, now, ""},
+		{"过期令牌", conversation, valid, now.Add(time.Hour + time.Second), i18n.ErrCodeTokenExpired},
+		{"吊销后旧令牌失效", revoked, valid, now, i18n.ErrCodeTokenInvalid},
+		{"版本落后的令牌", conversation, stale, now, i18n.ErrCodeTokenInvalid},
+		{"篡改版本号", revoked, bumped, now, i18n.ErrCodeTokenInvalid},
+		{"用于其他对话", other, valid, now, i18n.ErrCodeTokenInvalid},
+		{"格式错误", conversation, parts[0] + "." + parts[2], now, i18n.ErrCodeTokenInvalid},
+		{"空令牌", conversation, "", now, i18n.ErrCodeTokenInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ConversationToken.check(secret, tt.conversation, tt.token, tt.now)
+			if tt.wantCode == "" {
+				if err != nil {
+					t.Errorf("check() error = %v", err)
+				}
+				return
+			}
+			var info *i18n.ErrorInfo
+			if !errors.As(err, &info) || info.Code != tt.wantCode {
+				t.Errorf("check() error = %v, want code %s", err, tt.wantCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,195 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go.uber.org/zap"
+
+	"support-plugin/internal/models"
+	"support-plugin/internal/pkg/database"
+	bizErrors "support-plugin/internal/pkg/errors"
+	"support-plugin/internal/pkg/eventbus"
+	"support-plugin/internal/pkg/logger"
+	"support-plugin/internal/pkg/websocket"
+)
+
+// 满意度评价内容的最大长度（字符）
+const csatCommentMaxLength = 1000
+
+// 默认的满意度评价问题
+const csatDefaultQuestion = "请对本次服务进行评价"
+
+type CSATService struct{}
+
+var CSAT = &CSATService{}
+
+// Offer 对话关闭后向客户推送满意度评价邀请，未启用或已评价时不推送
+func (s *CSATService) Offer(conversation *models.Conversations) {
+	survey := s.Pending(conversation)
+	if survey == nil {
+		return
+	}
+	websocket.BroadcastMessage(conversation.Uuid, survey, websocket.MessageTypeCSATSurvey)
+}
+
+// Pending 获取对话待提交的满意度评价，对话未关闭、未启用或本次关闭后已评价时返回nil
+func (s *CSATService) Pending(conversation *models.Conversations) *models.CSATSurvey {
+	if conversation.Status != "closed" {
+		return nil
+	}
+	settings := s.resolve(conversation.SourceKey)
+	if !settings.Enabled {
+		return nil
+	}
+	if _, rated := s.rating(conversation); rated {
+		return nil
+	}
+	return &models.CSATSurvey{
+		ConversationUUID: conversation.Uuid,
+		Type:             settings.Type,
+		Question:         settings.Question,
+		AllowComment:     settings.AllowComment,
+	}
+}
+
+// Submit 客户提交满意度评价，评价关联对话当前的负责客服
+// 每个对话只保存一条评价，重新打开后再次关闭的对话可以重新评价，新的评价替换之前的评价
+func (s *CSATService) Submit(conversationUUID string, score int, comment string) (*models.ConversationRating, error) {
+	var conversation models.Conversations
+	if err := database.DB.Where("uuid = ?", conversationUUID).First(&conversation).Error; err != nil {
+		return nil, bizErrors.ErrConversationNotFound
+	}
+
+	survey := s.Pending(&conversation)
+	if survey == nil {
+		if _, rated := s.rating(&conversation); rated {
+			return nil, bizErrors.NewBusinessError("CSAT_ALREADY_SUBMITTED", "该对话已评价", nil)
+		}
+		return nil, bizErrors.NewBusinessError("CSAT_NOT_AVAILABLE", "对话未关闭或未启用满意度评价", nil)
+	}
+
+	switch survey.Type {
+	case models.CSATTypeThumbs:
+		if score != 0 && score != 1 {
+			return nil, bizErrors.NewBusinessError("INVALID_CSAT_SCORE", "评分无效", nil)
+		}
+	default:
+		if score < 1 || score > 5 {
+			return nil, bizErrors.NewBusinessError("INVALID_CSAT_SCORE", "评分无效", nil)
+		}
+	}
+
+	comment = strings.TrimSpace(comment)
+	if !survey.AllowComment {
+		comment = ""
+	} else if utf8.RuneCountInString(comment) > csatCommentMaxLength {
+		return nil, bizErrors.NewBusinessError("CSAT_COMMENT_TOO_LONG", fmt.Sprintf("评价内容不能超过%d个字符", csatCommentMaxLength), nil)
+	}
+
+	rating := models.ConversationRating{
+		ConversationID: conversation.ID,
+		AgentID:        conversation.AgentID,
+		SourceKey:      conversation.SourceKey,
+		Type:           survey.Type,
+		Score:          score,
+		Comment:        comment,
+		CreatedAt:      time.Now(),
+	}
+	if previous, _ := s.rating(&conversation); previous != nil {
+		// 替换之前关闭时的评价，条件更新保证并发提交时只保存一次
+		rating.ID = previous.ID
+		result := database.DB.Model(&models.ConversationRating{}).
+			Where("id = ? AND created_at < ?", previous.ID, *conversation.ClosedAt).
+			Updates(map[string]interface{}{
+				"agent_id":   rating.AgentID,
+				"source_key": rating.SourceKey,
+				"type":       rating.Type,
+				"score":      rating.Score,
+				"comment":    rating.Comment,
+				"created_at": rating.CreatedAt,
+			})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return nil, bizErrors.NewBusinessError("CSAT_ALREADY_SUBMITTED", "该对话已评价", nil)
+		}
+	} else if err := database.DB.Create(&rating).Error; err != nil {
+		// 唯一索引保证并发提交时只保存一次
+		return nil, bizErrors.NewBusinessError("CSAT_ALREADY_SUBMITTED", "该对话已评价", nil)
+	}
+	logger.App.Info("客户已提交满意度评价",
+		zap.Uint("conversationID", conversation.ID),
+		zap.Int("score", score))
+
+	if eventbus.GlobalEventBus != nil {
+		if err := eventbus.GlobalEventBus.Publish(eventbus.NewRatingSubmittedEvent(rating.ID, conversation.ID)); err != nil {
+			logger.App.Error("发布满意度评价事件失败", zap.Uint("ratingID", rating.ID), zap.Error(err))
+		}
+	}
+
+	// 同步到DooTask任务对话
+	if conversation.DooTaskDialogID > 0 && conversation.DooTaskTaskID > 0 {
+		go func() {
+			customerServiceConfigData, err := models.LoadConfig[models.CustomerServiceConfigData](database.DB, models.CSConfigKeySystem)
+			if err != nil {
+				return
+			}
+			ChatPublic.sendToBot(customerServiceConfigData, s.DooTaskText(&rating), fmt.Sprintf("%d", conversation.DooTaskDialogID))
+		}()
+	}
+
+	return &rating, nil
+}
+
+// rating 获取对话已保存的评价，以及该评价是否提交于对话最近一次关闭之后
+func (s *CSATService) rating(conversation *models.Conversations) (*models.ConversationRating, bool) {
+	var rating models.ConversationRating
+	if err := database.DB.Where("conversation_id = ?", conversation.ID).First(&rating).Error; err != nil {
+		return nil, false
+	}
+	if conversation.ClosedAt == nil {
+		return &rating, true
+	}
+	return &rating, !rating.CreatedAt.Before(*conversation.ClosedAt)
+}
+
+// DooTaskText 满意度评价在DooTask任务对话中的展示文本
+func (s *CSATService) DooTaskText(rating *models.ConversationRating) string {
+	var result string
+	if rating.Type == models.CSATTypeThumbs {
+		result = "👎 不满意"
+		if rating.Score > 0 {
+			result = "👍 满意"
+		}
+	} else {
+		result = fmt.Sprintf("%s%s（%d/5）", strings.Repeat("★", rating.Score), strings.Repeat("☆", 5-rating.Score), rating.Score)
+	}
+	text := fmt.Sprintf("[满意度评价]\n%s", result)
+	if rating.Comment != "" {
+		text += "\n" + rating.Comment
+	}
+	return text
+}
+
+// resolve 获取生效的满意度评价设置，来源启用时优先使用来源配置
+func (s *CSATService) resolve(sourceKey string) models.CSATData {
+	var settings models.CSATData
+	if systemConfig, err := models.LoadConfig[models.CustomerServiceConfigData](database.DB, models.CSConfigKeySystem); err == nil {
+		settings = systemConfig.CSAT
+	}
+	var source models.CustomerServiceSource
+	if err := database.DB.Where("source_key = ?", sourceKey).First(&source).Error; err == nil {
+		if sourceConfig, err := source.GetConfig(); err == nil && sourceConfig.CSAT.Enabled {
+			settings = sourceConfig.CSAT
+		}
+	}
+
+	if settings.Type != models.CSATTypeThumbs {
+		settings.Type = models.CSATTypeStars
+	}
+	if settings.Question == "" {
+		settings.Question = csatDefaultQuestion
+	}
+	return settings
+}
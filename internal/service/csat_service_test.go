@@ -0,0 +1,48 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"support-plugin/internal/models"
+	bizErrors "support-plugin/internal/pkg/errors"
+)
+
+func TestCSATSubmitPerCloseCycle(t *testing.T) {
+	db := useTestDB(t, &models.CSConfig{}, &models.CustomerServiceSource{}, &models.Conversations{}, &models.ConversationRating{})
+	db.Create(&models.CSConfig{ConfigKey: models.CSConfigKeySystem, ConfigJSON: `{"csat":{"enabled":true,"type":"stars"}}`})
+
+	closedAt := time.Now().Add(-time.Hour)
+	conversation := models.Conversations{ID: 1, Uuid: "conv-1", AgentID: 10, Status: "closed", ClosedAt: &closedAt}
+	db.Create(&conversation)
+
+	first, err := CSAT.Submit("conv-1", 5, "")
+	if err != nil {
+		t.Fatalf("Submit() error = %v", err)
+	}
+	if _, err := CSAT.Submit("conv-1", 4, ""); err == nil {
+		t.Fatal("Submit() 同一次关闭重复评价应失败")
+	} else if businessErr, ok := err.(*bizErrors.BusinessError); !ok || businessErr.Code != "CSAT_ALREADY_SUBMITTED" {
+		t.Fatalf("Submit() error = %v, want CSAT_ALREADY_SUBMITTED", err)
+	}
+
+	// 重新打开后由其他客服再次关闭，可以重新评价并替换之前的评价
+	closedAt = time.Now().Add(time.Second)
+	db.Model(&conversation).Updates(map[string]interface{}{"agent_id": 11, "closed_at": closedAt})
+	if CSAT.Pending(&models.Conversations{ID: 1, Status: "closed", ClosedAt: &closedAt}) == nil {
+		t.Fatal("Pending() 再次关闭后应可评价")
+	}
+	second, err := CSAT.Submit("conv-1", 3, "")
+	if err != nil {
+		t.Fatalf("Submit() 再次关闭后 error = %v", err)
+	}
+	if second.ID != first.ID {
+		t.Errorf("Submit() rating ID = %d, want %d", second.ID, first.ID)
+	}
+
+	var ratings []models.ConversationRating
+	db.Find(&ratings)
+	if len(ratings) != 1 || ratings[0].Score != 3 || ratings[0].AgentID != 11 {
+		t.Errorf("评价记录 = %+v, want 一条3分且归属客服11的评价", ratings)
+	}
+}
@@ -0,0 +1,315 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"support-plugin/internal/models"
+	"support-plugin/internal/pkg/database"
+	bizErrors "support-plugin/internal/pkg/errors"
+	"support-plugin/internal/pkg/logger"
+)
+
+// 文本类型自定义字段的最大长度
+const customFieldTextMaxLength = 1000
+
+var customFieldKeyPattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,63}$`)
+
+var errCustomFieldNotFound = bizErrors.NewBusinessError("CUSTOM_FIELD_NOT_FOUND", "自定义字段不存在", nil)
+
+// CustomFieldService 对话自定义字段的管理与取值
+type CustomFieldService struct{}
+
+var CustomField = &CustomFieldService{}
+
+// List 获取全部自定义字段
+func (s *CustomFieldService) List() ([]models.CustomField, error) {
+	fields := []models.CustomField{}
+	err := database.DB.Order("sort ASC, id ASC").Find(&fields).Error
+	return fields, err
+}
+
+// Get 根据ID获取自定义字段
+func (s *CustomFieldService) Get(id uint) (*models.CustomField, error) {
+	var field models.CustomField
+	if err := database.DB.First(&field, id).Error; err != nil {
+		return nil, errCustomFieldNotFound
+	}
+	return &field, nil
+}
+
+// Create 创建自定义字段
+func (s *CustomFieldService) Create(req models.CustomFieldRequest) (*models.CustomField, error) {
+	field := models.CustomField{}
+	if err := s.apply(&field, req); err != nil {
+		return nil, err
+	}
+	if err := database.DB.Create(&field).Error; err != nil {
+		return nil, err
+	}
+	logger.App.Info("自定义字段已创建", zap.Uint("id", field.ID), zap.String("key", field.Key))
+	return &field, nil
+}
+
+// Update 更新自定义字段，已有取值的字段不能修改类型
+func (s *CustomFieldService) Update(id uint, req models.CustomFieldRequest) (*models.CustomField, error) {
+	field, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if req.Type != field.Type {
+		var count int64
+		database.DB.Model(&models.ConversationFieldValue{}).Where("field_id = ?", field.ID).Count(&count)
+		if count > 0 {
+			return nil, bizErrors.NewBusinessError("CUSTOM_FIELD_IN_USE", "字段已有取值，不能修改类型", nil)
+		}
+	}
+	if err := s.apply(field, req); err != nil {
+		return nil, err
+	}
+	if err := database.DB.Save(field).Error; err != nil {
+		return nil, err
+	}
+	return field, nil
+}
+
+// Delete 删除自定义字段及其在对话上的取值
+func (s *CustomFieldService) Delete(id uint) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Delete(&models.CustomField{}, id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errCustomFieldNotFound
+		}
+		return tx.Where("field_id = ?", id).Delete(&models.ConversationFieldValue{}).Error
+	})
+}
+
+// SetValues 设置对话的自定义字段取值，只修改提交的字段，全部校验通过后才写入
+func (s *CustomFieldService) SetValues(conversationID uint, values map[string]interface{}, agentID uint) (map[string]interface{}, error) {
+	var conversation models.Conversations
+	if err := database.DB.First(&conversation, conversationID).Error; err != nil {
+		return nil, bizErrors.ErrConversationNotFound
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	var fields []models.CustomField
+	if err := database.DB.Where("field_key IN ?", keys).Find(&fields).Error; err != nil {
+		return nil, err
+	}
+	byKey := make(map[string]*models.CustomField, len(fields))
+	for i := range fields {
+		byKey[fields[i].Key] = &fields[i]
+	}
+
+	normalized := make(map[uint]string, len(values))
+	for key, raw := range values {
+		field, ok := byKey[key]
+		if !ok {
+			return nil, bizErrors.NewBusinessError("CUSTOM_FIELD_NOT_FOUND", fmt.Sprintf("自定义字段 %s 不存在", key), nil)
+		}
+		value, err := s.normalize(field, raw)
+		if err != nil {
+			return nil, err
+		}
+		normalized[field.ID] = value
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		for fieldID, value := range normalized {
+			if value == "" {
+				if err := tx.Where("conversation_id = ? AND field_id = ?", conversationID, fieldID).Delete(&models.ConversationFieldValue{}).Error; err != nil {
+					return err
+				}
+				continue
+			}
+			err := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "conversation_id"}, {Name: "field_id"}},
+				DoUpdates: clause.AssignmentColumns([]string{"value", "updated_by", "updated_at"}),
+			}).Create(&models.ConversationFieldValue{
+				ConversationID: conversationID,
+				FieldID:        fieldID,
+				Value:          value,
+				UpdatedBy:      agentID,
+			}).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	valueMap, err := s.load(conversationID)
+	if err != nil {
+		return nil, err
+	}
+	if valueMap[conversationID] == nil {
+		return map[string]interface{}{}, nil
+	}
+	return valueMap[conversationID], nil
+}
+
+// FillValues 填充对话列表的自定义字段取值
+func (s *CustomFieldService) FillValues(conversations []models.Conversations) error {
+	ids := make([]uint, len(conversations))
+	for i := range conversations {
+		ids[i] = conversations[i].ID
+	}
+	valueMap, err := s.load(ids...)
+	if err != nil {
+		return err
+	}
+	for i := range conversations {
+		conversations[i].Fields = valueMap[conversations[i].ID]
+	}
+	return nil
+}
+
+// load 按对话ID加载自定义字段取值，数字类型转为数值
+func (s *CustomFieldService) load(conversationIDs ...uint) (map[uint]map[string]interface{}, error) {
+	result := make(map[uint]map[string]interface{}, len(conversationIDs))
+	if len(conversationIDs) == 0 {
+		return result, nil
+	}
+	var rows []struct {
+		ConversationID uint
+		FieldKey       string
+		Type           string
+		Value          string
+	}
+	err := database.DB.Table("cs_conversation_field_values AS v").
+		Select("v.conversation_id, f.field_key, f.type, v.value").
+		Joins("JOIN cs_custom_fields AS f ON f.id = v.field_id").
+		Where("v.conversation_id IN ?", conversationIDs).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		if result[row.ConversationID] == nil {
+			result[row.ConversationID] = map[string]interface{}{}
+		}
+		var value interface{} = row.Value
+		if row.Type == models.CustomFieldTypeNumber {
+			if number, err := strconv.ParseFloat(row.Value, 64); err == nil {
+				value = number
+			}
+		}
+		result[row.ConversationID][row.FieldKey] = value
+	}
+	return result, nil
+}
+
+// normalize 按字段类型校验取值并转为保存的字符串，返回空字符串表示清除取值
+func (s *CustomFieldService) normalize(field *models.CustomField, raw interface{}) (string, error) {
+	invalid := bizErrors.NewBusinessError("INVALID_FIELD_VALUE", fmt.Sprintf("字段 %s 的取值无效", field.Name), nil)
+
+	var text string
+	switch value := raw.(type) {
+	case nil:
+	case string:
+		text = strings.TrimSpace(value)
+	case float64:
+		if field.Type != models.CustomFieldTypeNumber {
+			return "", invalid
+		}
+		text = strconv.FormatFloat(value, 'f', -1, 64)
+	default:
+		return "", invalid
+	}
+	if text == "" {
+		if field.Required {
+			return "", bizErrors.NewBusinessError("FIELD_REQUIRED", fmt.Sprintf("字段 %s 为必填项", field.Name), nil)
+		}
+		return "", nil
+	}
+
+	switch field.Type {
+	case models.CustomFieldTypeText:
+		if utf8.RuneCountInString(text) > customFieldTextMaxLength {
+			return "", invalid
+		}
+	case models.CustomFieldTypeNumber:
+		number, err := strconv.ParseFloat(text, 64)
+		if err != nil || math.IsNaN(number) || math.IsInf(number, 0) {
+			return "", invalid
+		}
+		text = strconv.FormatFloat(number, 'f', -1, 64)
+	case models.CustomFieldTypeDate:
+		date, err := time.Parse(models.CustomFieldDateLayout, text)
+		if err != nil {
+			return "", invalid
+		}
+		text = date.Format(models.CustomFieldDateLayout)
+	case models.CustomFieldTypeSelect:
+		for _, option := range field.Options {
+			if option == text {
+				return text, nil
+			}
+		}
+		return "", invalid
+	}
+	return text, nil
+}
+
+// apply 校验请求并写入自定义字段，单选字段至少需要一个选项
+func (s *CustomFieldService) apply(field *models.CustomField, req models.CustomFieldRequest) error {
+	key := strings.TrimSpace(req.Key)
+	if !customFieldKeyPattern.MatchString(key) {
+		return bizErrors.NewBusinessError("INVALID_FIELD_KEY", "字段标识须以小写字母开头，只能包含小写字母、数字和下划线，且不超过64个字符", nil)
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" || utf8.RuneCountInString(name) > 64 {
+		return bizErrors.NewBusinessError("INVALID_FIELD_NAME", "字段名称不能为空，且不超过64个字符", nil)
+	}
+
+	var options []string
+	switch req.Type {
+	case models.CustomFieldTypeText, models.CustomFieldTypeNumber, models.CustomFieldTypeDate:
+	case models.CustomFieldTypeSelect:
+		seen := make(map[string]bool, len(req.Options))
+		for _, option := range req.Options {
+			option = strings.TrimSpace(option)
+			if option != "" && !seen[option] {
+				seen[option] = true
+				options = append(options, option)
+			}
+		}
+		if len(options) == 0 {
+			return bizErrors.NewBusinessError("INVALID_FIELD_OPTIONS", "单选字段至少需要一个选项", nil)
+		}
+	default:
+		return bizErrors.NewBusinessError("INVALID_FIELD_TYPE", "字段类型应为 text、select、number 或 date", nil)
+	}
+
+	var exists int64
+	database.DB.Model(&models.CustomField{}).Where("field_key = ? AND id <> ?", key, field.ID).Count(&exists)
+	if exists > 0 {
+		return bizErrors.NewBusinessError("CUSTOM_FIELD_EXISTS", "已存在相同标识的自定义字段", nil)
+	}
+
+	field.Key = key
+	field.Name = name
+	field.Type = req.Type
+	field.Options = options
+	field.Required = req.Required
+	field.Sort = req.Sort
+	return nil
+}
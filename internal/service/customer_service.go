@@ -0,0 +1,312 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"support-plugin/internal/i18n"
+	"support-plugin/internal/models"
+	"support-plugin/internal/pkg/database"
+	"support-plugin/internal/pkg/logger"
+	"support-plugin/internal/utils/common"
+)
+
+// CustomerProfile 创建或更新客户时使用的资料
+type CustomerProfile struct {
+	Name         string
+	Email        string
+	Phone        string
+	IP           string
+	UserAgent    string
+	CustomFields map[string]interface{}
+}
+
+// CustomerIdentity 宿主站点传入的已签名用户身份
+type CustomerIdentity struct {
+	UserID   string
+	UserHash string
+}
+
+// 访客令牌默认有效期（小时），客户每次发起对话时续期
+const defaultVisitorTokenHours = 720
+
+type CustomerService struct{}
+
+var Customer = &CustomerService{}
+
+// Identify 识别发起对话的客户
+// 携带用户身份时校验签名并绑定到对应客户；否则按访客令牌查找回访客户，令牌无效或为空时创建新客户
+// 返回客户信息和（重新）签发的访客令牌
+func (s *CustomerService) Identify(sourceKey, visitorToken string, identity *CustomerIdentity, profile *CustomerProfile) (*models.Customer, string, error) {
+	if sourceKey == "" {
+		sourceKey = "widget"
+	}
+	// 先确认可以签发访客令牌，避免创建客户后才失败
+	secret, err := s.secret()
+	if err != nil {
+		return nil, "", err
+	}
+	var source models.CustomerServiceSource
+	if err := database.DB.Where("source_key = ?", sourceKey).First(&source).Error; err != nil {
+		return nil, "", &i18n.ErrorInfo{
+			Code:    i18n.ErrCodeSourceNotFound,
+			Message: "来源不存在",
+		}
+	}
+
+	var customer *models.Customer
+	if identity != nil && identity.UserID != "" {
+		verified, profileSigned, err := s.findVerified(&source, identity, profile)
+		if err != nil {
+			return nil, "", err
+		}
+		customer = verified
+		// 只采用签名覆盖的姓名与邮箱，其余资料未经验证，不能覆盖已绑定客户的资料
+		profile = s.verifiedProfile(profile, profileSigned)
+	} else {
+		if !s.allowAnonymous(&source) {
+			return nil, "", &i18n.ErrorInfo{
+				Code:    i18n.ErrCodeAnonymousNotAllowed,
+				Message: "anonymous chats are not allowed",
+			}
+		}
+		// 已验证身份客户的访客令牌不能用于匿名对话
+		parsed, err := s.ParseVisitorToken(visitorToken)
+		if err != nil || parsed.ExternalID != "" {
+			parsed = &models.Customer{
+				UUID: uuid.New().String(),
+			}
+		}
+		customer = parsed
+	}
+
+	s.applyProfile(customer, profile)
+
+	if customer.ID == 0 {
+		if err := database.DB.Create(customer).Error; err != nil {
+			return nil, "", err
+		}
+		logger.App.Info("创建新客户", zap.Uint("customerID", customer.ID), zap.String("externalID", customer.ExternalID))
+	} else if err := database.DB.Save(customer).Error; err != nil {
+		return nil, "", err
+	}
+
+	return customer, s.issueVisitorToken(secret, customer.UUID, s.visitorTokenExpiry()), nil
+}
+
+// findVerified 校验用户身份签名，返回绑定的客户（不存在时返回未保存的新客户）以及签名是否覆盖姓名与邮箱
+func (s *CustomerService) findVerified(source *models.CustomerServiceSource, identity *CustomerIdentity, profile *CustomerProfile) (*models.Customer, bool, error) {
+	verified, profileSigned := s.verifyIdentity(source.Secret, identity, profile)
+	if !verified {
+		logger.App.Warn("客户身份验证失败",
+			zap.String("sourceKey", source.SourceKey),
+			zap.String("userID", identity.UserID))
+		return nil, false, &i18n.ErrorInfo{
+			Code:    i18n.ErrCodeIdentityVerifyFailed,
+			Message: "identity verification failed",
+		}
+	}
+
+	var customer models.Customer
+	err := database.DB.Where("source_key = ? AND external_id = ?", source.SourceKey, identity.UserID).First(&customer).Error
+	if err != nil {
+		customer = models.Customer{
+			UUID:       uuid.New().String(),
+			SourceKey:  source.SourceKey,
+			ExternalID: identity.UserID,
+		}
+	}
+	return &customer, profileSigned, nil
+}
+
+// verifyIdentity 校验用户身份签名，来源未配置密钥时一律失败
+// 签名为 HMAC-SHA256(来源密钥, user_id) 时只验证身份；签名内容为 identityPayload 时同时验证姓名与邮箱，第二个返回值为 true
+func (s *CustomerService) verifyIdentity(secret string, identity *CustomerIdentity, profile *CustomerProfile) (bool, bool) {
+	if secret == "" || identity == nil || identity.UserID == "" {
+		return false, false
+	}
+	userHash := strings.ToLower(identity.UserHash)
+	if profile != nil && (profile.Name != "" || profile.Email != "") &&
+		common.HmacSha256Verify(secret, s.identityPayload(identity.UserID, profile.Name, profile.Email), userHash) {
+		return true, true
+	}
+	return common.HmacSha256Verify(secret, identity.UserID, userHash), false
+}
+
+// identityPayload 同时签名姓名与邮箱时的签名内容：user_id、姓名、邮箱以换行符连接
+func (s *CustomerService) identityPayload(userID, name, email string) string {
+	return userID + "\n" + name + "\n" + email
+}
+
+// verifiedProfile 已验证身份客户可采用的资料：访问设备信息，签名覆盖时再加上姓名与邮箱
+func (s *CustomerService) verifiedProfile(profile *CustomerProfile, profileSigned bool) *CustomerProfile {
+	verified := s.deviceProfile(profile)
+	if verified != nil && profileSigned {
+		verified.Name = profile.Name
+		verified.Email = profile.Email
+	}
+	return verified
+}
+
+// deviceProfile 只保留服务端获取的访问设备信息
+func (s *CustomerService) deviceProfile(profile *CustomerProfile) *CustomerProfile {
+	if profile == nil {
+		return nil
+	}
+	return &CustomerProfile{
+		IP:        profile.IP,
+		UserAgent: profile.UserAgent,
+	}
+}
+
+// allowAnonymous 判断来源是否允许匿名对话
+func (s *CustomerService) allowAnonymous(source *models.CustomerServiceSource) bool {
+	if source.IdentityVerification {
+		return false
+	}
+	authConfig, err := models.LoadConfig[models.AuthConfig](database.DB, models.CSConfigKeyAuth)
+	if err != nil {
+		return true
+	}
+	return authConfig.AllowCustomerAnonymous
+}
+
+// IssueVisitorToken 为客户签发访客令牌
+func (s *CustomerService) IssueVisitorToken(customer *models.Customer) (string, error) {
+	secret, err := s.secret()
+	if err != nil {
+		return "", err
+	}
+	return s.issueVisitorToken(secret, customer.UUID, s.visitorTokenExpiry()), nil
+}
+
+// ParseVisitorToken 校验访客令牌并返回对应的客户
+func (s *CustomerService) ParseVisitorToken(token string) (*models.Customer, error) {
+	secret, err := s.secret()
+	if err != nil {
+		return nil, err
+	}
+	customerUUID, err := s.verifyVisitorToken(secret, token, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	var customer models.Customer
+	if err := database.DB.Where("uuid = ?", customerUUID).First(&customer).Error; err != nil {
+		return nil, &i18n.ErrorInfo{
+			Code:    i18n.ErrCodeTokenInvalid,
+			Message: "visitor not found",
+		}
+	}
+	return &customer, nil
+}
+
+// visitorTokenExpiry 新签发访客令牌的过期时间
+func (s *CustomerService) visitorTokenExpiry() time.Time {
+	hours := defaultVisitorTokenHours
+	if authConfig, err := models.LoadConfig[models.AuthConfig](database.DB, models.CSConfigKeyAuth); err == nil && authConfig.VisitorTokenHours > 0 {
+		hours = authConfig.VisitorTokenHours
+	}
+	return time.Now().Add(time.Duration(hours) * time.Hour)
+}
+
+// issueVisitorToken 签发访客令牌，格式为 "<客户UUID>.<过期时间戳>.<签名>"
+func (s *CustomerService) issueVisitorToken(secret, customerUUID string, expireAt time.Time) string {
+	payload := fmt.Sprintf("%s.%d", customerUUID, expireAt.Unix())
+	return payload + "." + common.HmacSha256(secret, "visitor:"+payload)
+}
+
+// verifyVisitorToken 校验访客令牌的签名与有效期，返回客户UUID
+func (s *CustomerService) verifyVisitorToken(secret, token string, now time.Time) (string, error) {
+	parts := strings.Split(token, ".")
+	if token == "" || len(parts) != 3 {
+		return "", &i18n.ErrorInfo{
+			Code:    i18n.ErrCodeTokenInvalid,
+			Message: "visitor token is invalid",
+		}
+	}
+	expireAt, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return "", &i18n.ErrorInfo{
+			Code:    i18n.ErrCodeTokenInvalid,
+			Message: "visitor token is malformed",
+		}
+	}
+	if !common.HmacSha256Verify(secret, "visitor:"+parts[0]+"."+parts[1], parts[2]) {
+		return "", &i18n.ErrorInfo{
+			Code:    i18n.ErrCodeTokenInvalid,
+			Message: "visitor token signature mismatch",
+		}
+	}
+	if now.Unix() > expireAt {
+		return "", &i18n.ErrorInfo{
+			Code:    i18n.ErrCodeTokenExpired,
+			Message: "visitor token has expired",
+		}
+	}
+	return parts[0], nil
+}
+
+// GetConversations 获取客户的历史对话
+func (s *CustomerService) GetConversations(customerID uint) ([]models.Conversations, error) {
+	var conversations []models.Conversations
+	err := database.DB.Where("customer_id = ?", customerID).
+		Order("updated_at DESC").
+		Find(&conversations).Error
+	return conversations, err
+}
+
+// applyProfile 将请求中的资料合并到客户记录，空值不会覆盖已有信息
+func (s *CustomerService) applyProfile(customer *models.Customer, profile *CustomerProfile) {
+	if profile == nil {
+		return
+	}
+	if profile.Name != "" {
+		customer.Name = profile.Name
+	}
+	if profile.Email != "" {
+		customer.Email = profile.Email
+	}
+	if profile.Phone != "" {
+		customer.Phone = profile.Phone
+	}
+	if profile.IP != "" {
+		customer.IP = profile.IP
+	}
+	if profile.UserAgent != "" {
+		customer.UserAgent = profile.UserAgent
+	}
+
+	if len(profile.CustomFields) > 0 {
+		fields := map[string]interface{}{}
+		if customer.CustomFields != "" {
+			if existing, err := common.StrToMap(customer.CustomFields); err == nil {
+				fields = existing
+			}
+		}
+		for key, value := range profile.CustomFields {
+			fields[key] = value
+		}
+		if fieldsJSON, err := json.Marshal(fields); err == nil {
+			customer.CustomFields = string(fieldsJSON)
+		}
+	}
+}
+
+// secret 获取访客令牌签名密钥
+func (s *CustomerService) secret() (string, error) {
+	authConfig, err := models.LoadConfig[models.AuthConfig](database.DB, models.CSConfigKeyAuth)
+	if err != nil || authConfig.JWTSecret == "" {
+		return "", &i18n.ErrorInfo{
+			Code:    i18n.ErrCodeConfigNotFound,
+			Message: "auth secret is not configured",
+		}
+	}
+	return authConfig.JWTSecret, nil
+}
@@ -0,0 +1,125 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"support-plugin/internal/i18n"
+	"support-plugin/internal/models"
+	"support-plugin/internal/utils/common"
+)
+
+func TestVerifyVisitorToken(t *testing.T) {
+	const secret = "visitor-secret"
+	const customerUUID = "2f1c6a5e-8d3b-4b7a-9c1e-5a6b7c8d9e0f"
+	now := testTime("2026-10-19 10:00")
+
+	valid := Customer.issueVisitorToken(secret, customerUUID, now.Add(time.Hour))
+	parts := strings.Split(valid, ".")
+	tamperedExpiry := parts[0] + ".9999999999." + parts[2]
+	tamperedUUID := "other-uuid." + parts[1] + "." + parts[2]
+
+	tests := []struct {
+		name     string
+		secret   string
+		token    string
+		now      time.Time
+		wantCode i18n.ErrorCode
+	}{
+		{"有效令牌", secret, valid, now, ""},
+		{"过期前一刻仍有效", secret, valid, now.Add(time.Hour), ""},
+		{"过期令牌", secret, valid, now.Add(time.Hour + time.Second), i18n.ErrCodeTokenExpired},
+		{"篡改过期时间", secret, tamperedExpiry, now, i18n.ErrCodeTokenInvalid},
+		{"篡改客户UUID", secret, tamperedUUID, now, i18n.ErrCodeTokenInvalid},
+		{"密钥不匹配", "other-secret", valid, now, i18n.ErrCodeTokenInvalid},
+		{"旧版两段式令牌", secret, customerUUID + "." + parts[2], now, i18n.ErrCodeTokenInvalid},
+		{"过期时间非数字", secret, customerUUID + ".abc." + parts[2], now, i18n.ErrCodeTokenInvalid},
+		{"空令牌", secret, "", now, i18n.ErrCodeTokenInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Customer.verifyVisitorToken(tt.secret, tt.token, tt.now)
+			if tt.wantCode == "" {
+				if err != nil {
+					t.Fatalf("verifyVisitorToken() error = %v", err)
+				}
+				if got != customerUUID {
+					t.Errorf("verifyVisitorToken() = %s, want %s", got, customerUUID)
+				}
+				return
+			}
+			var info *i18n.ErrorInfo
+			if !errors.As(err, &info) || info.Code != tt.wantCode {
+				t.Errorf("verifyVisitorToken() error = %v, want code %s", err, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestVerifyIdentity(t *testing.T) {
+	const secret = "source-secret"
+	userHash := common.HmacSha256(secret, "user-42")
+	profile := &CustomerProfile{Name: "张三", Email: "zhangsan@example.com"}
+	profileHash := common.HmacSha256(secret, Customer.identityPayload("user-42", profile.Name, profile.Email))
+
+	tests := []struct {
+		name          string
+		secret        string
+		identity      *CustomerIdentity
+		profile       *CustomerProfile
+		want          bool
+		profileSigned bool
+	}{
+		{"签名正确", secret, &CustomerIdentity{UserID: "user-42", UserHash: userHash}, nil, true, false},
+		{"签名大小写不敏感", secret, &CustomerIdentity{UserID: "user-42", UserHash: strings.ToUpper(userHash)}, nil, true, false},
+		{"签名与用户ID不匹配", secret, &CustomerIdentity{UserID: "user-43", UserHash: userHash}, nil, false, false},
+		{"密钥不匹配", "other-secret", &CustomerIdentity{UserID: "user-42", UserHash: userHash}, nil, false, false},
+		{"缺少签名", secret, &CustomerIdentity{UserID: "user-42"}, nil, false, false},
+		{"来源未配置密钥", "", &CustomerIdentity{UserID: "user-42", UserHash: common.HmacSha256("", "user-42")}, nil, false, false},
+		{"缺少用户ID", secret, &CustomerIdentity{UserHash: userHash}, nil, false, false},
+		{"签名只覆盖用户ID时不采用资料", secret, &CustomerIdentity{UserID: "user-42", UserHash: userHash}, profile, true, false},
+		{"签名覆盖姓名与邮箱", secret, &CustomerIdentity{UserID: "user-42", UserHash: profileHash}, profile, true, true},
+		{"篡改签名覆盖的邮箱", secret, &CustomerIdentity{UserID: "user-42", UserHash: profileHash},
+			&CustomerProfile{Name: "张三", Email: "fake@example.com"}, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, profileSigned := Customer.verifyIdentity(tt.secret, tt.identity, tt.profile)
+			if got != tt.want || profileSigned != tt.profileSigned {
+				t.Errorf("verifyIdentity() = %v, %v, want %v, %v", got, profileSigned, tt.want, tt.profileSigned)
+			}
+		})
+	}
+}
+
+func TestVerifiedCustomerKeepsProfile(t *testing.T) {
+	customer := &models.Customer{Name: "张三", Email: "zhangsan@example.com", ExternalID: "user-42"}
+	profile := &CustomerProfile{
+		Name:         "伪造",
+		Email:        "fake@example.com",
+		Phone:        "10086",
+		IP:           "10.0.0.1",
+		UserAgent:    "Mozilla/5.0",
+		CustomFields: map[string]interface{}{"plan": "pro"},
+	}
+
+	Customer.applyProfile(customer, Customer.deviceProfile(profile))
+	if customer.Name != "张三" || customer.Email != "zhangsan@example.com" || customer.Phone != "" || customer.CustomFields != "" {
+		t.Errorf("未签名资料覆盖了已验证客户: %+v", customer)
+	}
+	if customer.IP != "10.0.0.1" || customer.UserAgent != "Mozilla/5.0" {
+		t.Errorf("访问设备信息未更新: %+v", customer)
+	}
+	if Customer.deviceProfile(nil) != nil {
+		t.Error("deviceProfile(nil) 应返回 nil")
+	}
+
+	// 签名覆盖姓名与邮箱时采用签名内容，其余资料仍不采用
+	signed := &CustomerProfile{Name: "李四", Email: "lisi@example.com", Phone: "10086", IP: "10.0.0.2"}
+	Customer.applyProfile(customer, Customer.verifiedProfile(signed, true))
+	if customer.Name != "李四" || customer.Email != "lisi@example.com" || customer.Phone != "" || customer.IP != "10.0.0.2" {
+		t.Errorf("签名覆盖的资料未正确采用: %+v", customer)
+	}
+}
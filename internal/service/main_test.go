@@ -0,0 +1,45 @@
+package service
+
+import (
+	"os"
+	"testing"
+
+	"go.uber.org/zap"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+
+	"support-plugin/internal/pkg/database"
+	"support-plugin/internal/pkg/logger"
+)
+
+func TestMain(m *testing.M) {
+	logger.App = zap.NewNop()
+	os.Exit(m.Run())
+}
+
+// useTestDB 使用内存SQLite替换全局数据库连接，测试结束后恢复
+func useTestDB(t *testing.T, tables ...interface{}) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: gormlogger.Discard})
+	if err != nil {
+		t.Fatalf("打开测试数据库失败: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("获取测试数据库连接失败: %v", err)
+	}
+	// 内存数据库每个连接相互独立，限制为单连接
+	sqlDB.SetMaxOpenConns(1)
+	if err := db.AutoMigrate(tables...); err != nil {
+		t.Fatalf("初始化测试表失败: %v", err)
+	}
+
+	previous := database.DB
+	database.DB = db
+	t.Cleanup(func() {
+		database.DB = previous
+		sqlDB.Close()
+	})
+	return db
+}
@@ -0,0 +1,115 @@
+package service
+
+import (
+	"support-plugin/internal/i18n"
+	"support-plugin/internal/models"
+	"support-plugin/internal/pkg/database"
+)
+
+const (
+	defaultHistoryLimit = 20
+	maxHistoryLimit     = 100
+)
+
+type MessageHistoryService struct{}
+
+var MessageHistory = &MessageHistoryService{}
+
+// List 按 (created_at, id) 排序的游标分页查询对话消息（含内部备注），返回结果按时间正序排列
+// hasMore 表示查询方向上是否还有更多消息：向前翻页时为更早的消息，同步模式时为更新的消息
+func (s *MessageHistoryService) List(conversationID uint, query models.MessageHistoryQuery) ([]models.Message, bool, error) {
+	return s.list(conversationID, query, false)
+}
+
+// ListPublic 与 List 相同，但不包含内部备注，用于客户端接口
+func (s *MessageHistoryService) ListPublic(conversationID uint, query models.MessageHistoryQuery) ([]models.Message, bool, error) {
+	return s.list(conversationID, query, true)
+}
+
+func (s *MessageHistoryService) list(conversationID uint, query models.MessageHistoryQuery, public bool) ([]models.Message, bool, error) {
+	limit := query.Limit
+	if limit <= 0 {
+		limit = defaultHistoryLimit
+	}
+	if limit > maxHistoryLimit {
+		limit = maxHistoryLimit
+	}
+
+	db := database.DB.Where("conversation_id = ?", conversationID)
+	if public {
+		db = db.Where("visibility <> ?", models.MessageVisibilityInternal)
+	}
+
+	// 同步模式：从游标之后正序读取
+	if query.AfterID > 0 {
+		cursor, err := s.cursor(conversationID, query.AfterID, public)
+		if err != nil {
+			return nil, false, err
+		}
+		var messages []models.Message
+		err = db.Where("created_at > ? OR (created_at = ? AND id > ?)", cursor.CreatedAt, cursor.CreatedAt, cursor.ID).
+			Order("created_at ASC, id ASC").
+			Limit(limit + 1).
+			Find(&messages).Error
+		if err != nil {
+			return nil, false, err
+		}
+		hasMore := len(messages) > limit
+		if hasMore {
+			messages = messages[:limit]
+		}
+		return messages, hasMore, nil
+	}
+
+	// 历史模式：从游标（为空时从最新消息）之前倒序读取
+	if query.BeforeID > 0 {
+		cursor, err := s.cursor(conversationID, query.BeforeID, public)
+		if err != nil {
+			return nil, false, err
+		}
+		db = db.Where("created_at < ? OR (created_at = ? AND id < ?)", cursor.CreatedAt, cursor.CreatedAt, cursor.ID)
+	}
+	var messages []models.Message
+	err := db.Order("created_at DESC, id DESC").
+		Limit(limit + 1).
+		Find(&messages).Error
+	if err != nil {
+		return nil, false, err
+	}
+	hasMore := len(messages) > limit
+	if hasMore {
+		messages = messages[:limit]
+	}
+
+	// 反转切片，使消息按时间正序排列
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
+	return messages, hasMore, nil
+}
+
+// cursor 获取游标消息，游标必须属于当前对话，客户端接口不能以内部备注作为游标
+func (s *MessageHistoryService) cursor(conversationID, messageID uint, public bool) (*models.Message, error) {
+	var message models.Message
+	db := database.DB.Select("id", "created_at").
+		Where("id = ? AND conversation_id = ?", messageID, conversationID)
+	if public {
+		db = db.Where("visibility <> ?", models.MessageVisibilityInternal)
+	}
+	err := db.First(&message).Error
+	if err != nil {
+		return nil, &i18n.ErrorInfo{
+			Code:    i18n.ErrCodeMessageNotFound,
+			Message: "cursor message not found",
+		}
+	}
+	return &message, nil
+}
+
+// Cursors 返回消息列表首尾的ID，用于客户端继续翻页或同步
+func (s *MessageHistoryService) Cursors(messages []models.Message) (uint, uint) {
+	if len(messages) == 0 {
+		return 0, 0
+	}
+	return messages[0].ID, messages[len(messages)-1].ID
+}
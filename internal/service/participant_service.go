@@ -0,0 +1,324 @@
+package service
+
+import (
+	"fmt"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"support-plugin/internal/i18n"
+	"support-plugin/internal/models"
+	"support-plugin/internal/pkg/database"
+	"support-plugin/internal/pkg/dootask"
+	bizErrors "support-plugin/internal/pkg/errors"
+	"support-plugin/internal/pkg/logger"
+	"support-plugin/internal/pkg/websocket"
+)
+
+// ParticipantNotice 推送给目标客服的转接、邀请通知
+type ParticipantNotice struct {
+	Conversation  models.Conversations `json:"conversation"`
+	FromAgentID   uint                 `json:"from_agent_id"`
+	FromAgentName string               `json:"from_agent_name"`
+	Note          string               `json:"note,omitempty"`
+}
+
+type ParticipantService struct{}
+
+var Participant = &ParticipantService{}
+
+// List 获取对话中当前的参与客服
+func (s *ParticipantService) List(conversationID uint) ([]models.ConversationParticipant, error) {
+	conversation, err := s.openConversation(conversationID, false)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.ensureOwner(conversation); err != nil {
+		return nil, err
+	}
+
+	var participants []models.ConversationParticipant
+	err = database.DB.Where("conversation_id = ? AND left_at IS NULL", conversationID).
+		Order("joined_at ASC").
+		Find(&participants).Error
+	if err != nil {
+		return nil, err
+	}
+
+	agentIDs := make([]uint, len(participants))
+	for i := range participants {
+		agentIDs[i] = participants[i].AgentID
+	}
+	var agents []models.Agent
+	database.DB.Unscoped().Where("id IN ?", agentIDs).Find(&agents)
+	agentMap := make(map[uint]*models.Agent, len(agents))
+	for i := range agents {
+		agentMap[agents[i].ID] = &agents[i]
+	}
+	for i := range participants {
+		if agent, ok := agentMap[participants[i].AgentID]; ok {
+			participants[i].AgentName = Agent.DisplayName(agent)
+			participants[i].AgentAvatar = agent.Avatar
+		}
+	}
+	return participants, nil
+}
+
+// Transfer 将对话转接给其他客服，原负责客服离开对话
+// operator 为操作人，DooTask管理员未登记为客服时为nil；仅管理员、负责客服与参与客服可以转接
+func (s *ParticipantService) Transfer(conversationID uint, operator *models.Agent, isAdmin bool, targetID uint, note string) (*models.Conversations, error) {
+	conversation, err := s.openConversation(conversationID, true)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.authorize(conversation, operator, isAdmin); err != nil {
+		return nil, err
+	}
+	target, err := s.activeAgent(targetID)
+	if err != nil {
+		return nil, err
+	}
+	if conversation.AgentID == target.ID {
+		return nil, bizErrors.NewBusinessError("AGENT_ALREADY_ASSIGNED", "该客服已负责此对话", nil)
+	}
+
+	operatorID, operatorName := s.operator(operator)
+	previousID := conversation.AgentID
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(conversation).Update("agent_id", target.ID).Error; err != nil {
+			return err
+		}
+		if previousID > 0 {
+			if _, err := models.LeaveConversation(tx, conversation.ID, previousID); err != nil {
+				return err
+			}
+		}
+		_, err := models.JoinConversation(tx, conversation.ID, target.ID, models.ParticipantRoleOwner, operatorID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	conversation.AgentID = target.ID
+
+	logger.App.Info("对话已转接",
+		zap.Uint("conversationID", conversation.ID),
+		zap.Uint("fromAgentID", previousID),
+		zap.Uint("toAgentID", target.ID),
+		zap.Uint("operatorID", operatorID))
+
+	targetName := Agent.DisplayName(target)
+	s.announce(conversation, fmt.Sprintf("对话已转接给客服 %s", targetName))
+	websocket.SendToAgent(target.WsKey(), ParticipantNotice{
+		Conversation:  *conversation,
+		FromAgentID:   operatorID,
+		FromAgentName: operatorName,
+		Note:          note,
+	}, websocket.MessageTypeConversationTransferred)
+
+	dootaskText := fmt.Sprintf("[转接] %s 将对话转接给 %s", operatorName, targetName)
+	if note != "" {
+		dootaskText += fmt.Sprintf("\n备注：%s", note)
+	}
+	go s.syncDooTask(*conversation, dootaskText)
+
+	return conversation, nil
+}
+
+// Invite 邀请客服加入对话协助处理，负责客服不变；仅管理员、负责客服与参与客服可以邀请
+func (s *ParticipantService) Invite(conversationID uint, operator *models.Agent, isAdmin bool, targetID uint) error {
+	conversation, err := s.openConversation(conversationID, true)
+	if err != nil {
+		return err
+	}
+	if err := s.authorize(conversation, operator, isAdmin); err != nil {
+		return err
+	}
+	target, err := s.activeAgent(targetID)
+	if err != nil {
+		return err
+	}
+	if err := s.ensureOwner(conversation); err != nil {
+		return err
+	}
+
+	operatorID, operatorName := s.operator(operator)
+	role := models.ParticipantRoleParticipant
+	if conversation.AgentID == target.ID {
+		role = models.ParticipantRoleOwner
+	}
+	joined, err := models.JoinConversation(database.DB, conversation.ID, target.ID, role, operatorID)
+	if err != nil {
+		return err
+	}
+	if !joined {
+		return bizErrors.NewBusinessError("AGENT_ALREADY_JOINED", "该客服已在对话中", nil)
+	}
+
+	targetName := Agent.DisplayName(target)
+	s.announce(conversation, fmt.Sprintf("客服 %s 加入了对话", targetName))
+	websocket.SendToAgent(target.WsKey(), ParticipantNotice{
+		Conversation:  *conversation,
+		FromAgentID:   operatorID,
+		FromAgentName: operatorName,
+	}, websocket.MessageTypeConversationInvited)
+
+	go s.syncDooTask(*conversation, fmt.Sprintf("[邀请] %s 邀请 %s 加入对话", operatorName, targetName))
+	return nil
+}
+
+// Leave 客服离开对话，负责客服需要先转接
+func (s *ParticipantService) Leave(conversationID uint, agent *models.Agent) error {
+	conversation, err := s.openConversation(conversationID, false)
+	if err != nil {
+		return err
+	}
+	if conversation.AgentID == agent.ID {
+		return bizErrors.NewBusinessError("OWNER_CANNOT_LEAVE", "负责客服不能离开对话，请先转接", nil)
+	}
+
+	left, err := models.LeaveConversation(database.DB, conversation.ID, agent.ID)
+	if err != nil {
+		return err
+	}
+	if !left {
+		return bizErrors.NewBusinessError("AGENT_NOT_JOINED", "客服不在对话中", nil)
+	}
+
+	agentName := Agent.DisplayName(agent)
+	s.announce(conversation, fmt.Sprintf("客服 %s 离开了对话", agentName))
+	go s.syncDooTask(*conversation, fmt.Sprintf("[离开] %s 离开了对话", agentName))
+	return nil
+}
+
+// IsMember 客服是否为对话的负责客服或仍在对话中的参与客服
+func (s *ParticipantService) IsMember(conversation *models.Conversations, agentID uint) bool {
+	if agentID == 0 {
+		return false
+	}
+	if conversation.AgentID == agentID {
+		return true
+	}
+	var count int64
+	database.DB.Model(&models.ConversationParticipant{}).
+		Where("conversation_id = ? AND agent_id = ? AND left_at IS NULL", conversation.ID, agentID).
+		Count(&count)
+	return count > 0
+}
+
+// Authorize 校验操作人能否操作对话，仅管理员、负责客服与参与客服可以操作
+func (s *ParticipantService) Authorize(conversationID uint, operator *models.Agent, isAdmin bool) error {
+	conversation, err := s.openConversation(conversationID, false)
+	if err != nil {
+		return err
+	}
+	return s.authorize(conversation, operator, isAdmin)
+}
+
+// authorize 校验操作人是否为管理员、对话的负责客服或参与客服
+func (s *ParticipantService) authorize(conversation *models.Conversations, operator *models.Agent, isAdmin bool) error {
+	if isAdmin {
+		return nil
+	}
+	if operator != nil && s.IsMember(conversation, operator.ID) {
+		return nil
+	}
+	return &i18n.ErrorInfo{
+		Code:    i18n.ErrCodePermissionDenied,
+		Message: "only the assigned agent, participants or admins can operate the conversation",
+	}
+}
+
+// openConversation 查找对话，requireOpen 为 true 时已关闭的对话返回错误
+func (s *ParticipantService) openConversation(conversationID uint, requireOpen bool) (*models.Conversations, error) {
+	var conversation models.Conversations
+	if err := database.DB.First(&conversation, conversationID).Error; err != nil {
+		return nil, bizErrors.ErrConversationNotFound
+	}
+	if requireOpen && conversation.Status == "closed" {
+		return nil, bizErrors.ErrConversationClosed
+	}
+	return &conversation, nil
+}
+
+// activeAgent 查找可接手对话的客服
+func (s *ParticipantService) activeAgent(agentID uint) (*models.Agent, error) {
+	var agent models.Agent
+	if err := database.DB.Where("id = ? AND status = ?", agentID, "active").First(&agent).Error; err != nil {
+		return nil, &i18n.ErrorInfo{
+			Code:    i18n.ErrCodeAgentNotFound,
+			Message: "agent not found",
+		}
+	}
+	return &agent, nil
+}
+
+// ensureOwner 补齐负责客服的参与记录（早于参与者功能分配的对话没有记录）
+func (s *ParticipantService) ensureOwner(conversation *models.Conversations) error {
+	if conversation.AgentID == 0 {
+		return nil
+	}
+	var count int64
+	database.DB.Model(&models.ConversationParticipant{}).
+		Where("conversation_id = ? AND agent_id = ?", conversation.ID, conversation.AgentID).
+		Count(&count)
+	if count > 0 {
+		return nil
+	}
+	_, err := models.JoinConversation(database.DB, conversation.ID, conversation.AgentID, models.ParticipantRoleOwner, 0)
+	return err
+}
+
+// operator 操作人的ID与显示名称
+func (s *ParticipantService) operator(operator *models.Agent) (uint, string) {
+	if operator == nil {
+		return 0, "管理员"
+	}
+	return operator.ID, Agent.DisplayName(operator)
+}
+
+// announce 在对话中发送系统消息，并通知客服端
+func (s *ParticipantService) announce(conversation *models.Conversations, content string) {
+	message, err := ChatPublic.postSystemMessage(conversation, content, "")
+	if err != nil {
+		logger.App.Error("发送参与者变更消息失败", zap.Uint("conversationID", conversation.ID), zap.Error(err))
+		return
+	}
+	go websocket.BroadcastToConversationAgents(conversation.Uuid, message, websocket.MessageTypeNewMessage)
+}
+
+// syncDooTask 将参与客服同步为DooTask任务的协助人员，并在任务对话中记录变更
+func (s *ParticipantService) syncDooTask(conversation models.Conversations, content string) {
+	if conversation.DooTaskTaskID == 0 {
+		return
+	}
+	customerServiceConfigData, err := models.LoadConfig[models.CustomerServiceConfigData](database.DB, models.CSConfigKeySystem)
+	if err != nil {
+		return
+	}
+
+	var assist []int
+	err = database.DB.Model(&models.Agent{}).
+		Joins("JOIN cs_conversation_participants AS p ON p.agent_id = cs_agents.id").
+		Where("p.conversation_id = ? AND p.left_at IS NULL AND cs_agents.dootask_user_id > 0", conversation.ID).
+		Pluck("cs_agents.dootask_user_id", &assist).Error
+	if err != nil {
+		logger.App.Error("查询对话参与客服失败", zap.Uint("conversationID", conversation.ID), zap.Error(err))
+		return
+	}
+	if assist == nil {
+		assist = []int{}
+	}
+
+	botToken := customerServiceConfigData.DooTaskIntegration.BotToken
+	if err := dootask.NewIDootaskService().UpdateTaskAssist(botToken, conversation.DooTaskTaskID, assist); err != nil {
+		logger.App.Error("同步DooTask任务协助人员失败",
+			zap.Uint("conversationID", conversation.ID),
+			zap.Int("taskID", conversation.DooTaskTaskID),
+			zap.Error(err))
+	}
+
+	if conversation.DooTaskDialogID > 0 {
+		ChatAgent.sendToBot(customerServiceConfigData, content, fmt.Sprintf("%d", conversation.DooTaskDialogID))
+	}
+}
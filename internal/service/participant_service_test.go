@@ -0,0 +1,68 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"support-plugin/internal/i18n"
+	"support-plugin/internal/models"
+	bizErrors "support-plugin/internal/pkg/errors"
+)
+
+func TestParticipantAuthorize(t *testing.T) {
+	db := useTestDB(t, &models.ConversationParticipant{})
+
+	conversation := &models.Conversations{ID: 1, AgentID: 10}
+	leftAt := time.Now()
+	db.Create(&models.ConversationParticipant{ConversationID: 1, AgentID: 11, Role: models.ParticipantRoleParticipant, JoinedAt: time.Now()})
+	db.Create(&models.ConversationParticipant{ConversationID: 1, AgentID: 12, Role: models.ParticipantRoleParticipant, JoinedAt: time.Now(), LeftAt: &leftAt})
+	db.Create(&models.ConversationParticipant{ConversationID: 2, AgentID: 13, Role: models.ParticipantRoleOwner, JoinedAt: time.Now()})
+
+	tests := []struct {
+		name     string
+		operator *models.Agent
+		isAdmin  bool
+		allowed  bool
+	}{
+		{"负责客服", &models.Agent{ID: 10}, false, true},
+		{"参与客服", &models.Agent{ID: 11}, false, true},
+		{"已离开的参与客服", &models.Agent{ID: 12}, false, false},
+		{"其他对话的客服", &models.Agent{ID: 13}, false, false},
+		{"无关客服", &models.Agent{ID: 14}, false, false},
+		{"管理员", &models.Agent{ID: 14}, true, true},
+		{"未登记为客服的管理员", nil, true, true},
+		{"未登记为客服的非管理员", nil, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Participant.authorize(conversation, tt.operator, tt.isAdmin)
+			if tt.allowed {
+				if err != nil {
+					t.Errorf("authorize() error = %v", err)
+				}
+				return
+			}
+			var info *i18n.ErrorInfo
+			if !errors.As(err, &info) || info.Code != i18n.ErrCodePermissionDenied {
+				t.Errorf("authorize() error = %v, want %s", err, i18n.ErrCodePermissionDenied)
+			}
+		})
+	}
+}
+
+func TestParticipantAuthorizeConversation(t *testing.T) {
+	db := useTestDB(t, &models.Conversations{}, &models.ConversationParticipant{})
+	db.Create(&models.Conversations{ID: 1, Uuid: "conv-1", AgentID: 10, Status: "closed"})
+
+	if err := Participant.Authorize(1, &models.Agent{ID: 10}, false); err != nil {
+		t.Errorf("Authorize() 负责客服操作已关闭对话 error = %v", err)
+	}
+	var info *i18n.ErrorInfo
+	if err := Participant.Authorize(1, &models.Agent{ID: 14}, false); !errors.As(err, &info) || info.Code != i18n.ErrCodePermissionDenied {
+		t.Errorf("Authorize() 无关客服 error = %v, want %s", err, i18n.ErrCodePermissionDenied)
+	}
+	if err := Participant.Authorize(2, nil, true); !errors.Is(err, bizErrors.ErrConversationNotFound) {
+		t.Errorf("Authorize() 对话不存在 error = %v, want %v", err, bizErrors.ErrConversationNotFound)
+	}
+}
@@ -0,0 +1,149 @@
+package service
+
+import (
+	"go.uber.org/zap"
+
+	"support-plugin/internal/i18n"
+	"support-plugin/internal/models"
+	"support-plugin/internal/pkg/database"
+	bizErrors "support-plugin/internal/pkg/errors"
+	"support-plugin/internal/pkg/logger"
+	"support-plugin/internal/pkg/websocket"
+)
+
+type PresenceService struct{}
+
+var Presence = &PresenceService{}
+
+// List 获取所有启用客服的在线状态
+func (s *PresenceService) List() ([]models.AgentPresence, error) {
+	var agents []models.Agent
+	if err := database.DB.Where("status = ?", "active").Order("id ASC").Find(&agents).Error; err != nil {
+		return nil, err
+	}
+	loads, err := models.CountActiveChats(database.DB)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := websocket.Presence()
+	result := make([]models.AgentPresence, 0, len(agents))
+	for i := range agents {
+		result = append(result, s.build(&agents[i], snapshot, loads[agents[i].ID]))
+	}
+	return result, nil
+}
+
+// SetStatus 客服手动设置在线状态
+func (s *PresenceService) SetStatus(agent *models.Agent, status string) (*models.AgentPresence, error) {
+	if !models.ValidPresenceStatus(status) {
+		return nil, bizErrors.NewBusinessError("INVALID_PRESENCE_STATUS", "无效的在线状态", nil)
+	}
+	if err := database.DB.Model(agent).Update("presence_status", status).Error; err != nil {
+		return nil, err
+	}
+	agent.PresenceStatus = status
+
+	logger.App.Info("客服在线状态已变更", zap.Uint("agentID", agent.ID), zap.String("status", status))
+	return s.Notify(agent), nil
+}
+
+// SetMaxChats 设置客服最大同时接待对话数，0表示不限制
+func (s *PresenceService) SetMaxChats(agentID uint, maxChats int) (*models.Agent, error) {
+	if maxChats < 0 {
+		return nil, &i18n.ErrorInfo{
+			Code:    i18n.ErrCodeInvalidParams,
+			Message: "max concurrent chats must not be negative",
+		}
+	}
+	var agent models.Agent
+	if err := database.DB.First(&agent, agentID).Error; err != nil {
+		return nil, &i18n.ErrorInfo{
+			Code:    i18n.ErrCodeAgentNotFound,
+			Message: "agent not found",
+		}
+	}
+	if err := database.DB.Model(&agent).Update("max_concurrent_chats", maxChats).Error; err != nil {
+		return nil, err
+	}
+	agent.MaxConcurrentChats = maxChats
+	s.Notify(&agent)
+	return &agent, nil
+}
+
+// HandleConnection 客服WebSocket连接上线或全部断开时推送在线状态，由websocket层调用
+func (s *PresenceService) HandleConnection(wsKey string) {
+	var agent models.Agent
+	err := database.DB.Where("dootask_user_id = ? OR ((dootask_user_id = 0 OR dootask_user_id IS NULL) AND id = ?)", wsKey, wsKey).
+		First(&agent).Error
+	if err != nil {
+		return
+	}
+	s.Notify(&agent)
+}
+
+// Notify 向所有客服以及该客服负责对话中的客户推送在线状态
+func (s *PresenceService) Notify(agent *models.Agent) *models.AgentPresence {
+	var activeChats int64
+	database.DB.Model(&models.Conversations{}).
+		Where("status IN ? AND agent_id = ?", models.ActiveConversationStatuses, agent.ID).
+		Count(&activeChats)
+
+	presence := s.build(agent, websocket.Presence(), activeChats)
+	websocket.BroadcastToAllAgents(presence, websocket.MessageTypeAgentOnlineStatus)
+
+	// 客户只需要知道负责客服是否在线，不推送接待数量
+	customerPresence := presence
+	customerPresence.ActiveChats = 0
+	customerPresence.MaxConcurrentChats = 0
+	var uuids []string
+	database.DB.Model(&models.Conversations{}).
+		Where("status IN ? AND agent_id = ?", models.ActiveConversationStatuses, agent.ID).
+		Pluck("uuid", &uuids)
+	for _, uuid := range uuids {
+		websocket.BroadcastMessage(uuid, customerPresence, websocket.MessageTypeAgentOnlineStatus)
+	}
+	return &presence
+}
+
+// AnyAvailable 来源是否有在线且未满负荷的客服，来源设置了成员时只统计成员
+func (s *PresenceService) AnyAvailable(sourceKey string) bool {
+	var agents []models.Agent
+	var err error
+	if Routing.hasMembers(sourceKey) {
+		err = database.DB.Joins("JOIN cs_source_members AS m ON m.agent_id = cs_agents.id").
+			Joins("JOIN cs_sources AS s ON s.id = m.source_id").
+			Where("s.source_key = ? AND cs_agents.status = ?", sourceKey, "active").
+			Find(&agents).Error
+	} else {
+		err = database.DB.Where("status = ?", "active").Find(&agents).Error
+	}
+	if err != nil || len(agents) == 0 {
+		return false
+	}
+	loads, err := models.CountActiveChats(database.DB)
+	if err != nil {
+		return false
+	}
+
+	snapshot := websocket.Presence()
+	for i := range agents {
+		status := models.EffectivePresence(agents[i].PresenceStatus, snapshot.Agents[agents[i].WsKey()] > 0)
+		if status == models.PresenceOnline && agents[i].HasCapacity(loads[agents[i].ID]) {
+			return true
+		}
+	}
+	return false
+}
+
+// build 组装客服在线状态
+func (s *PresenceService) build(agent *models.Agent, snapshot *websocket.PresenceSnapshot, activeChats int64) models.AgentPresence {
+	return models.AgentPresence{
+		AgentID:            agent.ID,
+		Name:               Agent.DisplayName(agent),
+		Avatar:             agent.Avatar,
+		Status:             models.EffectivePresence(agent.PresenceStatus, snapshot.Agents[agent.WsKey()] > 0),
+		ActiveChats:        activeChats,
+		MaxConcurrentChats: agent.MaxConcurrentChats,
+	}
+}
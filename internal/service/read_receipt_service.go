@@ -0,0 +1,143 @@
+package service
+
+import (
+	"strconv"
+	"time"
+
+	"gorm.io/gorm/clause"
+
+	"support-plugin/internal/i18n"
+	"support-plugin/internal/models"
+	"support-plugin/internal/pkg/database"
+	bizErrors "support-plugin/internal/pkg/errors"
+	"support-plugin/internal/pkg/websocket"
+)
+
+// ReadReceiptData 推送给对方的已读回执
+type ReadReceiptData struct {
+	ConvUUID   string    `json:"conv_uuid"`
+	ReaderType string    `json:"reader_type"`
+	ReaderID   string    `json:"reader_id"`
+	MessageID  uint      `json:"message_id"`
+	ReadAt     time.Time `json:"read_at"`
+}
+
+type ReadReceiptService struct{}
+
+var ReadReceipt = &ReadReceiptService{}
+
+// HandleWsRead 处理WebSocket上报的已读回执
+func (s *ReadReceiptService) HandleWsRead(convUUID, readerType, readerID string, messageID uint) error {
+	var conversation models.Conversations
+	if err := database.DB.Where("uuid = ?", convUUID).First(&conversation).Error; err != nil {
+		return bizErrors.ErrConversationNotFound
+	}
+	_, err := s.MarkRead(&conversation, readerType, readerID, messageID)
+	return err
+}
+
+// MarkRead 将参与者的已读游标推进到指定消息，游标只前进不后退
+// 客户的读者ID固定为对话的客户ID
+func (s *ReadReceiptService) MarkRead(conversation *models.Conversations, readerType, readerID string, messageID uint) (*models.ConversationRead, error) {
+	if readerType == "customer" {
+		readerID = strconv.FormatUint(uint64(conversation.CustomerID), 10)
+	}
+
+	// 已读位置不能超过对话中的最后一条消息，客户看不到内部备注
+	var lastMessageID uint
+	db := database.DB.Model(&models.Message{}).Where("conversation_id = ?", conversation.ID)
+	if readerType == "customer" {
+		db = db.Where("visibility <> ?", models.MessageVisibilityInternal)
+	}
+	db.Select("COALESCE(MAX(id), 0)").Scan(&lastMessageID)
+	if messageID > lastMessageID {
+		messageID = lastMessageID
+	}
+	if messageID == 0 {
+		return nil, &i18n.ErrorInfo{
+			Code:    i18n.ErrCodeMessageNotFound,
+			Message: "no message to mark as read",
+		}
+	}
+
+	now := time.Now()
+	read := models.ConversationRead{
+		ConversationID: conversation.ID,
+		ReaderType:     readerType,
+		ReaderID:       readerID,
+		LastReadID:     messageID,
+		ReadAt:         now,
+	}
+	created := database.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "conversation_id"}, {Name: "reader_type"}, {Name: "reader_id"}},
+		DoNothing: true,
+	}).Create(&read)
+	if created.Error != nil {
+		return nil, created.Error
+	}
+
+	// 记录已存在时只向前推进游标
+	result := database.DB.Model(&models.ConversationRead{}).
+		Where("conversation_id = ? AND reader_type = ? AND reader_id = ? AND last_read_id < ?", conversation.ID, readerType, readerID, messageID).
+		Updates(map[string]interface{}{
+			"last_read_id": messageID,
+			"read_at":      now,
+		})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	// 冲突时部分驱动仍会回填ID，以影响行数判断是否新建了记录
+	if created.RowsAffected == 0 && result.RowsAffected == 0 {
+		// 游标未变化，无需通知
+		return &read, nil
+	}
+
+	receipt := ReadReceiptData{
+		ConvUUID:   conversation.Uuid,
+		ReaderType: readerType,
+		ReaderID:   readerID,
+		MessageID:  messageID,
+		ReadAt:     now,
+	}
+	if readerType == "customer" {
+		websocket.BroadcastToConversationAgents(conversation.Uuid, receipt, websocket.MessageTypeMessageRead)
+	} else {
+		websocket.BroadcastMessage(conversation.Uuid, receipt, websocket.MessageTypeMessageRead)
+	}
+	return &read, nil
+}
+
+// FillUnreadCounts 统计客服在各对话中未读的客户消息数
+func (s *ReadReceiptService) FillUnreadCounts(agentKey string, conversations []models.Conversations) error {
+	if len(conversations) == 0 {
+		return nil
+	}
+	ids := make([]uint, len(conversations))
+	for i := range conversations {
+		ids[i] = conversations[i].ID
+	}
+
+	type unreadCount struct {
+		ConversationID uint
+		Total          int64
+	}
+	var counts []unreadCount
+	err := database.DB.Table("cs_messages AS m").
+		Select("m.conversation_id, count(*) AS total").
+		Joins("LEFT JOIN cs_conversation_reads AS r ON r.conversation_id = m.conversation_id AND r.reader_type = ? AND r.reader_id = ?", "agent", agentKey).
+		Where("m.conversation_id IN ? AND m.sender = ? AND m.id > COALESCE(r.last_read_id, 0)", ids, "customer").
+		Group("m.conversation_id").
+		Scan(&counts).Error
+	if err != nil {
+		return err
+	}
+
+	countMap := make(map[uint]int64, len(counts))
+	for _, count := range counts {
+		countMap[count.ConversationID] = count.Total
+	}
+	for i := range conversations {
+		conversations[i].UnreadCount = countMap[conversations[i].ID]
+	}
+	return nil
+}
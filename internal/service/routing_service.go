@@ -0,0 +1,82 @@
+package service
+
+import (
+	"strconv"
+
+	"support-plugin/internal/models"
+	"support-plugin/internal/pkg/database"
+	"support-plugin/internal/pkg/websocket"
+)
+
+type RoutingService struct{}
+
+var Routing = &RoutingService{}
+
+// Audience 计算对话相关推送的接收客服
+// 已分配的对话推送给负责客服和参与客服；待分配的对话推送给来源成员，来源未设置成员时推送给所有客服
+func (s *RoutingService) Audience(convUUID string) (*websocket.Audience, error) {
+	var conversation models.Conversations
+	err := database.DB.Select("id", "agent_id", "source_key").
+		Where("uuid = ?", convUUID).
+		First(&conversation).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var agents []models.Agent
+	if conversation.AgentID > 0 {
+		err = database.DB.Where("id = ? OR id IN (?)", conversation.AgentID,
+			database.DB.Model(&models.ConversationParticipant{}).
+				Select("agent_id").
+				Where("conversation_id = ? AND left_at IS NULL", conversation.ID)).
+			Find(&agents).Error
+	} else {
+		err = database.DB.Joins("JOIN cs_source_members AS m ON m.agent_id = cs_agents.id").
+			Joins("JOIN cs_sources AS s ON s.id = m.source_id").
+			Where("s.source_key = ? AND cs_agents.status = ?", conversation.SourceKey, "active").
+			Find(&agents).Error
+		if err == nil && len(agents) == 0 && !s.hasMembers(conversation.SourceKey) {
+			return &websocket.Audience{All: true}, nil
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	audience := &websocket.Audience{AgentIDs: make([]string, 0, len(agents))}
+	for i := range agents {
+		audience.AgentIDs = append(audience.AgentIDs, agents[i].WsKey())
+	}
+	return audience, nil
+}
+
+// AuthorizeAgent 校验客服连接能否在对话中上报输入状态与已读回执
+// 管理员不受限制，其他客服须为对话的负责客服或参与客服
+func (s *RoutingService) AuthorizeAgent(convUUID, agentKey string, isAdmin bool) bool {
+	if isAdmin {
+		return true
+	}
+	authID, err := strconv.ParseUint(agentKey, 10, 64)
+	if err != nil {
+		return false
+	}
+	agent, err := Agent.FindByAuthID(uint(authID))
+	if err != nil {
+		return false
+	}
+	var conversation models.Conversations
+	if err := database.DB.Select("id", "agent_id").Where("uuid = ?", convUUID).First(&conversation).Error; err != nil {
+		return false
+	}
+	return Participant.IsMember(&conversation, agent.ID)
+}
+
+// hasMembers 来源是否设置了成员
+func (s *RoutingService) hasMembers(sourceKey string) bool {
+	var count int64
+	database.DB.Model(&models.SourceMember{}).
+		Joins("JOIN cs_sources AS s ON s.id = cs_source_members.source_id").
+		Where("s.source_key = ?", sourceKey).
+		Count(&count)
+	return count > 0
+}
@@ -0,0 +1,216 @@
+package service
+
+import (
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"support-plugin/internal/i18n"
+	"support-plugin/internal/models"
+	"support-plugin/internal/pkg/database"
+	"support-plugin/internal/pkg/logger"
+	"support-plugin/internal/pkg/search"
+)
+
+// 检索关键词的最大长度与分页限制
+const (
+	searchKeywordMaxLength = 100
+	searchDefaultPageSize  = 20
+	searchMaxPageSize      = 100
+)
+
+type SearchService struct{}
+
+var Search = &SearchService{}
+
+// searchRow 检索文档与所属对话的查询结果
+type searchRow struct {
+	Kind           string
+	RefID          uint
+	ConversationID uint
+	Content        string
+	CreatedAt      time.Time
+	Uuid           string
+	Title          string
+	Status         string
+	SourceKey      string
+	AgentID        uint
+	Snippet        string
+}
+
+// Query 按关键词检索消息内容与对话元数据，支持来源、负责客服、状态与日期范围筛选
+func (s *SearchService) Query(query models.SearchQuery) ([]models.SearchHit, int64, error) {
+	invalid := &i18n.ErrorInfo{
+		Code:    i18n.ErrCodeInvalidParams,
+		Message: "invalid search query",
+	}
+	keyword := strings.TrimSpace(query.Q)
+	if keyword == "" || len([]rune(keyword)) > searchKeywordMaxLength {
+		return nil, 0, invalid
+	}
+
+	db := database.DB.Table("cs_search_documents AS d").
+		Joins("JOIN cs_conversations AS c ON c.id = d.conversation_id AND c.deleted_at IS NULL")
+	switch query.Kind {
+	case "":
+	case models.SearchKindMessage, models.SearchKindConversation:
+		db = db.Where("d.kind = ?", query.Kind)
+	default:
+		return nil, 0, invalid
+	}
+	if query.SourceKey != "" {
+		db = db.Where("c.source_key = ?", query.SourceKey)
+	}
+	if query.AgentID > 0 {
+		db = db.Where("c.agent_id = ?", query.AgentID)
+	}
+	if query.Status != "" {
+		db = db.Where("c.status = ?", query.Status)
+	}
+	if query.StartDate != "" {
+		start, err := time.ParseInLocation(models.StatisticsDateLayout, query.StartDate, time.Local)
+		if err != nil {
+			return nil, 0, invalid
+		}
+		db = db.Where("d.created_at >= ?", start)
+	}
+	if query.EndDate != "" {
+		end, err := time.ParseInLocation(models.StatisticsDateLayout, query.EndDate, time.Local)
+		if err != nil {
+			return nil, 0, invalid
+		}
+		db = db.Where("d.created_at < ?", end.AddDate(0, 0, 1))
+	}
+
+	db, snippet := search.Default.Match(db, keyword)
+
+	var total int64
+	if err := db.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	columns := "d.kind, d.ref_id, d.conversation_id, d.content, d.created_at, c.uuid, c.title, c.status, c.source_key, c.agent_id"
+	if snippet != "" {
+		columns += ", " + snippet + " AS snippet"
+	}
+	var rows []searchRow
+	err := db.Select(columns).
+		Offset((query.Page - 1) * query.PageSize).
+		Limit(query.PageSize).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	hits := make([]models.SearchHit, 0, len(rows))
+	for _, row := range rows {
+		hit := models.SearchHit{
+			Kind:             row.Kind,
+			ConversationID:   row.ConversationID,
+			ConversationUUID: row.Uuid,
+			Title:            row.Title,
+			Status:           row.Status,
+			SourceKey:        row.SourceKey,
+			AgentID:          row.AgentID,
+			CreatedAt:        row.CreatedAt,
+		}
+		if row.Kind == models.SearchKindMessage {
+			hit.MessageID = row.RefID
+		}
+		if snippet != "" {
+			hit.Snippet = search.Highlight(row.Snippet)
+		} else {
+			hit.Snippet = search.Snippet(row.Content, keyword)
+		}
+		hits = append(hits, hit)
+	}
+	return hits, total, nil
+}
+
+// Normalize 补齐检索的分页参数
+func (s *SearchService) Normalize(query *models.SearchQuery) {
+	if query.Page < 1 {
+		query.Page = 1
+	}
+	if query.PageSize < 1 {
+		query.PageSize = searchDefaultPageSize
+	}
+	if query.PageSize > searchMaxPageSize {
+		query.PageSize = searchMaxPageSize
+	}
+}
+
+// BackfillIfEmpty 检索文档为空且已有消息时，根据历史消息与对话生成检索文档
+func (s *SearchService) BackfillIfEmpty() {
+	var documents, messages int64
+	database.DB.Model(&models.SearchDocument{}).Count(&documents)
+	if documents > 0 {
+		return
+	}
+	database.DB.Model(&models.Message{}).Count(&messages)
+	if messages == 0 {
+		return
+	}
+	if _, err := s.Rebuild(); err != nil {
+		logger.App.Error("回填检索文档失败", zap.Error(err))
+	}
+}
+
+// Rebuild 清空检索文档并根据 cs_messages、cs_conversations 与客户信息重新生成，返回文档数
+func (s *SearchService) Rebuild() (int, error) {
+	count := 0
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("1 = 1").Delete(&models.SearchDocument{}).Error; err != nil {
+			return err
+		}
+
+		var messages []models.Message
+		err := tx.Where("sender <> ?", "system").FindInBatches(&messages, 500, func(batch *gorm.DB, _ int) error {
+			documents := make([]*models.SearchDocument, 0, len(messages))
+			for i := range messages {
+				if document := search.MessageDocument(&messages[i]); document != nil {
+					documents = append(documents, document)
+				}
+			}
+			count += len(documents)
+			return search.Save(tx, documents...)
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		var conversations []models.Conversations
+		return tx.FindInBatches(&conversations, 500, func(batch *gorm.DB, _ int) error {
+			customerIDs := make([]uint, 0, len(conversations))
+			for _, conversation := range conversations {
+				customerIDs = append(customerIDs, conversation.CustomerID)
+			}
+			var customers []models.Customer
+			if err := tx.Unscoped().Where("id IN ?", customerIDs).Find(&customers).Error; err != nil {
+				return err
+			}
+			byID := make(map[uint]*models.Customer, len(customers))
+			for i := range customers {
+				byID[customers[i].ID] = &customers[i]
+			}
+
+			documents := make([]*models.SearchDocument, 0, len(conversations))
+			for i := range conversations {
+				documents = append(documents, search.ConversationDocument(&conversations[i], byID[conversations[i].CustomerID]))
+			}
+			count += len(documents)
+			return search.Save(tx, documents...)
+		}).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+	if err := search.Default.Rebuild(database.DB); err != nil {
+		return 0, err
+	}
+
+	logger.App.Info("检索文档已重建", zap.Int("documents", count), zap.String("backend", search.Default.Name()))
+	return count, nil
+}
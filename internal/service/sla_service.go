@@ -0,0 +1,298 @@
+package service
+
+import (
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm/clause"
+
+	"support-plugin/internal/config"
+	"support-plugin/internal/models"
+	"support-plugin/internal/pkg/database"
+	bizErrors "support-plugin/internal/pkg/errors"
+	"support-plugin/internal/pkg/eventbus"
+	"support-plugin/internal/pkg/logger"
+)
+
+// 只计算工作时间时最多向后查找的天数，超出时按自然时间计算
+const slaWorkingHoursMaxDays = 60
+
+// SLAService 对话优先级与 SLA 到期时间的计算和超时提醒
+type SLAService struct {
+	once sync.Once
+	// 事件总线并发处理同一对话的事件，按对话ID分段加锁保证后计算的结果基于最新状态
+	locks [64]sync.Mutex
+}
+
+// slaSchedule 预先解析的工作时间，分钟数为距当天零点的偏移
+type slaSchedule struct {
+	workingHours models.WorkingHoursData
+	loc          *time.Location
+	startMinutes int
+	endMinutes   int
+}
+
+var SLA = &SLAService{}
+
+// Start 启动 SLA 到期检查，启动时先按当前策略重新计算未关闭对话的到期时间
+func (s *SLAService) Start() {
+	interval := config.Cfg.SLA.CheckInterval
+	if interval <= 0 {
+		logger.App.Info("未启用SLA到期检查")
+		return
+	}
+	s.once.Do(func() {
+		go func() {
+			defer func() {
+				if err := recover(); err != nil {
+					logger.App.Error("SLA到期检查 panic", zap.Any("error", err))
+				}
+			}()
+
+			s.RefreshOpen("")
+			ticker := time.NewTicker(time.Duration(interval) * time.Second)
+			defer ticker.Stop()
+			for range ticker.C {
+				s.check()
+			}
+		}()
+		logger.App.Info("SLA到期检查已启动", zap.Int("interval", interval))
+	})
+}
+
+// SetPriority 设置对话优先级并重新计算到期时间
+func (s *SLAService) SetPriority(conversationID uint, priority string) (*models.Conversations, error) {
+	if _, ok := models.Priorities[priority]; !ok {
+		return nil, bizErrors.NewBusinessError("INVALID_PRIORITY", "优先级应为 low、normal、high 或 urgent", nil)
+	}
+	var conversation models.Conversations
+	if err := database.DB.First(&conversation, conversationID).Error; err != nil {
+		return nil, bizErrors.ErrConversationNotFound
+	}
+	if err := database.DB.Model(&conversation).Update("priority", priority).Error; err != nil {
+		return nil, err
+	}
+	s.Refresh(conversation.ID)
+
+	if err := database.DB.First(&conversation, conversationID).Error; err != nil {
+		return nil, err
+	}
+	return &conversation, nil
+}
+
+// RefreshOpen 重新计算未关闭对话的到期时间，sourceKey 为空时处理全部来源
+func (s *SLAService) RefreshOpen(sourceKey string) {
+	query := database.DB.Model(&models.Conversations{}).Where("status <> ?", "closed")
+	if sourceKey != "" {
+		query = query.Where("source_key = ?", sourceKey)
+	}
+	var ids []uint
+	if err := query.Pluck("id", &ids).Error; err != nil {
+		logger.App.Error("查询未关闭对话失败", zap.Error(err))
+		return
+	}
+	for _, id := range ids {
+		s.Refresh(id)
+	}
+}
+
+// Refresh 根据对话当前的消息与状态重新计算 SLA 到期时间
+// 尚无客服公开回复时考核首次响应；客服回复后客户再次发送消息时考核后续响应；对话关闭前考核解决时限
+func (s *SLAService) Refresh(conversationID uint) {
+	lock := &s.locks[conversationID%uint(len(s.locks))]
+	lock.Lock()
+	defer lock.Unlock()
+
+	var conversation models.Conversations
+	if err := database.DB.First(&conversation, conversationID).Error; err != nil {
+		return
+	}
+
+	var firstResponseDueAt, nextResponseDueAt, resolutionDueAt *time.Time
+	if policy, workingHours, ok := s.policy(conversation.SourceKey); ok && conversation.Status != "closed" {
+		target := policy.Target(conversation.Priority)
+		clock := s.clock(policy, workingHours)
+
+		var reply models.Message
+		replied := database.DB.Where("conversation_id = ? AND sender = ? AND visibility <> ?",
+			conversation.ID, "agent", models.MessageVisibilityInternal).
+			Order("id DESC").Limit(1).Find(&reply).RowsAffected > 0
+		if !replied {
+			firstResponseDueAt = clock(conversation.CreatedAt, target.FirstResponse)
+		} else {
+			var waiting models.Message
+			if database.DB.Where("conversation_id = ? AND sender = ? AND id > ?", conversation.ID, "customer", reply.ID).
+				Order("id ASC").Limit(1).Find(&waiting).RowsAffected > 0 {
+				nextResponseDueAt = clock(waiting.CreatedAt, target.NextResponse)
+			}
+		}
+
+		// 重新打开的对话从重新打开时开始计算解决时限
+		start := conversation.CreatedAt
+		if conversation.ReopenedAt != nil && conversation.ReopenedAt.After(start) {
+			start = *conversation.ReopenedAt
+		}
+		resolutionDueAt = clock(start, target.Resolution)
+	}
+
+	err := database.DB.Model(&models.Conversations{}).Where("id = ?", conversation.ID).UpdateColumns(map[string]interface{}{
+		"first_response_due_at": firstResponseDueAt,
+		"next_response_due_at":  nextResponseDueAt,
+		"resolution_due_at":     resolutionDueAt,
+	}).Error
+	if err != nil {
+		logger.App.Error("更新SLA到期时间失败", zap.Uint("conversationID", conversation.ID), zap.Error(err))
+	}
+}
+
+// policy 获取来源生效的 SLA 策略与工作时间，未启用 SLA 时返回 false
+func (s *SLAService) policy(sourceKey string) (models.SLAData, models.WorkingHoursData, bool) {
+	var source models.CustomerServiceSource
+	if err := database.DB.Where("source_key = ?", sourceKey).First(&source).Error; err != nil {
+		return models.SLAData{}, models.WorkingHoursData{}, false
+	}
+	sourceConfig, err := source.GetConfig()
+	if err != nil || !sourceConfig.SLA.Enabled {
+		return models.SLAData{}, models.WorkingHoursData{}, false
+	}
+	workingHours, _ := Availability.resolve(&source)
+	return sourceConfig.SLA, workingHours, true
+}
+
+// clock 返回按策略计算到期时间的函数，时限为0时不考核
+func (s *SLAService) clock(policy models.SLAData, workingHours models.WorkingHoursData) func(time.Time, int) *time.Time {
+	schedule, workingHoursOnly := s.schedule(policy, workingHours)
+	return func(start time.Time, minutes int) *time.Time {
+		if minutes <= 0 {
+			return nil
+		}
+		due := start.Add(time.Duration(minutes) * time.Minute)
+		if workingHoursOnly {
+			if t, ok := s.addWorkingMinutes(schedule, start, minutes); ok {
+				due = t
+			}
+		}
+		return &due
+	}
+}
+
+// schedule 解析只计算工作时间所需的排班，未启用、格式无效或上下班时间相同时按自然时间计算
+func (s *SLAService) schedule(policy models.SLAData, workingHours models.WorkingHoursData) (slaSchedule, bool) {
+	if !policy.WorkingHoursOnly || !workingHours.Enabled ||
+		(len(workingHours.WorkDays) == 0 && len(workingHours.SpecialWorkDays) == 0) {
+		return slaSchedule{}, false
+	}
+	startMinutes, endMinutes, ok := Availability.workingMinutes(workingHours)
+	if !ok || startMinutes == endMinutes {
+		return slaSchedule{}, false
+	}
+	return slaSchedule{
+		workingHours: workingHours,
+		loc:          Availability.location(workingHours),
+		startMinutes: startMinutes,
+		endMinutes:   endMinutes,
+	}, true
+}
+
+// intervals 返回 day 当天的工作时段，跨夜班次拆分为零点至下班与上班至次日零点两段
+func (sc slaSchedule) intervals(day time.Time) [][2]time.Time {
+	at := func(minutes int) time.Time {
+		return time.Date(day.Year(), day.Month(), day.Day(), 0, minutes, 0, 0, sc.loc)
+	}
+	if sc.startMinutes < sc.endMinutes {
+		return [][2]time.Time{{at(sc.startMinutes), at(sc.endMinutes)}}
+	}
+	return [][2]time.Time{{at(0), at(sc.endMinutes)}, {at(sc.startMinutes), at(24 * 60)}}
+}
+
+// addWorkingMinutes 从 start 开始按天累计指定分钟数的工作时间，返回到期时间
+func (s *SLAService) addWorkingMinutes(schedule slaSchedule, start time.Time, minutes int) (time.Time, bool) {
+	start = start.In(schedule.loc)
+	remaining := time.Duration(minutes) * time.Minute
+	for i := 0; i < slaWorkingHoursMaxDays; i++ {
+		day := time.Date(start.Year(), start.Month(), start.Day()+i, 0, 0, 0, 0, schedule.loc)
+		if !Availability.isWorkDay(schedule.workingHours, day) {
+			continue
+		}
+		for _, interval := range schedule.intervals(day) {
+			from, to := interval[0], interval[1]
+			if !to.After(start) {
+				continue
+			}
+			if from.Before(start) {
+				from = start
+			}
+			available := to.Sub(from)
+			if remaining <= available {
+				return from.Add(remaining), true
+			}
+			remaining -= available
+		}
+	}
+	logger.App.Warn("工作时间内无法满足SLA时限，按自然时间计算", zap.Int("minutes", minutes))
+	return time.Time{}, false
+}
+
+// check 检查到期时间，临近到期发布 sla.warning 事件，超时发布 sla.breached 事件
+func (s *SLAService) check() {
+	var conversations []models.Conversations
+	err := database.DB.Where("status <> ?", "closed").
+		Where("first_response_due_at IS NOT NULL OR next_response_due_at IS NOT NULL OR resolution_due_at IS NOT NULL").
+		Find(&conversations).Error
+	if err != nil {
+		logger.App.Error("查询SLA考核中的对话失败", zap.Error(err))
+		return
+	}
+
+	now := time.Now()
+	policies := make(map[string]models.SLAData)
+	for i := range conversations {
+		conversation := &conversations[i]
+		policy, ok := policies[conversation.SourceKey]
+		if !ok {
+			policy, _, _ = s.policy(conversation.SourceKey)
+			policies[conversation.SourceKey] = policy
+		}
+		warningBefore := time.Duration(policy.WarningBefore) * time.Minute
+
+		for metric, dueAt := range map[string]*time.Time{
+			models.SLAMetricFirstResponse: conversation.FirstResponseDueAt,
+			models.SLAMetricNextResponse:  conversation.NextResponseDueAt,
+			models.SLAMetricResolution:    conversation.ResolutionDueAt,
+		} {
+			if dueAt == nil {
+				continue
+			}
+			switch {
+			case !now.Before(*dueAt):
+				s.alert(conversation, metric, models.SLALevelBreached, *dueAt)
+			case warningBefore > 0 && !now.Before(dueAt.Add(-warningBefore)):
+				s.alert(conversation, metric, models.SLALevelWarning, *dueAt)
+			}
+		}
+	}
+}
+
+// alert 记录提醒并发布事件，同一截止时间的同一级别提醒只发布一次，多节点部署时同样只有一个节点发布
+func (s *SLAService) alert(conversation *models.Conversations, metric, level string, dueAt time.Time) {
+	result := database.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.SLAAlert{
+		ConversationID: conversation.ID,
+		Metric:         metric,
+		Level:          level,
+		DueAt:          dueAt,
+	})
+	if result.Error != nil {
+		logger.App.Error("记录SLA提醒失败", zap.Uint("conversationID", conversation.ID), zap.Error(result.Error))
+		return
+	}
+	if result.RowsAffected == 0 {
+		return
+	}
+
+	if eventbus.GlobalEventBus != nil {
+		if err := eventbus.GlobalEventBus.Publish(eventbus.NewSLAEvent(level, conversation.ID, metric, dueAt)); err != nil {
+			logger.App.Error("发布SLA提醒事件失败", zap.Uint("conversationID", conversation.ID), zap.Error(err))
+		}
+	}
+}
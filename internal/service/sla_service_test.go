@@ -0,0 +1,114 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"support-plugin/internal/models"
+)
+
+func TestSLAAddWorkingMinutes(t *testing.T) {
+	weekdays := slaSchedule{
+		workingHours: models.WorkingHoursData{
+			Enabled:         true,
+			StartTime:       "09:00",
+			EndTime:         "18:00",
+			WorkDays:        []int{1, 2, 3, 4, 5},
+			Holidays:        []string{"2026-10-21"},
+			SpecialWorkDays: []string{"2026-10-24"},
+		},
+		loc:          testLocation,
+		startMinutes: 9 * 60,
+		endMinutes:   18 * 60,
+	}
+	overnight := slaSchedule{
+		workingHours: models.WorkingHoursData{
+			Enabled:   true,
+			StartTime: "22:00",
+			EndTime:   "06:00",
+			WorkDays:  []int{0, 1, 2, 3, 4, 5, 6},
+		},
+		loc:          testLocation,
+		startMinutes: 22 * 60,
+		endMinutes:   6 * 60,
+	}
+
+	tests := []struct {
+		name     string
+		schedule slaSchedule
+		start    string
+		minutes  int
+		want     string
+	}{
+		{"当天工作时间内", weekdays, "2026-10-19 10:00", 60, "2026-10-19 11:00"},
+		{"恰好下班时到期", weekdays, "2026-10-19 17:00", 60, "2026-10-19 18:00"},
+		{"跨过下班顺延至次日", weekdays, "2026-10-19 17:30", 60, "2026-10-20 09:30"},
+		{"上班前从上班时开始计算", weekdays, "2026-10-19 07:00", 30, "2026-10-19 09:30"},
+		{"下班后从次日上班开始计算", weekdays, "2026-10-19 20:00", 30, "2026-10-20 09:30"},
+		{"跳过节假日", weekdays, "2026-10-20 17:00", 120, "2026-10-22 10:00"},
+		{"调休上班日按工作日计算", weekdays, "2026-10-23 17:00", 120, "2026-10-24 10:00"},
+		{"跳过周末", weekdays, "2026-10-24 17:00", 120, "2026-10-26 10:00"},
+		{"跨多个工作日并跳过节假日", weekdays, "2026-10-19 09:00", 3 * 9 * 60, "2026-10-22 18:00"},
+		{"跨夜班次跨过零点", overnight, "2026-10-19 23:00", 120, "2026-10-20 01:00"},
+		{"跨夜班次白天从上班开始计算", overnight, "2026-10-19 12:00", 60, "2026-10-19 23:00"},
+		{"跨夜班次早间时段用完顺延至当晚", overnight, "2026-10-19 05:00", 120, "2026-10-19 23:00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := SLA.addWorkingMinutes(tt.schedule, testTime(tt.start), tt.minutes)
+			if !ok || !got.Equal(testTime(tt.want)) {
+				t.Errorf("addWorkingMinutes(%s, %d) = %v, %v, want %s", tt.start, tt.minutes, got, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestSLAClock(t *testing.T) {
+	policy := models.SLAData{Enabled: true, WorkingHoursOnly: true}
+	workingHours := models.WorkingHoursData{
+		Enabled:   true,
+		StartTime: "09:00",
+		EndTime:   "18:00",
+		WorkDays:  []int{1, 2, 3, 4, 5},
+		Timezone:  "UTC",
+	}
+	sameBounds := workingHours
+	sameBounds.EndTime = "09:00"
+	invalid := workingHours
+	invalid.StartTime = "9点"
+	noWorkDays := workingHours
+	noWorkDays.WorkDays = nil
+	naturalPolicy := policy
+	naturalPolicy.WorkingHoursOnly = false
+
+	// 2026-10-19 为周一
+	start := time.Date(2026, 10, 19, 17, 30, 0, 0, time.UTC)
+	natural := start.Add(time.Hour)
+	nextDay := time.Date(2026, 10, 20, 9, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		policy       models.SLAData
+		workingHours models.WorkingHoursData
+		minutes      int
+		want         *time.Time
+	}{
+		{"只计算工作时间", policy, workingHours, 60, &nextDay},
+		{"按自然时间计算", naturalPolicy, workingHours, 60, &natural},
+		{"上下班时间相同时按自然时间计算", policy, sameBounds, 60, &natural},
+		{"工作时间格式无效时按自然时间计算", policy, invalid, 60, &natural},
+		{"没有工作日时按自然时间计算", policy, noWorkDays, 60, &natural},
+		{"时限为0时不考核", policy, workingHours, 0, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SLA.clock(tt.policy, tt.workingHours)(start, tt.minutes)
+			switch {
+			case tt.want == nil && got != nil:
+				t.Errorf("clock() = %v, want nil", *got)
+			case tt.want != nil && (got == nil || !got.Equal(*tt.want)):
+				t.Errorf("clock() = %v, want %v", got, *tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,376 @@
+package service
+
+import (
+	"math"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"support-plugin/internal/i18n"
+	"support-plugin/internal/models"
+	"support-plugin/internal/pkg/database"
+	"support-plugin/internal/pkg/logger"
+)
+
+// 统计查询默认与最大的日期范围（天）
+const (
+	statsDefaultDays = 30
+	statsMaxDays     = 366
+)
+
+type StatsService struct{}
+
+var Stats = &StatsService{}
+
+// statsRow 统计行按分组汇总后的结果
+type statsRow struct {
+	Date                string
+	AgentID             uint
+	SourceKey           string
+	NewConversations    int64
+	ClosedConversations int64
+	TotalMessages       int64
+	AgentMessages       int64
+	CustomerMessages    int64
+	FirstResponseCount  int64
+	FirstResponseTime   int64
+	ResolutionTime      int64
+	CSATCount           int64
+	CSATScore           int64
+	CSATSatisfied       int64
+}
+
+const statsSumColumns = "SUM(new_conversations) AS new_conversations, SUM(closed_conversations) AS closed_conversations, " +
+	"SUM(total_messages) AS total_messages, SUM(agent_messages) AS agent_messages, SUM(customer_messages) AS customer_messages, " +
+	"SUM(first_response_count) AS first_response_count, SUM(first_response_time) AS first_response_time, SUM(resolution_time) AS resolution_time, " +
+	"SUM(csat_count) AS csat_count, SUM(csat_score) AS csat_score, SUM(csat_satisfied) AS csat_satisfied"
+
+// Report 按日期范围、客服与来源筛选统计，返回汇总以及按日期、客服、来源的分组数据
+func (s *StatsService) Report(query models.StatsQuery) (*models.StatsReport, error) {
+	start, end, err := s.parseRange(query)
+	if err != nil {
+		return nil, err
+	}
+	report := &models.StatsReport{
+		StartDate: start.Format(models.StatisticsDateLayout),
+		EndDate:   end.Format(models.StatisticsDateLayout),
+	}
+
+	scope := func() *gorm.DB {
+		db := database.DB.Model(&models.ChatStatistics{}).
+			Where("date >= ? AND date <= ?", report.StartDate, report.EndDate)
+		if query.AgentID > 0 {
+			db = db.Where("agent_id = ?", query.AgentID)
+		}
+		if query.SourceKey != "" {
+			db = db.Where("source_key = ?", query.SourceKey)
+		}
+		return db
+	}
+
+	var total statsRow
+	if err := scope().Select(statsSumColumns).Scan(&total).Error; err != nil {
+		return nil, err
+	}
+	report.Summary = s.summarize(total)
+
+	var daily []statsRow
+	if err := scope().Select("date, " + statsSumColumns).Group("date").Order("date ASC").Scan(&daily).Error; err != nil {
+		return nil, err
+	}
+	// 补齐没有数据的日期，便于绘制趋势图
+	byDate := make(map[string]statsRow, len(daily))
+	for _, row := range daily {
+		byDate[row.Date] = row
+	}
+	for day := start; !day.After(end); day = day.AddDate(0, 0, 1) {
+		date := day.Format(models.StatisticsDateLayout)
+		summary := s.summarize(byDate[date])
+		summary.Date = date
+		report.Daily = append(report.Daily, summary)
+	}
+
+	var agentRows []statsRow
+	if err := scope().Select("agent_id, " + statsSumColumns).Group("agent_id").Order("agent_id ASC").Scan(&agentRows).Error; err != nil {
+		return nil, err
+	}
+	agentIDs := make([]uint, 0, len(agentRows))
+	for _, row := range agentRows {
+		agentIDs = append(agentIDs, row.AgentID)
+	}
+	var agents []models.Agent
+	database.DB.Unscoped().Where("id IN ?", agentIDs).Find(&agents)
+	agentNames := make(map[uint]string, len(agents))
+	for i := range agents {
+		agentNames[agents[i].ID] = Agent.DisplayName(&agents[i])
+	}
+	report.Agents = make([]models.StatsSummary, 0, len(agentRows))
+	for _, row := range agentRows {
+		summary := s.summarize(row)
+		agentID := row.AgentID
+		summary.AgentID = &agentID
+		summary.AgentName = agentNames[agentID]
+		report.Agents = append(report.Agents, summary)
+	}
+
+	var sourceRows []statsRow
+	if err := scope().Select("source_key, " + statsSumColumns).Group("source_key").Order("source_key ASC").Scan(&sourceRows).Error; err != nil {
+		return nil, err
+	}
+	sourceKeys := make([]string, 0, len(sourceRows))
+	for _, row := range sourceRows {
+		sourceKeys = append(sourceKeys, row.SourceKey)
+	}
+	var sources []models.CustomerServiceSource
+	database.DB.Where("source_key IN ?", sourceKeys).Find(&sources)
+	sourceNames := make(map[string]string, len(sources))
+	for _, source := range sources {
+		sourceNames[source.SourceKey] = source.Name
+	}
+	report.Sources = make([]models.StatsSummary, 0, len(sourceRows))
+	for _, row := range sourceRows {
+		summary := s.summarize(row)
+		summary.SourceKey = row.SourceKey
+		summary.SourceName = sourceNames[row.SourceKey]
+		report.Sources = append(report.Sources, summary)
+	}
+
+	// 标签统计基于对话当前的标签，按对话创建时间筛选
+	tagQuery := database.DB.Table("cs_conversation_tags AS ct").
+		Select("t.id AS tag_id, t.name, t.color, COUNT(*) AS conversations").
+		Joins("JOIN cs_tags AS t ON t.id = ct.tag_id").
+		Joins("JOIN cs_conversations AS c ON c.id = ct.conversation_id AND c.deleted_at IS NULL").
+		Where("c.created_at >= ? AND c.created_at < ?", start, end.AddDate(0, 0, 1))
+	if query.AgentID > 0 {
+		tagQuery = tagQuery.Where("c.agent_id = ?", query.AgentID)
+	}
+	if query.SourceKey != "" {
+		tagQuery = tagQuery.Where("c.source_key = ?", query.SourceKey)
+	}
+	report.Tags = []models.StatsTagCount{}
+	if err := tagQuery.Group("t.id, t.name, t.color, t.sort").Order("conversations DESC, t.sort ASC, t.id ASC").Scan(&report.Tags).Error; err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// BackfillIfEmpty 统计事件表为空且已有对话时，根据历史对话与消息重建统计
+func (s *StatsService) BackfillIfEmpty() {
+	var rows, conversations int64
+	database.DB.Model(&models.StatisticsEvent{}).Count(&rows)
+	if rows > 0 {
+		return
+	}
+	database.DB.Model(&models.Conversations{}).Count(&conversations)
+	if conversations == 0 {
+		return
+	}
+	if _, err := s.Rebuild(); err != nil {
+		logger.App.Error("回填统计数据失败", zap.Error(err))
+	}
+}
+
+// Rebuild 根据 cs_conversations、cs_messages 与满意度评价补齐缺失的统计事件，再由统计事件重新汇总每日统计，返回生成的统计行数
+// 已记录的事件保持原有归属，与实时统计按同一事件计数，汇总在同一事务中完成
+func (s *StatsService) Rebuild() (int, error) {
+	type convState struct {
+		agentID         uint
+		sourceKey       string
+		firstCustomerAt *time.Time
+		firstResponseAt *time.Time
+	}
+
+	states := map[uint]*convState{}
+	var conversations []models.Conversations
+	err := database.DB.FindInBatches(&conversations, 500, func(tx *gorm.DB, batch int) error {
+		events := make([]models.StatisticsEvent, 0, len(conversations))
+		for i := range conversations {
+			conversation := &conversations[i]
+			states[conversation.ID] = &convState{agentID: conversation.AgentID, sourceKey: conversation.SourceKey}
+			events = append(events, models.CreatedStatistics(conversation))
+			if closed, ok := models.ClosedStatistics(conversation); ok {
+				events = append(events, closed)
+			}
+		}
+		return s.backfill(events)
+	}).Error
+	if err != nil {
+		return 0, err
+	}
+
+	// 按消息ID顺序处理，客户第一条消息之后客服的第一条回复计入首次响应
+	var messages []models.Message
+	err = database.DB.FindInBatches(&messages, 1000, func(tx *gorm.DB, batch int) error {
+		events := make([]models.StatisticsEvent, 0, len(messages))
+		for i := range messages {
+			message := &messages[i]
+			state := states[message.ConversationID]
+			if state == nil {
+				continue
+			}
+			conversation := &models.Conversations{ID: message.ConversationID, AgentID: state.agentID, SourceKey: state.sourceKey}
+			stats, ok := models.MessageStatistics(conversation, message)
+			if !ok {
+				continue
+			}
+			switch message.Sender {
+			case "customer":
+				if state.firstCustomerAt == nil {
+					createdAt := message.CreatedAt
+					state.firstCustomerAt = &createdAt
+				}
+			case "agent":
+				if state.firstCustomerAt != nil && state.firstResponseAt == nil {
+					createdAt := message.CreatedAt
+					state.firstResponseAt = &createdAt
+					stats.AddFirstResponse(*state.firstCustomerAt, createdAt)
+				}
+			}
+			events = append(events, stats)
+		}
+		return s.backfill(events)
+	}).Error
+	if err != nil {
+		return 0, err
+	}
+
+	var ratings []models.ConversationRating
+	err = database.DB.FindInBatches(&ratings, 1000, func(tx *gorm.DB, batch int) error {
+		events := make([]models.StatisticsEvent, 0, len(ratings))
+		for i := range ratings {
+			if states[ratings[i].ConversationID] == nil {
+				continue
+			}
+			events = append(events, models.RatingStatistics(&ratings[i]))
+		}
+		return s.backfill(events)
+	}).Error
+	if err != nil {
+		return 0, err
+	}
+
+	var rows int64
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		// 重新打开的对话与已删除对话的事件不再计入
+		err := tx.Where("kind = ? AND ref_id NOT IN (?)", models.StatisticsKindClosed,
+			tx.Model(&models.Conversations{}).Select("id").Where("status = ?", models.ConversationStatusClosed)).
+			Delete(&models.StatisticsEvent{}).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Where("conversation_id NOT IN (?)", tx.Model(&models.Conversations{}).Select("id")).
+			Delete(&models.StatisticsEvent{}).Error
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Where("1 = 1").Delete(&models.ChatStatistics{}).Error; err != nil {
+			return err
+		}
+		now := time.Now()
+		err = tx.Exec(`INSERT INTO cs_statistics (date, agent_id, source_key, new_conversations, closed_conversations,
+			total_messages, agent_messages, customer_messages, first_response_count, first_response_time,
+			resolution_time, csat_count, csat_score, csat_satisfied, created_at, updated_at)
+			SELECT date, agent_id, source_key, SUM(new_conversations), SUM(closed_conversations),
+			SUM(total_messages), SUM(agent_messages), SUM(customer_messages), SUM(first_response_count), SUM(first_response_time),
+			SUM(resolution_time), SUM(csat_count), SUM(csat_score), SUM(csat_satisfied), ?, ?
+			FROM cs_statistics_events GROUP BY date, agent_id, source_key`, now, now).Error
+		if err != nil {
+			return err
+		}
+		if err := tx.Model(&models.ChatStatistics{}).Count(&rows).Error; err != nil {
+			return err
+		}
+
+		for id, state := range states {
+			if state.firstResponseAt == nil {
+				continue
+			}
+			err := tx.Model(&models.Conversations{}).
+				Where("id = ? AND first_response_at IS NULL", id).
+				Update("first_response_at", *state.firstResponseAt).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	logger.App.Info("统计数据已重建", zap.Int64("rows", rows), zap.Int("conversations", len(states)))
+	return int(rows), nil
+}
+
+// backfill 保存尚未记录的统计事件，已记录的事件保持不变
+func (s *StatsService) backfill(events []models.StatisticsEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	now := time.Now()
+	for i := range events {
+		events[i].CreatedAt = now
+	}
+	return database.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "kind"}, {Name: "ref_id"}},
+		DoNothing: true,
+	}).CreateInBatches(events, 200).Error
+}
+
+// parseRange 解析统计日期范围，默认最近30天
+func (s *StatsService) parseRange(query models.StatsQuery) (time.Time, time.Time, error) {
+	invalid := &i18n.ErrorInfo{
+		Code:    i18n.ErrCodeInvalidParams,
+		Message: "invalid date range",
+	}
+
+	now := time.Now()
+	end := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	if query.EndDate != "" {
+		parsed, err := time.ParseInLocation(models.StatisticsDateLayout, query.EndDate, time.Local)
+		if err != nil {
+			return time.Time{}, time.Time{}, invalid
+		}
+		end = parsed
+	}
+	start := end.AddDate(0, 0, -(statsDefaultDays - 1))
+	if query.StartDate != "" {
+		parsed, err := time.ParseInLocation(models.StatisticsDateLayout, query.StartDate, time.Local)
+		if err != nil {
+			return time.Time{}, time.Time{}, invalid
+		}
+		start = parsed
+	}
+	if start.After(end) || end.Sub(start) >= statsMaxDays*24*time.Hour {
+		return time.Time{}, time.Time{}, invalid
+	}
+	return start, end, nil
+}
+
+// summarize 由汇总行计算平均首次响应与处理时长
+func (s *StatsService) summarize(row statsRow) models.StatsSummary {
+	summary := models.StatsSummary{
+		NewConversations:    row.NewConversations,
+		ClosedConversations: row.ClosedConversations,
+		TotalMessages:       row.TotalMessages,
+		AgentMessages:       row.AgentMessages,
+		CustomerMessages:    row.CustomerMessages,
+		FirstResponseCount:  row.FirstResponseCount,
+	}
+	if row.FirstResponseCount > 0 {
+		summary.AvgFirstResponseTime = row.FirstResponseTime / row.FirstResponseCount
+	}
+	if row.ClosedConversations > 0 {
+		summary.AvgResolutionTime = row.ResolutionTime / row.ClosedConversations
+	}
+	if row.CSATCount > 0 {
+		summary.CSATCount = row.CSATCount
+		summary.AvgCSAT = math.Round(float64(row.CSATScore)/float64(row.CSATCount)*100) / 100
+		summary.CSATSatisfiedRate = math.Round(float64(row.CSATSatisfied)/float64(row.CSATCount)*10000) / 100
+	}
+	return summary
+}
@@ -0,0 +1,134 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"support-plugin/internal/models"
+	"support-plugin/internal/pkg/database"
+)
+
+// statsTotals 按客服汇总每日统计，便于比较实时统计与重建结果
+func statsTotals(t *testing.T) map[uint]models.ChatStatistics {
+	t.Helper()
+	var rows []models.ChatStatistics
+	if err := database.DB.Find(&rows).Error; err != nil {
+		t.Fatalf("查询统计失败: %v", err)
+	}
+	totals := map[uint]models.ChatStatistics{}
+	for _, row := range rows {
+		total := totals[row.AgentID]
+		total.AgentID = row.AgentID
+		total.NewConversations += row.NewConversations
+		total.ClosedConversations += row.ClosedConversations
+		total.TotalMessages += row.TotalMessages
+		total.AgentMessages += row.AgentMessages
+		total.CustomerMessages += row.CustomerMessages
+		total.FirstResponseCount += row.FirstResponseCount
+		total.FirstResponseTime += row.FirstResponseTime
+		total.ResolutionTime += row.ResolutionTime
+		total.CSATCount += row.CSATCount
+		totals[row.AgentID] = total
+	}
+	return totals
+}
+
+func TestStatisticsEvents(t *testing.T) {
+	db := useTestDB(t, &models.Conversations{}, &models.Message{}, &models.ConversationRating{},
+		&models.ChatStatistics{}, &models.StatisticsEvent{})
+
+	createdAt := testTime("2024-05-06 10:00")
+	conversation := models.Conversations{ID: 1, Uuid: "conv-1", AgentID: 10, SourceKey: "web", Status: "open", CreatedAt: createdAt}
+	db.Create(&conversation)
+	messages := []models.Message{
+		{ID: 1, ConversationID: 1, Sender: "customer", Content: "你好", CreatedAt: createdAt.Add(time.Minute)},
+		{ID: 2, ConversationID: 1, Sender: "agent", SenderID: 11, Content: "备注", Visibility: models.MessageVisibilityInternal, CreatedAt: createdAt.Add(2 * time.Minute)},
+		{ID: 3, ConversationID: 1, Sender: "agent", SenderID: 11, Content: "您好", CreatedAt: createdAt.Add(3 * time.Minute)},
+	}
+	db.Create(&messages)
+
+	record := func(event models.StatisticsEvent) {
+		t.Helper()
+		if err := models.RecordStatistics(db, event); err != nil {
+			t.Fatalf("RecordStatistics() error = %v", err)
+		}
+	}
+
+	// 重复处理同一事件只计入一次
+	record(models.CreatedStatistics(&conversation))
+	record(models.CreatedStatistics(&conversation))
+	for i := range messages {
+		if event, ok := models.MessageStatistics(&conversation, &messages[i]); ok {
+			if messages[i].ID == 3 {
+				event.AddFirstResponse(messages[0].CreatedAt, messages[i].CreatedAt)
+			}
+			record(event)
+			record(event)
+		}
+	}
+
+	// 第一次关闭后重新打开，再由其他客服关闭，只保留最后一次关闭
+	closedAt := createdAt.Add(time.Hour)
+	conversation.Status = models.ConversationStatusClosed
+	conversation.ClosedAt = &closedAt
+	conversation.ClosedBy = 10
+	db.Save(&conversation)
+	closed, _ := models.ClosedStatistics(&conversation)
+	record(closed)
+
+	conversation.Status = "open"
+	conversation.ClosedAt = nil
+	conversation.ClosedBy = 0
+	db.Save(&conversation)
+	if _, ok := models.ClosedStatistics(&conversation); ok {
+		t.Fatal("ClosedStatistics() 未关闭的对话不应计入")
+	}
+	if err := models.RetractStatistics(db, models.StatisticsKindClosed, conversation.ID); err != nil {
+		t.Fatalf("RetractStatistics() error = %v", err)
+	}
+
+	closedAt = createdAt.Add(2 * time.Hour)
+	conversation.Status = models.ConversationStatusClosed
+	conversation.ClosedAt = &closedAt
+	conversation.ClosedBy = 11
+	db.Save(&conversation)
+	closed, _ = models.ClosedStatistics(&conversation)
+	record(closed)
+	record(closed)
+
+	want := map[uint]models.ChatStatistics{
+		10: {AgentID: 10, NewConversations: 1, TotalMessages: 1, CustomerMessages: 1},
+		11: {AgentID: 11, ClosedConversations: 1, ResolutionTime: 7200, TotalMessages: 1, AgentMessages: 1, FirstResponseCount: 1, FirstResponseTime: 120},
+	}
+	live := statsTotals(t)
+	for agentID, expected := range want {
+		if live[agentID] != expected {
+			t.Errorf("实时统计 客服%d = %+v, want %+v", agentID, live[agentID], expected)
+		}
+	}
+
+	// 重建结果与实时统计一致，再次重建结果不变
+	for i := 0; i < 2; i++ {
+		if _, err := Stats.Rebuild(); err != nil {
+			t.Fatalf("Rebuild() error = %v", err)
+		}
+		rebuilt := statsTotals(t)
+		for agentID, expected := range want {
+			if rebuilt[agentID] != expected {
+				t.Errorf("重建统计 客服%d = %+v, want %+v", agentID, rebuilt[agentID], expected)
+			}
+		}
+	}
+
+	// 清空事件后重建，按消息与对话记录补齐
+	db.Where("1 = 1").Delete(&models.StatisticsEvent{})
+	if _, err := Stats.Rebuild(); err != nil {
+		t.Fatalf("Rebuild() error = %v", err)
+	}
+	backfilled := statsTotals(t)
+	for agentID, expected := range want {
+		if backfilled[agentID] != expected {
+			t.Errorf("回填统计 客服%d = %+v, want %+v", agentID, backfilled[agentID], expected)
+		}
+	}
+}
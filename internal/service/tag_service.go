@@ -0,0 +1,215 @@
+package service
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"support-plugin/internal/models"
+	"support-plugin/internal/pkg/database"
+	bizErrors "support-plugin/internal/pkg/errors"
+	"support-plugin/internal/pkg/eventbus"
+	"support-plugin/internal/pkg/logger"
+)
+
+var tagColorPattern = regexp.MustCompile(`^#[0-9A-Fa-f]{6}$`)
+
+var errTagNotFound = bizErrors.NewBusinessError("TAG_NOT_FOUND", "标签不存在", nil)
+
+// TagService 对话标签的管理与设置
+type TagService struct{}
+
+var Tag = &TagService{}
+
+// List 获取全部标签
+func (s *TagService) List() ([]models.Tag, error) {
+	tags := []models.Tag{}
+	err := database.DB.Order("sort ASC, name ASC").Find(&tags).Error
+	return tags, err
+}
+
+// Get 根据ID获取标签
+func (s *TagService) Get(id uint) (*models.Tag, error) {
+	var tag models.Tag
+	if err := database.DB.First(&tag, id).Error; err != nil {
+		return nil, errTagNotFound
+	}
+	return &tag, nil
+}
+
+// Create 创建标签
+func (s *TagService) Create(req models.TagRequest) (*models.Tag, error) {
+	tag := models.Tag{}
+	if err := s.apply(&tag, req); err != nil {
+		return nil, err
+	}
+	if err := database.DB.Create(&tag).Error; err != nil {
+		return nil, err
+	}
+	logger.App.Info("标签已创建", zap.Uint("id", tag.ID), zap.String("name", tag.Name))
+	return &tag, nil
+}
+
+// Update 更新标签
+func (s *TagService) Update(id uint, req models.TagRequest) (*models.Tag, error) {
+	tag, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.apply(tag, req); err != nil {
+		return nil, err
+	}
+	if err := database.DB.Save(tag).Error; err != nil {
+		return nil, err
+	}
+	return tag, nil
+}
+
+// Delete 删除标签，同时移除对话上的该标签
+func (s *TagService) Delete(id uint) error {
+	var conversationIDs []uint
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Delete(&models.Tag{}, id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errTagNotFound
+		}
+		if err := tx.Model(&models.ConversationTag{}).Where("tag_id = ?", id).Pluck("conversation_id", &conversationIDs).Error; err != nil {
+			return err
+		}
+		return tx.Where("tag_id = ?", id).Delete(&models.ConversationTag{}).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	// 使用该标签的对话标签已变化，同步到关联的DooTask任务
+	if eventbus.GlobalEventBus != nil {
+		for _, conversationID := range conversationIDs {
+			if err := eventbus.GlobalEventBus.Publish(eventbus.NewTagsUpdatedEvent(conversationID)); err != nil {
+				logger.App.Error("发布对话标签变更事件失败", zap.Uint("conversationID", conversationID), zap.Error(err))
+			}
+		}
+	}
+	return nil
+}
+
+// SetConversationTags 整体替换对话的标签，标签有变化时发布标签变更事件
+func (s *TagService) SetConversationTags(conversationID uint, tagIDs []uint, agentID uint) ([]models.Tag, error) {
+	var conversation models.Conversations
+	if err := database.DB.First(&conversation, conversationID).Error; err != nil {
+		return nil, bizErrors.ErrConversationNotFound
+	}
+
+	ids := make([]uint, 0, len(tagIDs))
+	seen := make(map[uint]bool, len(tagIDs))
+	for _, id := range tagIDs {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) > 0 {
+		var count int64
+		database.DB.Model(&models.Tag{}).Where("id IN ?", ids).Count(&count)
+		if count != int64(len(ids)) {
+			return nil, errTagNotFound
+		}
+	}
+
+	changed := false
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		remove := tx.Where("conversation_id = ?", conversationID)
+		if len(ids) > 0 {
+			remove = remove.Where("tag_id NOT IN ?", ids)
+		}
+		result := remove.Delete(&models.ConversationTag{})
+		if result.Error != nil {
+			return result.Error
+		}
+		changed = result.RowsAffected > 0
+
+		for _, id := range ids {
+			result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.ConversationTag{
+				ConversationID: conversationID,
+				TagID:          id,
+				CreatedBy:      agentID,
+			})
+			if result.Error != nil {
+				return result.Error
+			}
+			changed = changed || result.RowsAffected > 0
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if changed && eventbus.GlobalEventBus != nil {
+		if err := eventbus.GlobalEventBus.Publish(eventbus.NewTagsUpdatedEvent(conversationID)); err != nil {
+			logger.App.Error("发布对话标签变更事件失败", zap.Uint("conversationID", conversationID), zap.Error(err))
+		}
+	}
+
+	tagMap, err := models.LoadConversationTags(database.DB, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	tags := tagMap[conversationID]
+	if tags == nil {
+		tags = []models.Tag{}
+	}
+	return tags, nil
+}
+
+// FillTags 填充对话列表的标签
+func (s *TagService) FillTags(conversations []models.Conversations) error {
+	ids := make([]uint, len(conversations))
+	for i := range conversations {
+		ids[i] = conversations[i].ID
+	}
+	tagMap, err := models.LoadConversationTags(database.DB, ids...)
+	if err != nil {
+		return err
+	}
+	for i := range conversations {
+		conversations[i].Tags = tagMap[conversations[i].ID]
+	}
+	return nil
+}
+
+// apply 校验请求并写入标签
+func (s *TagService) apply(tag *models.Tag, req models.TagRequest) error {
+	name := strings.TrimSpace(req.Name)
+	if name == "" || utf8.RuneCountInString(name) > 64 {
+		return bizErrors.NewBusinessError("INVALID_TAG_NAME", "标签名称不能为空，且不超过64个字符", nil)
+	}
+	color := strings.TrimSpace(req.Color)
+	if color != "" && !tagColorPattern.MatchString(color) {
+		return bizErrors.NewBusinessError("INVALID_TAG_COLOR", "标签颜色格式应为 #RRGGBB", nil)
+	}
+	if _, ok := models.DooTaskPriorities[req.DooTaskPriority]; req.DooTaskPriority != 0 && !ok {
+		return bizErrors.NewBusinessError("INVALID_DOOTASK_PRIORITY", "DooTask任务优先级应为1-4，0表示不映射", nil)
+	}
+
+	var exists int64
+	database.DB.Model(&models.Tag{}).Where("name = ? AND id <> ?", name, tag.ID).Count(&exists)
+	if exists > 0 {
+		return bizErrors.NewBusinessError("TAG_EXISTS", "已存在相同名称的标签", nil)
+	}
+
+	tag.Name = name
+	tag.Color = color
+	tag.Description = strings.TrimSpace(req.Description)
+	tag.DooTaskPriority = req.DooTaskPriority
+	tag.DooTaskLabel = req.DooTaskLabel
+	tag.Sort = req.Sort
+	return nil
+}
@@ -0,0 +1,203 @@
+package service
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"support-plugin/internal/config"
+	"support-plugin/internal/models"
+	"support-plugin/internal/pkg/database"
+	"support-plugin/internal/pkg/dootask"
+	"support-plugin/internal/pkg/logger"
+)
+
+// 每批轮询的对话数
+const taskSyncPageSize = 100
+
+// TaskSyncService 对话与DooTask任务的完成状态双向同步
+type TaskSyncService struct {
+	once sync.Once
+}
+
+var TaskSync = &TaskSyncService{}
+
+// Start 启动任务状态轮询，任务在DooTask中被完成时关闭对应对话
+func (s *TaskSyncService) Start() {
+	interval := config.Cfg.DooTask.TaskSyncInterval
+	if interval <= 0 {
+		logger.App.Info("未启用DooTask任务状态轮询")
+		return
+	}
+	s.once.Do(func() {
+		go func() {
+			ticker := time.NewTicker(time.Duration(interval) * time.Second)
+			defer ticker.Stop()
+			for range ticker.C {
+				s.poll()
+			}
+		}()
+		logger.App.Info("DooTask任务状态轮询已启动", zap.Int("interval", interval))
+	})
+}
+
+// Closed 对话关闭后将关联任务标记为已完成，并在任务对话中说明
+func (s *TaskSyncService) Closed(conversation *models.Conversations, agent *models.Agent) {
+	botToken, ok := s.botToken(conversation)
+	if !ok {
+		return
+	}
+	if err := dootask.NewIDootaskService().CompleteTask(botToken, conversation.DooTaskTaskID); err != nil {
+		logger.App.Error("标记DooTask任务完成失败",
+			zap.Uint("conversationID", conversation.ID),
+			zap.Int("taskID", conversation.DooTaskTaskID),
+			zap.Error(err))
+	}
+	s.notify(conversation, fmt.Sprintf("[关闭] %s 关闭了对话，任务已标记为完成", s.operator(agent)))
+}
+
+// Reopened 对话重新打开后将关联任务标记为未完成，并在任务对话中说明
+func (s *TaskSyncService) Reopened(conversation *models.Conversations, agent *models.Agent) {
+	botToken, ok := s.botToken(conversation)
+	if !ok {
+		return
+	}
+	if err := dootask.NewIDootaskService().UncompleteTask(botToken, conversation.DooTaskTaskID); err != nil {
+		logger.App.Error("标记DooTask任务未完成失败",
+			zap.Uint("conversationID", conversation.ID),
+			zap.Int("taskID", conversation.DooTaskTaskID),
+			zap.Error(err))
+	}
+	s.notify(conversation, fmt.Sprintf("[重新打开] %s 重新打开了对话，任务已标记为未完成", s.operator(agent)))
+}
+
+// poll 分批检查最近活跃、未关闭且关联了任务的对话，任务已完成时关闭对话
+// 单次轮询出错不影响后续轮询
+func (s *TaskSyncService) poll() {
+	defer func() {
+		if err := recover(); err != nil {
+			logger.App.Error("DooTask任务状态轮询 panic", zap.Any("error", err))
+		}
+	}()
+
+	customerServiceConfigData, err := models.LoadConfig[models.CustomerServiceConfigData](database.DB, models.CSConfigKeySystem)
+	if err != nil || customerServiceConfigData.DooTaskIntegration.BotToken == "" {
+		return
+	}
+	botToken := customerServiceConfigData.DooTaskIntegration.BotToken
+
+	activeDays := config.Cfg.DooTask.TaskSyncActiveDays
+	if activeDays <= 0 {
+		activeDays = 7
+	}
+	concurrency := config.Cfg.DooTask.TaskSyncConcurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	activeSince := time.Now().AddDate(0, 0, -activeDays)
+
+	dootaskService := dootask.NewIDootaskService()
+	var lastID uint
+	for {
+		var conversations []models.Conversations
+		err := database.DB.
+			Where("id > ? AND status <> ? AND dootask_task_id > 0", lastID, "closed").
+			Where("COALESCE(last_message_at, created_at) >= ?", activeSince).
+			Order("id ASC").
+			Limit(taskSyncPageSize).
+			Find(&conversations).Error
+		if err != nil {
+			logger.App.Error("查询关联任务的对话失败", zap.Error(err))
+			return
+		}
+		if len(conversations) == 0 {
+			return
+		}
+		lastID = conversations[len(conversations)-1].ID
+
+		// 限制同时请求DooTask的数量
+		var wg sync.WaitGroup
+		slots := make(chan struct{}, concurrency)
+		for i := range conversations {
+			slots <- struct{}{}
+			wg.Add(1)
+			go func(conversation *models.Conversations) {
+				defer func() {
+					<-slots
+					wg.Done()
+				}()
+				s.syncCompleted(dootaskService, botToken, conversation)
+			}(&conversations[i])
+		}
+		wg.Wait()
+
+		if len(conversations) < taskSyncPageSize {
+			return
+		}
+	}
+}
+
+// syncCompleted 任务已完成时关闭对话，并在任务对话中说明
+func (s *TaskSyncService) syncCompleted(dootaskService dootask.IDootaskService, botToken string, conversation *models.Conversations) {
+	defer func() {
+		if err := recover(); err != nil {
+			logger.App.Error("同步DooTask任务状态 panic",
+				zap.Uint("conversationID", conversation.ID),
+				zap.Any("error", err))
+		}
+	}()
+
+	task, err := dootaskService.GetTask(botToken, conversation.DooTaskTaskID)
+	if err != nil {
+		logger.App.Warn("获取DooTask任务状态失败",
+			zap.Uint("conversationID", conversation.ID),
+			zap.Int("taskID", conversation.DooTaskTaskID),
+			zap.Error(err))
+		return
+	}
+	if !task.Completed() {
+		return
+	}
+
+	if err := ChatAgent.closeConversation(conversation, nil); err != nil {
+		return
+	}
+	logger.App.Info("DooTask任务已完成，对话已关闭",
+		zap.Uint("conversationID", conversation.ID),
+		zap.Int("taskID", conversation.DooTaskTaskID))
+	s.notify(conversation, "[关闭] 任务已完成，对话已自动关闭")
+}
+
+// botToken 对话关联了DooTask任务且配置了机器人时返回机器人令牌
+func (s *TaskSyncService) botToken(conversation *models.Conversations) (string, bool) {
+	if conversation.DooTaskTaskID <= 0 {
+		return "", false
+	}
+	customerServiceConfigData, err := models.LoadConfig[models.CustomerServiceConfigData](database.DB, models.CSConfigKeySystem)
+	if err != nil || customerServiceConfigData.DooTaskIntegration.BotToken == "" {
+		return "", false
+	}
+	return customerServiceConfigData.DooTaskIntegration.BotToken, true
+}
+
+// notify 在任务对话中发送系统消息
+func (s *TaskSyncService) notify(conversation *models.Conversations, content string) {
+	if conversation.DooTaskDialogID <= 0 {
+		return
+	}
+	customerServiceConfigData, err := models.LoadConfig[models.CustomerServiceConfigData](database.DB, models.CSConfigKeySystem)
+	if err != nil {
+		return
+	}
+	ChatAgent.sendToBot(customerServiceConfigData, content, fmt.Sprintf("%d", conversation.DooTaskDialogID))
+}
+
+// operator 操作人名称
+func (s *TaskSyncService) operator(agent *models.Agent) string {
+	if agent == nil {
+		return "系统"
+	}
+	return Agent.DisplayName(agent)
+}
@@ -22,7 +22,7 @@ func NewHTTPClient(timeout time.Duration) *HTTPClient {
 
 // Get 发送GET请求
 func (c *HTTPClient) Get(url string) ([]byte, error) {
-	resp, err := http.Get(url)
+	resp, err := c.client.Get(url)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,30 @@
+package common
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// SecureRandString 生成密码学安全的随机字符串（十六进制），用于密钥和令牌
+func SecureRandString(n int) string {
+	b := make([]byte, (n+1)/2)
+	if _, err := rand.Read(b); err != nil {
+		return RandString(n)
+	}
+	return hex.EncodeToString(b)[:n]
+}
+
+// HmacSha256 计算HMAC-SHA256签名，返回十六进制字符串
+func HmacSha256(secret, data string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(data))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// HmacSha256Verify 校验HMAC-SHA256签名，使用常量时间比较
+func HmacSha256Verify(secret, data, signature string) bool {
+	expected := HmacSha256(secret, data)
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
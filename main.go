@@ -13,8 +13,10 @@ import (
 	"support-plugin/internal/pkg/eventbus"
 	"support-plugin/internal/pkg/initialize"
 	"support-plugin/internal/pkg/logger"
+	"support-plugin/internal/pkg/storage"
 	"support-plugin/internal/pkg/websocket"
 	"support-plugin/internal/routes"
+	"support-plugin/internal/service"
 
 	"github.com/gin-gonic/gin"
 )
@@ -36,6 +38,9 @@ func main() {
 
 	database.InitDB()
 
+	// 初始化附件存储
+	storage.InitStorage()
+
 	// 执行初始化操作
 	initialize.Init()
 
@@ -44,9 +49,29 @@ func main() {
 
 	// 注册所有事件处理器
 	eventbus.RegisterAllEventHandlers()
+	// 注册对话变化后的SLA到期时间计算
+	eventbus.SetSLARefresher(service.SLA.Refresh)
+
+	// 初始化WebSocket跨节点推送通道
+	websocket.InitBackplane()
 
 	// 启动WebSocket管理器
 	go websocket.WebSocketManager.Start()
+	// 注册WebSocket已读回执处理
+	websocket.SetReadReceiptHandler(service.ReadReceipt.HandleWsRead)
+	// 注册WebSocket客服对话权限校验
+	websocket.SetAgentAccessChecker(service.Routing.AuthorizeAgent)
+	// 注册WebSocket对话推送范围计算
+	websocket.SetAudienceResolver(service.Routing.Audience)
+	// 注册客服连接变化时的在线状态推送
+	websocket.SetAgentPresenceHandler(service.Presence.HandleConnection)
+
+	// 启动自动回复调度器
+	service.AutoReply.Start()
+	// 启动DooTask任务状态同步
+	service.TaskSync.Start()
+	// 启动SLA到期检查
+	service.SLA.Start()
 
 	// 创建Gin实例
 	r := gin.Default()
@@ -82,6 +107,9 @@ func setupGracefulShutdown() {
 		// 关闭事件总线
 		eventbus.ShutdownEventBus()
 
+		// 清除本节点在线状态并关闭推送通道
+		websocket.ShutdownBackplane()
+
 		fmt.Println("服务器已关闭")
 		os.Exit(0)
 	}()